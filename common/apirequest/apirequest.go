@@ -2,6 +2,7 @@ package apirequest
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
@@ -43,6 +44,14 @@ func Get(url string, data interface{}) (int, error) {
 	return doRequest("GET", url, nil, data)
 }
 
+/*
+	Executes an API GET request within the given context and populates the data with the response
+	The request fails once the context's deadline is exceeded or it is cancelled
+*/
+func GetWithContext(ctx context.Context, url string, data interface{}) (int, error) {
+	return doRequestWithContext(ctx, "GET", url, nil, data)
+}
+
 /*
 	Executes an API POST request and populates the data with the response
 */
@@ -68,6 +77,13 @@ func Delete(url string, data interface{}) (int, error) {
 	Builds a request, executes it, and then decodes the response into data
 */
 func doRequest(method string, url string, payload interface{}, data interface{}) (int, error) {
+	return doRequestWithContext(context.Background(), method, url, payload, data)
+}
+
+/*
+	Builds a request within the given context, executes it, and then decodes the response into data
+*/
+func doRequestWithContext(ctx context.Context, method string, url string, payload interface{}, data interface{}) (int, error) {
 	var req *http.Request
 	var err error
 
@@ -84,6 +100,8 @@ func doRequest(method string, url string, payload interface{}, data interface{})
 		return -1, err
 	}
 
+	req = req.WithContext(ctx)
+
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("HackIllinois-Identity", Identity)
 
@@ -19,6 +19,7 @@ func StartServer(address string, router *mux.Router, name string, initialize fun
 	}
 
 	router.Use(middleware.ContentTypeMiddleware)
+	router.Use(middleware.TimeoutMiddleware(name))
 
 	stats_middleware := stats.New()
 	router.Use(stats_middleware.Handler)
@@ -1,13 +1,54 @@
 package config
 
 import (
+	"fmt"
 	"github.com/HackIllinois/api/common/configloader"
 	"os"
+	"time"
 )
 
 var IS_PRODUCTION bool
 var DEBUG_MODE bool
 
+/*
+	The driver used to connect to mongo databases, either "mgo" or "mongo-driver" for the official MongoDB Go driver
+	Defaults to "mgo" if not set
+*/
+var DATABASE_DRIVER string
+
+const DEFAULT_DATABASE_DRIVER = "mgo"
+
+var DATABASE_DRIVERS = []string{"mgo", "mongo-driver"}
+
+/*
+	The database host used by every service instead of its own, such as "memory://" to run every service against
+	an in memory database
+	Each service uses its own database host if not set
+*/
+var DATABASE_HOST string
+
+/*
+	The deadline for handling a request, including every database operation it performs
+	REQUEST_TIMEOUTS maps service names to timeouts such as "5s", and the "default" key applies to every other service
+*/
+var REQUEST_TIMEOUTS map[string]time.Duration
+
+const DEFAULT_REQUEST_TIMEOUT = 10 * time.Second
+
+/*
+	Whether deletable resources such as events and projects are soft deleted, allowing them to be restored
+	Soft deleted items are purged once they have been deleted for longer than SOFT_DELETE_RETENTION, such as "720h"
+*/
+var SOFT_DELETE bool
+var SOFT_DELETE_RETENTION time.Duration
+
+const DEFAULT_SOFT_DELETE_RETENTION = 30 * 24 * time.Hour
+
+/*
+	How often soft deleted items which have passed the retention period are purged
+*/
+const SOFT_DELETE_PURGE_INTERVAL = time.Hour
+
 func init() {
 	err := Initialize()
 
@@ -39,5 +80,88 @@ func Initialize() error {
 
 	DEBUG_MODE = (debug_mode == "true")
 
+	DATABASE_DRIVER, err = cfg_loader.Get("DATABASE_DRIVER")
+
+	if err == configloader.ErrNotSet {
+		DATABASE_DRIVER = DEFAULT_DATABASE_DRIVER
+	} else if err != nil {
+		return err
+	} else if !isDatabaseDriver(DATABASE_DRIVER) {
+		return fmt.Errorf("Unknown DATABASE_DRIVER %q, must be one of %v", DATABASE_DRIVER, DATABASE_DRIVERS)
+	}
+
+	DATABASE_HOST, err = cfg_loader.Get("DATABASE_HOST")
+
+	if err != nil && err != configloader.ErrNotSet {
+		return err
+	}
+
+	var request_timeouts map[string]string
+	err = cfg_loader.ParseInto("REQUEST_TIMEOUTS", &request_timeouts)
+
+	if err != nil && err != configloader.ErrNotSet {
+		return err
+	}
+
+	soft_delete, err := cfg_loader.Get("SOFT_DELETE")
+
+	if err != nil && err != configloader.ErrNotSet {
+		return err
+	}
+
+	SOFT_DELETE = (soft_delete == "true")
+
+	soft_delete_retention, err := cfg_loader.Get("SOFT_DELETE_RETENTION")
+
+	if err == configloader.ErrNotSet {
+		SOFT_DELETE_RETENTION = DEFAULT_SOFT_DELETE_RETENTION
+	} else if err != nil {
+		return err
+	} else {
+		SOFT_DELETE_RETENTION, err = time.ParseDuration(soft_delete_retention)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	REQUEST_TIMEOUTS = make(map[string]time.Duration)
+
+	for service, timeout := range request_timeouts {
+		REQUEST_TIMEOUTS[service], err = time.ParseDuration(timeout)
+
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+/*
+	Returns the deadline for handling a request to the given service
+*/
+func GetRequestTimeout(service string) time.Duration {
+	if timeout, exists := REQUEST_TIMEOUTS[service]; exists {
+		return timeout
+	}
+
+	if timeout, exists := REQUEST_TIMEOUTS["default"]; exists {
+		return timeout
+	}
+
+	return DEFAULT_REQUEST_TIMEOUT
+}
+
+/*
+	Returns true if the given name is one of the supported database drivers
+*/
+func isDatabaseDriver(name string) bool {
+	for _, driver := range DATABASE_DRIVERS {
+		if name == driver {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"errors"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+	The types of operation which can be run by BulkWrite
+*/
+const (
+	BulkInsert = "insert"
+	BulkUpdate = "update"
+	BulkUpsert = "upsert"
+	BulkDelete = "delete"
+)
+
+var ErrBulkWrite = errors.New("Error: BULK_WRITE_FAILED")
+
+/*
+	Describes a single operation run by BulkWrite
+	Item is the item to insert for inserts, and the update to apply for updates and upserts
+	Updates and deletes apply to the first item matching the selector, or every matching item if Multi is set
+*/
+type BulkOperation struct {
+	Type     string
+	Selector interface{}
+	Item     interface{}
+	Multi    bool
+}
+
+/*
+	Stores the outcome of a single operation run by BulkWrite
+	Upserted is set if an upsert created a new item, and Err is set if the operation failed
+*/
+type BulkOperationResult struct {
+	Upserted bool
+	Err      error
+}
+
+/*
+	Stores the outcome of a BulkWrite, with a result for each operation in the order they were given
+	Updated counts the items matched by updates and upserts which did not create a new item
+*/
+type BulkWriteResults struct {
+	Inserted   int
+	Updated    int
+	Upserted   int
+	Deleted    int
+	Operations []BulkOperationResult
+}
+
+/*
+	Returns empty results for the given number of operations
+*/
+func newBulkWriteResults(count int) *BulkWriteResults {
+	return &BulkWriteResults{
+		Operations: make([]BulkOperationResult, count),
+	}
+}
+
+/*
+	Returns ErrBulkWrite if any of the operations failed
+*/
+func (results *BulkWriteResults) getError() error {
+	for _, result := range results.Operations {
+		if result.Err != nil {
+			return ErrBulkWrite
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the name of the write command which runs the given type of operation
+	Updates and upserts are both run by the update command
+*/
+func getBulkCommand(operation_type string) (string, error) {
+	switch operation_type {
+	case BulkInsert:
+		return "insert", nil
+	case BulkUpdate, BulkUpsert:
+		return "update", nil
+	case BulkDelete:
+		return "delete", nil
+	}
+
+	return "", errors.New("Invalid bulk operation type " + operation_type)
+}
+
+/*
+	Describes a run of consecutive operations which are sent to the database as a single write command
+*/
+type bulkBatch struct {
+	command string
+	start   int
+	end     int
+}
+
+/*
+	Splits the operations into runs of consecutive operations with the same write command
+	This allows operations to be sent in a few round trips while still being run in the order they were given
+*/
+func getBulkBatches(operations []BulkOperation) ([]bulkBatch, error) {
+	batches := []bulkBatch{}
+
+	for i, operation := range operations {
+		command, err := getBulkCommand(operation.Type)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(batches) > 0 && batches[len(batches)-1].command == command {
+			batches[len(batches)-1].end = i + 1
+			continue
+		}
+
+		batches = append(batches, bulkBatch{
+			command: command,
+			start:   i,
+			end:     i + 1,
+		})
+	}
+
+	return batches, nil
+}
+
+/*
+	Decodes the given values into result, which must be a pointer to a slice
+	This respects bson tags as well as the bson Setter interface, the same as the mongo driver
+*/
+func fromValues(values []interface{}, result interface{}) error {
+	raw, err := bson.Marshal(bson.M{"values": values})
+
+	if err != nil {
+		return ErrUnknown
+	}
+
+	return fromValuesDocument(raw, result)
+}
+
+/*
+	Decodes the values array of the given raw bson document into result, which must be a pointer to a slice
+	This is the format of the response to the distinct command
+*/
+func fromValuesDocument(raw []byte, result interface{}) error {
+	var document struct {
+		Values bson.Raw `bson:"values"`
+	}
+
+	err := bson.Unmarshal(raw, &document)
+
+	if err != nil {
+		return ErrUnknown
+	}
+
+	err = document.Values.Unmarshal(result)
+
+	if err != nil {
+		return ErrUnknown
+	}
+
+	return nil
+}
@@ -1,21 +1,54 @@
 package database
 
+import (
+	"context"
+	"strings"
+
+	"github.com/HackIllinois/api/common/config"
+)
+
+const MongoDriver = "mongo-driver"
+
 /*
 	Database interface exposing the methods necessary to querying, inserting, updating, upserting, and removing records
+	WithContext returns a variant of the database whose methods are performed within the given context,
+	failing with ErrTimeout or ErrCanceled once the context's deadline is exceeded or it is cancelled
+	Watch returns a stream of the changes to items matching the filter, which runs until it is closed or
+	the database's context is done
+	RunTransaction runs fn within a transaction, committing its operations on tx if it returns nil
+	The projected finds only include the given fields in each result, or every field if the projection is empty
+	The paginated, projected, and text finds break ties between items by _id, so that consecutive pages do not overlap
+	BulkWrite runs a batch of mixed operations in few round trips, reporting the outcome of each operation
+	FindAllText finds the items containing the text in their text indexed fields, ordered by relevance unless sort fields are given
 */
 type Database interface {
 	Connect(host string) error
 	Close()
+	WithContext(ctx context.Context) Database
 	FindOne(collection_name string, query interface{}, result interface{}) error
+	FindOneProjected(collection_name string, query interface{}, projection []string, result interface{}) error
 	FindAll(collection_name string, query interface{}, result interface{}) error
 	FindAllSorted(collection_name string, query interface{}, sort_fields []SortField, result interface{}) error
+	FindAllPaginated(collection_name string, query interface{}, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error)
+	FindAllProjected(collection_name string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error)
+	FindAllText(collection_name string, text string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error)
+	Count(collection_name string, query interface{}) (int, error)
+	Distinct(collection_name string, field string, query interface{}, result interface{}) error
 	RemoveOne(collection_name string, query interface{}) error
 	RemoveAll(collection_name string, query interface{}) (*ChangeResults, error)
 	Insert(collection_name string, item interface{}) error
 	Upsert(collection_name string, selector interface{}, update interface{}) (*ChangeResults, error)
 	Update(collection_name string, selector interface{}, update interface{}) error
 	UpdateAll(collection_name string, selector interface{}, update interface{}) (*ChangeResults, error)
+	FindAndModify(collection_name string, selector interface{}, update interface{}, upsert bool, result interface{}) error
+	UpdateIfMatches(collection_name string, selector interface{}, condition interface{}, update interface{}) (bool, error)
+	BulkWrite(collection_name string, operations []BulkOperation) (*BulkWriteResults, error)
+	Aggregate(collection_name string, pipeline interface{}, result interface{}) error
+	Watch(collection_name string, filter interface{}) (*ChangeStream, error)
+	RunTransaction(fn func(tx Database) error) error
 	DropDatabase() error
+	EnsureIndex(collection_name string, index Index) error
+	GetIndexes(collection_name string) ([]Index, error)
 	GetStats(collection_name string, fields []string) (map[string]interface{}, error)
 }
 
@@ -46,8 +79,26 @@ type ChangeResults struct {
 	This function wraps a database specific initializion function
 	This makes it simple to change the database used without rewriting
 	code in the microservices
+
+	DATABASE_HOST replaces the given host if it is set
+	Hosts beginning with memory:// use an in memory database, all other hosts use mongo
+	Mongo databases use mgo unless DATABASE_DRIVER is set to mongo-driver to use the official driver
 */
 func InitDatabase(host string, db_name string) (Database, error) {
+	if config.DATABASE_HOST != "" {
+		host = config.DATABASE_HOST
+	}
+
+	if strings.HasPrefix(host, MemoryHostPrefix) {
+		db, err := InitMemoryDatabase(host, db_name)
+		return db, err
+	}
+
+	if config.DATABASE_DRIVER == MongoDriver {
+		db, err := InitMongoDriverDatabase(host, db_name)
+		return db, err
+	}
+
 	db, err := InitMongoDatabase(host, db_name)
 	return db, err
 }
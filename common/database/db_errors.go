@@ -1,7 +1,9 @@
 package database
 
 import (
+	"context"
 	"errors"
+	"go.mongodb.org/mongo-driver/mongo"
 	"gopkg.in/mgo.v2"
 )
 
@@ -9,8 +11,24 @@ var (
 	ErrNotFound   = errors.New("Error: NOT_FOUND")
 	ErrConnection = errors.New("Error: CONNECTION_FAILED")
 	ErrUnknown    = errors.New("Error: UNKNOWN")
+	ErrTimeout    = errors.New("Error: TIMEOUT")
+	ErrCanceled   = errors.New("Error: CANCELED")
+
+	ErrTransactionsUnsupported = errors.New("Error: TRANSACTIONS_UNSUPPORTED")
+	ErrNoTextIndex             = errors.New("Error: NO_TEXT_INDEX")
+	ErrDuplicateKey            = errors.New("Error: DUPLICATE_KEY")
 )
 
+/*
+	The error code returned by mongo when a command references a collection which does not exist
+*/
+const mgoNamespaceNotFoundCode = 26
+
+/*
+	The error code returned by mongo when a text search is run on a collection without a text index
+*/
+const mgoIndexNotFoundCode = 27
+
 /*
 	Converts internal mgo errors to external presented errors
 */
@@ -19,6 +37,47 @@ func convertMgoError(err error) error {
 		return nil
 	} else if err == mgo.ErrNotFound {
 		return ErrNotFound
+	} else if query_err, ok := err.(*mgo.QueryError); ok && query_err.Code == mgoIndexNotFoundCode {
+		return ErrNoTextIndex
+	} else if mgo.IsDup(err) {
+		return ErrDuplicateKey
+	}
+
+	return ErrUnknown
+}
+
+/*
+	Converts internal errors from the official mongo driver to external presented errors
+*/
+func convertMongoDriverError(err error) error {
+	if err == nil {
+		return nil
+	} else if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	} else if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) {
+		return ErrTimeout
+	} else if errors.Is(err, context.Canceled) {
+		return ErrCanceled
+	} else if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
+
+	var server_err mongo.ServerError
+	if errors.As(err, &server_err) && server_err.HasErrorCode(mgoIndexNotFoundCode) {
+		return ErrNoTextIndex
+	}
+
+	return ErrUnknown
+}
+
+/*
+	Converts the error of a done context to external presented errors
+*/
+func convertContextError(err error) error {
+	if err == context.DeadlineExceeded {
+		return ErrTimeout
+	} else if err == context.Canceled {
+		return ErrCanceled
 	}
 
 	return ErrUnknown
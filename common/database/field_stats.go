@@ -0,0 +1,463 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	StatKindCategorical = "categorical"
+	StatKindNumeric     = "numeric"
+)
+
+/*
+	The key of the stats under which the cross tabulations of fields are stored
+*/
+const CrossTabulationsKey = "crossTabulations"
+
+/*
+	The number of histogram buckets used for numeric fields without configured buckets
+*/
+const DefaultHistogramBucketCount = 10
+
+/*
+	The largest number of histogram buckets a bucket size may produce
+	If the range of the values would need more buckets, DefaultHistogramBucketCount buckets are used instead
+*/
+const MaxHistogramBucketCount = 1000
+
+var ErrInvalidStatField = errors.New("Error: INVALID_STAT_FIELD")
+
+/*
+	Describes how the stats of a field are computed
+	Categorical fields are counted by value, and if by is set, their counts are also cross tabulated with the
+	values of the by field
+	Numeric fields are summarized by their min, max, mean, and median, and a histogram of their values
+	The histogram's buckets are given by their boundaries, or otherwise by their size, and otherwise
+	the range of the values is split into DefaultHistogramBucketCount buckets
+	A field may be configured with only its name, in which case it is categorical
+*/
+type StatField struct {
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`
+	By         string    `json:"by"`
+	Buckets    []float64 `json:"buckets"`
+	BucketSize float64   `json:"bucketSize"`
+}
+
+/*
+	Stats describing the values of a numeric field
+*/
+type NumericStats struct {
+	Count     int               `json:"count"`
+	Min       float64           `json:"min"`
+	Max       float64           `json:"max"`
+	Mean      float64           `json:"mean"`
+	Median    float64           `json:"median"`
+	Histogram []HistogramBucket `json:"histogram"`
+}
+
+/*
+	The number of values in the range [min, max) of a histogram
+	The last bucket of a histogram also includes its max
+*/
+type HistogramBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+/*
+	Used to decode the number of items with a given pair of values from a cross tabulation pipeline
+*/
+type statsCrossValueCount struct {
+	Value struct {
+		Value interface{} `bson:"value"`
+		By    interface{} `bson:"by"`
+	} `bson:"_id"`
+	Count int `bson:"count"`
+}
+
+/*
+	Decodes a stat field from either its name, or an object describing it
+*/
+func (field *StatField) UnmarshalJSON(data []byte) error {
+	var name string
+	err := json.Unmarshal(data, &name)
+
+	if err == nil {
+		*field = StatField{
+			Name: name,
+			Kind: StatKindCategorical,
+		}
+
+		return nil
+	}
+
+	type rawStatField StatField
+	var raw_field rawStatField
+	err = json.Unmarshal(data, &raw_field)
+
+	if err != nil {
+		return err
+	}
+
+	*field = StatField(raw_field)
+
+	if field.Kind == "" {
+		field.Kind = StatKindCategorical
+	}
+
+	return field.Validate()
+}
+
+/*
+	Returns an error if the stat field does not have a name, has an unknown kind, or has invalid buckets
+*/
+func (field *StatField) Validate() error {
+	if field.Name == "" {
+		return fmt.Errorf("%v: stat fields must have a name", ErrInvalidStatField)
+	}
+
+	switch field.Kind {
+	case StatKindCategorical:
+		if len(field.Buckets) != 0 || field.BucketSize != 0 {
+			return fmt.Errorf("%v: categorical stat field %s cannot have buckets", ErrInvalidStatField, field.Name)
+		}
+	case StatKindNumeric:
+		if field.By != "" {
+			return fmt.Errorf("%v: numeric stat field %s cannot be cross tabulated", ErrInvalidStatField, field.Name)
+		}
+
+		if field.BucketSize < 0 {
+			return fmt.Errorf("%v: stat field %s must have a positive bucket size", ErrInvalidStatField, field.Name)
+		}
+
+		if len(field.Buckets) == 1 {
+			return fmt.Errorf("%v: stat field %s must have at least two bucket boundaries", ErrInvalidStatField, field.Name)
+		}
+
+		for i := 1; i < len(field.Buckets); i++ {
+			if field.Buckets[i-1] >= field.Buckets[i] {
+				return fmt.Errorf("%v: the bucket boundaries of stat field %s must be increasing", ErrInvalidStatField, field.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("%v: stat field %s has unknown kind %s", ErrInvalidStatField, field.Name, field.Kind)
+	}
+
+	return nil
+}
+
+/*
+	Returns the stats for the given fields of every item in the collection
+	Categorical fields are counted as in GetAggregatedStats, numeric fields are stored as NumericStats at their path,
+	and cross tabulations are stored under CrossTabulationsKey, keyed by the names of both fields
+*/
+func GetFieldStats(db Database, collection_name string, fields []StatField) (map[string]interface{}, error) {
+	categorical_fields := []string{}
+
+	for _, field := range fields {
+		err := field.Validate()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if field.Kind == StatKindCategorical {
+			categorical_fields = append(categorical_fields, field.Name)
+		}
+	}
+
+	stats, err := GetAggregatedStats(db, collection_name, categorical_fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		switch {
+		case field.Kind == StatKindNumeric:
+			numeric_stats, err := getNumericStats(db, collection_name, field)
+
+			if err != nil {
+				return nil, err
+			}
+
+			err = setStatsValue(stats, field.Name, numeric_stats)
+
+			if err != nil {
+				return nil, err
+			}
+		case field.By != "":
+			cross_tabulation, err := getCrossTabulation(db, collection_name, field.Name, field.By)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if _, exists := stats[CrossTabulationsKey]; !exists {
+				stats[CrossTabulationsKey] = make(map[string]interface{})
+			}
+
+			cross_tabulations, ok := stats[CrossTabulationsKey].(map[string]interface{})
+
+			if !ok {
+				return nil, ErrTypeMismatch
+			}
+
+			cross_tabulations[field.Name+","+field.By] = cross_tabulation
+		}
+	}
+
+	return stats, nil
+}
+
+/*
+	Returns the numeric stats of the values of the given field
+	Values which are not finite numbers are ignored, and array values are described by their elements
+*/
+func getNumericStats(db Database, collection_name string, field StatField) (NumericStats, error) {
+	pipeline := []QuerySelector{
+		{
+			"$match": QuerySelector{
+				field.Name: QuerySelector{
+					"$exists": true,
+				},
+			},
+		},
+		{
+			"$unwind": "$" + field.Name,
+		},
+		{
+			"$group": QuerySelector{
+				"_id": "$" + field.Name,
+				"count": QuerySelector{
+					"$sum": 1,
+				},
+			},
+		},
+	}
+
+	var value_counts []statsValueCount
+	err := db.Aggregate(collection_name, pipeline, &value_counts)
+
+	if err != nil {
+		return NumericStats{}, err
+	}
+
+	return computeNumericStats(value_counts, field), nil
+}
+
+/*
+	Computes the numeric stats of a field from the number of items with each of its values
+*/
+func computeNumericStats(value_counts []statsValueCount, field StatField) NumericStats {
+	type numericValueCount struct {
+		value float64
+		count int
+	}
+
+	numeric_counts := []numericValueCount{}
+	total := 0
+	sum := 0.0
+
+	for _, value_count := range value_counts {
+		value, ok := toFloat64(value_count.Value)
+
+		if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		numeric_counts = append(numeric_counts, numericValueCount{value: value, count: value_count.Count})
+		total += value_count.Count
+		sum += value * float64(value_count.Count)
+	}
+
+	stats := NumericStats{
+		Histogram: []HistogramBucket{},
+	}
+
+	if total == 0 {
+		return stats
+	}
+
+	sort.Slice(numeric_counts, func(i, j int) bool {
+		return numeric_counts[i].value < numeric_counts[j].value
+	})
+
+	stats.Count = total
+	stats.Min = numeric_counts[0].value
+	stats.Max = numeric_counts[len(numeric_counts)-1].value
+	stats.Mean = sum / float64(total)
+
+	// The median is the middle value, or the mean of the two middle values if there are an even number of them
+	lower_middle := (total - 1) / 2
+	upper_middle := total / 2
+	position := 0
+	for _, numeric_count := range numeric_counts {
+		if position <= lower_middle && lower_middle < position+numeric_count.count {
+			stats.Median += numeric_count.value / 2
+		}
+
+		if position <= upper_middle && upper_middle < position+numeric_count.count {
+			stats.Median += numeric_count.value / 2
+		}
+
+		position += numeric_count.count
+	}
+
+	stats.Histogram = getHistogramBuckets(field, stats.Min, stats.Max)
+
+	for _, numeric_count := range numeric_counts {
+		for i := range stats.Histogram {
+			bucket := &stats.Histogram[i]
+			is_last := i == len(stats.Histogram)-1
+
+			if bucket.Min <= numeric_count.value && (numeric_count.value < bucket.Max || (is_last && numeric_count.value == bucket.Max)) {
+				bucket.Count += numeric_count.count
+				break
+			}
+		}
+	}
+
+	return stats
+}
+
+/*
+	Returns the empty histogram buckets for a field whose values are in the range [min, max]
+	Values outside of a field's configured bucket boundaries are not counted in any bucket, and a bucket size which
+	would need more than MaxHistogramBucketCount buckets is ignored
+*/
+func getHistogramBuckets(field StatField, min float64, max float64) []HistogramBucket {
+	boundaries := field.Buckets
+
+	if len(boundaries) == 0 && field.BucketSize > 0 && getBucketCount(min, max, field.BucketSize) <= MaxHistogramBucketCount {
+		start := math.Floor(min/field.BucketSize) * field.BucketSize
+		boundaries = []float64{start}
+
+		for boundary := start + field.BucketSize; boundary <= max; boundary += field.BucketSize {
+			boundaries = append(boundaries, boundary)
+		}
+
+		boundaries = append(boundaries, boundaries[len(boundaries)-1]+field.BucketSize)
+	} else if len(boundaries) == 0 {
+		if min == max {
+			boundaries = []float64{min, max}
+		} else {
+			width := (max - min) / DefaultHistogramBucketCount
+
+			for i := 0; i < DefaultHistogramBucketCount; i++ {
+				boundaries = append(boundaries, min+width*float64(i))
+			}
+
+			boundaries = append(boundaries, max)
+		}
+	}
+
+	buckets := make([]HistogramBucket, len(boundaries)-1)
+
+	for i := range buckets {
+		buckets[i] = HistogramBucket{
+			Min: boundaries[i],
+			Max: boundaries[i+1],
+		}
+	}
+
+	return buckets
+}
+
+/*
+	Returns the number of buckets of the given size needed to cover the range [min, max]
+*/
+func getBucketCount(min float64, max float64, bucket_size float64) float64 {
+	return math.Floor(max/bucket_size) - math.Floor(min/bucket_size) + 1
+}
+
+/*
+	Returns the number of items with each pair of values of the given fields, keyed by the value of the
+	first field and then the value of the second field
+	Items missing either field are not counted, and array values are counted by element
+*/
+func getCrossTabulation(db Database, collection_name string, field_name string, by_field_name string) (map[string]map[string]int, error) {
+	pipeline := []QuerySelector{
+		{
+			"$match": QuerySelector{
+				field_name: QuerySelector{
+					"$exists": true,
+				},
+				by_field_name: QuerySelector{
+					"$exists": true,
+				},
+			},
+		},
+		{
+			"$unwind": "$" + field_name,
+		},
+		{
+			"$unwind": "$" + by_field_name,
+		},
+		{
+			"$group": QuerySelector{
+				"_id": QuerySelector{
+					"value": "$" + field_name,
+					"by":    "$" + by_field_name,
+				},
+				"count": QuerySelector{
+					"$sum": 1,
+				},
+			},
+		},
+	}
+
+	var value_counts []statsCrossValueCount
+	err := db.Aggregate(collection_name, pipeline, &value_counts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cross_tabulation := make(map[string]map[string]int)
+
+	for _, value_count := range value_counts {
+		value_key := fmt.Sprintf("%v", value_count.Value.Value)
+		by_key := fmt.Sprintf("%v", value_count.Value.By)
+
+		if _, exists := cross_tabulation[value_key]; !exists {
+			cross_tabulation[value_key] = make(map[string]int)
+		}
+
+		cross_tabulation[value_key][by_key] += value_count.Count
+	}
+
+	return cross_tabulation, nil
+}
+
+/*
+	Sets the value at the given dotted path of the stats, creating any missing nested stats
+*/
+func setStatsValue(stats map[string]interface{}, path string, value interface{}) error {
+	keys := strings.Split(path, ".")
+
+	for _, key := range keys[:len(keys)-1] {
+		if _, exists := stats[key]; !exists {
+			stats[key] = make(map[string]interface{})
+		}
+
+		nested_stats, ok := stats[key].(map[string]interface{})
+
+		if !ok {
+			return ErrTypeMismatch
+		}
+
+		stats = nested_stats
+	}
+
+	stats[keys[len(keys)-1]] = value
+
+	return nil
+}
@@ -2,7 +2,9 @@ package database
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -10,12 +12,55 @@ import (
 type QueryType string
 
 const (
-	LessThan    QueryType = "LessThan"
-	In          QueryType = "In"
-	GreaterThan QueryType = "GreaterThan"
-	NotIn       QueryType = "NotIn"
+	LessThan           QueryType = "LessThan"
+	LessThanOrEqual    QueryType = "LessThanOrEqual"
+	In                 QueryType = "In"
+	Any                QueryType = "Any"
+	GreaterThan        QueryType = "GreaterThan"
+	GreaterThanOrEqual QueryType = "GreaterThanOrEqual"
+	NotIn              QueryType = "NotIn"
+	Contains           QueryType = "Contains"
+	Prefix             QueryType = "Prefix"
+	Exists             QueryType = "Exists"
 )
 
+/*
+	The key suffixes which select each query type, with longer suffixes first so that Lte is not read as Lt
+	Keys without any of these suffixes have the query type In
+*/
+var query_type_suffixes = []struct {
+	suffix     string
+	query_type QueryType
+}{
+	{"Contains", Contains},
+	{"Prefix", Prefix},
+	{"Exists", Exists},
+	{"Lte", LessThanOrEqual},
+	{"Gte", GreaterThanOrEqual},
+	{"Not", NotIn},
+	{"Any", Any},
+	{"Lt", LessThan},
+	{"Gt", GreaterThan},
+}
+
+/*
+	The url parameter holding groups of filters, where an item matches a group if it matches any filter in it
+*/
+const OrGroupParameter = "or"
+
+/*
+	Returned when the url parameters do not describe a valid filter, such as an unknown key,
+	a value of the wrong type, or an operator which is not supported on the field's type
+*/
+type FilterError struct {
+	Key string
+	Err error
+}
+
+func (e FilterError) Error() string {
+	return fmt.Sprintf("Invalid filter %s: %s", e.Key, e.Err)
+}
+
 /*
 	Returns a map for the given model, where each key is the JSON field name and
 	each value is a string representation of that field's type.
@@ -36,34 +81,226 @@ func GetFieldTypes(model interface{}) map[string]string {
 	return expected_types
 }
 
+/*
+	Implemented by models whose fields are described at runtime rather than by struct fields, such as DataStores
+	Returns a map from the dotted path each of the model's fields is stored under to the name of the field's type
+	Objects without any declared fields should have the type "object", and may hold any nested fields
+*/
+type FieldTypeLister interface {
+	GetFieldTypes() map[string]string
+}
+
+/*
+	Describes a field which can be filtered on, by the path it is stored under and the name of its type
+*/
+type filterField struct {
+	path      string
+	type_name string
+}
+
+/*
+	Returns whether fields nested under a field of the given type are free form, and so have no known type
+*/
+func isFreeFormType(type_name string) bool {
+	switch type_name {
+	case "object", "[]object", "map[string]interface {}", "interface {}":
+		return true
+	}
+
+	return strings.HasPrefix(type_name, "map[")
+}
+
+/*
+	Returns the name of the go type which DataStore values of the given type are stored as
+	Enums are stored as strings, and timestamps as unix times
+*/
+func getDataStoreGoType(type_name string) string {
+	element_type := strings.TrimPrefix(type_name, "[]")
+	prefix := type_name[:len(type_name)-len(element_type)]
+
+	switch element_type {
+	case "boolean":
+		return prefix + "bool"
+	case "enum":
+		return prefix + "string"
+	case "timestamp":
+		return prefix + "int64"
+	default:
+		return type_name
+	}
+}
+
+/*
+	Returns a map from the lowercased dotted path of each of the model's fields, including nested fields,
+	to the path the field is stored under and the name of its type
+	DataStore types are translated to the names of the equivalent go types
+*/
+func getFilterFields(model interface{}) map[string]filterField {
+	filter_fields := make(map[string]filterField)
+
+	if lister, ok := model.(FieldTypeLister); ok {
+		for path, type_name := range lister.GetFieldTypes() {
+			type_name = getDataStoreGoType(type_name)
+
+			filter_fields[strings.ToLower(path)] = filterField{
+				path:      path,
+				type_name: type_name,
+			}
+		}
+
+		return filter_fields
+	}
+
+	addStructFilterFields(filter_fields, reflect.TypeOf(model), "")
+
+	return filter_fields
+}
+
+/*
+	Adds the fields of the given struct type to filter_fields, under the given path prefix
+	Struct fields are stored under their lowercased names, and fields of nested structs
+	or slices of structs are added under their dotted paths
+*/
+func addStructFilterFields(filter_fields map[string]filterField, model_type reflect.Type, prefix string) {
+	for i := 0; i < model_type.NumField(); i++ {
+		field := model_type.Field(i)
+
+		path := prefix + strings.ToLower(field.Tag.Get("json"))
+		filter_fields[path] = filterField{
+			path:      path,
+			type_name: field.Type.String(),
+		}
+
+		nested_type := field.Type
+		if nested_type.Kind() == reflect.Slice || nested_type.Kind() == reflect.Ptr {
+			nested_type = nested_type.Elem()
+		}
+
+		if nested_type.Kind() == reflect.Struct {
+			addStructFilterFields(filter_fields, nested_type, path+".")
+		}
+	}
+}
+
+/*
+	Returns the field for the given lowercased dotted path
+	Paths nested under a free form field are accepted as is, and are given the type "interface {}"
+*/
+func getFilterField(filter_fields map[string]filterField, key string, original_key string) (filterField, bool) {
+	field, ok := filter_fields[key]
+
+	if ok {
+		return field, true
+	}
+
+	for i := len(key) - 1; i > 0; i-- {
+		if key[i] != '.' {
+			continue
+		}
+
+		parent, ok := filter_fields[key[:i]]
+
+		if ok {
+			if !isFreeFormType(parent.type_name) {
+				return filterField{}, false
+			}
+
+			return filterField{
+				path:      parent.path + original_key[i:],
+				type_name: "interface {}",
+			}, true
+		}
+	}
+
+	return filterField{}, false
+}
+
+/*
+	Returns an error for a query type which is not supported on the given kind of value
+*/
+func newErrUnsupportedOperation(query_type QueryType, value_kind string) error {
+	return fmt.Errorf("%s is not supported on %s", query_type, value_kind)
+}
+
+/*
+	Returns an error unless exactly one value was given, as is required by range operators
+*/
+func checkSingleValue(query_type QueryType, count int) error {
+	if count != 1 {
+		return fmt.Errorf("%s requires exactly one value", query_type)
+	}
+	return nil
+}
+
+/*
+	Returns a case insensitive regular expression matching any of the given values
+	If prefix is set, the values must appear at the start of the matched string, otherwise anywhere within it
+*/
+func getRegexSelector(values []string, prefix bool) QuerySelector {
+	patterns := make([]string, len(values))
+	for i, value := range values {
+		patterns[i] = regexp.QuoteMeta(value)
+	}
+
+	pattern := "(?:" + strings.Join(patterns, "|") + ")"
+	if prefix {
+		pattern = "^" + pattern
+	}
+
+	return QuerySelector{"$regex": pattern, "$options": "i"}
+}
+
 func UpdateQuerySelectorInt64(qs QuerySelector, query_type QueryType, cast_values []int64) (QuerySelector, error) {
 	switch query_type {
-	case LessThan:
-		qs["$lt"] = cast_values[0]
+	case LessThan, LessThanOrEqual, GreaterThan, GreaterThanOrEqual:
+		if err := checkSingleValue(query_type, len(cast_values)); err != nil {
+			return nil, err
+		}
+		qs[getRangeOperator(query_type)] = cast_values[0]
 	case In:
 		qs["$in"] = cast_values
-	case GreaterThan:
-		qs["$gt"] = cast_values[0]
 	case NotIn:
 		qs["$nin"] = cast_values
 	default:
-		return nil, errors.New("Invalid operation on integers")
+		return nil, newErrUnsupportedOperation(query_type, "integers")
+	}
+	return qs, nil
+}
+
+func UpdateQuerySelectorFloat64(qs QuerySelector, query_type QueryType, cast_values []float64) (QuerySelector, error) {
+	switch query_type {
+	case LessThan, LessThanOrEqual, GreaterThan, GreaterThanOrEqual:
+		if err := checkSingleValue(query_type, len(cast_values)); err != nil {
+			return nil, err
+		}
+		qs[getRangeOperator(query_type)] = cast_values[0]
+	case In:
+		qs["$in"] = cast_values
+	case NotIn:
+		qs["$nin"] = cast_values
+	default:
+		return nil, newErrUnsupportedOperation(query_type, "floats")
 	}
 	return qs, nil
 }
 
 func UpdateQuerySelectorString(qs QuerySelector, query_type QueryType, cast_values []string) (QuerySelector, error) {
 	switch query_type {
-	case LessThan:
-		qs["$lt"] = cast_values[0]
+	case LessThan, LessThanOrEqual, GreaterThan, GreaterThanOrEqual:
+		if err := checkSingleValue(query_type, len(cast_values)); err != nil {
+			return nil, err
+		}
+		qs[getRangeOperator(query_type)] = cast_values[0]
 	case In:
 		qs["$in"] = cast_values
-	case GreaterThan:
-		qs["$gt"] = cast_values[0]
 	case NotIn:
 		qs["$nin"] = cast_values
+	case Contains, Prefix:
+		for operator, operand := range getRegexSelector(cast_values, query_type == Prefix) {
+			qs[operator] = operand
+		}
 	default:
-		return nil, errors.New("Invalid operation on strings")
+		return nil, newErrUnsupportedOperation(query_type, "strings")
 	}
 	return qs, nil
 }
@@ -75,107 +312,332 @@ func UpdateQuerySelectorBool(qs QuerySelector, query_type QueryType, cast_values
 	case NotIn:
 		qs["$nin"] = cast_values
 	default:
-		return nil, errors.New("Invalid operation on booleans")
+		return nil, newErrUnsupportedOperation(query_type, "booleans")
 	}
 	return qs, nil
 }
 
-func UpdateQuerySelectorStringSlice(qs QuerySelector, query_type QueryType, cast_values []string) (QuerySelector, error) {
+/*
+	Builds the query for a field with no known type
+	Each value matches both itself as a string and, if it can be parsed as one, the equivalent number or boolean
+*/
+func UpdateQuerySelectorUntyped(qs QuerySelector, query_type QueryType, values []string) (QuerySelector, error) {
+	cast_values := []interface{}{}
+
+	for _, value := range values {
+		if value_int, err := strconv.ParseInt(value, 10, 64); err == nil {
+			cast_values = append(cast_values, value_int)
+		} else if value_float, err := strconv.ParseFloat(value, 64); err == nil {
+			cast_values = append(cast_values, value_float)
+		} else if value == "true" || value == "false" {
+			cast_values = append(cast_values, value == "true")
+		}
+
+		cast_values = append(cast_values, value)
+	}
+
+	switch query_type {
+	case LessThan, LessThanOrEqual, GreaterThan, GreaterThanOrEqual:
+		if err := checkSingleValue(query_type, len(values)); err != nil {
+			return nil, err
+		}
+		qs[getRangeOperator(query_type)] = cast_values[0]
+	case In, Any:
+		qs["$in"] = cast_values
+	case NotIn:
+		qs["$nin"] = cast_values
+	case Contains, Prefix:
+		for operator, operand := range getRegexSelector(values, query_type == Prefix) {
+			qs[operator] = operand
+		}
+	default:
+		return nil, newErrUnsupportedOperation(query_type, "untyped values")
+	}
+	return qs, nil
+}
+
+/*
+	Builds the query for a slice field
+	In matches slices holding all of the values, Any matches slices holding at least one of them,
+	and Not matches slices holding none of them
+*/
+func updateQuerySelectorSlice(qs QuerySelector, query_type QueryType, cast_values interface{}, value_kind string) (QuerySelector, error) {
 	switch query_type {
 	case In:
 		qs["$all"] = cast_values
+	case Any:
+		qs["$in"] = cast_values
+	case NotIn:
+		qs["$nin"] = cast_values
 	default:
-		return nil, errors.New("Invalid operation on string slices")
+		return nil, newErrUnsupportedOperation(query_type, value_kind)
 	}
 	return qs, nil
 }
 
+/*
+	Contains and Prefix match string slices with at least one element matching any of the values
+*/
+func UpdateQuerySelectorStringSlice(qs QuerySelector, query_type QueryType, cast_values []string) (QuerySelector, error) {
+	switch query_type {
+	case Contains, Prefix:
+		for operator, operand := range getRegexSelector(cast_values, query_type == Prefix) {
+			qs[operator] = operand
+		}
+		return qs, nil
+	}
+	return updateQuerySelectorSlice(qs, query_type, cast_values, "string slices")
+}
+
+func UpdateQuerySelectorInt64Slice(qs QuerySelector, query_type QueryType, cast_values []int64) (QuerySelector, error) {
+	return updateQuerySelectorSlice(qs, query_type, cast_values, "integer slices")
+}
+
+func UpdateQuerySelectorFloat64Slice(qs QuerySelector, query_type QueryType, cast_values []float64) (QuerySelector, error) {
+	return updateQuerySelectorSlice(qs, query_type, cast_values, "float slices")
+}
+
+func UpdateQuerySelectorBoolSlice(qs QuerySelector, query_type QueryType, cast_values []bool) (QuerySelector, error) {
+	return updateQuerySelectorSlice(qs, query_type, cast_values, "boolean slices")
+}
+
+/*
+	Returns the query operator for the given range query type
+*/
+func getRangeOperator(query_type QueryType) string {
+	switch query_type {
+	case LessThan:
+		return "$lt"
+	case LessThanOrEqual:
+		return "$lte"
+	case GreaterThan:
+		return "$gt"
+	default:
+		return "$gte"
+	}
+}
+
 func ParseQueryType(key string) (QueryType, string) {
-	query_type := In
+	for _, entry := range query_type_suffixes {
+		if len(key) > len(entry.suffix) && strings.HasSuffix(key, entry.suffix) {
+			return entry.query_type, key[0 : len(key)-len(entry.suffix)]
+		}
+	}
+	return In, key
+}
 
-	if strings.HasSuffix(key, "Lt") {
-		key = key[0 : len(key)-2]
-		query_type = LessThan
-	} else if strings.HasSuffix(key, "Gt") {
-		key = key[0 : len(key)-2]
-		query_type = GreaterThan
-	} else if strings.HasSuffix(key, "Not") {
-		key = key[0 : len(key)-3]
-		query_type = NotIn
+/*
+	Parses each of the given values as an integer
+*/
+func parseInt64Values(values []string) ([]int64, error) {
+	cast_values := make([]int64, len(values))
+	for i, value := range values {
+		value_int, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errors.New("Expected integers, got " + value)
+		}
+		cast_values[i] = value_int
 	}
-	return query_type, key
+	return cast_values, nil
 }
 
 /*
-	Turns a series of string URL parameters into a query for a particular data type
-	Returns a map of generated QuerySelectors
+	Parses each of the given values as a float
 */
-func CreateFilterQuery(
-	parameters map[string][]string, model interface{}) (map[string]interface{}, error) {
+func parseFloat64Values(values []string) ([]float64, error) {
+	cast_values := make([]float64, len(values))
+	for i, value := range values {
+		value_float, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, errors.New("Expected floats, got " + value)
+		}
+		cast_values[i] = value_float
+	}
+	return cast_values, nil
+}
 
-	expected_types := GetFieldTypes(model)
+/*
+	Parses each of the given values as a boolean
+*/
+func parseBoolValues(values []string) ([]bool, error) {
+	cast_values := make([]bool, len(values))
+	for i, value := range values {
+		value_bool, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("Expected booleans, got " + value)
+		}
+		cast_values[i] = value_bool
+	}
+	return cast_values, nil
+}
 
-	query := make(map[string]interface{})
+/*
+	Adds the condition described by a single url parameter to query
+	The key selects the field and the query type, and the value is a comma separated list of values
+*/
+func addFilterCondition(query map[string]interface{}, filter_fields map[string]filterField, key string, value string) error {
+	query_type, field_key := ParseQueryType(key)
 
-	for key, values := range parameters {
-		if len(values) > 1 {
-			return nil, errors.New("Multiple usage of key " + key)
+	field, ok := getFilterField(filter_fields, strings.ToLower(field_key), field_key)
+	if !ok {
+		return FilterError{Key: key, Err: errors.New("Unknown field " + field_key)}
+	}
+
+	values := strings.Split(value, ",")
+
+	// Each query selector might have several entries
+	// ie less than 10, greater than 2, not 4
+	qs, _ := query[field.path].(QuerySelector)
+	if qs == nil {
+		qs = QuerySelector{}
+	}
+
+	var err error
+
+	// Exists applies to fields of every type, so it is handled before the type specific operators
+	if query_type == Exists {
+		var cast_values []bool
+		cast_values, err = parseBoolValues(values)
+		if err == nil {
+			err = checkSingleValue(query_type, len(cast_values))
+		}
+		if err == nil {
+			qs["$exists"] = cast_values[0]
+		}
+		if err != nil {
+			return FilterError{Key: key, Err: err}
+		}
+		query[field.path] = qs
+		return nil
+	}
+
+	// We must specifically handle each data type
+	switch field.type_name {
+	case "[]string":
+		qs, err = UpdateQuerySelectorStringSlice(qs, query_type, values)
+	case "string":
+		qs, err = UpdateQuerySelectorString(qs, query_type, values)
+	case "int", "int64":
+		var cast_values []int64
+		cast_values, err = parseInt64Values(values)
+		if err == nil {
+			qs, err = UpdateQuerySelectorInt64(qs, query_type, cast_values)
 		}
+	case "[]int", "[]int64":
+		var cast_values []int64
+		cast_values, err = parseInt64Values(values)
+		if err == nil {
+			qs, err = UpdateQuerySelectorInt64Slice(qs, query_type, cast_values)
+		}
+	case "float", "float32", "float64":
+		var cast_values []float64
+		cast_values, err = parseFloat64Values(values)
+		if err == nil {
+			qs, err = UpdateQuerySelectorFloat64(qs, query_type, cast_values)
+		}
+	case "[]float", "[]float32", "[]float64":
+		var cast_values []float64
+		cast_values, err = parseFloat64Values(values)
+		if err == nil {
+			qs, err = UpdateQuerySelectorFloat64Slice(qs, query_type, cast_values)
+		}
+	case "bool":
+		var cast_values []bool
+		cast_values, err = parseBoolValues(values)
+		if err == nil {
+			qs, err = UpdateQuerySelectorBool(qs, query_type, cast_values)
+		}
+	case "[]bool":
+		var cast_values []bool
+		cast_values, err = parseBoolValues(values)
+		if err == nil {
+			qs, err = UpdateQuerySelectorBoolSlice(qs, query_type, cast_values)
+		}
+	case "interface {}":
+		qs, err = UpdateQuerySelectorUntyped(qs, query_type, values)
+	default:
+		err = errors.New("Only Exists is supported on fields of type " + field.type_name)
+	}
+
+	if err != nil {
+		return FilterError{Key: key, Err: err}
+	}
+
+	query[field.path] = qs
+	return nil
+}
+
+/*
+	Parses an or group, which is a list of key:value filters separated by |
+	For example, or=diet:VEGAN|transportationNot:NONE matches items with a VEGAN diet or which need transportation
+*/
+func parseOrGroup(filter_fields map[string]filterField, group string) ([]interface{}, error) {
+	conditions := []interface{}{}
 
-		query_type, key := ParseQueryType(key)
-		key = strings.ToLower(key)
+	for _, filter := range strings.Split(group, "|") {
+		separator := strings.Index(filter, ":")
+		if separator == -1 {
+			return nil, FilterError{Key: OrGroupParameter, Err: errors.New("Expected key:value, got " + filter)}
+		}
 
-		to_type, ok := expected_types[key]
-		if !ok {
-			return nil, errors.New("Invalid key " + key)
+		condition := make(map[string]interface{})
+		err := addFilterCondition(condition, filter_fields, filter[:separator], filter[separator+1:])
+		if err != nil {
+			return nil, err
 		}
 
-		values := strings.Split(values[0], ",")
+		conditions = append(conditions, condition)
+	}
+
+	return conditions, nil
+}
+
+/*
+	Turns a series of string URL parameters into a query for a particular data type
+	Returns a map of generated QuerySelectors, or a FilterError if the parameters do not describe a valid filter
+
+	Keys may be dotted paths to nested fields, such as programmingExperience.go
+	If the model implements FieldTypeLister, such as a DataStore, the field types are taken from it
+	rather than from the model's struct fields
+
+	Each value of the or parameter is a group of filters, of which an item must match at least one
+*/
+func CreateFilterQuery(
+	parameters map[string][]string, model interface{}) (map[string]interface{}, error) {
+
+	filter_fields := getFilterFields(model)
 
-		// Each query selector might have several entries
-		// ie less than 10, greater than 2, not 4
-		qs, ok := query[key].(QuerySelector)
-		if qs == nil {
-			qs = QuerySelector{}
+	query := make(map[string]interface{})
+
+	for key, values := range parameters {
+		if key == OrGroupParameter {
+			continue
 		}
 
-		var err error
+		if len(values) > 1 {
+			return nil, FilterError{Key: key, Err: errors.New("Multiple usage of key " + key)}
+		}
 
-		// We must specifically handle each data type
-		switch to_type {
-		case "[]string":
-			qs, err = UpdateQuerySelectorStringSlice(qs, query_type, values)
-			query[key] = qs
-		case "string":
-			qs, err = UpdateQuerySelectorString(qs, query_type, values)
-			query[key] = qs
-		case "int", "int64":
-			cast_values := make([]int64, len(values))
-			for i, value := range values {
-				value_int, err := strconv.ParseInt(value, 10, 64)
-				if err != nil {
-					return nil, err
-				}
-				cast_values[i] = value_int
-			}
-			qs, err = UpdateQuerySelectorInt64(qs, query_type, cast_values)
-			query[key] = qs
-		case "bool":
-			cast_values := make([]bool, len(values))
-			for i, value := range values {
-				value_int, err := strconv.ParseBool(value)
-				if err != nil {
-					return nil, err
-				}
-				cast_values[i] = value_int
-			}
-			qs, err = UpdateQuerySelectorBool(qs, query_type, cast_values)
-			query[key] = qs
+		err := addFilterCondition(query, filter_fields, key, values[0])
+		if err != nil {
+			return nil, err
 		}
+	}
 
+	or_groups := []interface{}{}
+	for _, group := range parameters[OrGroupParameter] {
+		conditions, err := parseOrGroup(filter_fields, group)
 		if err != nil {
 			return nil, err
 		}
+
+		or_groups = append(or_groups, map[string]interface{}{"$or": conditions})
+	}
+
+	if len(or_groups) == 1 {
+		query["$or"] = or_groups[0].(map[string]interface{})["$or"]
+	} else if len(or_groups) > 1 {
+		query["$and"] = or_groups
 	}
 
 	return query, nil
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+	Describes an index on a collection
+	Each key is a field name, prefixed with - for a descending index or with $text: for a text index
+	Multiple keys create a compound index, and a non-zero ExpireAfter creates a TTL index
+*/
+type Index struct {
+	Key         []string
+	Unique      bool
+	ExpireAfter time.Duration
+}
+
+/*
+	An alias of a collection name -> indexes map used by services to declare their indexes
+*/
+type IndexDeclarations map[string][]Index
+
+/*
+	Describes whether an index exists on a collection and whether it was declared by the service
+*/
+type IndexStatus struct {
+	Key                []string `json:"key"`
+	Unique             bool     `json:"unique"`
+	ExpireAfterSeconds int      `json:"expireAfterSeconds"`
+	Declared           bool     `json:"declared"`
+	Exists             bool     `json:"exists"`
+}
+
+/*
+	Ensures that every declared index exists in the given database
+	This should be called from each service's Initialize
+	Unique indexes which cannot be created since the collection already has duplicate values are logged and skipped,
+	so that the service still starts, and they are reported as not existing by GetIndexStatus until the duplicates
+	are removed and the service is restarted
+*/
+func EnsureIndexes(db Database, declarations IndexDeclarations) error {
+	for collection_name, indexes := range declarations {
+		for _, index := range indexes {
+			err := db.EnsureIndex(collection_name, index)
+
+			if err == ErrDuplicateKey {
+				log.Printf("Could not create unique index %v on %s, since the collection has items with duplicate values for it", index.Key, collection_name)
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the status of the indexes on every collection with declared indexes
+	Indexes which exist but were not declared are included with Declared set to false
+*/
+func GetIndexStatus(db Database, declarations IndexDeclarations) (map[string][]IndexStatus, error) {
+	index_status := make(map[string][]IndexStatus)
+
+	for collection_name, declared_indexes := range declarations {
+		existing_indexes, err := db.GetIndexes(collection_name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		collection_status := []IndexStatus{}
+
+		for _, index := range declared_indexes {
+			status := getIndexStatus(index)
+			status.Declared = true
+			status.Exists = containsIndex(existing_indexes, index)
+			collection_status = append(collection_status, status)
+		}
+
+		for _, index := range existing_indexes {
+			if !containsIndex(declared_indexes, index) {
+				status := getIndexStatus(index)
+				status.Exists = true
+				collection_status = append(collection_status, status)
+			}
+		}
+
+		index_status[collection_name] = collection_status
+	}
+
+	return index_status, nil
+}
+
+/*
+	Returns the status describing the given index
+*/
+func getIndexStatus(index Index) IndexStatus {
+	return IndexStatus{
+		Key:                index.Key,
+		Unique:             index.Unique,
+		ExpireAfterSeconds: int(index.ExpireAfter / time.Second),
+	}
+}
+
+/*
+	Returns true if an index with the same key and options as index is in indexes
+*/
+func containsIndex(indexes []Index, index Index) bool {
+	for _, candidate := range indexes {
+		if reflect.DeepEqual(getComparableIndexKey(candidate.Key), getComparableIndexKey(index.Key)) && candidate.Unique == index.Unique && candidate.ExpireAfter == index.ExpireAfter {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Returns the index key in a form which can be compared with other keys
+	The fields of a text index are unordered, so they are sorted and moved after the other fields
+*/
+func getComparableIndexKey(key []string) []string {
+	comparable_key := []string{}
+	text_fields := []string{}
+
+	for _, field := range key {
+		if strings.HasPrefix(field, TextIndexPrefix) {
+			text_fields = append(text_fields, field)
+		} else {
+			comparable_key = append(comparable_key, field)
+		}
+	}
+
+	sort.Strings(text_fields)
+
+	return append(comparable_key, text_fields...)
+}
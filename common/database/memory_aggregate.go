@@ -0,0 +1,766 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+	Runs the given aggregation pipeline against the documents in memory
+	Supports the $match, $unwind, $group, $project, $sort, $skip, $limit, and $count stages
+*/
+func runPipeline(documents []bson.M, pipeline interface{}) ([]bson.M, error) {
+	stages, err := toPipelineStages(pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stage := range stages {
+		var stage_document bson.M
+		err = stage.Unmarshal(&stage_document)
+
+		if err != nil || len(stage_document) != 1 {
+			return nil, ErrUnknown
+		}
+
+		var operator string
+		var operand interface{}
+		for key, value := range stage_document {
+			operator = key
+			operand = value
+		}
+
+		switch operator {
+		case "$match":
+			documents, err = runMatchStage(documents, operand)
+		case "$unwind":
+			documents, err = runUnwindStage(documents, operand)
+		case "$group":
+			documents, err = runGroupStage(documents, operand)
+		case "$project":
+			documents, err = runProjectStage(documents, operand)
+		case "$sort":
+			// The order of the sort fields is only preserved when decoding into a bson.D
+			var sort_stage struct {
+				Sort bson.D `bson:"$sort"`
+			}
+			err = stage.Unmarshal(&sort_stage)
+
+			if err == nil {
+				documents, err = runSortStage(documents, sort_stage.Sort)
+			}
+		case "$skip":
+			documents, err = runSkipStage(documents, operand)
+		case "$limit":
+			documents, err = runLimitStage(documents, operand)
+		case "$count":
+			documents, err = runCountStage(documents, operand)
+		default:
+			return nil, ErrUnknown
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return documents, nil
+}
+
+/*
+	Converts the given pipeline into its stages, each in their raw bson representation
+*/
+func toPipelineStages(pipeline interface{}) ([]bson.Raw, error) {
+	raw, err := bson.Marshal(bson.M{"pipeline": pipeline})
+
+	if err != nil {
+		return nil, ErrUnknown
+	}
+
+	var pipeline_document struct {
+		Pipeline []bson.Raw `bson:"pipeline"`
+	}
+
+	err = bson.Unmarshal(raw, &pipeline_document)
+
+	if err != nil {
+		return nil, ErrUnknown
+	}
+
+	return pipeline_document.Pipeline, nil
+}
+
+/*
+	Keeps only the documents matching the given query
+*/
+func runMatchStage(documents []bson.M, operand interface{}) ([]bson.M, error) {
+	query, ok := asDocument(operand)
+
+	if !ok {
+		return nil, ErrUnknown
+	}
+
+	matched := []bson.M{}
+
+	for _, document := range documents {
+		is_match, err := matchesQuery(document, query)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if is_match {
+			matched = append(matched, document)
+		}
+	}
+
+	return matched, nil
+}
+
+/*
+	Outputs a copy of each document for every element of the array at the given path
+	Non-array values are treated as a single element array
+*/
+func runUnwindStage(documents []bson.M, operand interface{}) ([]bson.M, error) {
+	path := ""
+	preserve_empty := false
+
+	switch unwind := operand.(type) {
+	case string:
+		path = unwind
+	default:
+		unwind_document, ok := asDocument(operand)
+
+		if !ok {
+			return nil, ErrUnknown
+		}
+
+		path, _ = unwind_document["path"].(string)
+		preserve_empty, _ = unwind_document["preserveNullAndEmptyArrays"].(bool)
+	}
+
+	if !strings.HasPrefix(path, "$") {
+		return nil, ErrUnknown
+	}
+
+	path = strings.TrimPrefix(path, "$")
+
+	unwound := []bson.M{}
+
+	for _, document := range documents {
+		value, exists := getField(document, path)
+		elements, is_array := value.([]interface{})
+
+		if !exists || value == nil || (is_array && len(elements) == 0) {
+			if preserve_empty {
+				unwound = append(unwound, document)
+			}
+			continue
+		}
+
+		if !is_array {
+			unwound = append(unwound, document)
+			continue
+		}
+
+		for _, element := range elements {
+			unwound_document, err := toDocument(document)
+
+			if err != nil {
+				return nil, err
+			}
+
+			err = setField(unwound_document, path, element)
+
+			if err != nil {
+				return nil, err
+			}
+
+			unwound = append(unwound, unwound_document)
+		}
+	}
+
+	return unwound, nil
+}
+
+/*
+	Used to accumulate a single output document of a $group stage
+*/
+type memoryGroup struct {
+	id     interface{}
+	values map[string][]interface{}
+}
+
+/*
+	Groups the documents by the _id expression and computes the accumulators for each group
+	Supports the $sum, $avg, $min, $max, $first, $last, $push, and $addToSet accumulators
+*/
+func runGroupStage(documents []bson.M, operand interface{}) ([]bson.M, error) {
+	group_document, ok := asDocument(operand)
+
+	if !ok {
+		return nil, ErrUnknown
+	}
+
+	id_expression, has_id := group_document["_id"]
+
+	if !has_id {
+		return nil, ErrUnknown
+	}
+
+	accumulators := make(map[string]bson.M)
+	for field, accumulator := range group_document {
+		if field == "_id" {
+			continue
+		}
+
+		accumulator_document, ok := asDocument(accumulator)
+
+		if !ok || len(accumulator_document) != 1 {
+			return nil, ErrUnknown
+		}
+
+		accumulators[field] = accumulator_document
+	}
+
+	groups := []*memoryGroup{}
+
+	for _, document := range documents {
+		id, _, err := evaluateExpression(document, id_expression)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var group *memoryGroup
+		for _, candidate := range groups {
+			if valuesEqual(candidate.id, id) {
+				group = candidate
+				break
+			}
+		}
+
+		if group == nil {
+			group = &memoryGroup{
+				id:     id,
+				values: make(map[string][]interface{}),
+			}
+			groups = append(groups, group)
+		}
+
+		for field, accumulator := range accumulators {
+			for _, expression := range accumulator {
+				value, exists, err := evaluateExpression(document, expression)
+
+				if err != nil {
+					return nil, err
+				}
+
+				if exists {
+					group.values[field] = append(group.values[field], value)
+				}
+			}
+		}
+	}
+
+	results := []bson.M{}
+
+	for _, group := range groups {
+		result := bson.M{
+			"_id": group.id,
+		}
+
+		for field, accumulator := range accumulators {
+			for operator := range accumulator {
+				value, err := accumulate(operator, group.values[field])
+
+				if err != nil {
+					return nil, err
+				}
+
+				result[field] = value
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+/*
+	Computes the value of a $group accumulator from the values in the group
+*/
+func accumulate(operator string, values []interface{}) (interface{}, error) {
+	switch operator {
+	case "$sum":
+		var sum interface{} = 0
+		for _, value := range values {
+			if total, ok := addNumbers(sum, value); ok {
+				sum = total
+			}
+		}
+		return sum, nil
+	case "$avg":
+		sum := 0.0
+		count := 0
+		for _, value := range values {
+			if number, ok := toFloat64(value); ok {
+				sum += number
+				count += 1
+			}
+		}
+		if count == 0 {
+			return nil, nil
+		}
+		return sum / float64(count), nil
+	case "$min", "$max":
+		var extreme interface{}
+		for _, value := range values {
+			if value == nil {
+				continue
+			}
+			if extreme == nil {
+				extreme = value
+				continue
+			}
+			ordering := compareOrdered(value, extreme)
+			if (operator == "$min" && ordering < 0) || (operator == "$max" && ordering > 0) {
+				extreme = value
+			}
+		}
+		return extreme, nil
+	case "$first":
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return values[0], nil
+	case "$last":
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return values[len(values)-1], nil
+	case "$push":
+		pushed := []interface{}{}
+		return append(pushed, values...), nil
+	case "$addToSet":
+		set := []interface{}{}
+		for _, value := range values {
+			if !containsValue(set, value) {
+				set = append(set, value)
+			}
+		}
+		return set, nil
+	default:
+		return nil, ErrUnknown
+	}
+}
+
+/*
+	Includes, excludes, or computes fields of each document
+*/
+func runProjectStage(documents []bson.M, operand interface{}) ([]bson.M, error) {
+	projection, ok := asDocument(operand)
+
+	if !ok {
+		return nil, ErrUnknown
+	}
+
+	include_id := true
+	is_inclusion := false
+
+	for field, value := range projection {
+		is_included, is_flag := projectionFlag(value)
+
+		if field == "_id" && is_flag {
+			include_id = is_included
+		} else if !is_flag || is_included {
+			is_inclusion = true
+		}
+	}
+
+	projected := []bson.M{}
+
+	for _, document := range documents {
+		var result bson.M
+
+		if is_inclusion {
+			result = bson.M{}
+
+			if id, exists := document["_id"]; exists && include_id {
+				result["_id"] = id
+			}
+
+			for field, value := range projection {
+				if field == "_id" {
+					continue
+				}
+
+				var field_value interface{}
+				var exists bool
+				var err error
+
+				if _, is_flag := projectionFlag(value); is_flag {
+					field_value, exists = getField(document, field)
+				} else {
+					field_value, exists, err = evaluateExpression(document, value)
+				}
+
+				if err != nil {
+					return nil, err
+				}
+
+				if exists {
+					err = setField(result, field, field_value)
+
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+		} else {
+			copied_document, err := toDocument(document)
+
+			if err != nil {
+				return nil, err
+			}
+
+			for field := range projection {
+				if field != "_id" || !include_id {
+					unsetField(copied_document, field)
+				}
+			}
+
+			result = copied_document
+		}
+
+		projected = append(projected, result)
+	}
+
+	return projected, nil
+}
+
+/*
+	Returns whether a projection value includes its field, and whether the value was an inclusion flag
+*/
+func projectionFlag(value interface{}) (bool, bool) {
+	if flag, ok := value.(bool); ok {
+		return flag, true
+	}
+
+	if number, ok := toFloat64(value); ok {
+		return number != 0, true
+	}
+
+	return false, false
+}
+
+/*
+	Sorts the documents by the given fields, where 1 is ascending and -1 is descending
+*/
+func runSortStage(documents []bson.M, sort_document bson.D) ([]bson.M, error) {
+	sort_fields := []SortField{}
+
+	for _, element := range sort_document {
+		direction, ok := toFloat64(element.Value)
+
+		if !ok {
+			return nil, ErrUnknown
+		}
+
+		sort_fields = append(sort_fields, SortField{
+			Name:     element.Name,
+			Reversed: direction < 0,
+		})
+	}
+
+	sorted := make([]bson.M, len(documents))
+	copy(sorted, documents)
+	sortDocuments(sorted, sort_fields)
+
+	return sorted, nil
+}
+
+/*
+	Skips the given number of documents
+*/
+func runSkipStage(documents []bson.M, operand interface{}) ([]bson.M, error) {
+	skip, ok := toInt64(operand)
+
+	if !ok || skip < 0 {
+		return nil, ErrUnknown
+	}
+
+	if int(skip) >= len(documents) {
+		return []bson.M{}, nil
+	}
+
+	return documents[skip:], nil
+}
+
+/*
+	Limits the output to the given number of documents
+*/
+func runLimitStage(documents []bson.M, operand interface{}) ([]bson.M, error) {
+	limit, ok := toInt64(operand)
+
+	if !ok || limit <= 0 {
+		return nil, ErrUnknown
+	}
+
+	if int(limit) >= len(documents) {
+		return documents, nil
+	}
+
+	return documents[:limit], nil
+}
+
+/*
+	Outputs a single document containing the number of documents in the given field
+	No document is output when there are no documents, the same as mongo
+*/
+func runCountStage(documents []bson.M, operand interface{}) ([]bson.M, error) {
+	field, ok := operand.(string)
+
+	if !ok || field == "" || strings.HasPrefix(field, "$") {
+		return nil, ErrUnknown
+	}
+
+	if len(documents) == 0 {
+		return []bson.M{}, nil
+	}
+
+	return []bson.M{{field: len(documents)}}, nil
+}
+
+/*
+	Evaluates an aggregation expression against the document
+	Returns false if the expression refers to a field which does not exist
+*/
+func evaluateExpression(document bson.M, expression interface{}) (interface{}, bool, error) {
+	switch value := expression.(type) {
+	case string:
+		if strings.HasPrefix(value, "$") {
+			field_value, exists := lookupField(document, strings.TrimPrefix(value, "$"))
+			return field_value, exists, nil
+		}
+
+		return value, true, nil
+	case []interface{}:
+		elements := []interface{}{}
+
+		for _, element := range value {
+			element_value, _, err := evaluateExpression(document, element)
+
+			if err != nil {
+				return nil, false, err
+			}
+
+			elements = append(elements, element_value)
+		}
+
+		return elements, true, nil
+	}
+
+	expression_document, is_document := asDocument(expression)
+
+	if !is_document {
+		return expression, true, nil
+	}
+
+	if len(expression_document) == 1 && isOperatorDocument(expression_document) {
+		for operator, operand := range expression_document {
+			result, err := evaluateOperator(document, operator, operand)
+			return result, true, err
+		}
+	}
+
+	result := bson.M{}
+
+	for field, field_expression := range expression_document {
+		field_value, exists, err := evaluateExpression(document, field_expression)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		if exists {
+			result[field] = field_value
+		}
+	}
+
+	return result, true, nil
+}
+
+/*
+	Evaluates an aggregation expression operator against the document
+	Supports $literal, $cond, $ifNull, $type, $and, $or, $not, and the comparison operators
+*/
+func evaluateOperator(document bson.M, operator string, operand interface{}) (interface{}, error) {
+	if operator == "$literal" {
+		return operand, nil
+	}
+
+	if operator == "$type" {
+		value, exists, err := evaluateExpression(document, unwrapSingleArgument(operand))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return getTypeName(value, exists), nil
+	}
+
+	arguments := []interface{}{}
+
+	if operator == "$cond" {
+		if cond_document, ok := asDocument(operand); ok {
+			operand = []interface{}{cond_document["if"], cond_document["then"], cond_document["else"]}
+		}
+	}
+
+	operands, is_array := operand.([]interface{})
+
+	if !is_array {
+		operands = []interface{}{operand}
+	}
+
+	for _, argument := range operands {
+		value, _, err := evaluateExpression(document, argument)
+
+		if err != nil {
+			return nil, err
+		}
+
+		arguments = append(arguments, value)
+	}
+
+	switch operator {
+	case "$cond":
+		if len(arguments) != 3 {
+			return nil, ErrUnknown
+		}
+		if isTruthy(arguments[0]) {
+			return arguments[1], nil
+		}
+		return arguments[2], nil
+	case "$ifNull":
+		if len(arguments) != 2 {
+			return nil, ErrUnknown
+		}
+		if arguments[0] != nil {
+			return arguments[0], nil
+		}
+		return arguments[1], nil
+	case "$and":
+		for _, argument := range arguments {
+			if !isTruthy(argument) {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "$or":
+		for _, argument := range arguments {
+			if isTruthy(argument) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$not":
+		if len(arguments) != 1 {
+			return nil, ErrUnknown
+		}
+		return !isTruthy(arguments[0]), nil
+	case "$eq", "$ne":
+		if len(arguments) != 2 {
+			return nil, ErrUnknown
+		}
+		is_equal := valuesEqual(arguments[0], arguments[1])
+		return is_equal == (operator == "$eq"), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		if len(arguments) != 2 {
+			return nil, ErrUnknown
+		}
+		ordering := compareOrdered(arguments[0], arguments[1])
+		switch operator {
+		case "$gt":
+			return ordering > 0, nil
+		case "$gte":
+			return ordering >= 0, nil
+		case "$lt":
+			return ordering < 0, nil
+		default:
+			return ordering <= 0, nil
+		}
+	default:
+		return nil, ErrUnknown
+	}
+}
+
+/*
+	Returns the single argument of an operator which may be given with or without an enclosing array
+*/
+func unwrapSingleArgument(operand interface{}) interface{} {
+	if arguments, ok := operand.([]interface{}); ok && len(arguments) == 1 {
+		return arguments[0]
+	}
+
+	return operand
+}
+
+/*
+	Returns true if the value is considered true by aggregation expressions
+*/
+func isTruthy(value interface{}) bool {
+	switch typed_value := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return typed_value
+	}
+
+	if number, ok := toFloat64(value); ok {
+		return number != 0
+	}
+
+	return true
+}
+
+/*
+	Returns the name of the value's bson type, as returned by the $type expression
+*/
+func getTypeName(value interface{}, exists bool) string {
+	if !exists {
+		return "missing"
+	}
+
+	switch value.(type) {
+	case nil:
+		return "null"
+	case float32, float64:
+		return "double"
+	case int, int32:
+		return "int"
+	case int64:
+		return "long"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case bson.M, map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case []byte:
+		return "binData"
+	case bson.ObjectId:
+		return "objectId"
+	case time.Time:
+		return "date"
+	default:
+		return "unknown"
+	}
+}
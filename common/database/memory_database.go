@@ -0,0 +1,1233 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const MemoryHostPrefix = "memory://"
+
+/*
+	Holds the collections of a single in memory database
+	Stores are shared by every MemoryDatabase connected to the same host and database name
+*/
+type memoryStore struct {
+	mutex             sync.RWMutex
+	transaction_mutex sync.Mutex
+	collections       map[string][]bson.M
+	indexes           map[string][]Index
+}
+
+var memory_stores = make(map[string]*memoryStore)
+var memory_stores_mutex sync.Mutex
+
+/*
+	MemoryDatabase struct which implements the Database interface entirely in process memory
+	Documents are stored in their bson representation so that models behave identically to MongoDatabase
+*/
+type MemoryDatabase struct {
+	store          *memoryStore
+	name           string
+	ctx            context.Context
+	in_transaction bool
+}
+
+/*
+	Initialize connection to an in memory database
+*/
+func InitMemoryDatabase(host string, db_name string) (*MemoryDatabase, error) {
+	db := MemoryDatabase{
+		name: db_name,
+		ctx:  context.Background(),
+	}
+
+	err := db.Connect(host)
+
+	if err != nil {
+		return &db, err
+	}
+
+	return &db, nil
+}
+
+/*
+	Attach to the in memory store for the given host, creating it if it does not exist
+*/
+func (db *MemoryDatabase) Connect(host string) error {
+	if !strings.HasPrefix(host, MemoryHostPrefix) {
+		return ErrConnection
+	}
+
+	store_key := strings.TrimPrefix(host, MemoryHostPrefix) + "/" + db.name
+
+	memory_stores_mutex.Lock()
+	defer memory_stores_mutex.Unlock()
+
+	store, exists := memory_stores[store_key]
+
+	if !exists {
+		store = &memoryStore{
+			collections: make(map[string][]bson.M),
+			indexes:     make(map[string][]Index),
+		}
+		memory_stores[store_key] = store
+	}
+
+	db.store = store
+
+	return nil
+}
+
+/*
+	Closing an in memory database is a no-op, the data persists for the lifetime of the process
+*/
+func (db *MemoryDatabase) Close() {
+}
+
+/*
+	Returns a copy of the database which performs every operation within the given context
+	Operations are not started once the context is done
+*/
+func (db *MemoryDatabase) WithContext(ctx context.Context) Database {
+	return &MemoryDatabase{
+		store:          db.store,
+		name:           db.name,
+		ctx:            ctx,
+		in_transaction: db.in_transaction,
+	}
+}
+
+/*
+	Returns the indices of all documents in the collection matching the given query
+	The store's mutex must be held by the caller
+*/
+func (db *MemoryDatabase) findMatches(collection_name string, query interface{}) ([]int, error) {
+	query_document, err := toDocument(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []int{}
+
+	for i, document := range db.store.collections[collection_name] {
+		is_match, err := matchesQuery(document, query_document)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if is_match {
+			matches = append(matches, i)
+		}
+	}
+
+	return matches, nil
+}
+
+/*
+	Find one element matching the given query parameters
+*/
+func (db *MemoryDatabase) FindOne(collection_name string, query interface{}, result interface{}) error {
+	return db.FindOneProjected(collection_name, query, nil, result)
+}
+
+/*
+	Find one element matching the given query parameters, including only the fields in projection
+*/
+func (db *MemoryDatabase) FindOneProjected(collection_name string, query interface{}, projection []string, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	db.store.mutex.RLock()
+	defer db.store.mutex.RUnlock()
+
+	matches, err := db.findMatches(collection_name, query)
+
+	if err != nil {
+		return err
+	}
+
+	if len(matches) == 0 {
+		return ErrNotFound
+	}
+
+	return fromDocument(projectDocument(db.store.collections[collection_name][matches[0]], projection), result)
+}
+
+/*
+	Find all elements matching the given query parameters
+*/
+func (db *MemoryDatabase) FindAll(collection_name string, query interface{}, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	return db.FindAllSorted(collection_name, query, nil, result)
+}
+
+/*
+	Find all elements matching the given query parameters, and sorts them based on given sort fields
+	The first sort field is highest priority, each subsequent field breaks ties
+*/
+func (db *MemoryDatabase) FindAllSorted(collection_name string, query interface{}, sort_fields []SortField, result interface{}) error {
+	_, err := db.FindAllPaginated(collection_name, query, sort_fields, PaginationOptions{}, result)
+	return err
+}
+
+/*
+	Find the page of elements matching the given query parameters described by pagination, sorted by the given sort fields
+	Ties, and elements when no sort fields are given, are ordered by _id so that pages do not overlap
+	Returns the total number of elements matching the query, ignoring pagination
+*/
+func (db *MemoryDatabase) FindAllPaginated(collection_name string, query interface{}, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	return db.FindAllProjected(collection_name, query, nil, sort_fields, pagination, result)
+}
+
+/*
+	Find the page of elements matching the given query parameters described by pagination, sorted by the given sort fields,
+	including only the fields in projection
+	Returns the total number of elements matching the query, ignoring pagination
+*/
+func (db *MemoryDatabase) FindAllProjected(collection_name string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	db.store.mutex.RLock()
+	defer db.store.mutex.RUnlock()
+
+	matches, err := db.findMatches(collection_name, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([]bson.M, len(matches))
+	for i, match := range matches {
+		documents[i] = db.store.collections[collection_name][match]
+	}
+
+	sortDocuments(documents, getPaginationSortFields(sort_fields))
+
+	return getDocumentsPage(documents, projection, pagination, result)
+}
+
+/*
+	Stores the page of the documents described by pagination in result, including only the fields in projection
+	Returns the total number of documents, ignoring pagination
+*/
+func getDocumentsPage(documents []bson.M, projection []string, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	pagination_results := PaginationResults{
+		Total: len(documents),
+	}
+
+	start := pagination.Skip
+	if start > len(documents) {
+		start = len(documents)
+	}
+
+	end := len(documents)
+	if pagination.Limit > 0 && start+pagination.Limit < end {
+		end = start + pagination.Limit
+	}
+
+	page := make([]bson.M, end-start)
+	for i, document := range documents[start:end] {
+		page[i] = projectDocument(document, projection)
+	}
+
+	err := fromDocuments(page, result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &pagination_results, nil
+}
+
+/*
+	Find the page of elements matching the given query parameters which contain the text in their text indexed fields,
+	including only the fields in projection
+	Results are ordered by relevance, unless sort fields are given
+	Returns the total number of elements matching the search, ignoring pagination
+*/
+func (db *MemoryDatabase) FindAllText(collection_name string, text string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	db.store.mutex.RLock()
+	defer db.store.mutex.RUnlock()
+
+	text_fields := getTextIndexFields(db.store.indexes[collection_name])
+
+	if len(text_fields) == 0 {
+		return nil, ErrNoTextIndex
+	}
+
+	matches, err := db.findMatches(collection_name, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	search := parseTextSearch(text)
+
+	documents := []bson.M{}
+	scores := []float64{}
+	for _, match := range matches {
+		document := db.store.collections[collection_name][match]
+		score := search.getScore(document, text_fields)
+
+		if score > 0 {
+			documents = append(documents, document)
+			scores = append(scores, score)
+		}
+	}
+
+	if len(sort_fields) > 0 {
+		sortDocuments(documents, getPaginationSortFields(sort_fields))
+	} else {
+		sortDocumentsByScore(documents, scores)
+	}
+
+	return getDocumentsPage(documents, projection, pagination, result)
+}
+
+/*
+	Remove one element matching the given query parameters
+*/
+func (db *MemoryDatabase) RemoveOne(collection_name string, query interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	matches, err := db.findMatches(collection_name, query)
+
+	if err != nil {
+		return err
+	}
+
+	if len(matches) == 0 {
+		return ErrNotFound
+	}
+
+	db.removeIndices(collection_name, matches[:1])
+
+	return nil
+}
+
+/*
+	Remove all elements matching the given query parameters
+*/
+func (db *MemoryDatabase) RemoveAll(collection_name string, query interface{}) (*ChangeResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	matches, err := db.findMatches(collection_name, query)
+
+	if err != nil {
+		return &ChangeResults{}, err
+	}
+
+	db.removeIndices(collection_name, matches)
+
+	change_results := ChangeResults{
+		Updated: 0,
+		Deleted: len(matches),
+	}
+
+	return &change_results, nil
+}
+
+/*
+	Removes the documents at the given ascending indices from the collection
+	The store's mutex must be held by the caller
+*/
+func (db *MemoryDatabase) removeIndices(collection_name string, indices []int) {
+	collection := db.store.collections[collection_name]
+	remaining := make([]bson.M, 0, len(collection)-len(indices))
+
+	next := 0
+	for i, document := range collection {
+		if next < len(indices) && indices[next] == i {
+			next++
+			continue
+		}
+		remaining = append(remaining, document)
+	}
+
+	db.store.collections[collection_name] = remaining
+}
+
+/*
+	Insert the given item into the collection
+*/
+func (db *MemoryDatabase) Insert(collection_name string, item interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	document, err := toDocument(item)
+
+	if err != nil {
+		return err
+	}
+
+	if _, exists := document["_id"]; !exists {
+		document["_id"] = bson.NewObjectId()
+	}
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	err = db.checkUniqueIndexes(collection_name, document, -1)
+
+	if err != nil {
+		return err
+	}
+
+	db.store.collections[collection_name] = append(db.store.collections[collection_name], document)
+
+	return nil
+}
+
+/*
+	Upsert the given item into the collection i.e.,
+	if the item exists, it is updated with the given values, else a new item with those values is created.
+*/
+func (db *MemoryDatabase) Upsert(collection_name string, selector interface{}, update interface{}) (*ChangeResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	update_document, err := toDocument(update)
+
+	if err != nil {
+		return &ChangeResults{}, err
+	}
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	matches, err := db.findMatches(collection_name, selector)
+
+	if err != nil {
+		return &ChangeResults{}, err
+	}
+
+	if len(matches) > 0 {
+		err = db.updateIndex(collection_name, matches[0], update_document)
+
+		if err != nil {
+			return &ChangeResults{}, err
+		}
+
+		return &ChangeResults{Updated: 1}, nil
+	}
+
+	selector_document, err := toDocument(selector)
+
+	if err != nil {
+		return &ChangeResults{}, err
+	}
+
+	document, err := createUpsertDocument(selector_document, update_document)
+
+	if err != nil {
+		return &ChangeResults{}, err
+	}
+
+	err = db.checkUniqueIndexes(collection_name, document, -1)
+
+	if err != nil {
+		return &ChangeResults{}, err
+	}
+
+	db.store.collections[collection_name] = append(db.store.collections[collection_name], document)
+
+	return &ChangeResults{}, nil
+}
+
+/*
+	Finds an item based on the given selector and updates it with the data in update
+*/
+func (db *MemoryDatabase) Update(collection_name string, selector interface{}, update interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	update_document, err := toDocument(update)
+
+	if err != nil {
+		return err
+	}
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	matches, err := db.findMatches(collection_name, selector)
+
+	if err != nil {
+		return err
+	}
+
+	if len(matches) == 0 {
+		return ErrNotFound
+	}
+
+	return db.updateIndex(collection_name, matches[0], update_document)
+}
+
+/*
+	Finds all items based on the given selector and updates them with the data in update
+*/
+func (db *MemoryDatabase) UpdateAll(collection_name string, selector interface{}, update interface{}) (*ChangeResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	update_document, err := toDocument(update)
+
+	if err != nil {
+		return &ChangeResults{}, err
+	}
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	matches, err := db.findMatches(collection_name, selector)
+
+	if err != nil {
+		return &ChangeResults{}, err
+	}
+
+	for _, match := range matches {
+		err = db.updateIndex(collection_name, match, update_document)
+
+		if err != nil {
+			return &ChangeResults{}, err
+		}
+	}
+
+	change_results := ChangeResults{
+		Updated: len(matches),
+		Deleted: 0,
+	}
+
+	return &change_results, nil
+}
+
+/*
+	Atomically finds an item based on the given selector, updates it with the data in update, and stores the updated item in result
+	If upsert is true and no item matches the selector, a new item is created
+*/
+func (db *MemoryDatabase) FindAndModify(collection_name string, selector interface{}, update interface{}, upsert bool, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	update_document, err := toDocument(update)
+
+	if err != nil {
+		return err
+	}
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	matches, err := db.findMatches(collection_name, selector)
+
+	if err != nil {
+		return err
+	}
+
+	var document bson.M
+
+	if len(matches) > 0 {
+		err = db.updateIndex(collection_name, matches[0], update_document)
+
+		if err != nil {
+			return err
+		}
+
+		document = db.store.collections[collection_name][matches[0]]
+	} else if upsert {
+		selector_document, err := toDocument(selector)
+
+		if err != nil {
+			return err
+		}
+
+		document, err = createUpsertDocument(selector_document, update_document)
+
+		if err != nil {
+			return err
+		}
+
+		err = db.checkUniqueIndexes(collection_name, document, -1)
+
+		if err != nil {
+			return err
+		}
+
+		db.store.collections[collection_name] = append(db.store.collections[collection_name], document)
+	} else {
+		return ErrNotFound
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return fromDocument(document, result)
+}
+
+/*
+	Atomically updates the item matching the given selector, but only if it also matches the given condition
+	Returns false if the item exists but does not match the condition, and ErrNotFound if no item matches the selector
+*/
+func (db *MemoryDatabase) UpdateIfMatches(collection_name string, selector interface{}, condition interface{}, update interface{}) (bool, error) {
+	if err := db.ctx.Err(); err != nil {
+		return false, convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	update_document, err := toDocument(update)
+
+	if err != nil {
+		return false, err
+	}
+
+	condition_document, err := toDocument(condition)
+
+	if err != nil {
+		return false, err
+	}
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	matches, err := db.findMatches(collection_name, selector)
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(matches) == 0 {
+		return false, ErrNotFound
+	}
+
+	for _, match := range matches {
+		is_match, err := matchesQuery(db.store.collections[collection_name][match], condition_document)
+
+		if err != nil {
+			return false, err
+		}
+
+		if is_match {
+			return true, db.updateIndex(collection_name, match, update_document)
+		}
+	}
+
+	return false, nil
+}
+
+/*
+	Applies the update to the document at the given index in the collection
+	The document is only replaced once the whole update has been applied successfully, and does not violate a unique index
+	The store's mutex must be held by the caller
+*/
+func (db *MemoryDatabase) updateIndex(collection_name string, index int, update bson.M) error {
+	document, err := applyUpdate(db.store.collections[collection_name][index], update)
+
+	if err != nil {
+		return err
+	}
+
+	err = db.checkUniqueIndexes(collection_name, document, index)
+
+	if err != nil {
+		return err
+	}
+
+	db.store.collections[collection_name][index] = document
+
+	return nil
+}
+
+/*
+	Drops the entire database
+*/
+func (db *MemoryDatabase) DropDatabase() error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	db.store.collections = make(map[string][]bson.M)
+	db.store.indexes = make(map[string][]Index)
+
+	return nil
+}
+
+/*
+	Records the given index on the collection if it does not already exist
+	Unique indexes are enforced on writes, and ErrDuplicateKey is returned if the collection already has duplicates
+	Other indexes are only recorded so that they can be reported
+*/
+func (db *MemoryDatabase) EnsureIndex(collection_name string, index Index) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	if containsIndex(db.store.indexes[collection_name], index) {
+		return nil
+	}
+
+	if index.Unique {
+		documents := db.store.collections[collection_name]
+
+		for i := range documents {
+			for j := i + 1; j < len(documents); j++ {
+				if hasSameIndexKey(index, documents[i], documents[j]) {
+					return ErrDuplicateKey
+				}
+			}
+		}
+	}
+
+	db.store.indexes[collection_name] = append(db.store.indexes[collection_name], index)
+
+	return nil
+}
+
+/*
+	Returns ErrDuplicateKey if the document has the same values for the fields of a unique index on the collection
+	as another document, ignoring the document at the given position in the collection, which it is replacing
+	The store's mutex must be held by the caller
+*/
+func (db *MemoryDatabase) checkUniqueIndexes(collection_name string, document bson.M, position int) error {
+	for _, index := range db.store.indexes[collection_name] {
+		if !index.Unique {
+			continue
+		}
+
+		for i, other := range db.store.collections[collection_name] {
+			if i != position && hasSameIndexKey(index, document, other) {
+				return ErrDuplicateKey
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns true if the documents have the same values for every field of the index
+	Missing fields are treated as null, the same as mongo
+*/
+func hasSameIndexKey(index Index, document bson.M, other bson.M) bool {
+	for _, key := range index.Key {
+		field := strings.TrimPrefix(key, "-")
+		value, _ := getField(document, field)
+		other_value, _ := getField(other, field)
+
+		if !valuesEqual(value, other_value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+	Returns the indexes recorded on the collection
+*/
+func (db *MemoryDatabase) GetIndexes(collection_name string) ([]Index, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	db.store.mutex.RLock()
+	defer db.store.mutex.RUnlock()
+
+	indexes := make([]Index, len(db.store.indexes[collection_name]))
+	copy(indexes, db.store.indexes[collection_name])
+
+	return indexes, nil
+}
+
+/*
+	Returns the number of items matching the given query parameters
+*/
+func (db *MemoryDatabase) Count(collection_name string, query interface{}) (int, error) {
+	if err := db.ctx.Err(); err != nil {
+		return 0, convertContextError(err)
+	}
+
+	db.store.mutex.RLock()
+	defer db.store.mutex.RUnlock()
+
+	matches, err := db.findMatches(collection_name, query)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(matches), nil
+}
+
+/*
+	Stores the unique values of the field among the items matching the given query parameters in result
+	The elements of array fields are treated as separate values, the same as mongo
+*/
+func (db *MemoryDatabase) Distinct(collection_name string, field string, query interface{}, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	db.store.mutex.RLock()
+	defer db.store.mutex.RUnlock()
+
+	matches, err := db.findMatches(collection_name, query)
+
+	if err != nil {
+		return err
+	}
+
+	values := []interface{}{}
+
+	for _, match := range matches {
+		value, exists := lookupField(db.store.collections[collection_name][match], field)
+
+		if !exists {
+			continue
+		}
+
+		elements, is_array := value.([]interface{})
+
+		if !is_array {
+			elements = []interface{}{value}
+		}
+
+		for _, element := range elements {
+			if !containsValue(values, element) {
+				values = append(values, element)
+			}
+		}
+	}
+
+	return fromValues(values, result)
+}
+
+/*
+	Runs the given insert, update, upsert, and delete operations on the collection in order
+	Every operation is attempted even if others fail, and ErrBulkWrite is returned if any of them failed
+	The outcome of each operation is stored in the returned results
+*/
+func (db *MemoryDatabase) BulkWrite(collection_name string, operations []BulkOperation) (*BulkWriteResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	defer db.lockWrite()()
+
+	_, err := getBulkBatches(operations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db.store.mutex.Lock()
+	defer db.store.mutex.Unlock()
+
+	results := newBulkWriteResults(len(operations))
+
+	for i, operation := range operations {
+		switch operation.Type {
+		case BulkInsert:
+			results.Operations[i].Err = db.bulkInsert(collection_name, operation, results)
+		case BulkUpdate, BulkUpsert:
+			results.Operations[i].Upserted, results.Operations[i].Err = db.bulkUpdate(collection_name, operation, results)
+		case BulkDelete:
+			results.Operations[i].Err = db.bulkDelete(collection_name, operation, results)
+		}
+	}
+
+	return results, results.getError()
+}
+
+/*
+	Inserts the operation's item into the collection and adds it to the results
+	The store's mutex must be held by the caller
+*/
+func (db *MemoryDatabase) bulkInsert(collection_name string, operation BulkOperation, results *BulkWriteResults) error {
+	document, err := toDocument(operation.Item)
+
+	if err != nil {
+		return err
+	}
+
+	if _, exists := document["_id"]; !exists {
+		document["_id"] = bson.NewObjectId()
+	}
+
+	err = db.checkUniqueIndexes(collection_name, document, -1)
+
+	if err != nil {
+		return err
+	}
+
+	db.store.collections[collection_name] = append(db.store.collections[collection_name], document)
+	results.Inserted++
+
+	return nil
+}
+
+/*
+	Applies the operation's update to the matching items, creating a new item for upserts with no matches
+	Returns true if a new item was created
+	The store's mutex must be held by the caller
+*/
+func (db *MemoryDatabase) bulkUpdate(collection_name string, operation BulkOperation, results *BulkWriteResults) (bool, error) {
+	update_document, err := toDocument(operation.Item)
+
+	if err != nil {
+		return false, err
+	}
+
+	matches, err := db.findMatches(collection_name, operation.Selector)
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(matches) == 0 {
+		if operation.Type != BulkUpsert {
+			return false, nil
+		}
+
+		selector_document, err := toDocument(operation.Selector)
+
+		if err != nil {
+			return false, err
+		}
+
+		document, err := createUpsertDocument(selector_document, update_document)
+
+		if err != nil {
+			return false, err
+		}
+
+		err = db.checkUniqueIndexes(collection_name, document, -1)
+
+		if err != nil {
+			return false, err
+		}
+
+		db.store.collections[collection_name] = append(db.store.collections[collection_name], document)
+		results.Upserted++
+
+		return true, nil
+	}
+
+	if !operation.Multi {
+		matches = matches[:1]
+	}
+
+	for _, match := range matches {
+		err = db.updateIndex(collection_name, match, update_document)
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	results.Updated += len(matches)
+
+	return false, nil
+}
+
+/*
+	Removes the items matching the operation's selector and adds them to the results
+	The store's mutex must be held by the caller
+*/
+func (db *MemoryDatabase) bulkDelete(collection_name string, operation BulkOperation, results *BulkWriteResults) error {
+	matches, err := db.findMatches(collection_name, operation.Selector)
+
+	if err != nil {
+		return err
+	}
+
+	if !operation.Multi && len(matches) > 1 {
+		matches = matches[:1]
+	}
+
+	db.removeIndices(collection_name, matches)
+	results.Deleted += len(matches)
+
+	return nil
+}
+
+/*
+	Runs the given aggregation pipeline on the collection and stores the output documents in result
+*/
+func (db *MemoryDatabase) Aggregate(collection_name string, pipeline interface{}, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	db.store.mutex.RLock()
+	defer db.store.mutex.RUnlock()
+
+	documents, err := runPipeline(db.store.collections[collection_name], pipeline)
+
+	if err != nil {
+		return err
+	}
+
+	return fromDocuments(documents, result)
+}
+
+/*
+	Returns a stream of the changes to items in the collection matching the filter
+	The collection is polled for changes, the same as MongoDatabase
+*/
+func (db *MemoryDatabase) Watch(collection_name string, filter interface{}) (*ChangeStream, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	return pollChanges(db.ctx, db, collection_name, filter)
+}
+
+/*
+	Runs fn, restoring every collection in the database to its prior state if fn returns an error
+	Transactions are run one at a time, and writes outside of a transaction wait for it to finish so that they are
+	never undone by an aborted transaction, but reads outside of a transaction can see its uncommitted writes
+*/
+func (db *MemoryDatabase) RunTransaction(fn func(tx Database) error) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	if db.in_transaction {
+		return fn(db)
+	}
+
+	db.store.transaction_mutex.Lock()
+	defer db.store.transaction_mutex.Unlock()
+
+	// Stored documents are never modified in place, so copying each collection is sufficient
+	db.store.mutex.RLock()
+	collections := make(map[string][]bson.M)
+	for collection_name, documents := range db.store.collections {
+		collections[collection_name] = append([]bson.M{}, documents...)
+	}
+	indexes := make(map[string][]Index)
+	for collection_name, collection_indexes := range db.store.indexes {
+		indexes[collection_name] = append([]Index{}, collection_indexes...)
+	}
+	db.store.mutex.RUnlock()
+
+	tx := &MemoryDatabase{
+		store:          db.store,
+		name:           db.name,
+		ctx:            db.ctx,
+		in_transaction: true,
+	}
+
+	err := fn(tx)
+
+	if err != nil {
+		db.store.mutex.Lock()
+		db.store.collections = collections
+		db.store.indexes = indexes
+		db.store.mutex.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+/*
+	Waits for any transaction to finish before a write outside of it, and holds off transactions until the write is done
+	Returns the function which must be called once the write is done
+*/
+func (db *MemoryDatabase) lockWrite() func() {
+	if db.in_transaction {
+		return func() {}
+	}
+
+	db.store.transaction_mutex.Lock()
+
+	return db.store.transaction_mutex.Unlock
+}
+
+/*
+	Returns a map of statistics for a given collection
+	The statistics are computed with aggregation pipelines, the same as MongoDatabase
+*/
+func (db *MemoryDatabase) GetStats(collection_name string, fields []string) (map[string]interface{}, error) {
+	return GetAggregatedStats(db, collection_name, fields)
+}
+
+/*
+	Converts the given item into a freshly allocated bson document
+	This respects bson tags as well as the bson Getter interface, the same as the mongo driver
+*/
+func toDocument(item interface{}) (bson.M, error) {
+	document := bson.M{}
+
+	if item == nil {
+		return document, nil
+	}
+
+	if value := reflect.ValueOf(item); value.Kind() == reflect.Ptr && value.IsNil() {
+		return document, nil
+	}
+
+	raw, err := bson.Marshal(item)
+
+	if err != nil {
+		return nil, ErrUnknown
+	}
+
+	err = bson.Unmarshal(raw, &document)
+
+	if err != nil {
+		return nil, ErrUnknown
+	}
+
+	return document, nil
+}
+
+/*
+	Decodes the given bson document into result
+	This respects bson tags as well as the bson Setter interface, the same as the mongo driver
+*/
+func fromDocument(document bson.M, result interface{}) error {
+	raw, err := bson.Marshal(document)
+
+	if err != nil {
+		return ErrUnknown
+	}
+
+	err = bson.Unmarshal(raw, result)
+
+	if err != nil {
+		return ErrUnknown
+	}
+
+	return nil
+}
+
+/*
+	Decodes the given bson documents into result, which must be a pointer to a slice
+*/
+func fromDocuments(documents []bson.M, result interface{}) error {
+	result_value := reflect.ValueOf(result)
+
+	if result_value.Kind() != reflect.Ptr || result_value.Elem().Kind() != reflect.Slice {
+		return ErrUnknown
+	}
+
+	slice_value := result_value.Elem().Slice(0, 0)
+	element_type := slice_value.Type().Elem()
+
+	for _, document := range documents {
+		element := reflect.New(element_type)
+
+		err := fromDocument(document, element.Interface())
+
+		if err != nil {
+			return err
+		}
+
+		slice_value = reflect.Append(slice_value, element.Elem())
+	}
+
+	result_value.Elem().Set(slice_value)
+
+	return nil
+}
+
+/*
+	Sorts the documents in place based on the given sort fields
+	The first sort field is highest priority, each subsequent field breaks ties
+*/
+func sortDocuments(documents []bson.M, sort_fields []SortField) {
+	if len(sort_fields) == 0 {
+		return
+	}
+
+	sort.SliceStable(documents, func(i, j int) bool {
+		for _, field := range sort_fields {
+			// Like the mongo driver, a leading '-' on the name also reverses the sort
+			name := strings.TrimPrefix(field.Name, "+")
+			reversed := field.Reversed
+
+			if strings.HasPrefix(name, "-") {
+				name = name[1:]
+				reversed = !reversed
+			}
+
+			value_i, _ := lookupField(documents[i], name)
+			value_j, _ := lookupField(documents[j], name)
+
+			ordering := compareOrdered(value_i, value_j)
+
+			if ordering == 0 {
+				continue
+			}
+
+			if reversed {
+				return ordering > 0
+			}
+
+			return ordering < 0
+		}
+
+		return false
+	})
+}
@@ -0,0 +1,1008 @@
+package database
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+	Returns true if the given document matches every condition in the query
+	Supports the subset of the mongo query language used by the services
+*/
+func matchesQuery(document bson.M, query bson.M) (bool, error) {
+	for key, condition := range query {
+		var is_match bool
+		var err error
+
+		switch key {
+		case "$and", "$or", "$nor":
+			is_match, err = matchesLogical(document, key, condition)
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, ErrUnknown
+			}
+
+			value, exists := lookupField(document, key)
+			is_match, err = matchesCondition(value, exists, condition)
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		if !is_match {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+/*
+	Evaluates a $and, $or, or $nor clause against the given document
+*/
+func matchesLogical(document bson.M, operator string, condition interface{}) (bool, error) {
+	clauses, ok := condition.([]interface{})
+
+	if !ok || len(clauses) == 0 {
+		return false, ErrUnknown
+	}
+
+	for _, clause := range clauses {
+		clause_query, ok := asDocument(clause)
+
+		if !ok {
+			return false, ErrUnknown
+		}
+
+		is_match, err := matchesQuery(document, clause_query)
+
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case operator == "$and" && !is_match:
+			return false, nil
+		case operator == "$or" && is_match:
+			return true, nil
+		case operator == "$nor" && is_match:
+			return false, nil
+		}
+	}
+
+	return operator != "$or", nil
+}
+
+/*
+	Returns true if the given field value satisfies the condition
+	The condition is either a document of operators or a value to test equality against
+*/
+func matchesCondition(value interface{}, exists bool, condition interface{}) (bool, error) {
+	operators, ok := asDocument(condition)
+
+	if !ok || !isOperatorDocument(operators) {
+		return matchesEquality(value, exists, condition), nil
+	}
+
+	for operator, operand := range operators {
+		is_match, err := matchesOperator(value, exists, operator, operand, operators)
+
+		if err != nil {
+			return false, err
+		}
+
+		if !is_match {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+/*
+	Returns true if the value equals the target, or if the value is an array containing the target
+*/
+func matchesEquality(value interface{}, exists bool, target interface{}) bool {
+	if target == nil {
+		return !exists || value == nil
+	}
+
+	if !exists {
+		return false
+	}
+
+	if valuesEqual(value, target) {
+		return true
+	}
+
+	if elements, ok := value.([]interface{}); ok {
+		for _, element := range elements {
+			if valuesEqual(element, target) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+	Evaluates a single query operator against the given field value
+*/
+func matchesOperator(value interface{}, exists bool, operator string, operand interface{}, operators bson.M) (bool, error) {
+	switch operator {
+	case "$eq":
+		return matchesEquality(value, exists, operand), nil
+	case "$ne":
+		return !matchesEquality(value, exists, operand), nil
+	case "$in", "$nin":
+		targets, ok := operand.([]interface{})
+
+		if !ok {
+			return false, ErrUnknown
+		}
+
+		is_in := false
+		for _, target := range targets {
+			if matchesEquality(value, exists, target) {
+				is_in = true
+				break
+			}
+		}
+
+		return is_in == (operator == "$in"), nil
+	case "$all":
+		targets, ok := operand.([]interface{})
+
+		if !ok {
+			return false, ErrUnknown
+		}
+
+		if len(targets) == 0 {
+			return false, nil
+		}
+
+		for _, target := range targets {
+			if !matchesEquality(value, exists, target) {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	case "$lt", "$lte", "$gt", "$gte":
+		if !exists {
+			return false, nil
+		}
+
+		for _, candidate := range candidateValues(value) {
+			ordering, comparable := compareValues(candidate, operand)
+
+			if !comparable {
+				continue
+			}
+
+			if (operator == "$lt" && ordering < 0) ||
+				(operator == "$lte" && ordering <= 0) ||
+				(operator == "$gt" && ordering > 0) ||
+				(operator == "$gte" && ordering >= 0) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	case "$exists":
+		should_exist, ok := operand.(bool)
+
+		if !ok {
+			number, is_number := toFloat64(operand)
+
+			if !is_number {
+				return false, ErrUnknown
+			}
+
+			should_exist = number != 0
+		}
+
+		return exists == should_exist, nil
+	case "$not":
+		is_match, err := matchesCondition(value, exists, operand)
+		return !is_match, err
+	case "$regex":
+		options, _ := operators["$options"].(string)
+
+		pattern, ok := operand.(string)
+
+		if !ok {
+			regex, is_regex := operand.(bson.RegEx)
+
+			if !is_regex {
+				return false, ErrUnknown
+			}
+
+			pattern = regex.Pattern
+			options = options + regex.Options
+		}
+
+		return matchesRegex(value, exists, pattern, options)
+	case "$options":
+		return true, nil
+	case "$size":
+		size, ok := toFloat64(operand)
+		elements, is_array := value.([]interface{})
+
+		if !ok {
+			return false, ErrUnknown
+		}
+
+		return is_array && float64(len(elements)) == size, nil
+	case "$elemMatch":
+		sub_query, ok := asDocument(operand)
+		elements, is_array := value.([]interface{})
+
+		if !ok {
+			return false, ErrUnknown
+		}
+
+		if !is_array {
+			return false, nil
+		}
+
+		for _, element := range elements {
+			is_match, err := matchesElement(element, sub_query)
+
+			if err != nil {
+				return false, err
+			}
+
+			if is_match {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	default:
+		return false, ErrUnknown
+	}
+}
+
+/*
+	Returns true if the array element matches the given condition
+	Document elements are matched as sub-queries, all other elements are matched as values
+*/
+func matchesElement(element interface{}, condition bson.M) (bool, error) {
+	if isOperatorDocument(condition) {
+		return matchesCondition(element, true, condition)
+	}
+
+	element_document, ok := asDocument(element)
+
+	if !ok {
+		return false, nil
+	}
+
+	return matchesQuery(element_document, condition)
+}
+
+/*
+	Returns true if the value, or any element of the value, is a string matching the pattern
+*/
+func matchesRegex(value interface{}, exists bool, pattern string, options string) (bool, error) {
+	if !exists {
+		return false, nil
+	}
+
+	flags := ""
+	for _, option := range []string{"i", "m", "s"} {
+		if strings.Contains(options, option) {
+			flags += option
+		}
+	}
+
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	regex, err := regexp.Compile(pattern)
+
+	if err != nil {
+		return false, ErrUnknown
+	}
+
+	for _, candidate := range candidateValues(value) {
+		str, ok := candidate.(string)
+
+		if ok && regex.MatchString(str) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+/*
+	Returns the value itself along with each of its elements if it is an array
+*/
+func candidateValues(value interface{}) []interface{} {
+	candidates := []interface{}{value}
+
+	if elements, ok := value.([]interface{}); ok {
+		candidates = append(candidates, elements...)
+	}
+
+	return candidates
+}
+
+/*
+	Returns the value at the given dotted path for use in queries
+	When the path passes through an array of documents, the values from each document are collected into an array
+*/
+func lookupField(document bson.M, path string) (interface{}, bool) {
+	var current interface{} = document
+
+	for _, part := range strings.Split(path, ".") {
+		switch value := current.(type) {
+		case bson.M, map[string]interface{}:
+			sub_document, _ := asDocument(value)
+			next, exists := sub_document[part]
+
+			if !exists {
+				return nil, false
+			}
+
+			current = next
+		case []interface{}:
+			if index, err := strconv.Atoi(part); err == nil {
+				if index < 0 || index >= len(value) {
+					return nil, false
+				}
+
+				current = value[index]
+				continue
+			}
+
+			collected := []interface{}{}
+			for _, element := range value {
+				sub_document, ok := asDocument(element)
+
+				if !ok {
+					continue
+				}
+
+				if next, exists := sub_document[part]; exists {
+					collected = append(collected, next)
+				}
+			}
+
+			if len(collected) == 0 {
+				return nil, false
+			}
+
+			current = collected
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+/*
+	Returns the value at the given dotted path for use in updates
+	Unlike lookupField, arrays may only be traversed using numeric indices
+*/
+func getField(document bson.M, path string) (interface{}, bool) {
+	var current interface{} = document
+
+	for _, part := range strings.Split(path, ".") {
+		switch value := current.(type) {
+		case bson.M, map[string]interface{}:
+			sub_document, _ := asDocument(value)
+			next, exists := sub_document[part]
+
+			if !exists {
+				return nil, false
+			}
+
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+
+			if err != nil || index < 0 || index >= len(value) {
+				return nil, false
+			}
+
+			current = value[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+/*
+	Sets the value at the given dotted path, creating intermediate documents as needed
+*/
+func setField(document bson.M, path string, value interface{}) error {
+	parts := strings.Split(path, ".")
+	var current interface{} = document
+
+	for i, part := range parts {
+		is_last := i == len(parts)-1
+
+		switch container := current.(type) {
+		case bson.M, map[string]interface{}:
+			sub_document, _ := asDocument(container)
+
+			if is_last {
+				sub_document[part] = value
+				return nil
+			}
+
+			next, exists := sub_document[part]
+
+			if !exists || next == nil {
+				next = bson.M{}
+				sub_document[part] = next
+			}
+
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+
+			if err != nil || index < 0 || index >= len(container) {
+				return ErrUnknown
+			}
+
+			if is_last {
+				container[index] = value
+				return nil
+			}
+
+			current = container[index]
+		default:
+			return ErrUnknown
+		}
+	}
+
+	return nil
+}
+
+/*
+	Removes the value at the given dotted path if it exists
+*/
+func unsetField(document bson.M, path string) {
+	parts := strings.Split(path, ".")
+
+	var parent interface{} = document
+	exists := true
+
+	if len(parts) > 1 {
+		parent, exists = getField(document, strings.Join(parts[:len(parts)-1], "."))
+	}
+
+	if !exists {
+		return
+	}
+
+	if parent_document, ok := asDocument(parent); ok {
+		delete(parent_document, parts[len(parts)-1])
+	}
+}
+
+/*
+	Applies the given update to a copy of the document and returns the copy
+	Updates without operators replace the document entirely, preserving its _id
+*/
+func applyUpdate(document bson.M, update bson.M) (bson.M, error) {
+	has_operators := false
+	for key := range update {
+		if strings.HasPrefix(key, "$") {
+			has_operators = true
+		} else if has_operators {
+			return nil, ErrUnknown
+		}
+	}
+
+	if !has_operators {
+		if len(update) > 0 && isOperatorDocument(update) {
+			return nil, ErrUnknown
+		}
+
+		replacement, err := toDocument(update)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if id, exists := document["_id"]; exists {
+			replacement["_id"] = id
+		}
+
+		return replacement, nil
+	}
+
+	updated, err := toDocument(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for operator, fields := range update {
+		field_updates, ok := asDocument(fields)
+
+		if !ok || !strings.HasPrefix(operator, "$") {
+			return nil, ErrUnknown
+		}
+
+		for path, value := range field_updates {
+			err = applyUpdateOperator(updated, operator, path, value)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return toDocument(updated)
+}
+
+/*
+	Returns a copy of the document including only _id and the fields in projection
+	An empty projection includes every field
+*/
+func projectDocument(document bson.M, projection []string) bson.M {
+	if len(projection) == 0 {
+		return document
+	}
+
+	projected := bson.M{}
+
+	if id, exists := document["_id"]; exists {
+		projected["_id"] = id
+	}
+
+	for _, path := range projection {
+		if value, exists := getField(document, path); exists {
+			setField(projected, path, value)
+		}
+	}
+
+	return projected
+}
+
+/*
+	Applies a single update operator to the field at the given path
+*/
+func applyUpdateOperator(document bson.M, operator string, path string, value interface{}) error {
+	switch operator {
+	case "$set":
+		return setField(document, path, value)
+	case "$unset":
+		unsetField(document, path)
+		return nil
+	case "$inc":
+		current, exists := getField(document, path)
+
+		if !exists {
+			current = 0
+		}
+
+		sum, ok := addNumbers(current, value)
+
+		if !ok {
+			return ErrUnknown
+		}
+
+		return setField(document, path, sum)
+	case "$push", "$addToSet":
+		elements, err := getArrayField(document, path)
+
+		if err != nil {
+			return err
+		}
+
+		for _, element := range eachValues(value) {
+			if operator == "$addToSet" && containsValue(elements, element) {
+				continue
+			}
+
+			elements = append(elements, element)
+		}
+
+		return setField(document, path, elements)
+	case "$pull":
+		elements, err := getArrayField(document, path)
+
+		if err != nil {
+			return err
+		}
+
+		condition, is_document := asDocument(value)
+		remaining := []interface{}{}
+
+		for _, element := range elements {
+			is_match := valuesEqual(element, value)
+
+			if is_document && !is_match {
+				is_match, err = matchesElement(element, condition)
+
+				if err != nil {
+					return err
+				}
+			}
+
+			if !is_match {
+				remaining = append(remaining, element)
+			}
+		}
+
+		return setField(document, path, remaining)
+	default:
+		return ErrUnknown
+	}
+}
+
+/*
+	Returns the array at the given path, or an empty array if the field does not exist
+*/
+func getArrayField(document bson.M, path string) ([]interface{}, error) {
+	current, exists := getField(document, path)
+
+	if !exists || current == nil {
+		return []interface{}{}, nil
+	}
+
+	elements, ok := current.([]interface{})
+
+	if !ok {
+		return nil, ErrUnknown
+	}
+
+	return elements, nil
+}
+
+/*
+	Returns the values to add for a $push or $addToSet, expanding $each modifiers
+*/
+func eachValues(value interface{}) []interface{} {
+	modifier, ok := asDocument(value)
+
+	if ok {
+		if elements, has_each := modifier["$each"].([]interface{}); has_each {
+			return elements
+		}
+	}
+
+	return []interface{}{value}
+}
+
+/*
+	Builds the document inserted by an upsert when no existing document matches the selector
+	Equality conditions in the selector are copied into the new document before the update is applied
+*/
+func createUpsertDocument(selector bson.M, update bson.M) (bson.M, error) {
+	document := bson.M{}
+
+	for key, condition := range selector {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+
+		operators, is_document := asDocument(condition)
+
+		if is_document && isOperatorDocument(operators) {
+			equal_value, has_equal := operators["$eq"]
+
+			if !has_equal {
+				continue
+			}
+
+			condition = equal_value
+		}
+
+		err := setField(document, key, condition)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := applyUpdate(document, update)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, exists := updated["_id"]; !exists {
+		updated["_id"] = bson.NewObjectId()
+	}
+
+	return updated, nil
+}
+
+/*
+	Returns the value as a document if it is one
+*/
+func asDocument(value interface{}) (bson.M, bool) {
+	switch document := value.(type) {
+	case bson.M:
+		return document, true
+	case map[string]interface{}:
+		return bson.M(document), true
+	case QuerySelector:
+		return bson.M(document), true
+	default:
+		return nil, false
+	}
+}
+
+/*
+	Returns true if the document is non-empty and all of its keys are operators
+*/
+func isOperatorDocument(document bson.M) bool {
+	if len(document) == 0 {
+		return false
+	}
+
+	for key := range document {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+	Returns true if any element in the slice is equal to the value
+*/
+func containsValue(elements []interface{}, value interface{}) bool {
+	for _, element := range elements {
+		if valuesEqual(element, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Returns the value as a float64 if it is numeric
+*/
+func toFloat64(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float32:
+		return float64(number), true
+	case float64:
+		return number, true
+	default:
+		return 0, false
+	}
+}
+
+/*
+	Adds two numeric values, preserving integer types when neither value is a float
+*/
+func addNumbers(a interface{}, b interface{}) (interface{}, bool) {
+	a_float, a_ok := toFloat64(a)
+	b_float, b_ok := toFloat64(b)
+
+	if !a_ok || !b_ok {
+		return nil, false
+	}
+
+	a_int, a_is_int := toInt64(a)
+	b_int, b_is_int := toInt64(b)
+
+	if !a_is_int || !b_is_int {
+		return a_float + b_float, true
+	}
+
+	_, a_is_int64 := a.(int64)
+	_, b_is_int64 := b.(int64)
+
+	if a_is_int64 || b_is_int64 {
+		return a_int + b_int, true
+	}
+
+	return int(a_int + b_int), true
+}
+
+/*
+	Returns the given value as an int64 if it is an integer type
+*/
+func toInt64(value interface{}) (int64, bool) {
+	switch number := value.(type) {
+	case int:
+		return int64(number), true
+	case int32:
+		return int64(number), true
+	case int64:
+		return number, true
+	default:
+		return 0, false
+	}
+}
+
+/*
+	Returns true if the two values are equal, treating all numeric types as comparable
+*/
+func valuesEqual(a interface{}, b interface{}) bool {
+	a_number, a_is_number := toFloat64(a)
+	b_number, b_is_number := toFloat64(b)
+
+	if a_is_number || b_is_number {
+		return a_is_number && b_is_number && a_number == b_number
+	}
+
+	a_document, a_is_document := asDocument(a)
+	b_document, b_is_document := asDocument(b)
+
+	if a_is_document || b_is_document {
+		if !a_is_document || !b_is_document || len(a_document) != len(b_document) {
+			return false
+		}
+
+		for key, a_value := range a_document {
+			b_value, exists := b_document[key]
+
+			if !exists || !valuesEqual(a_value, b_value) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	a_elements, a_is_array := a.([]interface{})
+	b_elements, b_is_array := b.([]interface{})
+
+	if a_is_array || b_is_array {
+		if !a_is_array || !b_is_array || len(a_elements) != len(b_elements) {
+			return false
+		}
+
+		for i := range a_elements {
+			if !valuesEqual(a_elements[i], b_elements[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+/*
+	Compares two values of the same kind, returning whether they could be compared
+	Values of different kinds are not comparable, matching mongo's type bracketing for range queries
+*/
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	a_number, a_is_number := toFloat64(a)
+	b_number, b_is_number := toFloat64(b)
+
+	if a_is_number && b_is_number {
+		switch {
+		case a_number < b_number:
+			return -1, true
+		case a_number > b_number:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	switch a_value := a.(type) {
+	case string:
+		b_value, ok := b.(string)
+
+		if !ok {
+			return 0, false
+		}
+
+		return strings.Compare(a_value, b_value), true
+	case bool:
+		b_value, ok := b.(bool)
+
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case a_value == b_value:
+			return 0, true
+		case !a_value:
+			return -1, true
+		default:
+			return 1, true
+		}
+	case time.Time:
+		b_value, ok := b.(time.Time)
+
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case a_value.Before(b_value):
+			return -1, true
+		case a_value.After(b_value):
+			return 1, true
+		default:
+			return 0, true
+		}
+	case bson.ObjectId:
+		b_value, ok := b.(bson.ObjectId)
+
+		if !ok {
+			return 0, false
+		}
+
+		return strings.Compare(string(a_value), string(b_value)), true
+	}
+
+	return 0, false
+}
+
+/*
+	Returns the rank of the value's type in mongo's sort order
+*/
+func typeRank(value interface{}) int {
+	if _, ok := toFloat64(value); ok {
+		return 2
+	}
+
+	switch value.(type) {
+	case nil:
+		return 1
+	case string:
+		return 3
+	case bson.M, map[string]interface{}:
+		return 4
+	case []interface{}:
+		return 5
+	case []byte:
+		return 6
+	case bson.ObjectId:
+		return 7
+	case bool:
+		return 8
+	case time.Time:
+		return 9
+	default:
+		return 10
+	}
+}
+
+/*
+	Totally orders two values for sorting, first by type and then by value
+*/
+func compareOrdered(a interface{}, b interface{}) int {
+	a_rank := typeRank(a)
+	b_rank := typeRank(b)
+
+	if a_rank != b_rank {
+		if a_rank < b_rank {
+			return -1
+		}
+		return 1
+	}
+
+	ordering, _ := compareValues(a, b)
+
+	return ordering
+}
@@ -0,0 +1,274 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+	The collection in each database which records the versions of the migrations applied to it
+*/
+const MigrationsCollection = "migrations"
+
+/*
+	Describes a change to the documents in a service's database
+	Up applies the change and Down reverses it, and a nil Down marks the migration as irreversible
+	Migrations should be safe to run again if they fail part way through
+*/
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(db Database) error
+	Down        func(db Database) error
+}
+
+/*
+	An alias of a list of migrations used by services to register their migrations in order of version
+*/
+type Migrations []Migration
+
+/*
+	Records a migration which has been applied to a database
+*/
+type MigrationRecord struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	AppliedAt   int64  `json:"appliedAt"`
+}
+
+/*
+	Describes how migrations should be run
+	If Rollback is set, applied migrations with a version after TargetVersion are rolled back, otherwise
+	pending migrations are applied
+	If DryRun is set, the migrations which would be run are returned without being run
+*/
+type MigrationOptions struct {
+	DryRun        bool
+	Rollback      bool
+	TargetVersion int
+}
+
+/*
+	Applies pending migrations or rolls back applied migrations as described by options
+	Returns the migrations which were run, in the order they were run
+*/
+func Migrate(db Database, migrations Migrations, options MigrationOptions) ([]Migration, error) {
+	err := validateMigrations(migrations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.EnsureIndex(MigrationsCollection, Index{Key: []string{"version"}, Unique: true})
+
+	if err != nil {
+		return nil, err
+	}
+
+	applied_migrations, err := GetAppliedMigrations(db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Rollback {
+		return rollbackMigrations(db, migrations, applied_migrations, options)
+	}
+
+	return applyMigrations(db, migrations, applied_migrations, options)
+}
+
+/*
+	Returns the records of the migrations applied to the database in order of version
+*/
+func GetAppliedMigrations(db Database) ([]MigrationRecord, error) {
+	applied_migrations := []MigrationRecord{}
+	err := db.FindAllSorted(MigrationsCollection, nil, []SortField{{Name: "version"}}, &applied_migrations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return applied_migrations, nil
+}
+
+/*
+	Applies every registered migration which has not been applied to the database, in order of version
+*/
+func applyMigrations(db Database, migrations Migrations, applied_migrations []MigrationRecord, options MigrationOptions) ([]Migration, error) {
+	run_migrations := []Migration{}
+
+	for _, migration := range migrations {
+		if isMigrationApplied(applied_migrations, migration.Version) {
+			continue
+		}
+
+		if !options.DryRun {
+			err := migration.Up(db)
+
+			if err != nil {
+				return run_migrations, fmt.Errorf("Migration %d failed: %v", migration.Version, err)
+			}
+
+			err = db.Insert(MigrationsCollection, MigrationRecord{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now().Unix(),
+			})
+
+			if err != nil {
+				return run_migrations, err
+			}
+		}
+
+		run_migrations = append(run_migrations, migration)
+	}
+
+	return run_migrations, nil
+}
+
+/*
+	Rolls back every applied migration with a version after the target version, in reverse order of version
+*/
+func rollbackMigrations(db Database, migrations Migrations, applied_migrations []MigrationRecord, options MigrationOptions) ([]Migration, error) {
+	rollback_migrations := []Migration{}
+
+	for i := len(applied_migrations) - 1; i >= 0; i-- {
+		if applied_migrations[i].Version <= options.TargetVersion {
+			break
+		}
+
+		migration, exists := getMigration(migrations, applied_migrations[i].Version)
+
+		if !exists {
+			return nil, fmt.Errorf("Applied migration %d is not registered", applied_migrations[i].Version)
+		}
+
+		if migration.Down == nil {
+			return nil, fmt.Errorf("Migration %d cannot be rolled back", migration.Version)
+		}
+
+		rollback_migrations = append(rollback_migrations, migration)
+	}
+
+	if options.DryRun {
+		return rollback_migrations, nil
+	}
+
+	run_migrations := []Migration{}
+
+	for _, migration := range rollback_migrations {
+		err := migration.Down(db)
+
+		if err != nil {
+			return run_migrations, fmt.Errorf("Rollback of migration %d failed: %v", migration.Version, err)
+		}
+
+		err = db.RemoveOne(MigrationsCollection, QuerySelector{"version": migration.Version})
+
+		if err != nil {
+			return run_migrations, err
+		}
+
+		run_migrations = append(run_migrations, migration)
+	}
+
+	return run_migrations, nil
+}
+
+/*
+	Sets each field to its default value on the items in the collection which do not have the field
+	This is used by migrations for fields added to existing models
+*/
+func BackfillFields(db Database, collection_name string, defaults QuerySelector) error {
+	for field, value := range defaults {
+		selector := QuerySelector{
+			field: QuerySelector{"$exists": false},
+		}
+
+		update := QuerySelector{
+			"$set": QuerySelector{field: value},
+		}
+
+		_, err := db.UpdateAll(collection_name, selector, update)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Removes each field from the items in the collection where it has its default value
+	Items decode missing fields to their default values, so this does not change any item as seen by the service
+	This is not limited to the items which BackfillFields changed, so it also removes the fields from items which were
+	created or edited with their default values after the backfill, and rolling back a backfill is destructive for them
+*/
+func UnsetDefaultFields(db Database, collection_name string, defaults QuerySelector) error {
+	for field, value := range defaults {
+		selector := QuerySelector{
+			field: value,
+		}
+
+		update := QuerySelector{
+			"$unset": QuerySelector{field: ""},
+		}
+
+		_, err := db.UpdateAll(collection_name, selector, update)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns an error if the migrations are not registered in increasing order of positive version
+*/
+func validateMigrations(migrations Migrations) error {
+	for i, migration := range migrations {
+		if migration.Version <= 0 {
+			return fmt.Errorf("Migration %d must have a positive version", migration.Version)
+		}
+
+		if migration.Up == nil {
+			return fmt.Errorf("Migration %d has no Up function", migration.Version)
+		}
+
+		if i > 0 && migrations[i-1].Version >= migration.Version {
+			return fmt.Errorf("Migration %d is not registered after migration %d", migration.Version, migrations[i-1].Version)
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns true if the migration with the given version has been applied
+*/
+func isMigrationApplied(applied_migrations []MigrationRecord, version int) bool {
+	for _, applied_migration := range applied_migrations {
+		if applied_migration.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Returns the registered migration with the given version
+*/
+func getMigration(migrations Migrations, version int) (Migration, bool) {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+
+	return Migration{}, false
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/HackIllinois/api/common/config"
 	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 /*
@@ -16,13 +18,16 @@ import (
 type MongoDatabase struct {
 	global_session *mgo.Session
 	name           string
+	ctx            context.Context
 }
 
 /*
 	Initialize connection to mongo database
 */
 func InitMongoDatabase(host string, db_name string) (*MongoDatabase, error) {
-	db := MongoDatabase{}
+	db := MongoDatabase{
+		ctx: context.Background(),
+	}
 	err := db.Connect(host)
 
 	if err != nil {
@@ -72,39 +77,120 @@ func (db *MongoDatabase) Close() {
 	db.global_session.Close()
 }
 
+/*
+	Returns a copy of the database which performs every operation within the given context
+	mgo cannot interrupt an operation once it has been sent, so operations are not started once the
+	context is done, and in flight operations are limited to the context's deadline by socket timeouts
+	and, for queries, by the server
+	Close should only be called on the database returned by InitMongoDatabase
+*/
+func (db *MongoDatabase) WithContext(ctx context.Context) Database {
+	return &MongoDatabase{
+		global_session: db.global_session,
+		name:           db.name,
+		ctx:            ctx,
+	}
+}
+
 /*
 	Returns a copy of the global session for use by a connection
+	The session's socket timeout is limited to the deadline of the database's context
 */
 func (db *MongoDatabase) GetSession() *mgo.Session {
-	return db.global_session.Copy()
+	session := db.global_session.Copy()
+
+	if max_time := db.getMaxTime(); max_time > 0 {
+		session.SetSocketTimeout(max_time)
+	}
+
+	return session
+}
+
+/*
+	Returns the time remaining until the deadline of the database's context, or 0 if there is no deadline
+	This is sent with queries so that the server stops running them once the deadline is exceeded
+*/
+func (db *MongoDatabase) getMaxTime() time.Duration {
+	deadline, has_deadline := db.ctx.Deadline()
+
+	if !has_deadline {
+		return 0
+	}
+
+	max_time := time.Until(deadline)
+
+	// A max time of 0 means no limit, so operations which race the deadline are given the minimum time
+	if max_time < time.Millisecond {
+		return time.Millisecond
+	}
+
+	return max_time
+}
+
+/*
+	Converts internal mgo errors to external presented errors
+	Errors caused by the database's context being done are reported as the context's error
+*/
+func (db *MongoDatabase) convertError(err error) error {
+	if err != nil && db.ctx.Err() != nil {
+		return convertContextError(db.ctx.Err())
+	}
+
+	return convertMgoError(err)
 }
 
 /*
 	Find one element matching the given query parameters
 */
 func (db *MongoDatabase) FindOne(collection_name string, query interface{}, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
 	collection := current_session.DB(db.name).C(collection_name)
 
-	err := collection.Find(query).One(result)
+	err := collection.Find(query).SetMaxTime(db.getMaxTime()).One(result)
 
-	return convertMgoError(err)
+	return db.convertError(err)
+}
+
+/*
+	Find one element matching the given query parameters, including only the fields in projection
+*/
+func (db *MongoDatabase) FindOneProjected(collection_name string, query interface{}, projection []string, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	current_session := db.GetSession()
+	defer current_session.Close()
+
+	collection := current_session.DB(db.name).C(collection_name)
+
+	err := collection.Find(query).Select(getMgoProjection(projection)).SetMaxTime(db.getMaxTime()).One(result)
+
+	return db.convertError(err)
 }
 
 /*
 	Find all elements matching the given query parameters
 */
 func (db *MongoDatabase) FindAll(collection_name string, query interface{}, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
 	collection := current_session.DB(db.name).C(collection_name)
 
-	err := collection.Find(query).All(result)
+	err := collection.Find(query).SetMaxTime(db.getMaxTime()).All(result)
 
-	return convertMgoError(err)
+	return db.convertError(err)
 }
 
 /*
@@ -112,9 +198,121 @@ func (db *MongoDatabase) FindAll(collection_name string, query interface{}, resu
         The first sort field is highest priority, each subsequent field breaks ties
 */
 func (db *MongoDatabase) FindAllSorted(collection_name string, query interface{}, sort_fields []SortField, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	current_session := db.GetSession()
+	defer current_session.Close()
+
+	collection := current_session.DB(db.name).C(collection_name)
+
+	err := collection.Find(query).SetMaxTime(db.getMaxTime()).Sort(getMgoSortFields(sort_fields)...).All(result)
+
+	return db.convertError(err)
+}
+
+/*
+	Find the page of elements matching the given query parameters described by pagination, sorted by the given sort fields
+	Ties, and elements when no sort fields are given, are ordered by _id so that pages do not overlap
+	Returns the total number of elements matching the query, ignoring pagination
+*/
+func (db *MongoDatabase) FindAllPaginated(collection_name string, query interface{}, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	return db.FindAllProjected(collection_name, query, nil, sort_fields, pagination, result)
+}
+
+/*
+	Find the page of elements matching the given query parameters described by pagination, sorted by the given sort fields,
+	including only the fields in projection
+	Returns the total number of elements matching the query, ignoring pagination
+*/
+func (db *MongoDatabase) FindAllProjected(collection_name string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	current_session := db.GetSession()
+	defer current_session.Close()
+
+	collection := current_session.DB(db.name).C(collection_name)
+
+	mgo_query := collection.Find(query).SetMaxTime(db.getMaxTime())
+
+	total, err := mgo_query.Count()
+
+	if err != nil {
+		return nil, db.convertError(err)
+	}
+
+	mgo_query = mgo_query.Sort(getMgoSortFields(getPaginationSortFields(sort_fields))...)
+
+	err = mgo_query.Select(getMgoProjection(projection)).Skip(pagination.Skip).Limit(pagination.Limit).All(result)
+
+	if err != nil {
+		return nil, db.convertError(err)
+	}
+
+	pagination_results := PaginationResults{
+		Total: total,
+	}
+
+	return &pagination_results, nil
+}
+
+/*
+	Find the page of elements matching the given query parameters which contain the text in their text indexed fields,
+	including only the fields in projection
+	Results are ordered by relevance, unless sort fields are given
+	Returns the total number of elements matching the search, ignoring pagination
+*/
+func (db *MongoDatabase) FindAllText(collection_name string, text string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
+	collection := current_session.DB(db.name).C(collection_name)
+
+	mgo_query := collection.Find(getTextQuery(text, query)).SetMaxTime(db.getMaxTime())
+
+	total, err := mgo_query.Count()
+
+	if err != nil {
+		return nil, db.convertError(err)
+	}
+
+	selection := getMgoProjection(projection)
+
+	if len(sort_fields) > 0 {
+		mgo_query = mgo_query.Sort(getMgoSortFields(getPaginationSortFields(sort_fields))...)
+	} else {
+		// Older versions of mongo can only sort by relevance if it is also selected
+		if selection == nil {
+			selection = bson.M{}
+		}
+		selection[textScoreField] = bson.M{"$meta": "textScore"}
+		mgo_query = mgo_query.Sort("$textScore:"+textScoreField, "_id")
+	}
+
+	err = mgo_query.Select(selection).Skip(pagination.Skip).Limit(pagination.Limit).All(result)
+
+	if err != nil {
+		return nil, db.convertError(err)
+	}
+
+	pagination_results := PaginationResults{
+		Total: total,
+	}
+
+	return &pagination_results, nil
+}
+
+/*
+	Converts the given sort fields into the format expected by mgo
+*/
+func getMgoSortFields(sort_fields []SortField) []string {
 	sort_fields_mgo := make([]string, len(sort_fields))
 	for i, field := range sort_fields {
 		if field.Reversed {
@@ -124,17 +322,71 @@ func (db *MongoDatabase) FindAllSorted(collection_name string, query interface{}
 		}
 	}
 
+	return sort_fields_mgo
+}
+
+/*
+	Converts the given projection into the format expected by mgo
+	Returns nil for an empty projection so that every field is selected
+*/
+func getMgoProjection(projection []string) bson.M {
+	if len(projection) == 0 {
+		return nil
+	}
+
+	mgo_projection := bson.M{}
+	for _, field := range projection {
+		mgo_projection[field] = 1
+	}
+
+	return mgo_projection
+}
+
+/*
+	Returns the number of items matching the given query parameters
+*/
+func (db *MongoDatabase) Count(collection_name string, query interface{}) (int, error) {
+	if err := db.ctx.Err(); err != nil {
+		return 0, convertContextError(err)
+	}
+
+	current_session := db.GetSession()
+	defer current_session.Close()
+
 	collection := current_session.DB(db.name).C(collection_name)
 
-	err := collection.Find(query).Sort(sort_fields_mgo...).All(result)
+	count, err := collection.Find(query).SetMaxTime(db.getMaxTime()).Count()
 
-	return convertMgoError(err)
+	return count, db.convertError(err)
+}
+
+/*
+	Stores the unique values of the field among the items matching the given query parameters in result
+	The elements of array fields are treated as separate values
+*/
+func (db *MongoDatabase) Distinct(collection_name string, field string, query interface{}, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	current_session := db.GetSession()
+	defer current_session.Close()
+
+	collection := current_session.DB(db.name).C(collection_name)
+
+	err := collection.Find(query).SetMaxTime(db.getMaxTime()).Distinct(field, result)
+
+	return db.convertError(err)
 }
 
 /*
 	Remove one element matching the given query parameters
 */
 func (db *MongoDatabase) RemoveOne(collection_name string, query interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
@@ -142,13 +394,17 @@ func (db *MongoDatabase) RemoveOne(collection_name string, query interface{}) er
 
 	err := collection.Remove(query)
 
-	return convertMgoError(err)
+	return db.convertError(err)
 }
 
 /*
 	Remove all elements matching the given query parameters
 */
 func (db *MongoDatabase) RemoveAll(collection_name string, query interface{}) (*ChangeResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
@@ -161,13 +417,17 @@ func (db *MongoDatabase) RemoveAll(collection_name string, query interface{}) (*
 		Deleted: change_info.Removed,
 	}
 
-	return &change_results, convertMgoError(err)
+	return &change_results, db.convertError(err)
 }
 
 /*
 	Insert the given item into the collection
 */
 func (db *MongoDatabase) Insert(collection_name string, item interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
@@ -175,7 +435,7 @@ func (db *MongoDatabase) Insert(collection_name string, item interface{}) error
 
 	err := collection.Insert(item)
 
-	return convertMgoError(err)
+	return db.convertError(err)
 }
 
 /*
@@ -183,6 +443,10 @@ func (db *MongoDatabase) Insert(collection_name string, item interface{}) error
 	if the item exists, it is updated with the given values, else a new item with those values is created.
 */
 func (db *MongoDatabase) Upsert(collection_name string, selector interface{}, update interface{}) (*ChangeResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
@@ -195,13 +459,17 @@ func (db *MongoDatabase) Upsert(collection_name string, selector interface{}, up
 		Deleted: change_info.Removed,
 	}
 
-	return &change_results, convertMgoError(err)
+	return &change_results, db.convertError(err)
 }
 
 /*
 	Finds an item based on the given selector and updates it with the data in update
 */
 func (db *MongoDatabase) Update(collection_name string, selector interface{}, update interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
@@ -209,13 +477,17 @@ func (db *MongoDatabase) Update(collection_name string, selector interface{}, up
 
 	err := collection.Update(selector, update)
 
-	return convertMgoError(err)
+	return db.convertError(err)
 }
 
 /*
 	Finds all items based on the given selector and updates them with the data in update
 */
 func (db *MongoDatabase) UpdateAll(collection_name string, selector interface{}, update interface{}) (*ChangeResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
@@ -228,60 +500,327 @@ func (db *MongoDatabase) UpdateAll(collection_name string, selector interface{},
 		Deleted: change_info.Removed,
 	}
 
-	return &change_results, convertMgoError(err)
+	return &change_results, db.convertError(err)
+}
+
+/*
+	Atomically finds an item based on the given selector, updates it with the data in update, and stores the updated item in result
+	If upsert is true and no item matches the selector, a new item is created
+	This should be used with update operators such as $inc to avoid lost updates from concurrent read-modify-write cycles
+*/
+func (db *MongoDatabase) FindAndModify(collection_name string, selector interface{}, update interface{}, upsert bool, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	current_session := db.GetSession()
+	defer current_session.Close()
+
+	collection := current_session.DB(db.name).C(collection_name)
+
+	change := mgo.Change{
+		Update:    update,
+		Upsert:    upsert,
+		ReturnNew: true,
+	}
+
+	_, err := collection.Find(selector).Apply(change, result)
+
+	return db.convertError(err)
+}
+
+/*
+	Atomically updates the item matching the given selector, but only if it also matches the given condition
+	Returns false if the item exists but does not match the condition, and ErrNotFound if no item matches the selector
+*/
+func (db *MongoDatabase) UpdateIfMatches(collection_name string, selector interface{}, condition interface{}, update interface{}) (bool, error) {
+	if err := db.ctx.Err(); err != nil {
+		return false, convertContextError(err)
+	}
+
+	current_session := db.GetSession()
+	defer current_session.Close()
+
+	collection := current_session.DB(db.name).C(collection_name)
+
+	conditional_selector := bson.M{
+		"$and": []interface{}{
+			getMgoSelector(selector),
+			getMgoSelector(condition),
+		},
+	}
+
+	err := collection.Update(conditional_selector, update)
+
+	if err == nil {
+		return true, nil
+	} else if err != mgo.ErrNotFound {
+		return false, db.convertError(err)
+	}
+
+	count, err := collection.Find(selector).SetMaxTime(db.getMaxTime()).Limit(1).Count()
+
+	if err != nil {
+		return false, db.convertError(err)
+	}
+
+	if count == 0 {
+		return false, ErrNotFound
+	}
+
+	return false, nil
+}
+
+/*
+	The response to the insert, update, and delete write commands
+*/
+type mgoWriteCommandResponse struct {
+	N        int `bson:"n"`
+	Upserted []struct {
+		Index int `bson:"index"`
+	} `bson:"upserted"`
+	WriteErrors []struct {
+		Index int `bson:"index"`
+	} `bson:"writeErrors"`
+}
+
+/*
+	Runs the given insert, update, upsert, and delete operations on the collection in order
+	Consecutive operations of the same kind are sent as a single write command
+	Every operation is attempted even if others fail, and ErrBulkWrite is returned if any of them failed
+	The outcome of each operation is stored in the returned results
+*/
+func (db *MongoDatabase) BulkWrite(collection_name string, operations []BulkOperation) (*BulkWriteResults, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	batches, err := getBulkBatches(operations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	current_session := db.GetSession()
+	defer current_session.Close()
+
+	results := newBulkWriteResults(len(operations))
+
+	for _, batch := range batches {
+		documents := []interface{}{}
+		for _, operation := range operations[batch.start:batch.end] {
+			documents = append(documents, getMgoBulkDocument(operation))
+		}
+
+		command := bson.D{
+			{Name: batch.command, Value: collection_name},
+			{Name: getMgoBulkDocumentsField(batch.command), Value: documents},
+			{Name: "ordered", Value: false},
+		}
+
+		var response mgoWriteCommandResponse
+		err := current_session.DB(db.name).Run(command, &response)
+
+		if err != nil {
+			return results, db.convertError(err)
+		}
+
+		for _, upserted := range response.Upserted {
+			results.Operations[batch.start+upserted.Index].Upserted = true
+		}
+
+		for _, write_error := range response.WriteErrors {
+			results.Operations[batch.start+write_error.Index].Err = ErrUnknown
+		}
+
+		switch batch.command {
+		case "insert":
+			results.Inserted += response.N
+		case "update":
+			results.Updated += response.N - len(response.Upserted)
+			results.Upserted += len(response.Upserted)
+		case "delete":
+			results.Deleted += response.N
+		}
+	}
+
+	return results, results.getError()
+}
+
+/*
+	Returns the document describing the operation in its write command
+*/
+func getMgoBulkDocument(operation BulkOperation) interface{} {
+	switch operation.Type {
+	case BulkUpdate, BulkUpsert:
+		return bson.M{
+			"q":      getMgoSelector(operation.Selector),
+			"u":      operation.Item,
+			"upsert": operation.Type == BulkUpsert,
+			"multi":  operation.Multi,
+		}
+	case BulkDelete:
+		limit := 1
+		if operation.Multi {
+			limit = 0
+		}
+
+		return bson.M{
+			"q":     getMgoSelector(operation.Selector),
+			"limit": limit,
+		}
+	}
+
+	return operation.Item
+}
+
+/*
+	Returns the name of the field holding the operations of the given write command
+*/
+func getMgoBulkDocumentsField(command string) string {
+	switch command {
+	case "update":
+		return "updates"
+	case "delete":
+		return "deletes"
+	}
+
+	return "documents"
+}
+
+/*
+	Returns the given selector, replacing nil with an empty selector which matches every item
+*/
+func getMgoSelector(selector interface{}) interface{} {
+	if selector == nil {
+		return bson.M{}
+	}
+
+	return selector
 }
 
 /*
 	Drops the entire database
 */
 func (db *MongoDatabase) DropDatabase() error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
 	err := current_session.DB(db.name).DropDatabase()
 
-	return convertMgoError(err)
+	return db.convertError(err)
 }
 
 /*
-	Returns a map of statistics for a given collection
+	Creates the given index on the collection if it does not already exist
+	Indexes are built in the background so that the collection remains available
 */
-func (db *MongoDatabase) GetStats(collection_name string, fields []string) (map[string]interface{}, error) {
+func (db *MongoDatabase) EnsureIndex(collection_name string, index Index) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
 	current_session := db.GetSession()
 	defer current_session.Close()
 
 	collection := current_session.DB(db.name).C(collection_name)
 
-	iter := collection.Find(nil).Iter()
+	err := collection.EnsureIndex(mgo.Index{
+		Key:         index.Key,
+		Unique:      index.Unique,
+		ExpireAfter: index.ExpireAfter,
+		Background:  true,
+	})
 
-	stats := GetDefaultStats()
+	return db.convertError(err)
+}
 
-	count := 0
+/*
+	Returns the indexes which exist on the collection, excluding the default index on _id
+*/
+func (db *MongoDatabase) GetIndexes(collection_name string) ([]Index, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
 
-	var result map[string]interface{}
-	for iter.Next(&result) {
-		count += 1
+	current_session := db.GetSession()
+	defer current_session.Close()
 
-		err := AddEntryToStats(stats, result, fields)
+	collection := current_session.DB(db.name).C(collection_name)
 
-		if err != nil {
-			return nil, convertMgoError(err)
+	mgo_indexes, err := collection.Indexes()
+
+	if err != nil {
+		// A collection which does not exist yet has no indexes
+		if query_err, ok := err.(*mgo.QueryError); ok && query_err.Code == mgoNamespaceNotFoundCode {
+			return []Index{}, nil
 		}
+
+		return nil, db.convertError(err)
 	}
 
-	err := iter.Err()
+	indexes := []Index{}
+	for _, mgo_index := range mgo_indexes {
+		if mgo_index.Name == "_id_" {
+			continue
+		}
 
-	if err != nil {
-		return nil, convertMgoError(err)
+		indexes = append(indexes, Index{
+			Key:         mgo_index.Key,
+			Unique:      mgo_index.Unique,
+			ExpireAfter: mgo_index.ExpireAfter,
+		})
 	}
 
-	err = iter.Close()
+	return indexes, nil
+}
 
-	if err != nil {
-		return nil, convertMgoError(err)
+/*
+	Runs the given aggregation pipeline on the collection and stores the output documents in result
+*/
+func (db *MongoDatabase) Aggregate(collection_name string, pipeline interface{}, result interface{}) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
 	}
 
-	stats["count"] = count
+	current_session := db.GetSession()
+	defer current_session.Close()
+
+	collection := current_session.DB(db.name).C(collection_name)
 
-	return stats, nil
+	err := collection.Pipe(pipeline).AllowDiskUse().All(result)
+
+	return db.convertError(err)
+}
+
+/*
+	Returns a stream of the changes to items in the collection matching the filter
+	mgo does not support change streams, so the collection is polled for changes
+*/
+func (db *MongoDatabase) Watch(collection_name string, filter interface{}) (*ChangeStream, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	return pollChanges(db.ctx, db, collection_name, filter)
+}
+
+/*
+	mgo does not support transactions, so this always fails with ErrTransactionsUnsupported
+	The official driver should be used by services which require transactions
+*/
+func (db *MongoDatabase) RunTransaction(fn func(tx Database) error) error {
+	return ErrTransactionsUnsupported
+}
+
+/*
+	Returns a map of statistics for a given collection
+	The statistics are computed by the database with aggregation pipelines
+*/
+func (db *MongoDatabase) GetStats(collection_name string, fields []string) (map[string]interface{}, error) {
+	return GetAggregatedStats(db, collection_name, fields)
 }
@@ -0,0 +1,1174 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/HackIllinois/api/common/config"
+	mongo_bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+	The error code returned by mongo when change streams are used on a server which is not part of a replica set
+*/
+const mongoChangeStreamUnsupportedCode = 40573
+
+/*
+	MongoDriverDatabase struct which implements the Database interface for a mongo database using the official MongoDB Go driver
+	Items are encoded and decoded with the mgo bson package so that models behave identically to MongoDatabase
+*/
+type MongoDriverDatabase struct {
+	client *mongo.Client
+	name   string
+	ctx    context.Context
+}
+
+/*
+	Initialize connection to mongo database with the official driver
+*/
+func InitMongoDriverDatabase(host string, db_name string) (*MongoDriverDatabase, error) {
+	db := MongoDriverDatabase{
+		name: db_name,
+		ctx:  context.Background(),
+	}
+
+	err := db.Connect(host)
+
+	if err != nil {
+		return &db, err
+	}
+
+	return &db, nil
+}
+
+/*
+	Open a client to the given mongo database
+	TLS, authentication, and other options are read from the connection string
+	In production TLS is enabled unless the connection string configures it
+	Returns ErrConnection if the server cannot be reached
+*/
+func (db *MongoDriverDatabase) Connect(host string) error {
+	if !strings.Contains(host, "://") {
+		host = "mongodb://" + host
+	}
+
+	client_options := options.Client().ApplyURI(host)
+
+	if client_options.MaxPoolSize == nil {
+		client_options.SetMaxPoolSize(25)
+	}
+
+	if client_options.ServerSelectionTimeout == nil {
+		client_options.SetServerSelectionTimeout(10 * time.Second)
+	}
+
+	if config.IS_PRODUCTION && client_options.TLSConfig == nil && !strings.Contains(host, "tls=") && !strings.Contains(host, "ssl=") {
+		client_options.SetTLSConfig(&tls.Config{})
+		client_options.SetConnectTimeout(60 * time.Second)
+	}
+
+	client, err := mongo.Connect(db.ctx, client_options)
+
+	if err != nil {
+		return ErrConnection
+	}
+
+	// Connecting does not wait for the server, so it is pinged to report an unreachable server at startup like mgo
+	err = client.Ping(db.ctx, nil)
+
+	if err != nil {
+		client.Disconnect(db.ctx)
+		return ErrConnection
+	}
+
+	db.client = client
+
+	return nil
+}
+
+/*
+	Close the client to the given mongo database
+*/
+func (db *MongoDriverDatabase) Close() {
+	db.client.Disconnect(context.Background())
+}
+
+/*
+	Returns a copy of the database which performs every operation within the given context
+	Close should only be called on the database returned by InitMongoDriverDatabase
+*/
+func (db *MongoDriverDatabase) WithContext(ctx context.Context) Database {
+	return &MongoDriverDatabase{
+		client: db.client,
+		name:   db.name,
+		ctx:    ctx,
+	}
+}
+
+/*
+	Returns the given collection in the database
+*/
+func (db *MongoDriverDatabase) collection(collection_name string) *mongo.Collection {
+	return db.client.Database(db.name).Collection(collection_name)
+}
+
+/*
+	Converts internal driver errors to external presented errors
+	Errors caused by the database's context being done are reported as the context's error
+*/
+func (db *MongoDriverDatabase) convertError(err error) error {
+	if err != nil && db.ctx.Err() != nil {
+		return convertContextError(db.ctx.Err())
+	}
+
+	return convertMongoDriverError(err)
+}
+
+/*
+	Find one element matching the given query parameters
+*/
+func (db *MongoDriverDatabase) FindOne(collection_name string, query interface{}, result interface{}) error {
+	return db.FindOneProjected(collection_name, query, nil, result)
+}
+
+/*
+	Find one element matching the given query parameters, including only the fields in projection
+*/
+func (db *MongoDriverDatabase) FindOneProjected(collection_name string, query interface{}, projection []string, result interface{}) error {
+	filter, err := toRawDocument(query)
+
+	if err != nil {
+		return err
+	}
+
+	find_options := options.FindOne()
+
+	if len(projection) > 0 {
+		find_options.SetProjection(getMgoProjection(projection))
+	}
+
+	raw, err := db.collection(collection_name).FindOne(db.ctx, filter, find_options).DecodeBytes()
+
+	if err != nil {
+		return db.convertError(err)
+	}
+
+	return fromRawDocument(raw, result)
+}
+
+/*
+	Find all elements matching the given query parameters
+*/
+func (db *MongoDriverDatabase) FindAll(collection_name string, query interface{}, result interface{}) error {
+	return db.find(collection_name, query, options.Find(), result)
+}
+
+/*
+	Find all elements matching the given query parameters, and sorts them based on given sort fields
+	The first sort field is highest priority, each subsequent field breaks ties
+*/
+func (db *MongoDriverDatabase) FindAllSorted(collection_name string, query interface{}, sort_fields []SortField, result interface{}) error {
+	sort, err := getMongoDriverSort(sort_fields)
+
+	if err != nil {
+		return err
+	}
+
+	return db.find(collection_name, query, options.Find().SetSort(sort), result)
+}
+
+/*
+	Find the page of elements matching the given query parameters described by pagination, sorted by the given sort fields
+	Ties, and elements when no sort fields are given, are ordered by _id so that pages do not overlap
+	Returns the total number of elements matching the query, ignoring pagination
+*/
+func (db *MongoDriverDatabase) FindAllPaginated(collection_name string, query interface{}, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	return db.FindAllProjected(collection_name, query, nil, sort_fields, pagination, result)
+}
+
+/*
+	Find the page of elements matching the given query parameters described by pagination, sorted by the given sort fields,
+	including only the fields in projection
+	Returns the total number of elements matching the query, ignoring pagination
+*/
+func (db *MongoDriverDatabase) FindAllProjected(collection_name string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	filter, err := toRawDocument(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := db.collection(collection_name).CountDocuments(db.ctx, filter)
+
+	if err != nil {
+		return nil, db.convertError(err)
+	}
+
+	sort, err := getMongoDriverSort(getPaginationSortFields(sort_fields))
+
+	if err != nil {
+		return nil, err
+	}
+
+	find_options := options.Find().SetSkip(int64(pagination.Skip)).SetLimit(int64(pagination.Limit)).SetSort(sort)
+
+	if len(projection) > 0 {
+		find_options.SetProjection(getMgoProjection(projection))
+	}
+
+	err = db.find(collection_name, filter, find_options, result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pagination_results := PaginationResults{
+		Total: int(total),
+	}
+
+	return &pagination_results, nil
+}
+
+/*
+	Find the page of elements matching the given query parameters which contain the text in their text indexed fields,
+	including only the fields in projection
+	Results are ordered by relevance, unless sort fields are given
+	Returns the total number of elements matching the search, ignoring pagination
+*/
+func (db *MongoDriverDatabase) FindAllText(collection_name string, text string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	filter, err := toRawDocument(getTextQuery(text, query))
+
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := db.collection(collection_name).CountDocuments(db.ctx, filter)
+
+	if err != nil {
+		return nil, db.convertError(err)
+	}
+
+	find_options := options.Find().SetSkip(int64(pagination.Skip)).SetLimit(int64(pagination.Limit))
+	selection := getMgoProjection(projection)
+
+	if len(sort_fields) > 0 {
+		sort, err := getMongoDriverSort(getPaginationSortFields(sort_fields))
+
+		if err != nil {
+			return nil, err
+		}
+
+		find_options.SetSort(sort)
+	} else {
+		text_score := bson.M{"$meta": "textScore"}
+
+		sort, err := toRawDocument(bson.D{{Name: textScoreField, Value: text_score}, {Name: "_id", Value: 1}})
+
+		if err != nil {
+			return nil, err
+		}
+
+		find_options.SetSort(sort)
+
+		// Older versions of mongo can only sort by relevance if it is also selected
+		if selection == nil {
+			selection = bson.M{}
+		}
+		selection[textScoreField] = text_score
+	}
+
+	if selection != nil {
+		find_options.SetProjection(selection)
+	}
+
+	err = db.find(collection_name, filter, find_options, result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pagination_results := PaginationResults{
+		Total: int(total),
+	}
+
+	return &pagination_results, nil
+}
+
+/*
+	Find all elements matching the given query parameters with the given options
+*/
+func (db *MongoDriverDatabase) find(collection_name string, query interface{}, find_options *options.FindOptions, result interface{}) error {
+	filter, err := toRawDocument(query)
+
+	if err != nil {
+		return err
+	}
+
+	cursor, err := db.collection(collection_name).Find(db.ctx, filter, find_options)
+
+	if err != nil {
+		return db.convertError(err)
+	}
+
+	return db.decodeCursor(cursor, result)
+}
+
+/*
+	Decodes every document from the cursor into result, which must be a pointer to a slice
+*/
+func (db *MongoDriverDatabase) decodeCursor(cursor *mongo.Cursor, result interface{}) error {
+	defer cursor.Close(context.Background())
+
+	raws := []mongo_bson.Raw{}
+
+	for cursor.Next(db.ctx) {
+		raws = append(raws, cursor.Current)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return db.convertError(err)
+	}
+
+	return fromRawDocuments(raws, result)
+}
+
+/*
+	Converts the given sort fields into the format expected by the driver
+*/
+func getMongoDriverSort(sort_fields []SortField) (mongo_bson.Raw, error) {
+	sort := bson.D{}
+
+	for _, field := range sort_fields {
+		// Like mgo, a leading '-' on the name also reverses the sort
+		name := strings.TrimPrefix(field.Name, "+")
+		reversed := field.Reversed
+
+		if strings.HasPrefix(name, "-") {
+			name = name[1:]
+			reversed = !reversed
+		}
+
+		direction := 1
+		if reversed {
+			direction = -1
+		}
+
+		sort = append(sort, bson.DocElem{Name: name, Value: direction})
+	}
+
+	return toRawDocument(sort)
+}
+
+/*
+	Returns the number of items matching the given query parameters
+*/
+func (db *MongoDriverDatabase) Count(collection_name string, query interface{}) (int, error) {
+	filter, err := toRawDocument(query)
+
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := db.collection(collection_name).CountDocuments(db.ctx, filter)
+
+	if err != nil {
+		return 0, db.convertError(err)
+	}
+
+	return int(count), nil
+}
+
+/*
+	Stores the unique values of the field among the items matching the given query parameters in result
+	The elements of array fields are treated as separate values
+	The distinct command is run directly so that the values are decoded with the mgo bson package
+*/
+func (db *MongoDriverDatabase) Distinct(collection_name string, field string, query interface{}, result interface{}) error {
+	filter, err := toRawDocument(query)
+
+	if err != nil {
+		return err
+	}
+
+	command := mongo_bson.D{
+		{Key: "distinct", Value: collection_name},
+		{Key: "key", Value: field},
+		{Key: "query", Value: filter},
+	}
+
+	response, err := db.client.Database(db.name).RunCommand(db.ctx, command).DecodeBytes()
+
+	if err != nil {
+		return db.convertError(err)
+	}
+
+	return fromValuesDocument(response, result)
+}
+
+/*
+	Remove one element matching the given query parameters
+*/
+func (db *MongoDriverDatabase) RemoveOne(collection_name string, query interface{}) error {
+	filter, err := toRawDocument(query)
+
+	if err != nil {
+		return err
+	}
+
+	delete_result, err := db.collection(collection_name).DeleteOne(db.ctx, filter)
+
+	if err != nil {
+		return db.convertError(err)
+	}
+
+	if delete_result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+/*
+	Remove all elements matching the given query parameters
+*/
+func (db *MongoDriverDatabase) RemoveAll(collection_name string, query interface{}) (*ChangeResults, error) {
+	filter, err := toRawDocument(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	delete_result, err := db.collection(collection_name).DeleteMany(db.ctx, filter)
+
+	if err != nil {
+		return &ChangeResults{}, db.convertError(err)
+	}
+
+	change_results := ChangeResults{
+		Deleted: int(delete_result.DeletedCount),
+	}
+
+	return &change_results, nil
+}
+
+/*
+	Insert the given item into the collection
+*/
+func (db *MongoDriverDatabase) Insert(collection_name string, item interface{}) error {
+	document, err := toRawDocument(item)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.collection(collection_name).InsertOne(db.ctx, document)
+
+	return db.convertError(err)
+}
+
+/*
+	Upsert the given item into the collection i.e.,
+	if the item exists, it is updated with the given values, else a new item with those values is created.
+*/
+func (db *MongoDriverDatabase) Upsert(collection_name string, selector interface{}, update interface{}) (*ChangeResults, error) {
+	update_result, err := db.updateOne(collection_name, selector, update, true)
+
+	if err != nil {
+		return &ChangeResults{}, err
+	}
+
+	change_results := ChangeResults{
+		Updated: int(update_result.ModifiedCount),
+	}
+
+	return &change_results, nil
+}
+
+/*
+	Finds an item based on the given selector and updates it with the data in update
+*/
+func (db *MongoDriverDatabase) Update(collection_name string, selector interface{}, update interface{}) error {
+	update_result, err := db.updateOne(collection_name, selector, update, false)
+
+	if err != nil {
+		return err
+	}
+
+	if update_result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+/*
+	Updates the first item matching the selector
+	Like mgo, an update without operators such as $set replaces the item
+*/
+func (db *MongoDriverDatabase) updateOne(collection_name string, selector interface{}, update interface{}, upsert bool) (*mongo.UpdateResult, error) {
+	filter, err := toRawDocument(selector)
+
+	if err != nil {
+		return nil, err
+	}
+
+	update_document, err := toRawDocument(update)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var update_result *mongo.UpdateResult
+
+	if isUpdateDocument(update_document) {
+		update_result, err = db.collection(collection_name).UpdateOne(db.ctx, filter, update_document, options.Update().SetUpsert(upsert))
+	} else {
+		update_result, err = db.collection(collection_name).ReplaceOne(db.ctx, filter, update_document, options.Replace().SetUpsert(upsert))
+	}
+
+	if err != nil {
+		return nil, db.convertError(err)
+	}
+
+	return update_result, nil
+}
+
+/*
+	Finds all items based on the given selector and updates them with the data in update
+*/
+func (db *MongoDriverDatabase) UpdateAll(collection_name string, selector interface{}, update interface{}) (*ChangeResults, error) {
+	filter, err := toRawDocument(selector)
+
+	if err != nil {
+		return nil, err
+	}
+
+	update_document, err := toRawDocument(update)
+
+	if err != nil {
+		return nil, err
+	}
+
+	update_result, err := db.collection(collection_name).UpdateMany(db.ctx, filter, update_document)
+
+	if err != nil {
+		return &ChangeResults{}, db.convertError(err)
+	}
+
+	change_results := ChangeResults{
+		Updated: int(update_result.ModifiedCount),
+	}
+
+	return &change_results, nil
+}
+
+/*
+	Atomically finds an item based on the given selector, updates it with the data in update, and stores the updated item in result
+	If upsert is true and no item matches the selector, a new item is created
+	This should be used with update operators such as $inc to avoid lost updates from concurrent read-modify-write cycles
+*/
+func (db *MongoDriverDatabase) FindAndModify(collection_name string, selector interface{}, update interface{}, upsert bool, result interface{}) error {
+	filter, err := toRawDocument(selector)
+
+	if err != nil {
+		return err
+	}
+
+	update_document, err := toRawDocument(update)
+
+	if err != nil {
+		return err
+	}
+
+	var single_result *mongo.SingleResult
+
+	if isUpdateDocument(update_document) {
+		single_result = db.collection(collection_name).FindOneAndUpdate(db.ctx, filter, update_document, options.FindOneAndUpdate().SetUpsert(upsert).SetReturnDocument(options.After))
+	} else {
+		single_result = db.collection(collection_name).FindOneAndReplace(db.ctx, filter, update_document, options.FindOneAndReplace().SetUpsert(upsert).SetReturnDocument(options.After))
+	}
+
+	raw, err := single_result.DecodeBytes()
+
+	if err != nil {
+		return db.convertError(err)
+	}
+
+	return fromRawDocument(raw, result)
+}
+
+/*
+	Atomically updates the item matching the given selector, but only if it also matches the given condition
+	Returns false if the item exists but does not match the condition, and ErrNotFound if no item matches the selector
+*/
+func (db *MongoDriverDatabase) UpdateIfMatches(collection_name string, selector interface{}, condition interface{}, update interface{}) (bool, error) {
+	conditional_selector := bson.M{
+		"$and": []interface{}{
+			getMgoSelector(selector),
+			getMgoSelector(condition),
+		},
+	}
+
+	update_result, err := db.updateOne(collection_name, conditional_selector, update, false)
+
+	if err != nil {
+		return false, err
+	}
+
+	if update_result.MatchedCount > 0 {
+		return true, nil
+	}
+
+	filter, err := toRawDocument(selector)
+
+	if err != nil {
+		return false, err
+	}
+
+	count, err := db.collection(collection_name).CountDocuments(db.ctx, filter, options.Count().SetLimit(1))
+
+	if err != nil {
+		return false, db.convertError(err)
+	}
+
+	if count == 0 {
+		return false, ErrNotFound
+	}
+
+	return false, nil
+}
+
+/*
+	Runs the given insert, update, upsert, and delete operations on the collection in order
+	Consecutive operations of the same kind are sent as a single bulk write
+	Every operation is attempted even if others fail, and ErrBulkWrite is returned if any of them failed
+	The outcome of each operation is stored in the returned results
+*/
+func (db *MongoDriverDatabase) BulkWrite(collection_name string, operations []BulkOperation) (*BulkWriteResults, error) {
+	batches, err := getBulkBatches(operations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := newBulkWriteResults(len(operations))
+
+	for _, batch := range batches {
+		models := []mongo.WriteModel{}
+		model_indices := []int{}
+
+		for i := batch.start; i < batch.end; i++ {
+			model, err := getMongoDriverWriteModel(operations[i])
+
+			if err != nil {
+				results.Operations[i].Err = err
+				continue
+			}
+
+			models = append(models, model)
+			model_indices = append(model_indices, i)
+		}
+
+		if len(models) == 0 {
+			continue
+		}
+
+		bulk_result, err := db.collection(collection_name).BulkWrite(db.ctx, models, options.BulkWrite().SetOrdered(false))
+
+		var bulk_exception mongo.BulkWriteException
+		if err != nil && !errors.As(err, &bulk_exception) {
+			return results, db.convertError(err)
+		}
+
+		for _, write_error := range bulk_exception.WriteErrors {
+			results.Operations[model_indices[write_error.Index]].Err = ErrUnknown
+		}
+
+		for index := range bulk_result.UpsertedIDs {
+			results.Operations[model_indices[index]].Upserted = true
+		}
+
+		results.Inserted += int(bulk_result.InsertedCount)
+		results.Updated += int(bulk_result.MatchedCount)
+		results.Upserted += int(bulk_result.UpsertedCount)
+		results.Deleted += int(bulk_result.DeletedCount)
+	}
+
+	return results, results.getError()
+}
+
+/*
+	Returns the driver's write model for the operation
+	Like mgo, an update without operators such as $set replaces the item, which may only be done to a single item
+*/
+func getMongoDriverWriteModel(operation BulkOperation) (mongo.WriteModel, error) {
+	if operation.Type == BulkInsert {
+		document, err := toRawDocument(operation.Item)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return mongo.NewInsertOneModel().SetDocument(document), nil
+	}
+
+	filter, err := toRawDocument(operation.Selector)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if operation.Type == BulkDelete {
+		if operation.Multi {
+			return mongo.NewDeleteManyModel().SetFilter(filter), nil
+		}
+
+		return mongo.NewDeleteOneModel().SetFilter(filter), nil
+	}
+
+	update_document, err := toRawDocument(operation.Item)
+
+	if err != nil {
+		return nil, err
+	}
+
+	upsert := operation.Type == BulkUpsert
+
+	if !isUpdateDocument(update_document) {
+		if operation.Multi {
+			return nil, ErrUnknown
+		}
+
+		return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(update_document).SetUpsert(upsert), nil
+	}
+
+	if operation.Multi {
+		return mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(update_document).SetUpsert(upsert), nil
+	}
+
+	return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update_document).SetUpsert(upsert), nil
+}
+
+/*
+	Runs the given aggregation pipeline on the collection and stores the output documents in result
+*/
+func (db *MongoDriverDatabase) Aggregate(collection_name string, pipeline interface{}, result interface{}) error {
+	stages, err := toRawDocuments(pipeline)
+
+	if err != nil {
+		return err
+	}
+
+	cursor, err := db.collection(collection_name).Aggregate(db.ctx, stages, options.Aggregate().SetAllowDiskUse(true))
+
+	if err != nil {
+		return db.convertError(err)
+	}
+
+	return db.decodeCursor(cursor, result)
+}
+
+/*
+	Returns a stream of the changes to items in the collection matching the filter
+	Change streams are used when the server supports them, in which case the filter is matched against
+	each item after it changes and deletes are reported for every item, since deleted items cannot be matched
+	Servers which are not part of a replica set do not support change streams, so the collection is polled for changes
+*/
+func (db *MongoDriverDatabase) Watch(collection_name string, filter interface{}) (*ChangeStream, error) {
+	if err := db.ctx.Err(); err != nil {
+		return nil, convertContextError(err)
+	}
+
+	filter_document, err := toDocument(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	match := bson.M{
+		"$or": []interface{}{
+			bson.M{"operationType": ChangeDelete},
+			bson.M{
+				"operationType": bson.M{"$in": []string{ChangeInsert, ChangeUpdate, "replace"}},
+				"$and":          []interface{}{prefixFilterFields(filter_document, "fullDocument.")},
+			},
+		},
+	}
+
+	stages, err := toRawDocuments([]interface{}{bson.M{"$match": match}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	stream_ctx, cancel := context.WithCancel(db.ctx)
+
+	change_stream, err := db.collection(collection_name).Watch(stream_ctx, stages, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+
+	if err != nil {
+		cancel()
+
+		var command_err mongo.CommandError
+		if errors.As(err, &command_err) && command_err.Code == mongoChangeStreamUnsupportedCode {
+			return pollChanges(db.ctx, db, collection_name, filter)
+		}
+
+		return nil, db.convertError(err)
+	}
+
+	stream := newChangeStream()
+
+	go func() {
+		select {
+		case <-stream.done:
+			cancel()
+		case <-stream_ctx.Done():
+		}
+	}()
+
+	go func() {
+		defer close(stream.events)
+		defer cancel()
+		defer change_stream.Close(context.Background())
+
+		for change_stream.Next(stream_ctx) {
+			var change struct {
+				OperationType string `bson:"operationType"`
+				DocumentKey   bson.M `bson:"documentKey"`
+				FullDocument  bson.M `bson:"fullDocument"`
+			}
+
+			err := bson.Unmarshal(change_stream.Current, &change)
+
+			if err != nil {
+				stream.setErr(ErrUnknown)
+				return
+			}
+
+			event := ChangeEvent{
+				Operation:  change.OperationType,
+				DocumentID: change.DocumentKey["_id"],
+				Document:   change.FullDocument,
+			}
+
+			if event.Operation == "replace" {
+				event.Operation = ChangeUpdate
+			}
+
+			if !stream.send(db.ctx, event) {
+				return
+			}
+		}
+
+		select {
+		case <-stream.done:
+		default:
+			stream.setErr(db.convertError(change_stream.Err()))
+		}
+	}()
+
+	return stream, nil
+}
+
+/*
+	Returns a copy of the filter with the given prefix added to each field name, including within $and, $or, and $nor
+*/
+func prefixFilterFields(filter bson.M, prefix string) bson.M {
+	prefixed_filter := bson.M{}
+
+	for key, value := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			clauses, ok := value.([]interface{})
+
+			if !ok {
+				prefixed_filter[key] = value
+				continue
+			}
+
+			prefixed_clauses := []interface{}{}
+			for _, clause := range clauses {
+				if clause_filter, ok := clause.(bson.M); ok {
+					prefixed_clauses = append(prefixed_clauses, prefixFilterFields(clause_filter, prefix))
+				} else {
+					prefixed_clauses = append(prefixed_clauses, clause)
+				}
+			}
+
+			prefixed_filter[key] = prefixed_clauses
+		default:
+			if strings.HasPrefix(key, "$") {
+				prefixed_filter[key] = value
+			} else {
+				prefixed_filter[prefix+key] = value
+			}
+		}
+	}
+
+	return prefixed_filter
+}
+
+/*
+	Runs fn within a multi-document transaction, which is committed if fn returns nil and aborted otherwise
+	fn must perform its operations on the given database, and may be run more than once if the transaction
+	encounters a transient error
+	Transactions require a server which is part of a replica set
+*/
+func (db *MongoDriverDatabase) RunTransaction(fn func(tx Database) error) error {
+	if err := db.ctx.Err(); err != nil {
+		return convertContextError(err)
+	}
+
+	session, err := db.client.StartSession()
+
+	if err != nil {
+		return db.convertError(err)
+	}
+
+	defer session.EndSession(context.Background())
+
+	var fn_err error
+
+	_, err = session.WithTransaction(db.ctx, func(session_ctx mongo.SessionContext) (interface{}, error) {
+		fn_err = fn(db.WithContext(session_ctx))
+		return nil, fn_err
+	})
+
+	if fn_err != nil {
+		return fn_err
+	}
+
+	return db.convertError(err)
+}
+
+/*
+	Drops the entire database
+*/
+func (db *MongoDriverDatabase) DropDatabase() error {
+	err := db.client.Database(db.name).Drop(db.ctx)
+
+	return db.convertError(err)
+}
+
+/*
+	Creates the given index on the collection if it does not already exist
+	Indexes are built in the background so that the collection remains available
+*/
+func (db *MongoDriverDatabase) EnsureIndex(collection_name string, index Index) error {
+	key := bson.D{}
+
+	for _, field := range index.Key {
+		if strings.HasPrefix(field, TextIndexPrefix) {
+			key = append(key, bson.DocElem{Name: strings.TrimPrefix(field, TextIndexPrefix), Value: "text"})
+		} else if strings.HasPrefix(field, "-") {
+			key = append(key, bson.DocElem{Name: field[1:], Value: -1})
+		} else {
+			key = append(key, bson.DocElem{Name: field, Value: 1})
+		}
+	}
+
+	raw_key, err := toRawDocument(key)
+
+	if err != nil {
+		return err
+	}
+
+	index_options := options.Index().SetBackground(true)
+
+	if index.Unique {
+		index_options.SetUnique(true)
+	}
+
+	if index.ExpireAfter > 0 {
+		index_options.SetExpireAfterSeconds(int32(index.ExpireAfter / time.Second))
+	}
+
+	_, err = db.collection(collection_name).Indexes().CreateOne(db.ctx, mongo.IndexModel{
+		Keys:    raw_key,
+		Options: index_options,
+	})
+
+	return db.convertError(err)
+}
+
+/*
+	Returns the indexes which exist on the collection, excluding the default index on _id
+*/
+func (db *MongoDriverDatabase) GetIndexes(collection_name string) ([]Index, error) {
+	cursor, err := db.collection(collection_name).Indexes().List(db.ctx)
+
+	if err != nil {
+		// A collection which does not exist yet has no indexes
+		var command_err mongo.CommandError
+		if errors.As(err, &command_err) && command_err.Code == mgoNamespaceNotFoundCode {
+			return []Index{}, nil
+		}
+
+		return nil, db.convertError(err)
+	}
+
+	var index_specs []struct {
+		Name               string `bson:"name"`
+		Key                bson.D `bson:"key"`
+		Weights            bson.D `bson:"weights"`
+		Unique             bool   `bson:"unique"`
+		ExpireAfterSeconds int    `bson:"expireAfterSeconds"`
+	}
+
+	err = db.decodeCursor(cursor, &index_specs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := []Index{}
+	for _, index_spec := range index_specs {
+		if index_spec.Name == "_id_" {
+			continue
+		}
+
+		index := Index{
+			Key:         []string{},
+			Unique:      index_spec.Unique,
+			ExpireAfter: time.Duration(index_spec.ExpireAfterSeconds) * time.Second,
+		}
+
+		for _, field := range index_spec.Key {
+			// Text indexes store their fields as weights, so they are described by the weighted fields like mgo
+			if field.Name == "_fts" {
+				for _, weight := range index_spec.Weights {
+					index.Key = append(index.Key, TextIndexPrefix+weight.Name)
+				}
+				continue
+			} else if field.Name == "_ftsx" {
+				continue
+			}
+
+			if direction, ok := toFloat64(field.Value); ok && direction < 0 {
+				index.Key = append(index.Key, "-"+field.Name)
+			} else if kind, ok := field.Value.(string); ok {
+				// Other special indexes, such as 2dsphere indexes, are described the same as mgo
+				index.Key = append(index.Key, "$"+kind+":"+field.Name)
+			} else {
+				index.Key = append(index.Key, field.Name)
+			}
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
+
+/*
+	Returns a map of statistics for a given collection
+	The statistics are computed by the database with aggregation pipelines
+*/
+func (db *MongoDriverDatabase) GetStats(collection_name string, fields []string) (map[string]interface{}, error) {
+	return GetAggregatedStats(db, collection_name, fields)
+}
+
+/*
+	Returns true if the document's first field is an update operator such as $set
+*/
+func isUpdateDocument(document mongo_bson.Raw) bool {
+	element, err := document.IndexErr(0)
+
+	return err == nil && strings.HasPrefix(element.Key(), "$")
+}
+
+/*
+	Encodes the given item with the mgo bson package for use by the driver
+	This respects bson tags as well as the bson Getter interface, the same as MongoDatabase
+*/
+func toRawDocument(item interface{}) (mongo_bson.Raw, error) {
+	if item == nil {
+		item = bson.M{}
+	} else if value := reflect.ValueOf(item); value.Kind() == reflect.Ptr && value.IsNil() {
+		item = bson.M{}
+	}
+
+	raw, err := bson.Marshal(item)
+
+	if err != nil {
+		return nil, ErrUnknown
+	}
+
+	return mongo_bson.Raw(raw), nil
+}
+
+/*
+	Encodes each item in the given slice with the mgo bson package for use by the driver
+*/
+func toRawDocuments(items interface{}) ([]mongo_bson.Raw, error) {
+	items_value := reflect.ValueOf(items)
+
+	if items_value.Kind() != reflect.Slice && items_value.Kind() != reflect.Array {
+		return nil, ErrUnknown
+	}
+
+	raws := make([]mongo_bson.Raw, items_value.Len())
+
+	for i := range raws {
+		raw, err := toRawDocument(items_value.Index(i).Interface())
+
+		if err != nil {
+			return nil, err
+		}
+
+		raws[i] = raw
+	}
+
+	return raws, nil
+}
+
+/*
+	Decodes the given document from the driver into result with the mgo bson package
+	This respects bson tags as well as the bson Setter interface, the same as MongoDatabase
+*/
+func fromRawDocument(raw mongo_bson.Raw, result interface{}) error {
+	err := bson.Unmarshal(raw, result)
+
+	if err != nil {
+		return ErrUnknown
+	}
+
+	return nil
+}
+
+/*
+	Decodes the given documents from the driver into result, which must be a pointer to a slice
+*/
+func fromRawDocuments(raws []mongo_bson.Raw, result interface{}) error {
+	result_value := reflect.ValueOf(result)
+
+	if result_value.Kind() != reflect.Ptr || result_value.Elem().Kind() != reflect.Slice {
+		return ErrUnknown
+	}
+
+	slice_value := result_value.Elem().Slice(0, 0)
+	element_type := slice_value.Type().Elem()
+
+	for _, raw := range raws {
+		element := reflect.New(element_type)
+
+		err := fromRawDocument(raw, element.Interface())
+
+		if err != nil {
+			return err
+		}
+
+		slice_value = reflect.Append(slice_value, element.Elem())
+	}
+
+	result_value.Elem().Set(slice_value)
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+/*
+	Describes which page of results should be returned by a paginated find
+	A Limit of 0 means the results are not limited
+*/
+type PaginationOptions struct {
+	Limit int
+	Skip  int
+}
+
+/*
+	Used to store information about the full result set of a paginated find
+*/
+type PaginationResults struct {
+	Total int
+}
+
+/*
+	Pagination metadata included in the response of every paginated filter or list endpoint
+*/
+type PageInfo struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Page       int    `json:"page"`
+	NextCursor string `json:"nextCursor"`
+}
+
+/*
+	Extracts the limit, page, and cursor url parameters and removes them from parameters
+	This must be called before passing parameters to CreateFilterQuery
+
+	The cursor is a token returned as nextCursor by a previous request, which encodes the offset and limit of the next page
+	A page may be requested either by page number, which requires a limit, or by cursor
+*/
+func ParsePaginationParameters(parameters map[string][]string) (*PaginationOptions, error) {
+	limit_param, has_limit := parameters["limit"]
+	page_param, has_page := parameters["page"]
+	cursor_param, has_cursor := parameters["cursor"]
+	delete(parameters, "limit")
+	delete(parameters, "page")
+	delete(parameters, "cursor")
+
+	pagination := PaginationOptions{}
+
+	if has_cursor {
+		if has_page {
+			return nil, errors.New("Cannot provide both 'page' and 'cursor'.")
+		}
+
+		if len(cursor_param) != 1 {
+			return nil, errors.New("Multiple usage of key cursor")
+		}
+
+		cursor, err := DecodeCursor(cursor_param[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		pagination = *cursor
+	}
+
+	if has_limit {
+		if len(limit_param) != 1 {
+			return nil, errors.New("Multiple usage of key limit")
+		}
+
+		limit, err := strconv.Atoi(limit_param[0])
+
+		if err != nil || limit < 0 {
+			return nil, errors.New("Could not convert 'limit' to a non-negative int.")
+		}
+
+		pagination.Limit = limit
+	}
+
+	if has_page {
+		if len(page_param) != 1 {
+			return nil, errors.New("Multiple usage of key page")
+		}
+
+		if pagination.Limit == 0 {
+			return nil, errors.New("Must provide a positive 'limit' when providing 'page'.")
+		}
+
+		page, err := strconv.Atoi(page_param[0])
+
+		if err != nil || page < 1 {
+			return nil, errors.New("Could not convert 'page' to a positive int.")
+		}
+
+		// Page numbers are 1-indexed
+		pagination.Skip = (page - 1) * pagination.Limit
+	}
+
+	return &pagination, nil
+}
+
+/*
+	Returns the pagination metadata for a page of results
+	Returns nil if the results were not limited, so that unpaginated responses are unchanged
+*/
+func GetPageInfo(pagination PaginationOptions, results *PaginationResults) *PageInfo {
+	if pagination.Limit == 0 || results == nil {
+		return nil
+	}
+
+	page_info := PageInfo{
+		Total: results.Total,
+		Limit: pagination.Limit,
+		Page:  pagination.Skip/pagination.Limit + 1,
+	}
+
+	next_skip := pagination.Skip + pagination.Limit
+
+	if next_skip < results.Total {
+		page_info.NextCursor = EncodeCursor(PaginationOptions{
+			Limit: pagination.Limit,
+			Skip:  next_skip,
+		})
+	}
+
+	return &page_info
+}
+
+/*
+	Encodes the given pagination options as a cursor token
+	The cursor only encodes the offset and limit of the page, so like page numbers, it may skip or repeat results
+	if results before the offset are added or removed between requests
+*/
+func EncodeCursor(pagination PaginationOptions) string {
+	raw_cursor := fmt.Sprintf("%d:%d", pagination.Skip, pagination.Limit)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw_cursor))
+}
+
+/*
+	Decodes a cursor token created by EncodeCursor
+*/
+func DecodeCursor(cursor string) (*PaginationOptions, error) {
+	raw_cursor, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	if err != nil {
+		return nil, errors.New("Invalid 'cursor'.")
+	}
+
+	var pagination PaginationOptions
+	_, err = fmt.Sscanf(string(raw_cursor), "%d:%d", &pagination.Skip, &pagination.Limit)
+
+	if err != nil || pagination.Skip < 0 || pagination.Limit < 0 {
+		return nil, errors.New("Invalid 'cursor'.")
+	}
+
+	return &pagination, nil
+}
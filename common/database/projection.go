@@ -0,0 +1,144 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/HackIllinois/api/common/utils"
+)
+
+/*
+	Implemented by models whose fields are described at runtime rather than by struct fields, such as DataStores
+	Returns the names of the model's top level fields, as they are stored
+*/
+type FieldNameLister interface {
+	GetFieldNames() []string
+}
+
+/*
+	Returns a map from the lowercased JSON name of each of the model's fields to the name the field is stored under
+*/
+func GetStoredFieldNames(model interface{}) map[string]string {
+	stored_names := make(map[string]string)
+
+	if lister, ok := model.(FieldNameLister); ok {
+		for _, name := range lister.GetFieldNames() {
+			stored_names[strings.ToLower(name)] = name
+		}
+
+		return stored_names
+	}
+
+	// Struct fields are stored under their lowercased names
+	for name := range GetFieldTypes(model) {
+		stored_names[name] = name
+	}
+
+	return stored_names
+}
+
+/*
+	Extracts the fields url parameter and removes it from parameters
+	This must be called before passing parameters to CreateFilterQuery
+
+	fields is a comma separated list of the JSON names of the model's fields to include in each result
+	Returns the names the requested fields are stored under, or nil if every field should be included
+*/
+func ParseProjectionParameters(parameters map[string][]string, model interface{}) ([]string, error) {
+	fields_param, has_fields := parameters["fields"]
+	delete(parameters, "fields")
+
+	if !has_fields {
+		return nil, nil
+	}
+
+	if len(fields_param) != 1 {
+		return nil, errors.New("Multiple usage of key fields")
+	}
+
+	stored_names := GetStoredFieldNames(model)
+	projection := []string{}
+
+	for _, field := range strings.Split(fields_param[0], ",") {
+		field = strings.TrimSpace(field)
+
+		stored_name, exists := stored_names[strings.ToLower(field)]
+
+		if !exists {
+			return nil, errors.New("Invalid field " + field)
+		}
+
+		if !utils.ContainsString(projection, stored_name) {
+			projection = append(projection, stored_name)
+		}
+	}
+
+	return projection, nil
+}
+
+/*
+	Removes every field not requested by the fields url parameter from the results in the response
+	Results are the objects in the response's top level arrays, such as the events of a filtered events response
+	Responses are returned unchanged if no fields were requested
+
+	Results decoded from a projected find still have a zero value for each field which was not selected,
+	so this must be applied to the response for those fields to be omitted
+*/
+func ProjectResponse(response interface{}, parameters map[string][]string) (interface{}, error) {
+	fields_param, has_fields := parameters["fields"]
+
+	if !has_fields || len(fields_param) != 1 {
+		return response, nil
+	}
+
+	fields := make(map[string]bool)
+	for _, field := range strings.Split(fields_param[0], ",") {
+		fields[strings.ToLower(strings.TrimSpace(field))] = true
+	}
+
+	raw_response, err := json.Marshal(response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var projected_response interface{}
+	err = json.Unmarshal(raw_response, &projected_response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := projected_response.(type) {
+	case []interface{}:
+		projectResults(value, fields)
+	case map[string]interface{}:
+		for _, field_value := range value {
+			if results, ok := field_value.([]interface{}); ok {
+				projectResults(results, fields)
+			}
+		}
+	}
+
+	return projected_response, nil
+}
+
+/*
+	Removes every field not in fields from each object in results
+*/
+func projectResults(results []interface{}, fields map[string]bool) {
+	for _, result := range results {
+		result_object, ok := result.(map[string]interface{})
+
+		if !ok {
+			continue
+		}
+
+		for name := range result_object {
+			if !fields[strings.ToLower(name)] {
+				delete(result_object, name)
+			}
+		}
+	}
+}
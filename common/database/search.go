@@ -0,0 +1,257 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+	Index keys with this prefix create a text index on the field, which allows it to be searched with FindAllText
+	For example, an Index with the key []string{"$text:name", "$text:description"} makes both fields searchable
+*/
+const TextIndexPrefix = "$text:"
+
+/*
+	The url parameter holding the text to search for
+*/
+const SearchParameter = "q"
+
+/*
+	The name of the field which holds the relevance of each result when sorting by relevance
+*/
+const textScoreField = "textscore"
+
+/*
+	Extracts the q url parameter and removes it from parameters
+	This must be called before passing parameters to CreateFilterQuery
+	Returns the text to search for, or an empty string if no search was requested
+*/
+func ParseSearchParameter(parameters map[string][]string) (string, error) {
+	search_param, has_search := parameters[SearchParameter]
+	delete(parameters, SearchParameter)
+
+	if !has_search {
+		return "", nil
+	}
+
+	if len(search_param) != 1 {
+		return "", FilterError{Key: SearchParameter, Err: errors.New("Multiple usage of key " + SearchParameter)}
+	}
+
+	return strings.TrimSpace(search_param[0]), nil
+}
+
+/*
+	Finds the page of items matching the query with FindAllText if text was given by the q url parameter,
+	or with FindAllProjected otherwise
+*/
+func FindAllSearched(db Database, collection_name string, text string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	if text == "" {
+		return db.FindAllProjected(collection_name, query, projection, sort_fields, pagination, result)
+	}
+
+	return db.FindAllText(collection_name, text, query, projection, sort_fields, pagination, result)
+}
+
+/*
+	Returns a query matching the items which match the given query and contain the given text in their text indexed fields
+*/
+func getTextQuery(text string, query interface{}) bson.M {
+	text_query := bson.M{
+		"$text": bson.M{"$search": text},
+	}
+
+	if query != nil {
+		text_query["$and"] = []interface{}{query}
+	}
+
+	return text_query
+}
+
+/*
+	Returns the fields covered by the text indexes among the given indexes
+*/
+func getTextIndexFields(indexes []Index) []string {
+	fields := []string{}
+
+	for _, index := range indexes {
+		for _, key := range index.Key {
+			if strings.HasPrefix(key, TextIndexPrefix) {
+				fields = append(fields, strings.TrimPrefix(key, TextIndexPrefix))
+			}
+		}
+	}
+
+	return fields
+}
+
+/*
+	Describes a parsed text search, in the same format as mongo's $search string
+	An item matches if it contains any of the terms and every phrase, and none of the excluded terms
+	Phrases are surrounded by double quotes and excluded terms are prefixed by -
+*/
+type textSearch struct {
+	terms          []string
+	phrases        []string
+	excluded_terms []string
+}
+
+/*
+	Splits the given text into lowercased words
+*/
+func getTextTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	})
+}
+
+/*
+	Parses the given search text into its terms, phrases, and excluded terms
+*/
+func parseTextSearch(text string) textSearch {
+	search := textSearch{}
+
+	parts := strings.Split(text, "\"")
+	for i, part := range parts {
+		// Every other part is within a pair of quotes
+		if i%2 == 1 {
+			phrase := strings.Join(getTextTokens(part), " ")
+
+			if phrase != "" {
+				search.phrases = append(search.phrases, phrase)
+				search.terms = append(search.terms, getTextTokens(part)...)
+			}
+
+			continue
+		}
+
+		for _, word := range strings.Fields(part) {
+			if strings.HasPrefix(word, "-") {
+				search.excluded_terms = append(search.excluded_terms, getTextTokens(word)...)
+			} else {
+				search.terms = append(search.terms, getTextTokens(word)...)
+			}
+		}
+	}
+
+	return search
+}
+
+/*
+	Returns the lowercased words of every string value of the field in the document
+	Strings within arrays, such as the tags of each location of an event, are included
+*/
+func getFieldTokens(document bson.M, field string) [][]string {
+	value, _ := lookupField(document, field)
+
+	field_tokens := [][]string{}
+	for _, text := range getStringValues(value) {
+		field_tokens = append(field_tokens, getTextTokens(text))
+	}
+
+	return field_tokens
+}
+
+/*
+	Returns the given value if it is a string, or every string nested within it if it is an array
+*/
+func getStringValues(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := []string{}
+		for _, element := range value {
+			values = append(values, getStringValues(element)...)
+		}
+		return values
+	}
+
+	return []string{}
+}
+
+/*
+	Returns the relevance of the document to the search, or 0 if it does not match
+	Each field scores a point for every distinct term it contains, plus the fraction of its words which match
+	so that shorter fields which match are more relevant than longer ones
+*/
+func (search textSearch) getScore(document bson.M, fields []string) float64 {
+	score := 0.0
+	full_text := []string{}
+
+	for _, field := range fields {
+		for _, tokens := range getFieldTokens(document, field) {
+			full_text = append(full_text, strings.Join(tokens, " "))
+
+			counts := make(map[string]int)
+			for _, token := range tokens {
+				counts[token]++
+			}
+
+			for _, term := range search.excluded_terms {
+				if counts[term] > 0 {
+					return 0
+				}
+			}
+
+			matched := make(map[string]bool)
+			for _, term := range search.terms {
+				if counts[term] > 0 && !matched[term] {
+					matched[term] = true
+					score += 1 + float64(counts[term])/float64(len(tokens))
+				}
+			}
+		}
+	}
+
+	for _, phrase := range search.phrases {
+		if !containsPhrase(full_text, phrase) {
+			return 0
+		}
+	}
+
+	return score
+}
+
+/*
+	Returns true if any of the given texts contain the phrase as whole words
+*/
+func containsPhrase(texts []string, phrase string) bool {
+	for _, text := range texts {
+		if strings.Contains(" "+text+" ", " "+phrase+" ") {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Orders the documents by their relevance, with the most relevant first
+	Documents with equal relevance are ordered by _id, as they are by the mongo implementations
+*/
+func sortDocumentsByScore(documents []bson.M, scores []float64) {
+	indices := make([]int, len(documents))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	sort.SliceStable(indices, func(i, j int) bool {
+		if scores[indices[i]] != scores[indices[j]] {
+			return scores[indices[i]] > scores[indices[j]]
+		}
+
+		return compareOrdered(documents[indices[i]]["_id"], documents[indices[j]]["_id"]) < 0
+	})
+
+	sorted_documents := make([]bson.M, len(documents))
+	for i, index := range indices {
+		sorted_documents[i] = documents[index]
+	}
+
+	copy(documents, sorted_documents)
+}
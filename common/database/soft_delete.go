@@ -0,0 +1,619 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HackIllinois/api/common/config"
+)
+
+/*
+	The field storing the unix time at which a soft deleted item was deleted
+*/
+const DeletedAtField = "deletedat"
+
+var ErrSoftDeleteDisabled = errors.New("Error: SOFT_DELETE_DISABLED")
+
+/*
+	SoftDeleteDatabase wraps a Database so that items removed from the given collections are marked as deleted
+	rather than removed, allowing them to be listed and restored until they are purged
+	Finds and updates exclude deleted items, and creating an item replaces any deleted item it conflicts with
+	on a unique index, or which matches the selector of an upsert
+	Operations on every other collection are passed through unchanged
+*/
+type SoftDeleteDatabase struct {
+	Database
+	collections map[string]bool
+}
+
+/*
+	Returns a database which soft deletes items from the given collections of db
+*/
+func WithSoftDelete(db Database, collections []string) *SoftDeleteDatabase {
+	soft_delete_collections := make(map[string]bool)
+
+	for _, collection_name := range collections {
+		soft_delete_collections[collection_name] = true
+	}
+
+	return &SoftDeleteDatabase{
+		Database:    db,
+		collections: soft_delete_collections,
+	}
+}
+
+/*
+	Returns db wrapped to soft delete items from the given collections if SOFT_DELETE is enabled, along with the
+	job purging the items which have been deleted for longer than SOFT_DELETE_RETENTION
+	If SOFT_DELETE is not enabled, db is returned unchanged with a nil job
+	This should be called from the Initialize of each service with deletable resources
+*/
+func EnableSoftDelete(db Database, collections []string) (Database, *PurgeJob) {
+	if !config.SOFT_DELETE {
+		return db, nil
+	}
+
+	soft_delete_db := WithSoftDelete(db, collections)
+
+	purge_job := StartPurgeJob(soft_delete_db, config.SOFT_DELETE_RETENTION, config.SOFT_DELETE_PURGE_INTERVAL, func(err error) {
+		log.Printf("Failed to purge soft deleted items: %v", err)
+	})
+
+	return soft_delete_db, purge_job
+}
+
+/*
+	Returns a copy of the database which performs every operation within the given context
+*/
+func (db *SoftDeleteDatabase) WithContext(ctx context.Context) Database {
+	return &SoftDeleteDatabase{
+		Database:    db.Database.WithContext(ctx),
+		collections: db.collections,
+	}
+}
+
+/*
+	Returns the given query, restricted to items which have not been deleted if the collection is soft deleted
+*/
+func (db *SoftDeleteDatabase) excludeDeleted(collection_name string, query interface{}) interface{} {
+	if !db.collections[collection_name] {
+		return query
+	}
+
+	return QuerySelector{
+		"$and": []interface{}{
+			getMgoSelector(query),
+			QuerySelector{DeletedAtField: QuerySelector{"$exists": false}},
+		},
+	}
+}
+
+/*
+	Returns the given query, restricted to items which have been deleted
+*/
+func onlyDeleted(query interface{}) interface{} {
+	return QuerySelector{
+		"$and": []interface{}{
+			getMgoSelector(query),
+			QuerySelector{DeletedAtField: QuerySelector{"$exists": true}},
+		},
+	}
+}
+
+/*
+	Returns the update which marks items as deleted now
+*/
+func getDeleteUpdate() QuerySelector {
+	return QuerySelector{
+		"$set": QuerySelector{
+			DeletedAtField: time.Now().Unix(),
+		},
+	}
+}
+
+/*
+	Find one element which has not been deleted matching the given query parameters
+*/
+func (db *SoftDeleteDatabase) FindOne(collection_name string, query interface{}, result interface{}) error {
+	return db.Database.FindOne(collection_name, db.excludeDeleted(collection_name, query), result)
+}
+
+/*
+	Find one element which has not been deleted matching the given query parameters, including only the fields in projection
+*/
+func (db *SoftDeleteDatabase) FindOneProjected(collection_name string, query interface{}, projection []string, result interface{}) error {
+	return db.Database.FindOneProjected(collection_name, db.excludeDeleted(collection_name, query), projection, result)
+}
+
+/*
+	Find all elements which have not been deleted matching the given query parameters
+*/
+func (db *SoftDeleteDatabase) FindAll(collection_name string, query interface{}, result interface{}) error {
+	return db.Database.FindAll(collection_name, db.excludeDeleted(collection_name, query), result)
+}
+
+/*
+	Find all elements which have not been deleted matching the given query parameters, sorted by the given sort fields
+*/
+func (db *SoftDeleteDatabase) FindAllSorted(collection_name string, query interface{}, sort_fields []SortField, result interface{}) error {
+	return db.Database.FindAllSorted(collection_name, db.excludeDeleted(collection_name, query), sort_fields, result)
+}
+
+/*
+	Find the page of elements which have not been deleted matching the given query parameters
+*/
+func (db *SoftDeleteDatabase) FindAllPaginated(collection_name string, query interface{}, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	return db.Database.FindAllPaginated(collection_name, db.excludeDeleted(collection_name, query), sort_fields, pagination, result)
+}
+
+/*
+	Find the page of elements which have not been deleted matching the given query parameters, including only the fields in projection
+*/
+func (db *SoftDeleteDatabase) FindAllProjected(collection_name string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	return db.Database.FindAllProjected(collection_name, db.excludeDeleted(collection_name, query), projection, sort_fields, pagination, result)
+}
+
+/*
+	Find the page of elements which have not been deleted matching the given query parameters and containing the text
+*/
+func (db *SoftDeleteDatabase) FindAllText(collection_name string, text string, query interface{}, projection []string, sort_fields []SortField, pagination PaginationOptions, result interface{}) (*PaginationResults, error) {
+	return db.Database.FindAllText(collection_name, text, db.excludeDeleted(collection_name, query), projection, sort_fields, pagination, result)
+}
+
+/*
+	Returns the number of items which have not been deleted matching the given query parameters
+*/
+func (db *SoftDeleteDatabase) Count(collection_name string, query interface{}) (int, error) {
+	return db.Database.Count(collection_name, db.excludeDeleted(collection_name, query))
+}
+
+/*
+	Stores the unique values of the field among the items which have not been deleted matching the given query parameters in result
+*/
+func (db *SoftDeleteDatabase) Distinct(collection_name string, field string, query interface{}, result interface{}) error {
+	return db.Database.Distinct(collection_name, field, db.excludeDeleted(collection_name, query), result)
+}
+
+/*
+	Marks one item matching the given query parameters as deleted
+*/
+func (db *SoftDeleteDatabase) RemoveOne(collection_name string, query interface{}) error {
+	if !db.collections[collection_name] {
+		return db.Database.RemoveOne(collection_name, query)
+	}
+
+	return db.Database.Update(collection_name, db.excludeDeleted(collection_name, query), getDeleteUpdate())
+}
+
+/*
+	Marks all items matching the given query parameters as deleted
+*/
+func (db *SoftDeleteDatabase) RemoveAll(collection_name string, query interface{}) (*ChangeResults, error) {
+	if !db.collections[collection_name] {
+		return db.Database.RemoveAll(collection_name, query)
+	}
+
+	change_results, err := db.Database.UpdateAll(collection_name, db.excludeDeleted(collection_name, query), getDeleteUpdate())
+
+	if err != nil {
+		return change_results, err
+	}
+
+	return &ChangeResults{Deleted: change_results.Updated}, nil
+}
+
+/*
+	Inserts the given item into the collection, replacing any deleted item it conflicts with on a unique index
+*/
+func (db *SoftDeleteDatabase) Insert(collection_name string, item interface{}) error {
+	err := db.removeConflictingDeleted(collection_name, item)
+
+	if err != nil {
+		return err
+	}
+
+	return db.Database.Insert(collection_name, item)
+}
+
+/*
+	Upserts the given item into the collection, replacing any deleted item matching the selector
+*/
+func (db *SoftDeleteDatabase) Upsert(collection_name string, selector interface{}, update interface{}) (*ChangeResults, error) {
+	err := db.removeMatchingDeleted(collection_name, selector)
+
+	if err != nil {
+		return &ChangeResults{}, err
+	}
+
+	return db.Database.Upsert(collection_name, selector, update)
+}
+
+/*
+	Finds an item which has not been deleted based on the given selector and updates it with the data in update
+*/
+func (db *SoftDeleteDatabase) Update(collection_name string, selector interface{}, update interface{}) error {
+	return db.Database.Update(collection_name, db.excludeDeleted(collection_name, selector), update)
+}
+
+/*
+	Finds all items which have not been deleted based on the given selector and updates them with the data in update
+*/
+func (db *SoftDeleteDatabase) UpdateAll(collection_name string, selector interface{}, update interface{}) (*ChangeResults, error) {
+	return db.Database.UpdateAll(collection_name, db.excludeDeleted(collection_name, selector), update)
+}
+
+/*
+	Atomically finds and updates an item which has not been deleted
+	Upserts replace any deleted item matching the selector
+*/
+func (db *SoftDeleteDatabase) FindAndModify(collection_name string, selector interface{}, update interface{}, upsert bool, result interface{}) error {
+	if !upsert {
+		return db.Database.FindAndModify(collection_name, db.excludeDeleted(collection_name, selector), update, upsert, result)
+	}
+
+	err := db.removeMatchingDeleted(collection_name, selector)
+
+	if err != nil {
+		return err
+	}
+
+	return db.Database.FindAndModify(collection_name, selector, update, upsert, result)
+}
+
+/*
+	Atomically updates the item which has not been deleted matching the given selector, but only if it also matches the given condition
+*/
+func (db *SoftDeleteDatabase) UpdateIfMatches(collection_name string, selector interface{}, condition interface{}, update interface{}) (bool, error) {
+	return db.Database.UpdateIfMatches(collection_name, db.excludeDeleted(collection_name, selector), condition, update)
+}
+
+/*
+	Runs the given operations, marking items as deleted rather than removing them for deletes
+	Consecutive operations of the same kind are run together so that deletes are still counted as deleted
+*/
+func (db *SoftDeleteDatabase) BulkWrite(collection_name string, operations []BulkOperation) (*BulkWriteResults, error) {
+	if !db.collections[collection_name] {
+		return db.Database.BulkWrite(collection_name, operations)
+	}
+
+	batches, err := getBulkBatches(operations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := newBulkWriteResults(len(operations))
+
+	for _, batch := range batches {
+		batch_operations := make([]BulkOperation, batch.end-batch.start)
+
+		for i, operation := range operations[batch.start:batch.end] {
+			batch_operations[i], err = db.getSoftDeleteOperation(collection_name, operation)
+
+			if err != nil {
+				return results, err
+			}
+		}
+
+		batch_results, err := db.Database.BulkWrite(collection_name, batch_operations)
+
+		if err != nil && err != ErrBulkWrite {
+			return results, err
+		}
+
+		copy(results.Operations[batch.start:batch.end], batch_results.Operations)
+
+		results.Inserted += batch_results.Inserted
+		results.Upserted += batch_results.Upserted
+
+		if batch.command == "delete" {
+			results.Deleted += batch_results.Updated
+		} else {
+			results.Updated += batch_results.Updated
+		}
+	}
+
+	return results, results.getError()
+}
+
+/*
+	Returns the operation which has the same effect as the given operation in a soft deleted collection
+	Deletes become updates which mark items as deleted, and creating items replaces any conflicting deleted items
+*/
+func (db *SoftDeleteDatabase) getSoftDeleteOperation(collection_name string, operation BulkOperation) (BulkOperation, error) {
+	switch operation.Type {
+	case BulkInsert:
+		return operation, db.removeConflictingDeleted(collection_name, operation.Item)
+	case BulkUpsert:
+		return operation, db.removeMatchingDeleted(collection_name, operation.Selector)
+	case BulkUpdate:
+		operation.Selector = db.excludeDeleted(collection_name, operation.Selector)
+	case BulkDelete:
+		operation.Type = BulkUpdate
+		operation.Selector = db.excludeDeleted(collection_name, operation.Selector)
+		operation.Item = getDeleteUpdate()
+	}
+
+	return operation, nil
+}
+
+/*
+	Runs the given aggregation pipeline on the items in the collection which have not been deleted
+	The pipeline must be a slice of stages
+*/
+func (db *SoftDeleteDatabase) Aggregate(collection_name string, pipeline interface{}, result interface{}) error {
+	if !db.collections[collection_name] {
+		return db.Database.Aggregate(collection_name, pipeline, result)
+	}
+
+	pipeline_value := reflect.ValueOf(pipeline)
+
+	if pipeline_value.Kind() != reflect.Slice && pipeline_value.Kind() != reflect.Array {
+		return ErrUnknown
+	}
+
+	stages := []interface{}{
+		QuerySelector{"$match": QuerySelector{DeletedAtField: QuerySelector{"$exists": false}}},
+	}
+
+	for i := 0; i < pipeline_value.Len(); i++ {
+		stages = append(stages, pipeline_value.Index(i).Interface())
+	}
+
+	return db.Database.Aggregate(collection_name, stages, result)
+}
+
+/*
+	Returns a stream of the changes to items in the collection matching the filter which have not been deleted
+	Deleting an item is reported as its removal from the items matching the filter
+*/
+func (db *SoftDeleteDatabase) Watch(collection_name string, filter interface{}) (*ChangeStream, error) {
+	return db.Database.Watch(collection_name, db.excludeDeleted(collection_name, filter))
+}
+
+/*
+	Runs fn within a transaction, soft deleting items removed by its operations on tx
+*/
+func (db *SoftDeleteDatabase) RunTransaction(fn func(tx Database) error) error {
+	return db.Database.RunTransaction(func(tx Database) error {
+		return fn(&SoftDeleteDatabase{
+			Database:    tx,
+			collections: db.collections,
+		})
+	})
+}
+
+/*
+	Returns a map of statistics for the items in a given collection which have not been deleted
+*/
+func (db *SoftDeleteDatabase) GetStats(collection_name string, fields []string) (map[string]interface{}, error) {
+	return GetAggregatedStats(db, collection_name, fields)
+}
+
+/*
+	Permanently removes the deleted items in the collection which match the given query
+*/
+func (db *SoftDeleteDatabase) removeMatchingDeleted(collection_name string, query interface{}) error {
+	if !db.collections[collection_name] {
+		return nil
+	}
+
+	_, err := db.Database.RemoveAll(collection_name, onlyDeleted(query))
+
+	return err
+}
+
+/*
+	Permanently removes the deleted items in the collection which have the same values as item
+	for the fields of a unique index, so that item can be inserted
+*/
+func (db *SoftDeleteDatabase) removeConflictingDeleted(collection_name string, item interface{}) error {
+	if !db.collections[collection_name] {
+		return nil
+	}
+
+	indexes, err := db.Database.GetIndexes(collection_name)
+
+	if err != nil {
+		return err
+	}
+
+	document, err := toDocument(item)
+
+	if err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		if !index.Unique {
+			continue
+		}
+
+		selector := QuerySelector{}
+
+		for _, key := range index.Key {
+			field := strings.TrimPrefix(key, "-")
+			value, exists := getField(document, field)
+
+			if !exists {
+				selector = nil
+				break
+			}
+
+			selector[field] = value
+		}
+
+		if selector == nil {
+			continue
+		}
+
+		err = db.removeMatchingDeleted(collection_name, selector)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the database as a SoftDeleteDatabase, or ErrSoftDeleteDisabled if it does not soft delete the collection
+*/
+func getSoftDeleteDatabase(db Database, collection_name string) (*SoftDeleteDatabase, error) {
+	soft_delete_db, ok := db.(*SoftDeleteDatabase)
+
+	if !ok || !soft_delete_db.collections[collection_name] {
+		return nil, ErrSoftDeleteDisabled
+	}
+
+	return soft_delete_db, nil
+}
+
+/*
+	Finds all deleted items in the collection matching the given query parameters, most recently deleted first
+	Returns ErrSoftDeleteDisabled if the database does not soft delete the collection
+*/
+func FindAllDeleted(db Database, collection_name string, query interface{}, result interface{}) error {
+	soft_delete_db, err := getSoftDeleteDatabase(db, collection_name)
+
+	if err != nil {
+		return err
+	}
+
+	sort_fields := []SortField{{Name: DeletedAtField, Reversed: true}}
+
+	return soft_delete_db.Database.FindAllSorted(collection_name, onlyDeleted(query), sort_fields, result)
+}
+
+/*
+	Restores one deleted item in the collection matching the given query parameters
+	Returns ErrNotFound if no deleted item matches, and ErrSoftDeleteDisabled if the database does not soft
+	delete the collection
+*/
+func RestoreOne(db Database, collection_name string, query interface{}) error {
+	soft_delete_db, err := getSoftDeleteDatabase(db, collection_name)
+
+	if err != nil {
+		return err
+	}
+
+	update := QuerySelector{
+		"$unset": QuerySelector{DeletedAtField: ""},
+	}
+
+	return soft_delete_db.Database.Update(collection_name, onlyDeleted(query), update)
+}
+
+/*
+	Restores every deleted item in the collection matching the given query parameters
+	Returns ErrSoftDeleteDisabled if the database does not soft delete the collection
+*/
+func RestoreAll(db Database, collection_name string, query interface{}) (*ChangeResults, error) {
+	soft_delete_db, err := getSoftDeleteDatabase(db, collection_name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	update := QuerySelector{
+		"$unset": QuerySelector{DeletedAtField: ""},
+	}
+
+	return soft_delete_db.Database.UpdateAll(collection_name, onlyDeleted(query), update)
+}
+
+/*
+	Permanently removes the items in every soft deleted collection which were deleted before the given time
+	Returns ErrSoftDeleteDisabled if the database does not soft delete items
+*/
+func PurgeDeleted(db Database, before time.Time) (*ChangeResults, error) {
+	soft_delete_db, ok := db.(*SoftDeleteDatabase)
+
+	if !ok {
+		return nil, ErrSoftDeleteDisabled
+	}
+
+	query := QuerySelector{
+		DeletedAtField: QuerySelector{"$lt": before.Unix()},
+	}
+
+	purge_results := ChangeResults{}
+
+	for collection_name := range soft_delete_db.collections {
+		change_results, err := soft_delete_db.Database.RemoveAll(collection_name, query)
+
+		if err != nil {
+			return &purge_results, err
+		}
+
+		purge_results.Deleted += change_results.Deleted
+	}
+
+	return &purge_results, nil
+}
+
+/*
+	Periodically purges the items which were deleted longer ago than the retention period until it is stopped
+*/
+type PurgeJob struct {
+	stop      chan struct{}
+	done      chan struct{}
+	stop_once sync.Once
+}
+
+/*
+	Starts a job purging the items in db which were deleted longer ago than retention, running once immediately
+	and then after every interval
+	Errors are passed to on_error, which may be nil
+*/
+func StartPurgeJob(db Database, retention time.Duration, interval time.Duration, on_error func(err error)) *PurgeJob {
+	job := PurgeJob{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(job.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			_, err := PurgeDeleted(db, time.Now().Add(-retention))
+
+			if err != nil && on_error != nil {
+				on_error(err)
+			}
+
+			select {
+			case <-job.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return &job
+}
+
+/*
+	Stops the job, waiting for any purge in progress to finish
+	Stopping a nil job does nothing
+*/
+func (job *PurgeJob) Stop() {
+	if job == nil {
+		return
+	}
+
+	job.stop_once.Do(func() {
+		close(job.stop)
+	})
+
+	<-job.done
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"errors"
+	"strings"
+)
+
+/*
+	The url parameter holding the fields to sort results by
+*/
+const SortParameter = "sort"
+
+/*
+	The url parameter which older clients used in place of sort, and which is still accepted as an alias of it
+*/
+const LegacySortParameter = "sortby"
+
+/*
+	Extracts the sort url parameter and removes it from parameters
+	This must be called before passing parameters to CreateFilterQuery
+
+	sort is a comma separated list of the model's fields, where fields prefixed by - are sorted in descending order
+	For example, sort=startTime,-name sorts by ascending startTime, breaking ties by descending name
+	Fields are validated in the same way as filter keys, so nested fields and DataStore fields are supported
+	Returns nil if no sort was requested, or a FilterError if a field is unknown
+	The legacy sortby parameter is parsed in the same way as sort, but both cannot be given
+*/
+func ParseSortParameters(parameters map[string][]string, model interface{}) ([]SortField, error) {
+	sort_param, has_sort := parameters[SortParameter]
+	legacy_sort_param, has_legacy_sort := parameters[LegacySortParameter]
+	delete(parameters, SortParameter)
+	delete(parameters, LegacySortParameter)
+
+	if has_legacy_sort {
+		if has_sort {
+			return nil, FilterError{Key: SortParameter, Err: errors.New("Cannot provide both " + SortParameter + " and " + LegacySortParameter)}
+		}
+
+		sort_param, has_sort = legacy_sort_param, true
+	}
+
+	if !has_sort {
+		return nil, nil
+	}
+
+	if len(sort_param) != 1 {
+		return nil, FilterError{Key: SortParameter, Err: errors.New("Multiple usage of key " + SortParameter)}
+	}
+
+	filter_fields := getFilterFields(model)
+	sort_fields := []SortField{}
+
+	for _, field_name := range strings.Split(sort_param[0], ",") {
+		field_name = strings.TrimSpace(field_name)
+
+		reversed := strings.HasPrefix(field_name, "-")
+		field_name = strings.TrimPrefix(field_name, "-")
+
+		if field_name == "" {
+			return nil, FilterError{Key: SortParameter, Err: errors.New("Empty sort field")}
+		}
+
+		field, ok := getFilterField(filter_fields, strings.ToLower(field_name), field_name)
+
+		if !ok {
+			return nil, FilterError{Key: SortParameter, Err: errors.New("Unknown field " + field_name)}
+		}
+
+		sort_fields = append(sort_fields, SortField{
+			Name:     field.path,
+			Reversed: reversed,
+		})
+	}
+
+	return sort_fields, nil
+}
+
+/*
+	Returns the sort fields followed by _id, unless they already sort by _id
+	Pages are only stable if no two items are ordered equally, so paginated finds break ties by the unique _id,
+	and are ordered by _id if no sort fields are given
+*/
+func getPaginationSortFields(sort_fields []SortField) []SortField {
+	for _, field := range sort_fields {
+		if strings.TrimLeft(field.Name, "+-") == "_id" {
+			return sort_fields
+		}
+	}
+
+	return append(sort_fields[:len(sort_fields):len(sort_fields)], SortField{Name: "_id"})
+}
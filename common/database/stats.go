@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/HackIllinois/api/common/utils"
+	"gopkg.in/mgo.v2/bson"
 	"reflect"
 	"strings"
 )
@@ -94,6 +95,157 @@ func UpdateStatsField(stats map[string]interface{}, key string, value interface{
 	return nil
 }
 
+/*
+	Used to decode the number of items with a given value from a stats aggregation pipeline
+*/
+type statsValueCount struct {
+	Value interface{} `bson:"_id"`
+	Count int         `bson:"count"`
+}
+
+/*
+	Returns the stats for the given fields of every item in the collection
+	The values are grouped and counted by the database, and the result is identical to
+	calling AddEntryToStats with every item in the collection
+*/
+func GetAggregatedStats(db Database, collection_name string, fields []string) (map[string]interface{}, error) {
+	stats := GetDefaultStats()
+
+	err := addAggregatedStats(db, collection_name, stats, "", fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	count_pipeline := []QuerySelector{
+		{
+			"$group": QuerySelector{
+				"_id": nil,
+				"count": QuerySelector{
+					"$sum": 1,
+				},
+			},
+		},
+	}
+
+	var counts []statsValueCount
+	err = db.Aggregate(collection_name, count_pipeline, &counts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats["count"] = 0
+
+	if len(counts) > 0 {
+		stats["count"] = counts[0].Count
+	}
+
+	return stats, nil
+}
+
+/*
+	Adds the counts of each value of the given fields, relative to the path prefix, to the stats
+	Fields containing documents are added as nested stats
+*/
+func addAggregatedStats(db Database, collection_name string, stats map[string]interface{}, prefix string, fields []string) error {
+	stripped_fields := RemoveTopLevel(fields)
+
+	for _, key := range ExtractTopLevel(fields) {
+		if _, exists := stats[key]; exists {
+			continue
+		}
+
+		path := prefix + key
+
+		// Array values are counted by element, and every document value is grouped
+		// together since document values are described by nested stats instead
+		pipeline := []QuerySelector{
+			{
+				"$match": QuerySelector{
+					path: QuerySelector{
+						"$exists": true,
+					},
+				},
+			},
+			{
+				"$unwind": "$" + path,
+			},
+			{
+				"$group": QuerySelector{
+					"_id": QuerySelector{
+						"$cond": []interface{}{
+							QuerySelector{
+								"$eq": []interface{}{
+									QuerySelector{
+										"$type": "$" + path,
+									},
+									"object",
+								},
+							},
+							QuerySelector{
+								"$literal": QuerySelector{},
+							},
+							"$" + path,
+						},
+					},
+					"count": QuerySelector{
+						"$sum": 1,
+					},
+				},
+			},
+		}
+
+		var value_counts []statsValueCount
+		err := db.Aggregate(collection_name, pipeline, &value_counts)
+
+		if err != nil {
+			return err
+		}
+
+		for _, value_count := range value_counts {
+			switch value_count.Value.(type) {
+			case bson.M, map[string]interface{}:
+				_, exists := stats[key]
+
+				if !exists {
+					stats[key] = make(map[string]interface{})
+				}
+
+				mapped_stats, ok := stats[key].(map[string]interface{})
+
+				if !ok {
+					return ErrTypeMismatch
+				}
+
+				err = addAggregatedStats(db, collection_name, mapped_stats, path+".", stripped_fields)
+
+				if err != nil {
+					return err
+				}
+			default:
+				_, exists := stats[key]
+
+				if !exists {
+					stats[key] = make(map[string]int)
+				}
+
+				mapped_stats, ok := stats[key].(map[string]int)
+
+				if !ok {
+					return ErrTypeMismatch
+				}
+
+				// Values are keyed by their string representation, so distinct values with the same representation are combined
+				value_key := fmt.Sprintf("%v", value_count.Value)
+				mapped_stats[value_key] = mapped_stats[value_key] + value_count.Count
+			}
+		}
+	}
+
+	return nil
+}
+
 /*
 	Remove everything in each field after, and including, the first '.'
 */
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	ChangeInsert = "insert"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+/*
+	The interval at which collections are polled for changes by databases which do not support change streams
+*/
+var WatchPollInterval = time.Second
+
+/*
+	Describes a change to an item in a watched collection
+	Document holds the item after the change, and is nil for deletes
+*/
+type ChangeEvent struct {
+	Operation  string
+	DocumentID interface{}
+	Document   bson.M
+}
+
+/*
+	Decodes the changed item into result
+*/
+func (event *ChangeEvent) Decode(result interface{}) error {
+	if event.Document == nil {
+		return ErrNotFound
+	}
+
+	return fromDocument(event.Document, result)
+}
+
+/*
+	A stream of changes to the items in a collection matching a filter
+	The events channel is closed when the stream is closed, its context is done, or an error occurs
+*/
+type ChangeStream struct {
+	events     chan ChangeEvent
+	done       chan struct{}
+	close_once sync.Once
+	mutex      sync.Mutex
+	err        error
+}
+
+func newChangeStream() *ChangeStream {
+	return &ChangeStream{
+		events: make(chan ChangeEvent),
+		done:   make(chan struct{}),
+	}
+}
+
+/*
+	Returns the channel on which change events are delivered
+*/
+func (stream *ChangeStream) Events() <-chan ChangeEvent {
+	return stream.events
+}
+
+/*
+	Returns the error which stopped the stream, or nil if it was closed or is still running
+*/
+func (stream *ChangeStream) Err() error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	return stream.err
+}
+
+/*
+	Stops the stream
+*/
+func (stream *ChangeStream) Close() {
+	stream.close_once.Do(func() {
+		close(stream.done)
+	})
+}
+
+/*
+	Records the error which stopped the stream
+*/
+func (stream *ChangeStream) setErr(err error) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	stream.err = err
+}
+
+/*
+	Delivers the event, returning false if the stream was stopped first
+*/
+func (stream *ChangeStream) send(ctx context.Context, event ChangeEvent) bool {
+	select {
+	case stream.events <- event:
+		return true
+	case <-stream.done:
+		return false
+	case <-ctx.Done():
+		stream.setErr(convertContextError(ctx.Err()))
+		return false
+	}
+}
+
+/*
+	Watches the collection by periodically finding every item matching the filter and comparing it to the previous results
+	Items which start or stop matching the filter are reported as inserts and deletes
+	This is the fallback for databases which do not support change streams
+*/
+func pollChanges(ctx context.Context, db Database, collection_name string, filter interface{}) (*ChangeStream, error) {
+	snapshot, err := getChangeSnapshot(db, collection_name, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stream := newChangeStream()
+
+	go func() {
+		defer close(stream.events)
+
+		ticker := time.NewTicker(WatchPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stream.done:
+				return
+			case <-ctx.Done():
+				stream.setErr(convertContextError(ctx.Err()))
+				return
+			case <-ticker.C:
+			}
+
+			current_snapshot, err := getChangeSnapshot(db, collection_name, filter)
+
+			if err != nil {
+				stream.setErr(err)
+				return
+			}
+
+			for _, event := range diffChangeSnapshots(snapshot, current_snapshot) {
+				if !stream.send(ctx, event) {
+					return
+				}
+			}
+
+			snapshot = current_snapshot
+		}
+	}()
+
+	return stream, nil
+}
+
+/*
+	Used to store the items matching a watched filter, keyed by their _id
+*/
+type changeSnapshot struct {
+	ids       []string
+	documents map[string]bson.M
+}
+
+/*
+	Returns the items in the collection currently matching the filter
+*/
+func getChangeSnapshot(db Database, collection_name string, filter interface{}) (*changeSnapshot, error) {
+	var documents []bson.M
+	err := db.FindAll(collection_name, filter, &documents)
+
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := changeSnapshot{
+		ids:       []string{},
+		documents: make(map[string]bson.M),
+	}
+
+	for _, document := range documents {
+		id := fmt.Sprintf("%#v", document["_id"])
+
+		snapshot.ids = append(snapshot.ids, id)
+		snapshot.documents[id] = document
+	}
+
+	return &snapshot, nil
+}
+
+/*
+	Returns the events describing the changes between two snapshots, in the order the items were found
+*/
+func diffChangeSnapshots(previous *changeSnapshot, current *changeSnapshot) []ChangeEvent {
+	events := []ChangeEvent{}
+
+	for _, id := range current.ids {
+		document := current.documents[id]
+		previous_document, existed := previous.documents[id]
+
+		if !existed {
+			events = append(events, ChangeEvent{
+				Operation:  ChangeInsert,
+				DocumentID: document["_id"],
+				Document:   document,
+			})
+		} else if !reflect.DeepEqual(previous_document, document) {
+			events = append(events, ChangeEvent{
+				Operation:  ChangeUpdate,
+				DocumentID: document["_id"],
+				Document:   document,
+			})
+		}
+	}
+
+	for _, id := range previous.ids {
+		if _, exists := current.documents[id]; !exists {
+			events = append(events, ChangeEvent{
+				Operation:  ChangeDelete,
+				DocumentID: previous.documents[id]["_id"],
+			})
+		}
+	}
+
+	return events
+}
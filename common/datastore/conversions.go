@@ -1,5 +1,9 @@
 package datastore
 
+import (
+	"time"
+)
+
 func toInt(raw_data interface{}, definition DataStoreDefinition) (interface{}, error) {
 	data, ok := raw_data.(float64)
 
@@ -52,15 +56,16 @@ func toObject(raw_data interface{}, definition DataStoreDefinition) (interface{}
 	for _, field := range definition.Fields {
 		unfiltered_fields, exists := unfiltered_data[field.Name]
 
+		var err error
+
 		if exists {
-			var err error
 			data[field.Name], err = buildDataFromDefinition(unfiltered_fields, field)
-
-			if err != nil {
-				return nil, NewErrInField(field.Name, err)
-			}
 		} else {
-			data[field.Name] = getDefaultValue(field.Type)
+			data[field.Name], err = getDefaultValue(field)
+		}
+
+		if err != nil {
+			return nil, NewErrInField(field.Name, err)
 		}
 	}
 
@@ -177,12 +182,110 @@ func toObjectArray(raw_data interface{}, definition DataStoreDefinition) (interf
 	return data, nil
 }
 
-func getDefaultValue(t string) interface{} {
-	value, exists := defaultValues[t]
+func toEnum(raw_data interface{}, definition DataStoreDefinition) (interface{}, error) {
+	data, ok := raw_data.(string)
+
+	if !ok {
+		return nil, NewErrTypeMismatch(raw_data, "string")
+	}
+
+	for _, value := range definition.Values {
+		if data == value {
+			return data, nil
+		}
+	}
+
+	return nil, NewErrInvalidEnumValue(data, definition.Values)
+}
+
+/*
+	Converts a unix time in seconds or an RFC3339 formatted string to a unix time in seconds
+*/
+func toTimestamp(raw_data interface{}, definition DataStoreDefinition) (interface{}, error) {
+	switch data := raw_data.(type) {
+	case float64:
+		return int64(data), nil
+	case string:
+		timestamp, err := time.Parse(time.RFC3339, data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return timestamp.Unix(), nil
+	default:
+		return nil, NewErrTypeMismatch(raw_data, "float64 or RFC3339 string")
+	}
+}
+
+/*
+	Converts each element of an array with the conversion of the array's element type
+*/
+func toArray(raw_data interface{}, definition DataStoreDefinition) (interface{}, error) {
+	unfiltered_data, ok := raw_data.([]interface{})
+
+	if !ok {
+		return nil, NewErrTypeMismatch(raw_data, "[]interface{}")
+	}
+
+	element_definition := getElementDefinition(definition)
+	data := make([]interface{}, len(unfiltered_data))
+
+	for i, element := range unfiltered_data {
+		var err error
+		data[i], err = buildDataFromDefinition(element, element_definition)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+/*
+	Converts each value of a map with the conversion of the map's value type
+*/
+func toMap(raw_data interface{}, definition DataStoreDefinition) (interface{}, error) {
+	unfiltered_data, ok := raw_data.(map[string]interface{})
+
+	if !ok {
+		return nil, NewErrTypeMismatch(raw_data, "map[string]interface{}")
+	}
+
+	element_definition := getElementDefinition(definition)
+	data := make(map[string]interface{})
+
+	for key, element := range unfiltered_data {
+		var err error
+		data[key], err = buildDataFromDefinition(element, element_definition)
+
+		if err != nil {
+			return nil, NewErrInField(key, err)
+		}
+	}
+
+	return data, nil
+}
+
+/*
+	Returns the value of a missing field, which is the definition's default if it has one
+	Otherwise nullable fields are nil, and other fields are their type's default value
+*/
+func getDefaultValue(definition DataStoreDefinition) (interface{}, error) {
+	if definition.Default != nil {
+		return buildDataFromDefinition(definition.Default, definition)
+	}
+
+	if definition.Nullable {
+		return nil, nil
+	}
+
+	value, exists := defaultValues[definition.Type]
 
 	if !exists {
-		return nil
+		return nil, nil
 	}
 
-	return value
+	return value, nil
 }
@@ -3,13 +3,27 @@ package datastore
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
+/*
+	Describes a field of a datastore
+	Values lists the accepted values of enum fields
+	Default is used in place of a missing field, and is given in the same format as the field in a request
+	Nullable fields accept null, which is stored as nil and skips the field's validations
+	Map types such as map[string]int hold values of the given type under any keys
+	The version and migrations of the top level definition describe how records written with older versions are upgraded
+*/
 type DataStoreDefinition struct {
 	Name        string                `json:"name"`
 	Type        string                `json:"type"`
 	Validations string                `json:"validations"`
 	Fields      []DataStoreDefinition `json:"fields"`
+	Values      []string              `json:"values"`
+	Default     interface{}           `json:"default"`
+	Nullable    bool                  `json:"nullable"`
+	Version     int                   `json:"version"`
+	Migrations  []DataStoreMigration  `json:"migrations"`
 }
 
 type DataStore struct {
@@ -23,6 +37,100 @@ func NewDataStore(definition DataStoreDefinition) DataStore {
 	}
 }
 
+/*
+	Returns the names of the top level fields in the datastore's definition
+*/
+func (datastore DataStore) GetFieldNames() []string {
+	field_names := make([]string, len(datastore.Definition.Fields))
+
+	for i, field := range datastore.Definition.Fields {
+		field_names[i] = field.Name
+	}
+
+	return field_names
+}
+
+/*
+	Returns a copy of the definition without the given top level fields
+	This is used to describe requests which omit fields that are set by the api, such as ids and timestamps
+*/
+func (definition DataStoreDefinition) WithoutFields(field_names ...string) DataStoreDefinition {
+	excluded_fields := make(map[string]bool)
+	for _, field_name := range field_names {
+		excluded_fields[field_name] = true
+	}
+
+	fields := []DataStoreDefinition{}
+	for _, field := range definition.Fields {
+		if !excluded_fields[field.Name] {
+			fields = append(fields, field)
+		}
+	}
+
+	definition.Fields = fields
+
+	return definition
+}
+
+/*
+	Returns a map from the dotted path of every field in the datastore's definition, including nested fields, to its type
+	For example, a programmingExperience object holding an int go field has types["programmingExperience.go"] = "int"
+*/
+func (datastore DataStore) GetFieldTypes() map[string]string {
+	field_types := make(map[string]string)
+
+	addFieldTypes(field_types, datastore.Definition.Fields, "")
+
+	return field_types
+}
+
+/*
+	Adds the types of the given fields to field_types, under the given path prefix
+	Fields of objects and object arrays are added under their dotted paths
+*/
+func addFieldTypes(field_types map[string]string, fields []DataStoreDefinition, prefix string) {
+	for _, field := range fields {
+		path := prefix + field.Name
+		field_types[path] = field.Type
+
+		if field.Type == "object" || field.Type == "[]object" {
+			addFieldTypes(field_types, field.Fields, path+".")
+		}
+	}
+}
+
+/*
+	The prefix of map types, which is followed by the type of the map's values
+*/
+const mapTypePrefix = "map[string]"
+
+/*
+	Returns whether the given type is a map type, such as map[string]int
+*/
+func isMapType(t string) bool {
+	return strings.HasPrefix(t, mapTypePrefix)
+}
+
+/*
+	Returns the definition of each element of a field with an array or map type
+	Validations, defaults and nullability belong to the field itself rather than its elements
+*/
+func getElementDefinition(definition DataStoreDefinition) DataStoreDefinition {
+	element_definition := definition
+
+	if isMapType(definition.Type) {
+		element_definition.Type = strings.TrimPrefix(definition.Type, mapTypePrefix)
+	} else {
+		element_definition.Type = strings.TrimPrefix(definition.Type, "[]")
+	}
+
+	element_definition.Validations = ""
+	element_definition.Default = nil
+	element_definition.Nullable = false
+
+	return element_definition
+}
+
 var ErrInvalidDefinition = errors.New("DataStore definition is invalid")
 var ErrInvalidData = errors.New("Invalid data unmarshalled")
 
@@ -30,6 +138,10 @@ func NewErrTypeMismatch(raw_data interface{}, expected string) error {
 	return fmt.Errorf("Type mismatch in data and definition. Expected %s, got %T", expected, raw_data)
 }
 
+func NewErrInvalidEnumValue(raw_data interface{}, accepted_values []string) error {
+	return fmt.Errorf("Value %v is not one of the accepted values %v", raw_data, accepted_values)
+}
+
 type ErrorInField struct {
 	FieldName string
 	Err       error
@@ -68,6 +180,10 @@ func init() {
 	conversionFuncs["[]string"] = toStringArray
 	conversionFuncs["[]boolean"] = toBooleanArray
 	conversionFuncs["[]object"] = toObjectArray
+	conversionFuncs["enum"] = toEnum
+	conversionFuncs["timestamp"] = toTimestamp
+	conversionFuncs["[]enum"] = toArray
+	conversionFuncs["[]timestamp"] = toArray
 
 	defaultValues = make(map[string]interface{})
 	defaultValues["int"] = 0
@@ -80,4 +196,8 @@ func init() {
 	defaultValues["[]string"] = nil
 	defaultValues["[]boolean"] = nil
 	defaultValues["[]object"] = nil
+	defaultValues["enum"] = ""
+	defaultValues["timestamp"] = 0
+	defaultValues["[]enum"] = nil
+	defaultValues["[]timestamp"] = nil
 }
@@ -0,0 +1,141 @@
+package datastore
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+var ErrInvalidPatch = errors.New("Merge patch must be a JSON object")
+var ErrStoredVersionChanged = errors.New("The stored record was changed to another definition version while it was being patched")
+
+/*
+	Returns a copy of the datastore with the given JSON Merge Patch (RFC 7396) applied to its data
+	Objects in the patch are merged into the existing objects, fields set to null are removed and so are given
+	their default value, and any other value replaces the existing one
+	Also returns the names of the top level fields in the definition which the patch changes
+*/
+func (datastore *DataStore) ApplyMergePatch(patch []byte) (DataStore, []string, error) {
+	var raw_patch interface{}
+	err := json.Unmarshal(patch, &raw_patch)
+
+	if err != nil {
+		return DataStore{}, nil, err
+	}
+
+	patch_data, ok := raw_patch.(map[string]interface{})
+
+	if !ok {
+		return DataStore{}, nil, ErrInvalidPatch
+	}
+
+	// The stored data is converted to its json form, so that it can be rebuilt from the definition once merged
+	original_json, err := json.Marshal(datastore.Data)
+
+	if err != nil {
+		return DataStore{}, nil, err
+	}
+
+	var original_data interface{}
+	err = json.Unmarshal(original_json, &original_data)
+
+	if err != nil {
+		return DataStore{}, nil, err
+	}
+
+	merged_json, err := json.Marshal(mergePatch(original_data, patch_data))
+
+	if err != nil {
+		return DataStore{}, nil, err
+	}
+
+	merged := NewDataStore(datastore.Definition)
+	err = json.Unmarshal(merged_json, &merged)
+
+	if err != nil {
+		return DataStore{}, nil, err
+	}
+
+	changed_fields := []string{}
+	for _, field := range datastore.Definition.Fields {
+		if _, is_patched := patch_data[field.Name]; is_patched {
+			changed_fields = append(changed_fields, field.Name)
+		}
+	}
+
+	return merged, changed_fields, nil
+}
+
+/*
+	Returns an update which sets the given top level fields to their values in the datastore,
+	leaving every other field of the stored record unchanged
+*/
+func (datastore *DataStore) GetFieldsUpdate(field_names []string) database.QuerySelector {
+	fields := database.QuerySelector{}
+
+	for _, field_name := range field_names {
+		fields[field_name] = datastore.Data[field_name]
+	}
+
+	return database.QuerySelector{"$set": fields}
+}
+
+/*
+	Returns an update which saves a patch, applied to the datastore, over a stored record which was written with
+	the given version of the definition
+	A stored record which needs to be upgraded is replaced by the whole datastore, so that its upgrade is saved
+	along with the patch, and otherwise only the given top level fields are set
+*/
+func (datastore *DataStore) GetPatchUpdate(stored_version int, field_names []string) interface{} {
+	if stored_version < datastore.Definition.Version {
+		return datastore
+	}
+
+	return datastore.GetFieldsUpdate(field_names)
+}
+
+/*
+	Returns the condition for saving a patch with GetPatchUpdate, which matches the stored record only if it is still
+	written with the given version of the definition
+	Records written before the definition was versioned have no version and match version 0
+*/
+func GetPatchCondition(stored_version int) database.QuerySelector {
+	if stored_version == 0 {
+		return database.QuerySelector{
+			"$or": []interface{}{
+				database.QuerySelector{DefinitionVersionKey: database.QuerySelector{"$exists": false}},
+				database.QuerySelector{DefinitionVersionKey: 0},
+			},
+		}
+	}
+
+	return database.QuerySelector{DefinitionVersionKey: stored_version}
+}
+
+/*
+	Merges the patch into the target as described by RFC 7396
+*/
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patch_object, is_object := patch.(map[string]interface{})
+
+	if !is_object {
+		return patch
+	}
+
+	target_object, is_object := target.(map[string]interface{})
+
+	if !is_object {
+		target_object = make(map[string]interface{})
+	}
+
+	for key, value := range patch_object {
+		if value == nil {
+			delete(target_object, key)
+		} else {
+			target_object[key] = mergePatch(target_object[key], value)
+		}
+	}
+
+	return target_object
+}
@@ -0,0 +1,621 @@
+package datastore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	The JSON Schema draft which generated schemas conform to
+*/
+const JSONSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+/*
+	The key under which the original validator tags of each field are included in its schema
+	Clients can fall back to these for any tags which have no JSON Schema equivalent
+*/
+const JSONSchemaValidationsKey = "x-validations"
+
+/*
+	Returns a JSON Schema describing the data accepted by a datastore with this definition
+	The validator tags of each field are translated to their JSON Schema equivalent where one exists,
+	so that clients can render and pre-validate forms from the same definition used by the api
+*/
+func (definition DataStoreDefinition) ToJSONSchema() (map[string]interface{}, error) {
+	schema, _, err := getFieldSchema(definition)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schema["$schema"] = JSONSchemaDraft
+	schema["title"] = definition.Name
+
+	return schema, nil
+}
+
+/*
+	Returns the schema of the given field, and whether the field must be present in its parent object
+	A missing field is given its type's default value before validation, so fields which cannot hold
+	their default value are required
+*/
+func getFieldSchema(definition DataStoreDefinition) (map[string]interface{}, bool, error) {
+	schema, err := getTypeSchema(definition)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if definition.Validations != "" {
+		schema[JSONSchemaValidationsKey] = definition.Validations
+	}
+
+	field_tags, item_tags, has_dive := splitDiveTags(definition.Validations)
+
+	is_required, err := applyValidationTags(schema, definition.Type, field_tags)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if has_dive {
+		items, is_array := schema["items"].(map[string]interface{})
+
+		if !is_array {
+			items, is_array = schema["additionalProperties"].(map[string]interface{})
+		}
+
+		if !is_array {
+			return nil, false, fmt.Errorf("dive is only supported on array and map types, not %s", definition.Type)
+		}
+
+		_, err = applyValidationTags(items, getElementDefinition(definition).Type, item_tags)
+
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Missing fields with a default, and nullable fields, skip the validations which would require them
+	if definition.Default != nil {
+		schema["default"] = definition.Default
+		is_required = false
+	}
+
+	if definition.Nullable {
+		allowNull(schema)
+		is_required = false
+	}
+
+	return schema, is_required, nil
+}
+
+/*
+	Allows null in addition to the values already accepted by the schema
+*/
+func allowNull(schema map[string]interface{}) {
+	switch schema_type := schema["type"].(type) {
+	case string:
+		schema["type"] = []interface{}{schema_type, "null"}
+	case []interface{}:
+		schema["type"] = append(schema_type, "null")
+	}
+
+	if values, has_enum := schema["enum"].([]interface{}); has_enum {
+		schema["enum"] = append(values, nil)
+	}
+
+	if alternatives, has_alternatives := schema["anyOf"].([]interface{}); has_alternatives {
+		schema["anyOf"] = append(alternatives, map[string]interface{}{"type": "null"})
+	}
+}
+
+/*
+	Returns the schema of the given field's type, without any of its validations
+	Objects include the schemas of their fields, and arrays include the schema of their elements
+*/
+func getTypeSchema(definition DataStoreDefinition) (map[string]interface{}, error) {
+	switch definition.Type {
+	case "int":
+		return map[string]interface{}{"type": "integer"}, nil
+	case "float":
+		return map[string]interface{}{"type": "number"}, nil
+	case "string":
+		return map[string]interface{}{"type": "string"}, nil
+	case "boolean":
+		return map[string]interface{}{"type": "boolean"}, nil
+	case "enum":
+		values := make([]interface{}, len(definition.Values))
+		for i, value := range definition.Values {
+			values[i] = value
+		}
+
+		return map[string]interface{}{"type": "string", "enum": values}, nil
+	case "timestamp":
+		// Formats only apply to strings, so unix times are still accepted
+		return map[string]interface{}{"type": []interface{}{"string", "integer"}, "format": "date-time"}, nil
+	case "object":
+		return getObjectSchema(definition)
+	case "[]int", "[]float", "[]string", "[]boolean", "[]object", "[]enum", "[]timestamp":
+		items, err := getTypeSchema(getElementDefinition(definition))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"type":  "array",
+			"items": items,
+		}, nil
+	default:
+		if isMapType(definition.Type) {
+			values, err := getTypeSchema(getElementDefinition(definition))
+
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": values,
+			}, nil
+		}
+
+		return nil, ErrInvalidDefinition
+	}
+}
+
+/*
+	Returns the schema of an object with the given definition's fields
+	An object without any fields accepts any properties
+*/
+func getObjectSchema(definition DataStoreDefinition) (map[string]interface{}, error) {
+	schema := map[string]interface{}{"type": "object"}
+
+	if len(definition.Fields) == 0 {
+		return schema, nil
+	}
+
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	for _, field := range definition.Fields {
+		field_schema, is_required, err := getFieldSchema(field)
+
+		if err != nil {
+			return nil, NewErrInField(field.Name, err)
+		}
+
+		properties[field.Name] = field_schema
+
+		if is_required {
+			required = append(required, field.Name)
+		}
+	}
+
+	schema["properties"] = properties
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	conditions, err := getConditionalSchemas(definition)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conditions) > 0 {
+		schema["allOf"] = conditions
+	}
+
+	return schema, nil
+}
+
+/*
+	Returns true if the tag is a required_if or required_unless tag
+*/
+func isConditionalTag(tag string) bool {
+	name, _ := splitValidationTag(tag)
+
+	return name == "required_if" || name == "required_unless"
+}
+
+/*
+	Returns an if then schema for each required_if and required_unless tag of the object's fields
+	required_if requires the field when the object matches the condition, and required_unless requires it otherwise
+*/
+func getConditionalSchemas(definition DataStoreDefinition) ([]interface{}, error) {
+	conditions := []interface{}{}
+
+	for _, field := range definition.Fields {
+		field_tags, _, _ := splitDiveTags(field.Validations)
+
+		for _, tag := range field_tags {
+			if !isConditionalTag(tag) {
+				continue
+			}
+
+			name, param := splitValidationTag(tag)
+
+			condition, err := getConditionSchema(definition, param)
+
+			if err != nil {
+				return nil, NewErrInField(field.Name, err)
+			}
+
+			required_schema := map[string]interface{}{"required": []string{field.Name}}
+
+			value_schema, err := getTagSchema("required", getStoredType(field.Type))
+
+			if err != nil {
+				return nil, NewErrInField(field.Name, err)
+			}
+
+			if len(value_schema) > 0 {
+				required_schema["properties"] = map[string]interface{}{field.Name: value_schema}
+			}
+
+			if name == "required_if" {
+				conditions = append(conditions, map[string]interface{}{"if": condition, "then": required_schema})
+			} else {
+				conditions = append(conditions, map[string]interface{}{"if": condition, "else": required_schema})
+			}
+		}
+	}
+
+	return conditions, nil
+}
+
+/*
+	Returns a schema matching objects in which every field named in the parameter has the value following it
+	Values are parsed according to the type of the field they are compared to
+*/
+func getConditionSchema(definition DataStoreDefinition, param string) (map[string]interface{}, error) {
+	pairs := strings.Fields(param)
+
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("Conditional validation parameter '%s' must be pairs of field names and values", param)
+	}
+
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	for i := 0; i < len(pairs); i += 2 {
+		value_type := "string"
+		if field, exists := getFieldDefinition(definition, pairs[i]); exists {
+			value_type = getStoredType(field.Type)
+		}
+
+		values, err := parseParamValues(pairs[i+1:i+2], value_type)
+
+		if err != nil {
+			return nil, err
+		}
+
+		properties[pairs[i]] = map[string]interface{}{"const": values[0]}
+		required = append(required, pairs[i])
+	}
+
+	return map[string]interface{}{
+		"properties": properties,
+		"required":   required,
+	}, nil
+}
+
+/*
+	Returns the type validator sees the values of the given type as
+	Enums are stored as strings, and timestamps as unix times
+*/
+func getStoredType(value_type string) string {
+	switch value_type {
+	case "enum":
+		return "string"
+	case "timestamp":
+		return "int"
+	default:
+		return value_type
+	}
+}
+
+/*
+	Splits a validations string into the tags which apply to the field itself
+	and the tags following dive, which apply to each of its elements
+*/
+func splitDiveTags(validations string) ([]string, []string, bool) {
+	if validations == "" {
+		return []string{}, []string{}, false
+	}
+
+	tags := strings.Split(validations, ",")
+
+	for i, tag := range tags {
+		if tag == "dive" {
+			return tags[:i], tags[i+1:], true
+		}
+	}
+
+	return tags, []string{}, false
+}
+
+/*
+	Adds the JSON Schema equivalent of the given validator tags to the schema of a value of the given type
+	Tags without an equivalent are skipped, and remain available to clients under JSONSchemaValidationsKey
+	Returns true if the tags include required
+*/
+func applyValidationTags(schema map[string]interface{}, value_type string, tags []string) (bool, error) {
+	value_type = getStoredType(value_type)
+	is_required := false
+	is_omitempty := false
+	constraints := []map[string]interface{}{}
+
+	for _, tag := range tags {
+		var constraint map[string]interface{}
+		var err error
+
+		switch {
+		case tag == "omitempty":
+			is_omitempty = true
+			continue
+		case isConditionalTag(tag):
+			// Whether the field is required is described by its parent object, so its other tags apply when it is not empty
+			is_omitempty = true
+			continue
+		case tag == "required":
+			is_required = true
+			constraint, err = getTagSchema(tag, value_type)
+		case strings.Contains(tag, "|"):
+			constraint, err = getAlternativesSchema(strings.Split(tag, "|"), value_type)
+		default:
+			constraint, err = getTagSchema(tag, value_type)
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		if len(constraint) > 0 {
+			constraints = append(constraints, constraint)
+		}
+	}
+
+	if len(constraints) == 0 {
+		return is_required, nil
+	}
+
+	zero_value, has_zero_value := getZeroValue(value_type)
+
+	// The tags following omitempty are skipped when the value is its type's zero value
+	if is_omitempty && has_zero_value {
+		schema["anyOf"] = []interface{}{
+			map[string]interface{}{"const": zero_value},
+			mergeSchemas(map[string]interface{}{}, constraints),
+		}
+	} else {
+		mergeSchemas(schema, constraints)
+	}
+
+	return is_required, nil
+}
+
+/*
+	Returns the schema for a group of tags separated by |, any one of which must pass
+	Returns no constraint if any of the tags has no JSON Schema equivalent, or if the group
+	is required|isdefault, which every value passes
+*/
+func getAlternativesSchema(tags []string, value_type string) (map[string]interface{}, error) {
+	has_required := false
+	has_isdefault := false
+	alternatives := []interface{}{}
+
+	for _, tag := range tags {
+		has_required = has_required || tag == "required"
+		has_isdefault = has_isdefault || tag == "isdefault"
+
+		constraint, err := getTagSchema(tag, value_type)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if constraint == nil {
+			return nil, nil
+		}
+
+		alternatives = append(alternatives, constraint)
+	}
+
+	if has_required && has_isdefault {
+		return nil, nil
+	}
+
+	return map[string]interface{}{"anyOf": alternatives}, nil
+}
+
+/*
+	Adds every key of the given constraints to the schema
+	Constraints which would overwrite an existing key are added under allOf instead
+*/
+func mergeSchemas(schema map[string]interface{}, constraints []map[string]interface{}) map[string]interface{} {
+	all_of := []interface{}{}
+
+	for _, constraint := range constraints {
+		has_conflict := false
+		for key := range constraint {
+			if _, exists := schema[key]; exists {
+				has_conflict = true
+			}
+		}
+
+		if has_conflict {
+			all_of = append(all_of, constraint)
+			continue
+		}
+
+		for key, value := range constraint {
+			schema[key] = value
+		}
+	}
+
+	if len(all_of) > 0 {
+		existing_all_of, _ := schema["allOf"].([]interface{})
+		schema["allOf"] = append(existing_all_of, all_of...)
+	}
+
+	return schema
+}
+
+/*
+	Returns the zero value of the given scalar type, which validator treats as an empty value
+	Arrays and objects have no zero value that can be sent in a request
+*/
+func getZeroValue(value_type string) (interface{}, bool) {
+	switch value_type {
+	case "int":
+		return 0, true
+	case "float":
+		return 0.0, true
+	case "string":
+		return "", true
+	case "boolean":
+		return false, true
+	default:
+		return nil, false
+	}
+}
+
+/*
+	Returns the JSON Schema equivalent of a single validator tag on a value of the given type
+	Returns an empty schema if the tag does not constrain values which can be sent in a request,
+	and nil if the tag has no JSON Schema equivalent
+*/
+func getTagSchema(tag string, value_type string) (map[string]interface{}, error) {
+	name, param := splitValidationTag(tag)
+
+	zero_value, has_zero_value := getZeroValue(value_type)
+
+	switch name {
+	case "required":
+		switch value_type {
+		case "string":
+			return map[string]interface{}{"minLength": 1}, nil
+		case "boolean":
+			return map[string]interface{}{"const": true}, nil
+		case "int", "float":
+			return map[string]interface{}{"not": map[string]interface{}{"const": 0}}, nil
+		default:
+			return map[string]interface{}{}, nil
+		}
+	case "isdefault":
+		if !has_zero_value {
+			return nil, nil
+		}
+		return map[string]interface{}{"const": zero_value}, nil
+	case "oneof":
+		values, err := parseParamValues(strings.Fields(param), value_type)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid oneof parameter %s: %v", param, err)
+		}
+
+		return map[string]interface{}{"enum": values}, nil
+	case "min", "gte", "max", "lte", "gt", "lt", "len":
+		return getBoundSchema(name, param, value_type)
+	case "email":
+		return map[string]interface{}{"format": "email"}, nil
+	case "url", "uri":
+		return map[string]interface{}{"format": "uri"}, nil
+	case "uuid":
+		return map[string]interface{}{"format": "uuid"}, nil
+	case "alpha":
+		return map[string]interface{}{"pattern": "^[a-zA-Z]+$"}, nil
+	case "alphanum":
+		return map[string]interface{}{"pattern": "^[a-zA-Z0-9]+$"}, nil
+	default:
+		return nil, nil
+	}
+}
+
+/*
+	Parses the parameters of a tag into values of the given type
+*/
+func parseParamValues(params []string, value_type string) ([]interface{}, error) {
+	values := make([]interface{}, len(params))
+
+	for i, param := range params {
+		var err error
+
+		switch value_type {
+		case "int":
+			values[i], err = strconv.ParseInt(param, 10, 64)
+		case "float":
+			values[i], err = strconv.ParseFloat(param, 64)
+		case "boolean":
+			values[i], err = strconv.ParseBool(param)
+		default:
+			values[i] = param
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+/*
+	Returns the schema for a bound on the given type
+	Bounds limit the value of numbers, the length of strings, the number of elements in arrays,
+	and the number of properties in objects
+*/
+func getBoundSchema(name string, param string, value_type string) (map[string]interface{}, error) {
+	bound, err := strconv.ParseFloat(param, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s parameter %s: %v", name, param, err)
+	}
+
+	if value_type == "int" || value_type == "float" {
+		switch name {
+		case "min", "gte":
+			return map[string]interface{}{"minimum": bound}, nil
+		case "max", "lte":
+			return map[string]interface{}{"maximum": bound}, nil
+		case "gt":
+			return map[string]interface{}{"exclusiveMinimum": bound}, nil
+		case "lt":
+			return map[string]interface{}{"exclusiveMaximum": bound}, nil
+		default:
+			return map[string]interface{}{"const": bound}, nil
+		}
+	}
+
+	var min_key, max_key string
+	switch {
+	case value_type == "string":
+		min_key, max_key = "minLength", "maxLength"
+	case value_type == "object" || isMapType(value_type):
+		min_key, max_key = "minProperties", "maxProperties"
+	case strings.HasPrefix(value_type, "[]"):
+		min_key, max_key = "minItems", "maxItems"
+	default:
+		return nil, nil
+	}
+
+	length := int(bound)
+
+	switch name {
+	case "min", "gte":
+		return map[string]interface{}{min_key: length}, nil
+	case "max", "lte":
+		return map[string]interface{}{max_key: length}, nil
+	case "gt":
+		return map[string]interface{}{min_key: length + 1}, nil
+	case "lt":
+		return map[string]interface{}{max_key: length - 1}, nil
+	default:
+		return map[string]interface{}{min_key: length, max_key: length}, nil
+	}
+}
@@ -5,8 +5,23 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
+/*
+	Marshals the datastore's data, without the definition version, which is only kept in the stored record
+*/
 func (datastore *DataStore) MarshalJSON() ([]byte, error) {
-	return json.Marshal(&datastore.Data)
+	if _, has_version := datastore.Data[DefinitionVersionKey]; !has_version {
+		return json.Marshal(&datastore.Data)
+	}
+
+	data := make(map[string]interface{}, len(datastore.Data))
+
+	for key, value := range datastore.Data {
+		if key != DefinitionVersionKey {
+			data[key] = value
+		}
+	}
+
+	return json.Marshal(&data)
 }
 
 func (datastore *DataStore) UnmarshalJSON(b []byte) error {
@@ -30,6 +45,10 @@ func (datastore *DataStore) UnmarshalJSON(b []byte) error {
 		return ErrInvalidData
 	}
 
+	if datastore.Definition.Version != 0 {
+		datastore.Data[DefinitionVersionKey] = datastore.Definition.Version
+	}
+
 	return nil
 }
 
@@ -50,6 +69,14 @@ func (datastore *DataStore) SetBSON(raw bson.Raw) error {
 }
 
 func buildDataFromDefinition(raw_data interface{}, definition DataStoreDefinition) (interface{}, error) {
+	if raw_data == nil && definition.Nullable {
+		return nil, nil
+	}
+
+	if isMapType(definition.Type) {
+		return toMap(raw_data, definition)
+	}
+
 	conversionFunc, exists := conversionFuncs[definition.Type]
 
 	if !exists {
@@ -2,18 +2,64 @@ package datastore
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/go-playground/validator/v10"
 )
 
+/*
+	A named validation which can be used in the validations of definitions alongside validator's tags
+	It is given the field's value, the parameter following = in the tag if any, and the object holding the field,
+	and returns true if the value is valid
+*/
+type ValidationFunc func(value interface{}, param string, parent map[string]interface{}) bool
+
+var customValidations = make(map[string]ValidationFunc)
+var customValidationsMutex sync.RWMutex
+
+/*
+	Registers a named validation, which can then be used in the validations of any definition
+	For example, a validation registered as "eligible" is used with the tag "eligible" or "eligible=param"
+	Custom validations apply to the field itself, rather than to its elements after dive
+*/
+func RegisterValidation(name string, validation ValidationFunc) {
+	customValidationsMutex.Lock()
+	defer customValidationsMutex.Unlock()
+
+	customValidations[name] = validation
+}
+
+/*
+	Returns the custom validation registered with the given name
+*/
+func getCustomValidation(name string) (ValidationFunc, bool) {
+	customValidationsMutex.RLock()
+	defer customValidationsMutex.RUnlock()
+
+	validation, exists := customValidations[name]
+
+	return validation, exists
+}
+
 func (datastore *DataStore) Validate() error {
 	validate := validator.New()
 
-	return validateField(datastore.Data, datastore.Definition, validate)
+	return validateField(datastore.Data, datastore.Definition, nil, validate)
 }
 
-func validateField(data interface{}, definition DataStoreDefinition, validate *validator.Validate) error {
-	err := validate.Var(data, definition.Validations)
+func validateField(data interface{}, definition DataStoreDefinition, parent map[string]interface{}, validate *validator.Validate) error {
+	if data == nil && definition.Nullable {
+		return nil
+	}
+
+	validations, err := resolveValidations(data, definition, parent, validate)
+
+	if err != nil {
+		return err
+	}
+
+	err = validate.Var(data, validations)
 
 	if err != nil {
 		return fmt.Errorf("Key '%v' with value '%v' failed validation '%v'", definition.Name, data, definition.Validations)
@@ -21,6 +67,11 @@ func validateField(data interface{}, definition DataStoreDefinition, validate *v
 
 	switch definition.Type {
 	case "object":
+		// Missing objects which are not required have no fields to validate
+		if data == nil {
+			return nil
+		}
+
 		mapped_data, ok := data.(map[string]interface{})
 
 		if !ok {
@@ -29,6 +80,10 @@ func validateField(data interface{}, definition DataStoreDefinition, validate *v
 
 		return validateFieldArray(mapped_data, definition, validate)
 	case "[]object":
+		if data == nil {
+			return nil
+		}
+
 		data_array, ok := data.([]map[string]interface{})
 
 		if !ok {
@@ -45,13 +100,44 @@ func validateField(data interface{}, definition DataStoreDefinition, validate *v
 
 		return nil
 	default:
+		if isMapType(definition.Type) {
+			return validateMapField(data, definition, validate)
+		}
+
 		return nil
 	}
 }
 
+/*
+	Validates each value of a map field against the definition of the map's values
+*/
+func validateMapField(data interface{}, definition DataStoreDefinition, validate *validator.Validate) error {
+	mapped_data, ok := data.(map[string]interface{})
+
+	if !ok {
+		if data == nil {
+			return nil
+		}
+
+		return NewErrTypeMismatch(data, "map[string]interface{}")
+	}
+
+	element_definition := getElementDefinition(definition)
+
+	for key, value := range mapped_data {
+		err := validateField(value, element_definition, nil, validate)
+
+		if err != nil {
+			return NewErrInField(key, err)
+		}
+	}
+
+	return nil
+}
+
 func validateFieldArray(data map[string]interface{}, definition DataStoreDefinition, validate *validator.Validate) error {
 	for _, field := range definition.Fields {
-		err := validateField(data[field.Name], field, validate)
+		err := validateField(data[field.Name], field, data, validate)
 
 		if err != nil {
 			return err
@@ -60,3 +146,119 @@ func validateFieldArray(data map[string]interface{}, definition DataStoreDefinit
 
 	return nil
 }
+
+/*
+	Returns the field's validations with its conditional and custom validations resolved, so that the remaining
+	tags can be checked by validator
+	required_if=field value requires the field if every listed sibling field has the given value, and
+	required_unless=field value requires the field unless every listed sibling field has the given value
+	Fields whose conditions do not require them are only validated if they are not empty
+	Custom validations are run against the field's value, unless it is empty and the field is optional,
+	and an error is returned if any fail
+*/
+func resolveValidations(data interface{}, definition DataStoreDefinition, parent map[string]interface{}, validate *validator.Validate) (string, error) {
+	if definition.Validations == "" {
+		return "", nil
+	}
+
+	tags := strings.Split(definition.Validations, ",")
+	resolved_tags := []string{}
+	custom_tags := []string{}
+
+	is_conditional := false
+	is_required := false
+
+	for i, tag := range tags {
+		if tag == "dive" {
+			resolved_tags = append(resolved_tags, tags[i:]...)
+			break
+		}
+
+		name, param := splitValidationTag(tag)
+
+		switch name {
+		case "required_if", "required_unless":
+			matches, err := siblingsMatch(param, parent)
+
+			if err != nil {
+				return "", NewErrInField(definition.Name, err)
+			}
+
+			is_conditional = true
+			is_required = is_required || (name == "required_if" && matches) || (name == "required_unless" && !matches)
+		default:
+			if _, exists := getCustomValidation(name); exists {
+				custom_tags = append(custom_tags, tag)
+			} else {
+				resolved_tags = append(resolved_tags, tag)
+			}
+		}
+	}
+
+	is_optional := (is_conditional && !is_required) || (len(resolved_tags) > 0 && resolved_tags[0] == "omitempty")
+	is_empty := validate.Var(data, "required") != nil
+
+	if !is_optional || !is_empty {
+		for _, tag := range custom_tags {
+			name, param := splitValidationTag(tag)
+			validation, _ := getCustomValidation(name)
+
+			if !validation(data, param, parent) {
+				return "", fmt.Errorf("Key '%v' with value '%v' failed validation '%v'", definition.Name, data, tag)
+			}
+		}
+	}
+
+	if !is_conditional {
+		return strings.Join(resolved_tags, ","), nil
+	}
+
+	condition_tag := "omitempty"
+	if is_required {
+		condition_tag = "required"
+	}
+
+	other_tags := []string{}
+	for _, tag := range resolved_tags {
+		if tag != "omitempty" && tag != "required" {
+			other_tags = append(other_tags, tag)
+		}
+	}
+
+	return strings.Join(append([]string{condition_tag}, other_tags...), ","), nil
+}
+
+/*
+	Splits a validation tag into its name and the parameter following =
+*/
+func splitValidationTag(tag string) (string, string) {
+	separator := strings.Index(tag, "=")
+
+	if separator == -1 {
+		return tag, ""
+	}
+
+	return tag[:separator], tag[separator+1:]
+}
+
+/*
+	Returns true if every sibling field named in the parameter has the value following it
+	The parameter is a space separated list of field names and values, such as "isAttending true"
+*/
+func siblingsMatch(param string, parent map[string]interface{}) (bool, error) {
+	pairs := strings.Fields(param)
+
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return false, fmt.Errorf("Conditional validation parameter '%s' must be pairs of field names and values", param)
+	}
+
+	for i := 0; i < len(pairs); i += 2 {
+		value, exists := parent[pairs[i]]
+
+		if !exists || fmt.Sprint(value) != pairs[i+1] {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
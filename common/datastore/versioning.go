@@ -0,0 +1,366 @@
+package datastore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/common/utils"
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+	The key under which the version of the definition a record was written with is stored
+	Records written before their definition was versioned have no version, and are treated as version 0
+*/
+const DefinitionVersionKey = "definitionVersion"
+
+/*
+	Describes the changes to stored records between the previous version of a definition and the given version
+	Renames move a field from its old path to its new path, removals delete fields, and defaults are set on
+	records which do not have the field, in that order
+	Paths may be dotted to refer to the fields of objects, and the renames of a migration should not depend on each other
+*/
+type DataStoreMigration struct {
+	Version  int                    `json:"version"`
+	Renames  map[string]string      `json:"renames"`
+	Removals []string               `json:"removals"`
+	Defaults map[string]interface{} `json:"defaults"`
+}
+
+/*
+	Returns the version of the definition the datastore's data was written with
+*/
+func (datastore *DataStore) GetVersion() int {
+	switch version := datastore.Data[DefinitionVersionKey].(type) {
+	case int:
+		return version
+	case int64:
+		return int(version)
+	case float64:
+		return int(version)
+	default:
+		return 0
+	}
+}
+
+/*
+	Upgrades the datastore's data, which was written with an older version of the given definition,
+	by applying each of the definition's migrations after the data's version in order
+	Data written with a newer version of the definition than the given one is left unchanged
+*/
+func (datastore *DataStore) Upgrade(definition DataStoreDefinition) error {
+	err := validateDataStoreMigrations(definition)
+
+	if err != nil {
+		return err
+	}
+
+	datastore.Definition = definition
+
+	version := datastore.GetVersion()
+
+	if version >= definition.Version {
+		return nil
+	}
+
+	for _, migration := range definition.Migrations {
+		if migration.Version <= version {
+			continue
+		}
+
+		err = applyDataStoreMigration(datastore.Data, migration, definition)
+
+		if err != nil {
+			return fmt.Errorf("DataStore migration %d failed: %v", migration.Version, err)
+		}
+	}
+
+	datastore.Data[DefinitionVersionKey] = definition.Version
+
+	return nil
+}
+
+/*
+	Upgrades each of the given datastores to the given definition
+*/
+func UpgradeAll(datastores []DataStore, definition DataStoreDefinition) error {
+	for i := range datastores {
+		err := datastores[i].Upgrade(definition)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the given projection along with the fields which upgrading a record to the given definition reads,
+	which are the record's version and the old paths of renamed fields, so that projected records can be upgraded
+	Returns nil if the projection is nil, since every field is then included
+*/
+func GetUpgradeProjection(definition DataStoreDefinition, projection []string) []string {
+	if projection == nil {
+		return nil
+	}
+
+	upgrade_projection := append([]string{}, projection...)
+	upgrade_fields := []string{DefinitionVersionKey}
+
+	for _, migration := range definition.Migrations {
+		for old_path := range migration.Renames {
+			upgrade_fields = append(upgrade_fields, strings.Split(old_path, ".")[0])
+		}
+	}
+
+	for _, field := range upgrade_fields {
+		if !utils.ContainsString(upgrade_projection, field) {
+			upgrade_projection = append(upgrade_projection, field)
+		}
+	}
+
+	return upgrade_projection
+}
+
+/*
+	Returns true if the datastore's data was written with an older version of the given definition
+*/
+func (datastore *DataStore) NeedsUpgrade(definition DataStoreDefinition) bool {
+	return datastore.GetVersion() < definition.Version
+}
+
+/*
+	Upgrades every record in the collection which was written with an older version of the given definition,
+	and saves the upgraded records
+	Records are identified by their id field
+	If dry_run is set, the records which would be upgraded are counted without being changed
+	Returns the number of records upgraded
+*/
+func UpgradeCollection(db database.Database, collection_name string, definition DataStoreDefinition, dry_run bool) (int, error) {
+	err := validateDataStoreMigrations(definition)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if definition.Version == 0 {
+		return 0, nil
+	}
+
+	query := database.QuerySelector{
+		"$or": []interface{}{
+			database.QuerySelector{DefinitionVersionKey: database.QuerySelector{"$exists": false}},
+			database.QuerySelector{DefinitionVersionKey: database.QuerySelector{"$lt": definition.Version}},
+		},
+	}
+
+	var stores []DataStore
+	err = db.FindAll(collection_name, query, &stores)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if dry_run {
+		return len(stores), nil
+	}
+
+	for i, store := range stores {
+		err = store.Upgrade(definition)
+
+		if err != nil {
+			return i, NewErrInField(fmt.Sprint(store.Data["id"]), err)
+		}
+
+		err = db.Update(collection_name, database.QuerySelector{"id": store.Data["id"]}, &store)
+
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(stores), nil
+}
+
+/*
+	Returns an error if the definition's migrations are not in increasing order of positive version,
+	or are for a version after the definition's version
+*/
+func validateDataStoreMigrations(definition DataStoreDefinition) error {
+	for i, migration := range definition.Migrations {
+		if migration.Version <= 0 {
+			return fmt.Errorf("DataStore migration %d must have a positive version", migration.Version)
+		}
+
+		if migration.Version > definition.Version {
+			return fmt.Errorf("DataStore migration %d is for a version after the definition's version %d", migration.Version, definition.Version)
+		}
+
+		if i > 0 && definition.Migrations[i-1].Version >= migration.Version {
+			return fmt.Errorf("DataStore migration %d is not declared after migration %d", migration.Version, definition.Migrations[i-1].Version)
+		}
+	}
+
+	return nil
+}
+
+/*
+	Applies the renames, removals, and defaults of a migration to the given data
+	Defaults are converted to the type of the field at their path in the given definition if it has one
+*/
+func applyDataStoreMigration(data map[string]interface{}, migration DataStoreMigration, definition DataStoreDefinition) error {
+	for old_path, new_path := range migration.Renames {
+		value, exists := getPathValue(data, old_path)
+
+		if !exists {
+			continue
+		}
+
+		if _, new_exists := getPathValue(data, new_path); !new_exists {
+			err := setPathValue(data, new_path, value)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		deletePathValue(data, old_path)
+	}
+
+	for _, path := range migration.Removals {
+		deletePathValue(data, path)
+	}
+
+	for path, raw_value := range migration.Defaults {
+		if _, exists := getPathValue(data, path); exists {
+			continue
+		}
+
+		value := raw_value
+
+		if field, has_field := getFieldDefinition(definition, path); has_field {
+			var err error
+			value, err = buildDataFromDefinition(raw_value, field)
+
+			if err != nil {
+				return NewErrInField(path, err)
+			}
+		}
+
+		err := setPathValue(data, path, value)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the definition of the field at the given dotted path
+*/
+func getFieldDefinition(definition DataStoreDefinition, path string) (DataStoreDefinition, bool) {
+	for _, name := range strings.Split(path, ".") {
+		found := false
+
+		for _, field := range definition.Fields {
+			if field.Name == name {
+				definition = field
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return DataStoreDefinition{}, false
+		}
+	}
+
+	return definition, true
+}
+
+/*
+	Returns the parent object of the value at the given dotted path, and the key of the value within it
+	If create is set, missing parent objects are created
+*/
+func getPathParent(data map[string]interface{}, path string, create bool) (map[string]interface{}, string, bool) {
+	keys := strings.Split(path, ".")
+
+	for _, key := range keys[:len(keys)-1] {
+		child, exists := data[key]
+
+		if !exists && create {
+			child = make(map[string]interface{})
+			data[key] = child
+		}
+
+		child_data, ok := toMapData(child)
+
+		if !ok {
+			return nil, "", false
+		}
+
+		data[key] = child_data
+		data = child_data
+	}
+
+	return data, keys[len(keys)-1], true
+}
+
+/*
+	Returns the value at the given dotted path, and whether it exists
+*/
+func getPathValue(data map[string]interface{}, path string) (interface{}, bool) {
+	parent, key, ok := getPathParent(data, path, false)
+
+	if !ok {
+		return nil, false
+	}
+
+	value, exists := parent[key]
+
+	return value, exists
+}
+
+/*
+	Sets the value at the given dotted path, creating any missing parent objects
+*/
+func setPathValue(data map[string]interface{}, path string, value interface{}) error {
+	parent, key, ok := getPathParent(data, path, true)
+
+	if !ok {
+		return fmt.Errorf("Cannot set %s, since one of its parents is not an object", path)
+	}
+
+	parent[key] = value
+
+	return nil
+}
+
+/*
+	Removes the value at the given dotted path if it exists
+*/
+func deletePathValue(data map[string]interface{}, path string) {
+	parent, key, ok := getPathParent(data, path, false)
+
+	if ok {
+		delete(parent, key)
+	}
+}
+
+/*
+	Returns the given value as a map, since objects read from the database may be decoded as bson documents
+*/
+func toMapData(value interface{}) (map[string]interface{}, bool) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return value, true
+	case bson.M:
+		return map[string]interface{}(value), true
+	default:
+		return nil, false
+	}
+}
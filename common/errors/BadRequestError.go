@@ -0,0 +1,8 @@
+package errors
+
+import "net/http"
+
+// An error for when the url parameters of a request are invalid, such as a filter on an unknown field.
+func BadRequestError(raw_error string, message string) ApiError {
+	return ApiError{Status: http.StatusBadRequest, Type: "BAD_REQUEST_ERROR", Message: message, RawError: raw_error}
+}
@@ -0,0 +1,8 @@
+package errors
+
+import "net/http"
+
+// An error for when a request conflicts with the current state of a resource, such as an item which already exists.
+func ConflictError(raw_error string, message string) ApiError {
+	return ApiError{Status: http.StatusConflict, Type: "CONFLICT_ERROR", Message: message, RawError: raw_error}
+}
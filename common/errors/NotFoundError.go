@@ -0,0 +1,8 @@
+package errors
+
+import "net/http"
+
+// An error for when the resource named by the url of a request does not exist, such as the stats of an unknown service.
+func NotFoundError(raw_error string, message string) ApiError {
+	return ApiError{Status: http.StatusNotFound, Type: "NOT_FOUND_ERROR", Message: message, RawError: raw_error}
+}
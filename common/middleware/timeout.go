@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/HackIllinois/api/common/config"
+)
+
+/*
+	Sets the configured deadline for the given service on the context of each request
+	The request's context is also cancelled when the client closes the connection,
+	so database operations using the context stop once the response is no longer wanted
+*/
+func TimeoutMiddleware(service string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), config.GetRequestTimeout(service))
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
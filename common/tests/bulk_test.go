@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+/*
+	Tests counting the items matching a query
+*/
+func TestMemoryCount(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	count, err := db.Count("items", database.QuerySelector{"tags": "blue"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("Wrong count. Expected 2, got %v", count)
+	}
+
+	count, err = db.Count("missing", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("Wrong count of a missing collection. Expected 0, got %v", count)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests collecting the unique values of a field, including the elements of array fields
+*/
+func TestMemoryDistinct(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	tags := []string{}
+	err := db.Distinct("items", "tags", nil, &tags)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_tags := []string{"red", "blue"}
+
+	if !reflect.DeepEqual(tags, expected_tags) {
+		t.Errorf("Wrong tags. Expected %v, got %v", expected_tags, tags)
+	}
+
+	ids := []string{}
+	err = db.Distinct("items", "id", database.QuerySelector{"points": database.QuerySelector{"$gte": 20}}, &ids)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_ids := []string{"b", "c"}
+
+	if !reflect.DeepEqual(ids, expected_ids) {
+		t.Errorf("Wrong ids. Expected %v, got %v", expected_ids, ids)
+	}
+
+	ids = []string{}
+	err = db.Distinct("items", "id", database.QuerySelector{"points": 0}, &ids)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids == nil || len(ids) != 0 {
+		t.Errorf("Expected no ids, got %v", ids)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests running a batch of mixed operations with a result for each operation
+*/
+func TestMemoryBulkWrite(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	operations := []database.BulkOperation{
+		{
+			Type: database.BulkInsert,
+			Item: MemoryTestItem{ID: "d", Points: 40, Tags: []string{}},
+		},
+		{
+			Type:     database.BulkUpdate,
+			Selector: database.QuerySelector{"tags": "blue"},
+			Item:     database.QuerySelector{"$inc": database.QuerySelector{"points": 1}},
+			Multi:    true,
+		},
+		{
+			Type:     database.BulkUpsert,
+			Selector: database.QuerySelector{"id": "e"},
+			Item:     database.QuerySelector{"$set": database.QuerySelector{"points": 50}},
+		},
+		{
+			Type:     database.BulkUpdate,
+			Selector: database.QuerySelector{"id": "c"},
+			Item:     database.QuerySelector{"$inc": database.QuerySelector{"tags": 1}},
+		},
+		{
+			Type:     database.BulkDelete,
+			Selector: database.QuerySelector{"id": "d"},
+		},
+	}
+
+	results, err := db.BulkWrite("items", operations)
+
+	if err != database.ErrBulkWrite {
+		t.Fatalf("Expected ErrBulkWrite, got %v", err)
+	}
+
+	if results.Inserted != 1 || results.Updated != 2 || results.Upserted != 1 || results.Deleted != 1 {
+		t.Errorf("Wrong counts. Got %+v", results)
+	}
+
+	for i, result := range results.Operations {
+		if (result.Err != nil) != (i == 3) {
+			t.Errorf("Wrong error for operation %d: %v", i, result.Err)
+		}
+
+		if result.Upserted != (i == 2) {
+			t.Errorf("Wrong upserted flag for operation %d", i)
+		}
+	}
+
+	var items []MemoryTestItem
+	err = db.FindAllSorted("items", nil, []database.SortField{{Name: "id"}}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_items := []MemoryTestItem{
+		{ID: "a", Points: 11, Tags: []string{"red", "blue"}},
+		{ID: "b", Points: 31, Tags: []string{"blue"}},
+		{ID: "c", Points: 20, Tags: []string{}},
+		{ID: "e", Points: 50},
+	}
+
+	if !reflect.DeepEqual(items, expected_items) {
+		t.Errorf("Wrong items. Expected %v, got %v", expected_items, items)
+	}
+
+	_, err = db.BulkWrite("items", []database.BulkOperation{{Type: "replace"}})
+
+	if err == nil {
+		t.Error("Expected an error for an invalid operation type")
+	}
+
+	CleanupMemoryDB(t, db)
+}
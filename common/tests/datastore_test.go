@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/HackIllinois/api/common/datastore"
 	"gopkg.in/mgo.v2/bson"
+	"reflect"
 	"testing"
 )
 
@@ -527,3 +528,327 @@ func TestBSONConversions(t *testing.T) {
 		t.Errorf("Wrong info.\nExpected %v\ngot %v\n", store.Data["isNovice"], unmarshalled_store.Data["isNovice"])
 	}
 }
+
+var types_json_definition = `
+{
+	"name": "types",
+	"type": "object",
+	"validations": "",
+	"fields": [
+		{
+			"name": "shirtSize",
+			"type": "enum",
+			"validations": "required",
+			"values": ["S", "M", "L"],
+			"fields": []
+		},
+		{
+			"name": "interests",
+			"type": "[]enum",
+			"validations": "",
+			"values": ["WEBDEV", "SYSTEMS"],
+			"fields": []
+		},
+		{
+			"name": "arrivalTime",
+			"type": "timestamp",
+			"validations": "required",
+			"fields": []
+		},
+		{
+			"name": "departureTime",
+			"type": "timestamp",
+			"validations": "required",
+			"fields": []
+		},
+		{
+			"name": "graduationYear",
+			"type": "int",
+			"validations": "required,min=1900",
+			"nullable": true,
+			"fields": []
+		},
+		{
+			"name": "skills",
+			"type": "map[string]int",
+			"validations": "",
+			"fields": []
+		},
+		{
+			"name": "answers",
+			"type": "map[string]object",
+			"validations": "",
+			"fields": [
+				{
+					"name": "response",
+					"type": "string",
+					"validations": "required",
+					"fields": []
+				}
+			]
+		},
+		{
+			"name": "diet",
+			"type": "[]string",
+			"validations": "required,min=1",
+			"default": ["NONE"],
+			"fields": []
+		},
+		{
+			"name": "teamSize",
+			"type": "int",
+			"validations": "",
+			"default": 4,
+			"fields": []
+		}
+	]
+}
+`
+
+var types_json_data = `
+{
+	"shirtSize": "M",
+	"interests": ["WEBDEV"],
+	"arrivalTime": "2020-02-28T18:00:00Z",
+	"departureTime": 1583020800,
+	"graduationYear": null,
+	"skills": {
+		"go": 3,
+		"python": 5
+	},
+	"answers": {
+		"why": {
+			"response": "To learn"
+		}
+	}
+}
+`
+
+/*
+	Tests conversion and validation of enum, timestamp, nullable, and map fields, and fields with defaults
+*/
+func TestDatastoreTypes(t *testing.T) {
+	var definition datastore.DataStoreDefinition
+	err := json.Unmarshal([]byte(types_json_definition), &definition)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := datastore.NewDataStore(definition)
+	err = json.Unmarshal([]byte(types_json_data), &store)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_data := map[string]interface{}{
+		"shirtSize":      "M",
+		"interests":      []interface{}{"WEBDEV"},
+		"arrivalTime":    int64(1582912800),
+		"departureTime":  int64(1583020800),
+		"graduationYear": nil,
+		"skills": map[string]interface{}{
+			"go":     int64(3),
+			"python": int64(5),
+		},
+		"answers": map[string]interface{}{
+			"why": map[string]interface{}{
+				"response": "To learn",
+			},
+		},
+		"diet":     []string{"NONE"},
+		"teamSize": int64(4),
+	}
+
+	if !reflect.DeepEqual(store.Data, expected_data) {
+		t.Errorf("Wrong data.\nExpected %v\ngot %v\n", expected_data, store.Data)
+	}
+
+	err = store.Validate()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Data["answers"].(map[string]interface{})["why"].(map[string]interface{})["response"] = ""
+
+	err = store.Validate()
+
+	if err == nil {
+		t.Errorf("Expected validation of the empty response in the answers map to fail")
+	}
+
+	store.Data["answers"].(map[string]interface{})["why"].(map[string]interface{})["response"] = "To learn"
+	store.Data["graduationYear"] = int64(1800)
+
+	err = store.Validate()
+
+	if err == nil {
+		t.Errorf("Expected validation of the non-null graduation year to fail")
+	}
+}
+
+/*
+	Tests that values which are not accepted by enum and timestamp fields are rejected
+*/
+func TestDatastoreTypeAssertions(t *testing.T) {
+	var definition datastore.DataStoreDefinition
+	err := json.Unmarshal([]byte(types_json_definition), &definition)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid_data := []struct {
+		data          string
+		expected_name string
+	}{
+		{`{"shirtSize": "XXL"}`, "shirtSize"},
+		{`{"interests": ["WEBDEV", "GAMES"]}`, "interests"},
+		{`{"arrivalTime": "Friday"}`, "arrivalTime"},
+		{`{"shirtSize": null}`, "shirtSize"},
+		{`{"skills": {"go": "expert"}}`, "skills.go"},
+	}
+
+	for _, test_case := range invalid_data {
+		store := datastore.NewDataStore(definition)
+		err = json.Unmarshal([]byte(test_case.data), &store)
+
+		field_err, ok := err.(datastore.ErrorInField)
+
+		if !ok {
+			t.Errorf("Expected an error in field %s, got %v", test_case.expected_name, err)
+			continue
+		}
+
+		if field_err.FieldName != test_case.expected_name {
+			t.Errorf("Wrong field name.\nExpected %s\ngot %s\n", test_case.expected_name, field_err.FieldName)
+		}
+	}
+}
+
+var conditional_json_definition = `
+{
+	"name": "rsvp",
+	"type": "object",
+	"validations": "",
+	"fields": [
+		{
+			"name": "isAttending",
+			"type": "boolean",
+			"validations": "required|isdefault",
+			"fields": []
+		},
+		{
+			"name": "transportation",
+			"type": "string",
+			"validations": "required_if=isAttending true,oneof=NONE BUS",
+			"fields": []
+		},
+		{
+			"name": "programmingExperience",
+			"type": "object",
+			"validations": "required_if=isAttending true",
+			"fields": [
+				{
+					"name": "go",
+					"type": "int",
+					"validations": "min=0,max=10",
+					"fields": []
+				}
+			]
+		},
+		{
+			"name": "reason",
+			"type": "string",
+			"validations": "required_unless=isAttending true",
+			"fields": []
+		},
+		{
+			"name": "phone",
+			"type": "string",
+			"validations": "omitempty,phonenumber",
+			"fields": []
+		}
+	]
+}
+`
+
+/*
+	Tests that required_if and required_unless only require fields when their sibling fields match the condition
+*/
+func TestDatastoreConditionalValidation(t *testing.T) {
+	var definition datastore.DataStoreDefinition
+	err := json.Unmarshal([]byte(conditional_json_definition), &definition)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	datastore.RegisterValidation("phonenumber", func(value interface{}, param string, parent map[string]interface{}) bool {
+		phone, ok := value.(string)
+		return ok && len(phone) == 10
+	})
+
+	test_cases := []struct {
+		data     string
+		is_valid bool
+	}{
+		{`{"isAttending": true, "transportation": "BUS", "programmingExperience": {"go": 5}}`, true},
+		{`{"isAttending": true, "programmingExperience": {"go": 5}}`, false},
+		{`{"isAttending": true, "transportation": "BUS"}`, false},
+		{`{"isAttending": true, "transportation": "TRAIN", "programmingExperience": {"go": 5}}`, false},
+		{`{"isAttending": false, "reason": "Busy"}`, true},
+		{`{"isAttending": false}`, false},
+		{`{"isAttending": false, "reason": "Busy", "transportation": "TRAIN"}`, false},
+		{`{"isAttending": false, "reason": "Busy", "phone": "2175550100"}`, true},
+		{`{"isAttending": false, "reason": "Busy", "phone": "555"}`, false},
+	}
+
+	for _, test_case := range test_cases {
+		store := datastore.NewDataStore(definition)
+		err = json.Unmarshal([]byte(test_case.data), &store)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Validate()
+
+		if test_case.is_valid && err != nil {
+			t.Errorf("Expected %s to be valid, got %v", test_case.data, err)
+		}
+
+		if !test_case.is_valid && err == nil {
+			t.Errorf("Expected %s to be invalid", test_case.data)
+		}
+	}
+}
+
+/*
+	Tests that conditional validations with an odd number of field names and values are rejected
+*/
+func TestDatastoreConditionalValidationErrors(t *testing.T) {
+	definition := datastore.DataStoreDefinition{
+		Name: "rsvp",
+		Type: "object",
+		Fields: []datastore.DataStoreDefinition{
+			{Name: "isAttending", Type: "boolean"},
+			{Name: "transportation", Type: "string", Validations: "required_if=isAttending"},
+		},
+	}
+
+	store := datastore.NewDataStore(definition)
+	err := json.Unmarshal([]byte(`{"isAttending": true}`), &store)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Validate()
+
+	if err == nil {
+		t.Errorf("Expected validation with an invalid required_if parameter to fail")
+	}
+}
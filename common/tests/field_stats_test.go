@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+/*
+	Tests that stat fields can be configured with their name or with an object, and that invalid fields are rejected
+*/
+func TestStatFieldUnmarshal(t *testing.T) {
+	var fields []database.StatField
+	err := json.Unmarshal([]byte(`["school", {"name": "age", "kind": "numeric", "bucketSize": 5}, {"name": "school", "by": "isAttending"}]`), &fields)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_fields := []database.StatField{
+		{Name: "school", Kind: database.StatKindCategorical},
+		{Name: "age", Kind: database.StatKindNumeric, BucketSize: 5},
+		{Name: "school", Kind: database.StatKindCategorical, By: "isAttending"},
+	}
+
+	if !reflect.DeepEqual(fields, expected_fields) {
+		t.Errorf("Wrong stat fields.\nExpected %v\ngot %v\n", expected_fields, fields)
+	}
+
+	invalid_fields := []string{
+		`{"kind": "numeric"}`,
+		`{"name": "age", "kind": "ordinal"}`,
+		`{"name": "age", "kind": "numeric", "buckets": [10, 5]}`,
+		`{"name": "age", "kind": "numeric", "buckets": [10]}`,
+		`{"name": "age", "kind": "numeric", "by": "isAttending"}`,
+		`{"name": "school", "bucketSize": 5}`,
+	}
+
+	for _, invalid_field := range invalid_fields {
+		var field database.StatField
+		err = json.Unmarshal([]byte(invalid_field), &field)
+
+		if err == nil {
+			t.Errorf("Expected the stat field %s to be rejected", invalid_field)
+		}
+	}
+}
+
+/*
+	Tests computing numeric stats, histograms, and cross tabulations alongside categorical stats
+*/
+func TestGetFieldStats(t *testing.T) {
+	db, err := database.InitDatabase("memory://", "test-field-stats")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.DropDatabase()
+
+	entries := []map[string]interface{}{
+		{"id": "a", "isAttending": true, "age": 18, "school": "UIUC", "scores": []interface{}{1, 2}},
+		{"id": "b", "isAttending": true, "age": 20, "school": "UIUC"},
+		{"id": "c", "isAttending": false, "age": 21, "school": "Purdue"},
+		{"id": "d", "isAttending": true, "age": 29, "school": "Purdue", "scores": []interface{}{4}},
+		{"id": "e", "age": "unknown", "school": "UIUC"},
+	}
+
+	for _, entry := range entries {
+		err = db.Insert("rsvps", entry)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fields := []database.StatField{
+		{Name: "isAttending", Kind: database.StatKindCategorical},
+		{Name: "age", Kind: database.StatKindNumeric, BucketSize: 5},
+		{Name: "scores", Kind: database.StatKindNumeric, Buckets: []float64{0, 2, 3}},
+		{Name: "school", Kind: database.StatKindCategorical, By: "isAttending"},
+	}
+
+	stats, err := database.GetFieldStats(db, "rsvps", fields)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_stats := map[string]interface{}{
+		"count":       5,
+		"isAttending": map[string]int{"true": 3, "false": 1},
+		"school":      map[string]int{"UIUC": 3, "Purdue": 2},
+		"age": database.NumericStats{
+			Count:  4,
+			Min:    18,
+			Max:    29,
+			Mean:   22,
+			Median: 20.5,
+			Histogram: []database.HistogramBucket{
+				{Min: 15, Max: 20, Count: 1},
+				{Min: 20, Max: 25, Count: 2},
+				{Min: 25, Max: 30, Count: 1},
+			},
+		},
+		"scores": database.NumericStats{
+			Count:  3,
+			Min:    1,
+			Max:    4,
+			Mean:   7.0 / 3.0,
+			Median: 2,
+			Histogram: []database.HistogramBucket{
+				{Min: 0, Max: 2, Count: 1},
+				{Min: 2, Max: 3, Count: 1},
+			},
+		},
+		database.CrossTabulationsKey: map[string]interface{}{
+			"school,isAttending": map[string]map[string]int{
+				"UIUC":   {"true": 2},
+				"Purdue": {"true": 1, "false": 1},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(stats, expected_stats) {
+		t.Errorf("Wrong stats.\nExpected %v\ngot %v\n", expected_stats, stats)
+	}
+}
+
+/*
+	Tests that numeric fields without configured buckets are split into the default number of buckets
+*/
+func TestGetFieldStatsDefaultBuckets(t *testing.T) {
+	db, err := database.InitDatabase("memory://", "test-field-stats-default-buckets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.DropDatabase()
+
+	for _, points := range []int{0, 5, 10, 100} {
+		err = db.Insert("events", map[string]interface{}{"points": points})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := database.GetFieldStats(db, "events", []database.StatField{{Name: "points", Kind: database.StatKindNumeric}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points_stats, ok := stats["points"].(database.NumericStats)
+
+	if !ok {
+		t.Fatalf("Expected numeric stats for points, got %v", stats["points"])
+	}
+
+	if len(points_stats.Histogram) != database.DefaultHistogramBucketCount {
+		t.Fatalf("Wrong number of buckets.\nExpected %v\ngot %v\n", database.DefaultHistogramBucketCount, len(points_stats.Histogram))
+	}
+
+	first_bucket := points_stats.Histogram[0]
+	last_bucket := points_stats.Histogram[len(points_stats.Histogram)-1]
+
+	if first_bucket.Count != 2 || first_bucket.Min != 0 || first_bucket.Max != 10 {
+		t.Errorf("Wrong first bucket.\nExpected %v\ngot %v\n", database.HistogramBucket{Min: 0, Max: 10, Count: 2}, first_bucket)
+	}
+
+	if last_bucket.Count != 1 || last_bucket.Max != 100 {
+		t.Errorf("Wrong last bucket.\nExpected %v\ngot %v\n", database.HistogramBucket{Min: 90, Max: 100, Count: 1}, last_bucket)
+	}
+}
+
+/*
+	Tests that a bucket size which would need too many buckets for the range of the values, such as when a single
+	outlier is stored, falls back to the default number of buckets
+*/
+func TestGetFieldStatsOutlierBuckets(t *testing.T) {
+	db, err := database.InitDatabase("memory://", "test-field-stats-outlier-buckets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.DropDatabase()
+
+	for _, graduation_year := range []int{2021, 2022, 2000000000} {
+		err = db.Insert("attendees", map[string]interface{}{"graduationYear": graduation_year})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := database.GetFieldStats(db, "attendees", []database.StatField{{Name: "graduationYear", Kind: database.StatKindNumeric, BucketSize: 1}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graduation_year_stats, ok := stats["graduationYear"].(database.NumericStats)
+
+	if !ok {
+		t.Fatalf("Expected numeric stats for graduationYear, got %v", stats["graduationYear"])
+	}
+
+	if len(graduation_year_stats.Histogram) != database.DefaultHistogramBucketCount {
+		t.Fatalf("Wrong number of buckets.\nExpected %v\ngot %v\n", database.DefaultHistogramBucketCount, len(graduation_year_stats.Histogram))
+	}
+
+	first_bucket := graduation_year_stats.Histogram[0]
+	last_bucket := graduation_year_stats.Histogram[len(graduation_year_stats.Histogram)-1]
+
+	if first_bucket.Count != 2 || last_bucket.Count != 1 || last_bucket.Max != 2000000000 {
+		t.Errorf("Wrong buckets.\nExpected the first bucket to have 2 values and the last bucket to end at the outlier\ngot %v\n", graduation_year_stats.Histogram)
+	}
+}
@@ -1,10 +1,12 @@
 package tests
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
 	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/common/datastore"
 )
 
 type TestStruct struct {
@@ -151,3 +153,289 @@ func TestFilterSliceQuery(t *testing.T) {
 		t.Errorf("Expected less than operation to fail on slice value")
 	}
 }
+
+type TestNestedStruct struct {
+	Name     string                 `json:"name"`
+	Location TestLocation           `json:"location"`
+	Extra    []TestLocation         `json:"extra"`
+	Data     map[string]interface{} `json:"data"`
+}
+
+type TestLocation struct {
+	Building string `json:"building"`
+	Floor    int    `json:"floor"`
+}
+
+func TestFilterNestedStruct(t *testing.T) {
+	params := map[string][]string{
+		"location.building":    {"siebel"},
+		"extra.floorGt":        {"2"},
+		"data.attendee.School": {"UIUC,42"},
+	}
+
+	expected_query := map[string]interface{}{
+		"location.building":    database.QuerySelector{"$in": []string{"siebel"}},
+		"extra.floor":          database.QuerySelector{"$gt": int64(2)},
+		"data.attendee.School": database.QuerySelector{"$in": []interface{}{"UIUC", int64(42), "42"}},
+	}
+
+	query, err := database.CreateFilterQuery(params, TestNestedStruct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(query, expected_query) {
+		t.Errorf("Incorrect query.\nExpected %v\ngot %v\n", expected_query, query)
+	}
+
+	params = map[string][]string{
+		"location.room": {"1404"},
+	}
+
+	_, err = database.CreateFilterQuery(params, TestNestedStruct{})
+
+	if err == nil {
+		t.Errorf("Expected filtering on an undeclared nested field to fail")
+	}
+}
+
+var filter_datastore_definition string = `
+{
+	"name": "test",
+	"type": "object",
+	"fields": [
+		{"name": "isAttending", "type": "boolean", "fields": []},
+		{"name": "diet", "type": "[]string", "fields": []},
+		{
+			"name": "programmingExperience",
+			"type": "object",
+			"fields": [
+				{"name": "go", "type": "int", "fields": []}
+			]
+		},
+		{"name": "registrationData", "type": "object", "fields": []}
+	]
+}
+`
+
+func TestFilterDataStore(t *testing.T) {
+	var definition datastore.DataStoreDefinition
+	err := json.Unmarshal([]byte(filter_datastore_definition), &definition)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := map[string][]string{
+		"isattending":                      {"true"},
+		"diet":                             {"VEGAN"},
+		"programmingExperience.goGt":       {"3"},
+		"registrationData.attendee.school": {"UIUC"},
+	}
+
+	expected_query := map[string]interface{}{
+		"isAttending":                      database.QuerySelector{"$in": []bool{true}},
+		"diet":                             database.QuerySelector{"$all": []string{"VEGAN"}},
+		"programmingExperience.go":         database.QuerySelector{"$gt": int64(3)},
+		"registrationData.attendee.school": database.QuerySelector{"$in": []interface{}{"UIUC"}},
+	}
+
+	query, err := database.CreateFilterQuery(params, datastore.NewDataStore(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(query, expected_query) {
+		t.Errorf("Incorrect query.\nExpected %v\ngot %v\n", expected_query, query)
+	}
+
+	params = map[string][]string{
+		"Definition": {"test"},
+	}
+
+	_, err = database.CreateFilterQuery(params, datastore.NewDataStore(definition))
+
+	if err == nil {
+		t.Errorf("Expected filtering on a field outside of the definition to fail")
+	}
+}
+
+/*
+	Tests that enum and timestamp fields are filtered as strings and unix times, and map fields as free form objects
+*/
+func TestFilterDataStoreTypes(t *testing.T) {
+	definition := datastore.DataStoreDefinition{
+		Name: "types",
+		Type: "object",
+		Fields: []datastore.DataStoreDefinition{
+			{Name: "shirtSize", Type: "enum", Values: []string{"S", "M", "L"}},
+			{Name: "interests", Type: "[]enum", Values: []string{"WEBDEV", "SYSTEMS"}},
+			{Name: "arrivalTime", Type: "timestamp"},
+			{Name: "skills", Type: "map[string]int"},
+		},
+	}
+
+	params := map[string][]string{
+		"shirtSize":      {"M,L"},
+		"interestsAny":   {"WEBDEV"},
+		"arrivalTimeGte": {"1582912800"},
+		"skills.go":      {"3"},
+	}
+
+	expected_query := map[string]interface{}{
+		"shirtSize":   database.QuerySelector{"$in": []string{"M", "L"}},
+		"interests":   database.QuerySelector{"$in": []string{"WEBDEV"}},
+		"arrivalTime": database.QuerySelector{"$gte": int64(1582912800)},
+		"skills.go":   database.QuerySelector{"$in": []interface{}{int64(3), "3"}},
+	}
+
+	query, err := database.CreateFilterQuery(params, datastore.NewDataStore(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(query, expected_query) {
+		t.Errorf("Incorrect query.\nExpected %v\ngot %v\n", expected_query, query)
+	}
+}
+
+type TestStruct4 struct {
+	Name     string       `json:"name"`
+	Points   int          `json:"points"`
+	Latitude float64      `json:"latitude"`
+	Tags     []string     `json:"tags"`
+	Location TestLocation `json:"location"`
+}
+
+func TestFilterOperators(t *testing.T) {
+	params := map[string][]string{
+		"pointsGte":      {"10"},
+		"pointsLte":      {"20"},
+		"latitudeGt":     {"40.1"},
+		"nameContains":   {"hack.il"},
+		"tagsAny":        {"foo,bar"},
+		"locationExists": {"true"},
+	}
+
+	expected_query := map[string]interface{}{
+		"points":   database.QuerySelector{"$gte": int64(10), "$lte": int64(20)},
+		"latitude": database.QuerySelector{"$gt": 40.1},
+		"name":     database.QuerySelector{"$regex": "(?:hack\\.il)", "$options": "i"},
+		"tags":     database.QuerySelector{"$in": []string{"foo", "bar"}},
+		"location": database.QuerySelector{"$exists": true},
+	}
+
+	query, err := database.CreateFilterQuery(params, TestStruct4{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(query, expected_query) {
+		t.Errorf("Incorrect query.\nExpected %v\ngot %v\n", expected_query, query)
+	}
+
+	params = map[string][]string{
+		"namePrefix": {"foo,bar"},
+		"tagsNot":    {"baz"},
+	}
+
+	expected_query = map[string]interface{}{
+		"name": database.QuerySelector{"$regex": "^(?:foo|bar)", "$options": "i"},
+		"tags": database.QuerySelector{"$nin": []string{"baz"}},
+	}
+
+	query, err = database.CreateFilterQuery(params, TestStruct4{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(query, expected_query) {
+		t.Errorf("Incorrect query.\nExpected %v\ngot %v\n", expected_query, query)
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	invalid_params := []map[string][]string{
+		{"unknown": {"foo"}},
+		{"points": {"foo"}},
+		{"pointsContains": {"1"}},
+		{"pointsLt": {"1,2"}},
+		{"location": {"siebel"}},
+		{"nameExists": {"maybe"}},
+		{"or": {"name"}},
+		{"or": {"name:foo|unknown:bar"}},
+	}
+
+	for _, params := range invalid_params {
+		_, err := database.CreateFilterQuery(params, TestStruct4{})
+
+		if _, ok := err.(database.FilterError); !ok {
+			t.Errorf("Expected a FilterError for %v, got %v", params, err)
+		}
+	}
+}
+
+func TestFilterOrGroups(t *testing.T) {
+	params := map[string][]string{
+		"pointsGt": {"5"},
+		"or":       {"name:foo|tagsAny:bar"},
+	}
+
+	expected_query := map[string]interface{}{
+		"points": database.QuerySelector{"$gt": int64(5)},
+		"$or": []interface{}{
+			map[string]interface{}{"name": database.QuerySelector{"$in": []string{"foo"}}},
+			map[string]interface{}{"tags": database.QuerySelector{"$in": []string{"bar"}}},
+		},
+	}
+
+	query, err := database.CreateFilterQuery(params, TestStruct4{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(query, expected_query) {
+		t.Errorf("Incorrect query.\nExpected %v\ngot %v\n", expected_query, query)
+	}
+
+	params = map[string][]string{
+		"or": {"name:foo|name:bar", "pointsLt:5|pointsGt:50"},
+	}
+
+	query, err = database.CreateFilterQuery(params, TestStruct4{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	and_conditions, ok := query["$and"].([]interface{})
+
+	if !ok || len(and_conditions) != 2 {
+		t.Errorf("Expected two or groups to be combined with $and, got %v", query)
+	}
+}
+
+func TestMemoryFilterOperators(t *testing.T) {
+	db := SetupMemoryDB(t)
+	defer CleanupMemoryDB(t, db)
+
+	params := map[string][]string{
+		"pointsGte": {"20"},
+		"or":        {"tagsAny:red|idPrefix:C"},
+	}
+
+	query, err := database.CreateFilterQuery(params, MemoryTestItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []MemoryTestItem
+	err = db.FindAll("items", query, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 1 || items[0].ID != "c" {
+		t.Errorf("Expected only item c, got %v", items)
+	}
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+/*
+	Tests that declared indexes are created and reported along with undeclared indexes
+*/
+func TestIndexStatus(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	declarations := database.IndexDeclarations{
+		"items": {
+			{Key: []string{"id"}, Unique: true},
+			{Key: []string{"tags", "-points"}},
+		},
+		"sessions": {
+			{Key: []string{"created"}, ExpireAfter: time.Hour},
+		},
+	}
+
+	index_status, err := database.GetIndexStatus(db, declarations)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for collection_name, collection_status := range index_status {
+		for _, status := range collection_status {
+			if status.Exists {
+				t.Errorf("Index %v on %v should not exist before being ensured", status.Key, collection_name)
+			}
+		}
+	}
+
+	err = database.EnsureIndexes(db, declarations)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.EnsureIndex("items", database.Index{Key: []string{"points"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index_status, err = database.GetIndexStatus(db, declarations)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_index_status := map[string][]database.IndexStatus{
+		"items": {
+			{Key: []string{"id"}, Unique: true, Declared: true, Exists: true},
+			{Key: []string{"tags", "-points"}, Declared: true, Exists: true},
+			{Key: []string{"points"}, Declared: false, Exists: true},
+		},
+		"sessions": {
+			{Key: []string{"created"}, ExpireAfterSeconds: 3600, Declared: true, Exists: true},
+		},
+	}
+
+	if !reflect.DeepEqual(index_status, expected_index_status) {
+		t.Errorf("Wrong index status.\nExpected %v\ngot %v\n", expected_index_status, index_status)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	A database on which unique indexes cannot be created, as if its collections had duplicate values
+*/
+type duplicateKeyDatabase struct {
+	database.Database
+}
+
+func (db duplicateKeyDatabase) EnsureIndex(collection_name string, index database.Index) error {
+	if index.Unique {
+		return database.ErrDuplicateKey
+	}
+
+	return db.Database.EnsureIndex(collection_name, index)
+}
+
+/*
+	Tests that unique indexes which cannot be created due to duplicate values are skipped and reported as missing
+*/
+func TestEnsureIndexesDuplicateKey(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	declarations := database.IndexDeclarations{
+		"items": {
+			{Key: []string{"id"}, Unique: true},
+			{Key: []string{"points"}},
+		},
+	}
+
+	err := database.EnsureIndexes(duplicateKeyDatabase{db}, declarations)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index_status, err := database.GetIndexStatus(db, declarations)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_index_status := map[string][]database.IndexStatus{
+		"items": {
+			{Key: []string{"id"}, Unique: true, Declared: true, Exists: false},
+			{Key: []string{"points"}, Declared: true, Exists: true},
+		},
+	}
+
+	if !reflect.DeepEqual(index_status, expected_index_status) {
+		t.Errorf("Wrong index status.\nExpected %v\ngot %v\n", expected_index_status, index_status)
+	}
+
+	CleanupMemoryDB(t, db)
+}
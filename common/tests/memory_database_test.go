@@ -0,0 +1,676 @@
+package tests
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+type MemoryTestItem struct {
+	ID     string   `json:"id"`
+	Points int      `json:"points"`
+	Tags   []string `json:"tags"`
+}
+
+func SetupMemoryDB(t *testing.T) database.Database {
+	db, err := database.InitDatabase("memory://", "test-memory")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := []MemoryTestItem{
+		{ID: "a", Points: 10, Tags: []string{"red", "blue"}},
+		{ID: "b", Points: 30, Tags: []string{"blue"}},
+		{ID: "c", Points: 20, Tags: []string{}},
+	}
+
+	for _, item := range items {
+		err = db.Insert("items", &item)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+func CleanupMemoryDB(t *testing.T, db database.Database) {
+	err := db.DropDatabase()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+	Tests that separate connections to the same memory host and database share data
+*/
+func TestMemorySharedStore(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	other_db, err := database.InitDatabase("memory://", "test-memory")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var item MemoryTestItem
+	err = other_db.FindOne("items", database.QuerySelector{"id": "b"}, &item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_item := MemoryTestItem{ID: "b", Points: 30, Tags: []string{"blue"}}
+
+	if !reflect.DeepEqual(item, expected_item) {
+		t.Errorf("Wrong item.\nExpected %v\ngot %v\n", expected_item, item)
+	}
+
+	err = other_db.FindOne("items", database.QuerySelector{"id": "z"}, &item)
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests the query operators generated by CreateFilterQuery
+*/
+func TestMemoryQueryOperators(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	queries := []struct {
+		query       map[string]interface{}
+		expected_id []string
+	}{
+		{map[string]interface{}{"points": database.QuerySelector{"$gt": 15}}, []string{"b", "c"}},
+		{map[string]interface{}{"points": database.QuerySelector{"$lt": int64(25), "$gt": int64(15)}}, []string{"c"}},
+		{map[string]interface{}{"id": database.QuerySelector{"$in": []string{"a", "c"}}}, []string{"a", "c"}},
+		{map[string]interface{}{"id": database.QuerySelector{"$nin": []string{"a", "c"}}}, []string{"b"}},
+		{map[string]interface{}{"tags": database.QuerySelector{"$all": []string{"blue", "red"}}}, []string{"a"}},
+		{map[string]interface{}{"tags": "blue"}, []string{"a", "b"}},
+		{nil, []string{"a", "b", "c"}},
+	}
+
+	for _, test := range queries {
+		var items []MemoryTestItem
+		err := db.FindAll("items", test.query, &items)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []string{}
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+
+		if !reflect.DeepEqual(ids, test.expected_id) {
+			t.Errorf("Wrong results for query %v.\nExpected %v\ngot %v\n", test.query, test.expected_id, ids)
+		}
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests sorting results by one or more fields
+*/
+func TestMemoryFindAllSorted(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	var items []MemoryTestItem
+	err := db.FindAllSorted("items", nil, []database.SortField{{Name: "points", Reversed: true}}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+
+	expected_ids := []string{"b", "c", "a"}
+
+	if !reflect.DeepEqual(ids, expected_ids) {
+		t.Errorf("Wrong sort order.\nExpected %v\ngot %v\n", expected_ids, ids)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests the update operators used by the services
+*/
+func TestMemoryUpdateOperators(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	selector := database.QuerySelector{"id": "a"}
+
+	updates := []database.QuerySelector{
+		{"$addToSet": database.QuerySelector{"tags": "green"}},
+		{"$addToSet": database.QuerySelector{"tags": "green"}},
+		{"$pull": database.QuerySelector{"tags": "red"}},
+		{"$set": database.QuerySelector{"points": 5}},
+	}
+
+	for _, update := range updates {
+		err := db.Update("items", selector, &update)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var item MemoryTestItem
+	err := db.FindOne("items", selector, &item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_item := MemoryTestItem{ID: "a", Points: 5, Tags: []string{"blue", "green"}}
+
+	if !reflect.DeepEqual(item, expected_item) {
+		t.Errorf("Wrong item.\nExpected %v\ngot %v\n", expected_item, item)
+	}
+
+	change_results, err := db.UpdateAll("items", nil, database.QuerySelector{"$pull": database.QuerySelector{"tags": "blue"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change_results.Updated != 3 {
+		t.Errorf("Wrong number of updated items.\nExpected %v\ngot %v\n", 3, change_results.Updated)
+	}
+
+	err = db.Update("items", database.QuerySelector{"id": "z"}, &item)
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests upserting both existing and new items
+*/
+func TestMemoryUpsert(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	new_item := MemoryTestItem{ID: "d", Points: 40, Tags: []string{}}
+
+	_, err := db.Upsert("items", database.QuerySelector{"id": "d"}, &new_item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	new_item.Points = 50
+
+	change_results, err := db.Upsert("items", database.QuerySelector{"id": "d"}, &new_item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change_results.Updated != 1 {
+		t.Errorf("Wrong number of updated items.\nExpected %v\ngot %v\n", 1, change_results.Updated)
+	}
+
+	var items []MemoryTestItem
+	err = db.FindAll("items", database.QuerySelector{"id": "d"}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_items := []MemoryTestItem{new_item}
+
+	if !reflect.DeepEqual(items, expected_items) {
+		t.Errorf("Wrong items.\nExpected %v\ngot %v\n", expected_items, items)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests removing items and computing stats
+*/
+func TestMemoryRemoveAndStats(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	change_results, err := db.RemoveAll("items", database.QuerySelector{"points": database.QuerySelector{"$lt": 25}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change_results.Deleted != 2 {
+		t.Errorf("Wrong number of deleted items.\nExpected %v\ngot %v\n", 2, change_results.Deleted)
+	}
+
+	stats, err := db.GetStats("items", []string{"tags"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_stats := map[string]interface{}{
+		"tags":  map[string]int{"blue": 1},
+		"count": 1,
+	}
+
+	if !reflect.DeepEqual(stats, expected_stats) {
+		t.Errorf("Wrong stats.\nExpected %v\ngot %v\n", expected_stats, stats)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests atomically modifying and returning an item
+*/
+func TestMemoryFindAndModify(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	var item MemoryTestItem
+	err := db.FindAndModify("items", database.QuerySelector{"id": "a"}, database.QuerySelector{"$inc": database.QuerySelector{"points": 5}}, false, &item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_item := MemoryTestItem{ID: "a", Points: 15, Tags: []string{"red", "blue"}}
+
+	if !reflect.DeepEqual(item, expected_item) {
+		t.Errorf("Wrong item.\nExpected %v\ngot %v\n", expected_item, item)
+	}
+
+	err = db.FindAndModify("items", database.QuerySelector{"id": "z"}, database.QuerySelector{"$inc": database.QuerySelector{"points": 5}}, false, &item)
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	item = MemoryTestItem{}
+	err = db.FindAndModify("items", database.QuerySelector{"id": "z"}, database.QuerySelector{"$inc": database.QuerySelector{"points": 5}}, true, &item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.ID != "z" || item.Points != 5 {
+		t.Errorf("Wrong upserted item. Got %v", item)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests updating an item only when it matches a condition
+*/
+func TestMemoryUpdateIfMatches(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	selector := database.QuerySelector{"id": "b"}
+	condition := database.QuerySelector{"tags": database.QuerySelector{"$ne": "red"}}
+	update := database.QuerySelector{"$addToSet": database.QuerySelector{"tags": "red"}}
+
+	was_updated, err := db.UpdateIfMatches("items", selector, condition, update)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !was_updated {
+		t.Errorf("Expected the item to be updated")
+	}
+
+	was_updated, err = db.UpdateIfMatches("items", selector, condition, update)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if was_updated {
+		t.Errorf("Expected the item not to be updated")
+	}
+
+	_, err = db.UpdateIfMatches("items", database.QuerySelector{"id": "z"}, condition, update)
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests running an aggregation pipeline
+*/
+func TestMemoryAggregate(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	pipeline := []database.QuerySelector{
+		{"$unwind": "$tags"},
+		{"$group": database.QuerySelector{
+			"_id":    "$tags",
+			"count":  database.QuerySelector{"$sum": 1},
+			"points": database.QuerySelector{"$sum": "$points"},
+		}},
+		{"$sort": database.QuerySelector{"_id": 1}},
+	}
+
+	var results []struct {
+		Tag    string `bson:"_id"`
+		Count  int    `bson:"count"`
+		Points int    `bson:"points"`
+	}
+
+	err := db.Aggregate("items", pipeline, &results)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 || results[0].Tag != "blue" || results[0].Count != 2 || results[0].Points != 40 || results[1].Tag != "red" || results[1].Count != 1 || results[1].Points != 10 {
+		t.Errorf("Wrong aggregation results. Got %v", results)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests that stats computed with aggregation pipelines match stats computed from each entry
+*/
+func TestMemoryStatsMatchEntryStats(t *testing.T) {
+	db, err := database.InitDatabase("memory://", "test-memory-stats")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []map[string]interface{}{
+		{
+			"id":          "a",
+			"isAttending": true,
+			"diet":        []interface{}{"vegan", "halal"},
+			"age":         20,
+			"registrationData": map[string]interface{}{
+				"attendee": map[string]interface{}{
+					"school": "UIUC",
+					"major":  "CS",
+				},
+			},
+		},
+		{
+			"id":          "b",
+			"isAttending": false,
+			"diet":        []interface{}{"vegan"},
+			"age":         21,
+			"registrationData": map[string]interface{}{
+				"attendee": map[string]interface{}{
+					"school": "Purdue",
+				},
+			},
+		},
+		{
+			"id":          "c",
+			"isAttending": true,
+			"diet":        []interface{}{},
+			"registrationData": map[string]interface{}{
+				"mentor": map[string]interface{}{
+					"school": "UIUC",
+				},
+			},
+		},
+	}
+
+	fields := []string{"isAttending", "diet", "age", "registrationData.attendee.school", "registrationData.mentor"}
+
+	expected_stats := database.GetDefaultStats()
+
+	for _, entry := range entries {
+		err = db.Insert("rsvps", entry)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = database.AddEntryToStats(expected_stats, entry, fields)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected_stats["count"] = len(entries)
+
+	stats, err := db.GetStats("rsvps", fields)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(stats, expected_stats) {
+		t.Errorf("Wrong stats.\nExpected %v\ngot %v\n", expected_stats, stats)
+	}
+
+	err = db.DropDatabase()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+	Tests that operations are not performed once the database's context is done
+*/
+func TestMemoryWithContext(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	var item MemoryTestItem
+	err := db.WithContext(context.Background()).FindOne("items", database.QuerySelector{"id": "a"}, &item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled_ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = db.WithContext(cancelled_ctx).Update("items", database.QuerySelector{"id": "a"}, database.QuerySelector{"$set": database.QuerySelector{"points": 0}})
+
+	if err != database.ErrCanceled {
+		t.Errorf("Expected ErrCanceled, got %v", err)
+	}
+
+	expired_ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, err = db.WithContext(expired_ctx).RemoveAll("items", nil)
+
+	if err != database.ErrTimeout {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+
+	var items []MemoryTestItem
+	err = db.FindAll("items", nil, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 3 || items[0].Points != 10 {
+		t.Errorf("Items were modified by a cancelled operation. Got %v", items)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests that transactions are committed when they succeed and rolled back when they fail
+*/
+func TestMemoryRunTransaction(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	err := db.RunTransaction(func(tx database.Database) error {
+		err := tx.Update("items", database.QuerySelector{"id": "a"}, database.QuerySelector{"$inc": database.QuerySelector{"points": -5}})
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Update("items", database.QuerySelector{"id": "b"}, database.QuerySelector{"$inc": database.QuerySelector{"points": 5}})
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.RunTransaction(func(tx database.Database) error {
+		err := tx.Update("items", database.QuerySelector{"id": "a"}, database.QuerySelector{"$inc": database.QuerySelector{"points": -5}})
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Update("items", database.QuerySelector{"id": "missing"}, database.QuerySelector{"$inc": database.QuerySelector{"points": 5}})
+	})
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound from the failed transaction, got %v", err)
+	}
+
+	var items []MemoryTestItem
+	err = db.FindAllSorted("items", nil, []database.SortField{{Name: "id"}}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 3 || items[0].Points != 5 || items[1].Points != 35 {
+		t.Errorf("Expected only the successful transaction to be applied. Got %v", items)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests that writes outside of a transaction wait for it, so that aborting the transaction does not undo them
+*/
+func TestMemoryTransactionConcurrentWrite(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	write_done := make(chan error)
+
+	err := db.RunTransaction(func(tx database.Database) error {
+		go func() {
+			write_done <- db.Insert("items", &MemoryTestItem{ID: "d", Points: 40, Tags: []string{}})
+		}()
+
+		select {
+		case err := <-write_done:
+			t.Errorf("Expected the write to wait for the transaction, but it finished with %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		err := tx.Update("items", database.QuerySelector{"id": "a"}, database.QuerySelector{"$inc": database.QuerySelector{"points": -5}})
+
+		if err != nil {
+			return err
+		}
+
+		return database.ErrNotFound
+	})
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound from the failed transaction, got %v", err)
+	}
+
+	err = <-write_done
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []MemoryTestItem
+	err = db.FindAllSorted("items", nil, []database.SortField{{Name: "id"}}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 4 || items[0].Points != 10 || items[3].ID != "d" {
+		t.Errorf("Expected the transaction to be rolled back and the write to be kept. Got %v", items)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests that unique indexes are enforced on writes and cannot be created on collections with duplicates
+*/
+func TestMemoryUniqueIndex(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	err := db.EnsureIndex("items", database.Index{Key: []string{"id"}, Unique: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Insert("items", &MemoryTestItem{ID: "a", Points: 50, Tags: []string{}})
+
+	if err != database.ErrDuplicateKey {
+		t.Errorf("Expected ErrDuplicateKey inserting a duplicate id, got %v", err)
+	}
+
+	_, err = db.Upsert("items", database.QuerySelector{"points": 50}, database.QuerySelector{"$set": database.QuerySelector{"id": "b"}})
+
+	if err != database.ErrDuplicateKey {
+		t.Errorf("Expected ErrDuplicateKey upserting a duplicate id, got %v", err)
+	}
+
+	err = db.Update("items", database.QuerySelector{"id": "c"}, database.QuerySelector{"$set": database.QuerySelector{"id": "b"}})
+
+	if err != database.ErrDuplicateKey {
+		t.Errorf("Expected ErrDuplicateKey updating to a duplicate id, got %v", err)
+	}
+
+	err = db.Update("items", database.QuerySelector{"id": "c"}, database.QuerySelector{"$set": database.QuerySelector{"points": 25}})
+
+	if err != nil {
+		t.Errorf("Expected updating an item without changing its id to succeed, got %v", err)
+	}
+
+	count, err := db.Count("items", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 {
+		t.Errorf("Expected no items to be added by the rejected writes, got %v items", count)
+	}
+
+	err = db.EnsureIndex("items", database.Index{Key: []string{"name"}, Unique: true})
+
+	if err != database.ErrDuplicateKey {
+		t.Errorf("Expected ErrDuplicateKey creating a unique index on a field every item is missing, got %v", err)
+	}
+
+	CleanupMemoryDB(t, db)
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+/*
+	Tests that migrations must be registered in increasing order of version, and that only registered reversible migrations can be rolled back
+*/
+func TestMigrationValidation(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	noop := func(db database.Database) error {
+		return nil
+	}
+
+	_, err := database.Migrate(db, database.Migrations{
+		{Version: 2, Up: noop},
+		{Version: 1, Up: noop},
+	}, database.MigrationOptions{})
+
+	if err == nil {
+		t.Error("Expected an error for migrations registered out of order")
+	}
+
+	migrations := database.Migrations{
+		{Version: 1, Up: noop, Down: noop},
+		{Version: 2, Up: noop},
+	}
+
+	applied, err := database.Migrate(db, migrations, database.MigrationOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != 2 {
+		t.Errorf("Expected 2 migrations to be applied, got %v", len(applied))
+	}
+
+	_, err = database.Migrate(db, migrations, database.MigrationOptions{Rollback: true, TargetVersion: 0})
+
+	if err == nil {
+		t.Error("Expected an error rolling back an irreversible migration")
+	}
+
+	_, err = database.Migrate(db, migrations[:1], database.MigrationOptions{Rollback: true, TargetVersion: 1})
+
+	if err == nil {
+		t.Error("Expected an error rolling back a migration which is not registered")
+	}
+
+	rolled_back, err := database.Migrate(db, migrations, database.MigrationOptions{Rollback: true, TargetVersion: 2})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rolled_back) != 0 {
+		t.Errorf("Expected no migrations after version 2 to be rolled back, got %v", len(rolled_back))
+	}
+
+	records, err := database.GetAppliedMigrations(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || records[0].Version != 1 || records[1].Version != 2 {
+		t.Errorf("Wrong applied migrations. Got %v", records)
+	}
+
+	CleanupMemoryDB(t, db)
+}
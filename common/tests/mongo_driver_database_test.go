@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+/*
+	Tests that operations within a done context fail with the context's error without contacting the server
+*/
+func TestMongoDriverWithContext(t *testing.T) {
+	db, err := database.InitMongoDriverDatabase("mongodb://localhost/?serverSelectionTimeoutMS=1000", "test-mongo-driver")
+
+	if err == database.ErrConnection {
+		t.Skip("Skipping since no mongo server is running on localhost")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var item MemoryTestItem
+	err = db.WithContext(ctx).FindOne("items", database.QuerySelector{"id": "a"}, &item)
+
+	if err != database.ErrCanceled {
+		t.Errorf("Expected ErrCanceled, got %v", err)
+	}
+
+	err = db.WithContext(ctx).RunTransaction(func(tx database.Database) error {
+		return tx.Insert("items", item)
+	})
+
+	if err != database.ErrCanceled {
+		t.Errorf("Expected ErrCanceled, got %v", err)
+	}
+}
+
+/*
+	Tests that connecting to a server which cannot be reached fails with ErrConnection
+*/
+func TestMongoDriverConnectionError(t *testing.T) {
+	_, err := database.InitMongoDriverDatabase("mongodb://localhost:1/?serverSelectionTimeoutMS=100", "test-mongo-driver")
+
+	if err != database.ErrConnection {
+		t.Errorf("Expected ErrConnection, got %v", err)
+	}
+}
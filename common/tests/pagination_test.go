@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+/*
+	Tests parsing limit and page parameters
+*/
+func TestParsePaginationPage(t *testing.T) {
+	params := map[string][]string{
+		"limit": {"10"},
+		"page":  {"3"},
+		"key":   {"value"},
+	}
+
+	pagination, err := database.ParsePaginationParameters(params)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_pagination := &database.PaginationOptions{
+		Limit: 10,
+		Skip:  20,
+	}
+
+	if !reflect.DeepEqual(pagination, expected_pagination) {
+		t.Errorf("Wrong pagination.\nExpected %v\ngot %v\n", expected_pagination, pagination)
+	}
+
+	expected_params := map[string][]string{
+		"key": {"value"},
+	}
+
+	if !reflect.DeepEqual(params, expected_params) {
+		t.Errorf("Pagination parameters not removed.\nExpected %v\ngot %v\n", expected_params, params)
+	}
+}
+
+/*
+	Tests that the next cursor returned with a page resumes where the page ended
+*/
+func TestParsePaginationCursor(t *testing.T) {
+	page_info := database.GetPageInfo(database.PaginationOptions{Limit: 5, Skip: 0}, &database.PaginationResults{Total: 12})
+
+	expected_page_info := &database.PageInfo{
+		Total:      12,
+		Limit:      5,
+		Page:       1,
+		NextCursor: page_info.NextCursor,
+	}
+
+	if page_info.NextCursor == "" || !reflect.DeepEqual(page_info, expected_page_info) {
+		t.Fatalf("Wrong page info.\nExpected %v\ngot %v\n", expected_page_info, page_info)
+	}
+
+	params := map[string][]string{
+		"cursor": {page_info.NextCursor},
+	}
+
+	pagination, err := database.ParsePaginationParameters(params)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_pagination := &database.PaginationOptions{
+		Limit: 5,
+		Skip:  5,
+	}
+
+	if !reflect.DeepEqual(pagination, expected_pagination) {
+		t.Errorf("Wrong pagination.\nExpected %v\ngot %v\n", expected_pagination, pagination)
+	}
+
+	last_page_info := database.GetPageInfo(database.PaginationOptions{Limit: 5, Skip: 10}, &database.PaginationResults{Total: 12})
+
+	if last_page_info.NextCursor != "" || last_page_info.Page != 3 {
+		t.Errorf("Wrong last page info. Got %v", last_page_info)
+	}
+}
+
+/*
+	Tests that invalid pagination parameters are rejected
+*/
+func TestParsePaginationInvalid(t *testing.T) {
+	invalid_params := []map[string][]string{
+		{"limit": {"-1"}},
+		{"page": {"2"}},
+		{"limit": {"5"}, "page": {"0"}},
+		{"cursor": {"not a cursor"}},
+	}
+
+	for _, params := range invalid_params {
+		_, err := database.ParsePaginationParameters(params)
+
+		if err == nil {
+			t.Errorf("Expected error for parameters %v", params)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/common/datastore"
+)
+
+var patch_definition = datastore.DataStoreDefinition{
+	Name: "registration",
+	Type: "object",
+	Fields: []datastore.DataStoreDefinition{
+		{Name: "firstName", Type: "string", Validations: "required"},
+		{Name: "age", Type: "int", Validations: "required"},
+		{Name: "interests", Type: "[]string"},
+		{
+			Name: "school",
+			Type: "object",
+			Fields: []datastore.DataStoreDefinition{
+				{Name: "name", Type: "string"},
+				{Name: "year", Type: "int"},
+			},
+		},
+	},
+}
+
+/*
+	Tests that a merge patch merges objects, replaces values, and resets fields set to null to their defaults
+*/
+func TestApplyMergePatch(t *testing.T) {
+	store := datastore.DataStore{
+		Definition: patch_definition,
+		Data: map[string]interface{}{
+			"firstName": "John",
+			"age":       int64(20),
+			"interests": []string{"WEBDEV"},
+			"school": map[string]interface{}{
+				"name": "UIUC",
+				"year": int64(2),
+			},
+		},
+	}
+
+	patched, changed_fields, err := store.ApplyMergePatch([]byte(`{"age": 21, "interests": null, "school": {"year": 3}, "unknown": 1}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_data := map[string]interface{}{
+		"firstName": "John",
+		"age":       int64(21),
+		"interests": nil,
+		"school": map[string]interface{}{
+			"name": "UIUC",
+			"year": int64(3),
+		},
+	}
+
+	if !reflect.DeepEqual(patched.Data, expected_data) {
+		t.Errorf("Wrong data.\nExpected %v\ngot %v\n", expected_data, patched.Data)
+	}
+
+	expected_changed_fields := []string{"age", "interests", "school"}
+
+	if !reflect.DeepEqual(changed_fields, expected_changed_fields) {
+		t.Errorf("Wrong changed fields.\nExpected %v\ngot %v\n", expected_changed_fields, changed_fields)
+	}
+
+	if store.Data["age"] != int64(20) {
+		t.Errorf("Expected the original datastore to be unchanged, got %v", store.Data)
+	}
+}
+
+/*
+	Tests that patches which are not JSON objects, or whose values do not match the definition, are rejected
+*/
+func TestApplyMergePatchErrors(t *testing.T) {
+	store := datastore.NewDataStore(patch_definition)
+
+	patches := []string{
+		`["firstName"]`,
+		`"John"`,
+		`{"age": "twenty"}`,
+		`{"firstName": `,
+	}
+
+	for _, patch := range patches {
+		_, _, err := store.ApplyMergePatch([]byte(patch))
+
+		if err == nil {
+			t.Errorf("Expected applying the patch %s to fail", patch)
+		}
+	}
+}
+
+/*
+	Tests that the update for a patch only sets the given fields
+*/
+func TestGetFieldsUpdate(t *testing.T) {
+	store := datastore.DataStore{
+		Definition: patch_definition,
+		Data: map[string]interface{}{
+			"firstName": "John",
+			"age":       int64(20),
+		},
+	}
+
+	update := store.GetFieldsUpdate([]string{"age"})
+
+	expected_update := database.QuerySelector{
+		"$set": database.QuerySelector{
+			"age": int64(20),
+		},
+	}
+
+	if !reflect.DeepEqual(update, expected_update) {
+		t.Errorf("Wrong update.\nExpected %v\ngot %v\n", expected_update, update)
+	}
+}
+
+/*
+	Tests that the update for a patch replaces the whole stored record only if the stored record needs an upgrade
+*/
+func TestGetPatchUpdate(t *testing.T) {
+	definition := patch_definition
+	definition.Version = 2
+
+	store := datastore.DataStore{
+		Definition: definition,
+		Data: map[string]interface{}{
+			"firstName": "John",
+			"age":       int64(20),
+		},
+	}
+
+	update := store.GetPatchUpdate(1, []string{"age"})
+
+	if update != &store {
+		t.Errorf("Wrong update.\nExpected the whole datastore\ngot %v\n", update)
+	}
+
+	update = store.GetPatchUpdate(2, []string{"age"})
+	expected_update := store.GetFieldsUpdate([]string{"age"})
+
+	if !reflect.DeepEqual(update, expected_update) {
+		t.Errorf("Wrong update.\nExpected %v\ngot %v\n", expected_update, update)
+	}
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/common/datastore"
+)
+
+type ProjectionTestModel struct {
+	ID        string `json:"id"`
+	FirstName string `json:"firstName"`
+	Email     string `json:"email"`
+}
+
+/*
+	Tests that requested fields are resolved to their stored names for struct and datastore models
+*/
+func TestParseProjectionParameters(t *testing.T) {
+	parameters := map[string][]string{
+		"fields":    {"id, firstName,id"},
+		"firstName": {"John"},
+	}
+
+	projection, err := database.ParseProjectionParameters(parameters, ProjectionTestModel{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_projection := []string{"id", "firstname"}
+
+	if !reflect.DeepEqual(projection, expected_projection) {
+		t.Errorf("Wrong projection. Expected %v, got %v", expected_projection, projection)
+	}
+
+	if _, exists := parameters["fields"]; exists {
+		t.Error("Expected fields to be removed from the parameters")
+	}
+
+	model := datastore.NewDataStore(datastore.DataStoreDefinition{
+		Name: "registration",
+		Type: "object",
+		Fields: []datastore.DataStoreDefinition{
+			{Name: "id", Type: "string"},
+			{Name: "firstName", Type: "string"},
+		},
+	})
+
+	projection, err = database.ParseProjectionParameters(map[string][]string{"fields": {"firstname"}}, model)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_projection = []string{"firstName"}
+
+	if !reflect.DeepEqual(projection, expected_projection) {
+		t.Errorf("Wrong projection. Expected %v, got %v", expected_projection, projection)
+	}
+
+	projection, err = database.ParseProjectionParameters(map[string][]string{}, model)
+
+	if err != nil || projection != nil {
+		t.Errorf("Expected no projection without a fields parameter, got %v, %v", projection, err)
+	}
+
+	_, err = database.ParseProjectionParameters(map[string][]string{"fields": {"password"}}, ProjectionTestModel{})
+
+	if err == nil {
+		t.Error("Expected an error for a field which is not in the model")
+	}
+}
+
+/*
+	Tests that unrequested fields are removed from the results in a response
+*/
+func TestProjectResponse(t *testing.T) {
+	response := struct {
+		Results    []ProjectionTestModel `json:"results"`
+		Pagination map[string]int        `json:"pagination"`
+	}{
+		Results: []ProjectionTestModel{
+			{ID: "a", FirstName: "John", Email: "john@example.com"},
+		},
+		Pagination: map[string]int{"total": 1},
+	}
+
+	projected_response, err := database.ProjectResponse(response, map[string][]string{"fields": {"id,FirstName"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_response := map[string]interface{}{
+		"results": []interface{}{
+			map[string]interface{}{"id": "a", "firstName": "John"},
+		},
+		"pagination": map[string]interface{}{"total": float64(1)},
+	}
+
+	if !reflect.DeepEqual(projected_response, expected_response) {
+		t.Errorf("Wrong response. Expected %v, got %v", expected_response, projected_response)
+	}
+
+	unchanged_response, err := database.ProjectResponse(response, map[string][]string{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(unchanged_response, response) {
+		t.Errorf("Expected the response to be unchanged without a fields parameter, got %v", unchanged_response)
+	}
+}
+
+/*
+	Tests that projected finds only include the selected fields
+*/
+func TestMemoryFindProjected(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	var item database.QuerySelector
+	err := db.FindOneProjected("items", database.QuerySelector{"id": "a"}, []string{"points"}, &item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, exists := item["id"]; exists || item["points"] != 10 {
+		t.Errorf("Expected only points to be selected, got %v", item)
+	}
+
+	var items []MemoryTestItem
+	results, err := db.FindAllProjected("items", nil, []string{"id"}, []database.SortField{{Name: "points", Reversed: true}}, database.PaginationOptions{Limit: 2}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_items := []MemoryTestItem{
+		{ID: "b"},
+		{ID: "c"},
+	}
+
+	if results.Total != 3 || !reflect.DeepEqual(items, expected_items) {
+		t.Errorf("Wrong items. Expected %v of 3, got %v of %v", expected_items, items, results.Total)
+	}
+
+	CleanupMemoryDB(t, db)
+}
@@ -0,0 +1,385 @@
+package tests
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/HackIllinois/api/common/datastore"
+)
+
+var schema_json_definition = `
+{
+	"name": "registration",
+	"type": "object",
+	"validations": "",
+	"fields": [
+		{
+			"name": "email",
+			"type": "string",
+			"validations": "required,email",
+			"fields": []
+		},
+		{
+			"name": "shirtSize",
+			"type": "string",
+			"validations": "required,oneof=S M L XL",
+			"fields": []
+		},
+		{
+			"name": "graduationYear",
+			"type": "int",
+			"validations": "omitempty,min=1900,max=2030",
+			"fields": []
+		},
+		{
+			"name": "isOSContributor",
+			"type": "boolean",
+			"validations": "required|isdefault",
+			"fields": []
+		},
+		{
+			"name": "interests",
+			"type": "[]string",
+			"validations": "required,max=3,dive,oneof=WEBDEV SYSTEMS",
+			"fields": []
+		},
+		{
+			"name": "answers",
+			"type": "[]object",
+			"validations": "",
+			"fields": [
+				{
+					"name": "rating",
+					"type": "float",
+					"validations": "gt=0,lte=5",
+					"fields": []
+				}
+			]
+		},
+		{
+			"name": "extraInfo",
+			"type": "object",
+			"validations": "",
+			"fields": []
+		}
+	]
+}
+`
+
+var expected_schema_json = `
+{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "registration",
+	"type": "object",
+	"required": ["email", "shirtSize", "interests"],
+	"properties": {
+		"email": {
+			"type": "string",
+			"x-validations": "required,email",
+			"minLength": 1,
+			"format": "email"
+		},
+		"shirtSize": {
+			"type": "string",
+			"x-validations": "required,oneof=S M L XL",
+			"minLength": 1,
+			"enum": ["S", "M", "L", "XL"]
+		},
+		"graduationYear": {
+			"type": "integer",
+			"x-validations": "omitempty,min=1900,max=2030",
+			"anyOf": [
+				{"const": 0},
+				{"minimum": 1900, "maximum": 2030}
+			]
+		},
+		"isOSContributor": {
+			"type": "boolean",
+			"x-validations": "required|isdefault"
+		},
+		"interests": {
+			"type": "array",
+			"x-validations": "required,max=3,dive,oneof=WEBDEV SYSTEMS",
+			"maxItems": 3,
+			"items": {
+				"type": "string",
+				"enum": ["WEBDEV", "SYSTEMS"]
+			}
+		},
+		"answers": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"rating": {
+						"type": "number",
+						"x-validations": "gt=0,lte=5",
+						"exclusiveMinimum": 0,
+						"maximum": 5
+					}
+				}
+			}
+		},
+		"extraInfo": {
+			"type": "object"
+		}
+	}
+}
+`
+
+/*
+	Tests that a definition's types and validations are converted to the equivalent JSON Schema
+*/
+func TestDataStoreJSONSchema(t *testing.T) {
+	var definition datastore.DataStoreDefinition
+	err := json.Unmarshal([]byte(schema_json_definition), &definition)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := definition.ToJSONSchema()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Round trip the schema through json so that it can be compared to the expected json
+	schema_json, err := json.Marshal(schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual_schema interface{}
+	err = json.Unmarshal(schema_json, &actual_schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected_schema interface{}
+	err = json.Unmarshal([]byte(expected_schema_json), &expected_schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual_schema, expected_schema) {
+		t.Errorf("Wrong schema.\nExpected %v\ngot %v\n", expected_schema, actual_schema)
+	}
+}
+
+/*
+	Tests that invalid types and tag parameters are reported with the field they are in
+*/
+func TestDataStoreJSONSchemaErrors(t *testing.T) {
+	definitions := []datastore.DataStoreDefinition{
+		{
+			Name: "registration",
+			Type: "object",
+			Fields: []datastore.DataStoreDefinition{
+				{Name: "age", Type: "integer"},
+			},
+		},
+		{
+			Name: "registration",
+			Type: "object",
+			Fields: []datastore.DataStoreDefinition{
+				{Name: "age", Type: "int", Validations: "min=ten"},
+			},
+		},
+		{
+			Name: "registration",
+			Type: "object",
+			Fields: []datastore.DataStoreDefinition{
+				{Name: "name", Type: "string", Validations: "dive,required"},
+			},
+		},
+	}
+
+	for _, definition := range definitions {
+		_, err := definition.ToJSONSchema()
+
+		field_err, ok := err.(datastore.ErrorInField)
+
+		if !ok {
+			t.Errorf("Expected an ErrorInField for %v, got %v", definition.Fields[0], err)
+			continue
+		}
+
+		if field_err.FieldName != definition.Fields[0].Name {
+			t.Errorf("Wrong field name.\nExpected %s\ngot %s\n", definition.Fields[0].Name, field_err.FieldName)
+		}
+	}
+}
+
+/*
+	Tests the schemas of enum, timestamp, nullable, and map fields, and fields with defaults
+*/
+func TestDataStoreJSONSchemaTypes(t *testing.T) {
+	definition := datastore.DataStoreDefinition{
+		Name: "types",
+		Type: "object",
+		Fields: []datastore.DataStoreDefinition{
+			{Name: "shirtSize", Type: "enum", Validations: "required", Values: []string{"S", "M"}},
+			{Name: "arrivalTime", Type: "timestamp", Validations: "required"},
+			{Name: "graduationYear", Type: "int", Validations: "required", Nullable: true},
+			{Name: "skills", Type: "map[string]int", Validations: "dive,max=5"},
+			{Name: "teamSize", Type: "int", Validations: "required", Default: float64(4)},
+		},
+	}
+
+	schema, err := definition.ToJSONSchema()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema_json, err := json.Marshal(schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual_schema interface{}
+	err = json.Unmarshal(schema_json, &actual_schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_schema_types_json := `
+	{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title": "types",
+		"type": "object",
+		"required": ["shirtSize", "arrivalTime"],
+		"properties": {
+			"shirtSize": {
+				"type": "string",
+				"enum": ["S", "M"],
+				"minLength": 1,
+				"x-validations": "required"
+			},
+			"arrivalTime": {
+				"type": ["string", "integer"],
+				"format": "date-time",
+				"not": {"const": 0},
+				"x-validations": "required"
+			},
+			"graduationYear": {
+				"type": ["integer", "null"],
+				"not": {"const": 0},
+				"x-validations": "required"
+			},
+			"skills": {
+				"type": "object",
+				"additionalProperties": {
+					"type": "integer",
+					"maximum": 5
+				},
+				"x-validations": "dive,max=5"
+			},
+			"teamSize": {
+				"type": "integer",
+				"default": 4,
+				"not": {"const": 0},
+				"x-validations": "required"
+			}
+		}
+	}
+	`
+
+	var expected_schema interface{}
+	err = json.Unmarshal([]byte(expected_schema_types_json), &expected_schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual_schema, expected_schema) {
+		t.Errorf("Wrong schema.\nExpected %v\ngot %v\n", expected_schema, actual_schema)
+	}
+}
+
+/*
+	Tests that required_if and required_unless are converted to conditional schemas on the parent object
+*/
+func TestDataStoreJSONSchemaConditions(t *testing.T) {
+	definition := datastore.DataStoreDefinition{
+		Name: "rsvp",
+		Type: "object",
+		Fields: []datastore.DataStoreDefinition{
+			{Name: "isAttending", Type: "boolean"},
+			{Name: "transportation", Type: "string", Validations: "required_if=isAttending true,oneof=NONE BUS"},
+			{Name: "reason", Type: "string", Validations: "required_unless=isAttending true"},
+		},
+	}
+
+	schema, err := definition.ToJSONSchema()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema_json, err := json.Marshal(schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual_schema interface{}
+	err = json.Unmarshal(schema_json, &actual_schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_schema_conditions_json := `
+	{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title": "rsvp",
+		"type": "object",
+		"properties": {
+			"isAttending": {
+				"type": "boolean"
+			},
+			"transportation": {
+				"type": "string",
+				"anyOf": [
+					{"const": ""},
+					{"enum": ["NONE", "BUS"]}
+				],
+				"x-validations": "required_if=isAttending true,oneof=NONE BUS"
+			},
+			"reason": {
+				"type": "string",
+				"x-validations": "required_unless=isAttending true"
+			}
+		},
+		"allOf": [
+			{
+				"if": {"properties": {"isAttending": {"const": true}}, "required": ["isAttending"]},
+				"then": {"required": ["transportation"], "properties": {"transportation": {"minLength": 1}}}
+			},
+			{
+				"if": {"properties": {"isAttending": {"const": true}}, "required": ["isAttending"]},
+				"else": {"required": ["reason"], "properties": {"reason": {"minLength": 1}}}
+			}
+		]
+	}
+	`
+
+	var expected_schema interface{}
+	err = json.Unmarshal([]byte(expected_schema_conditions_json), &expected_schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual_schema, expected_schema) {
+		t.Errorf("Wrong schema.\nExpected %v\ngot %v\n", expected_schema, actual_schema)
+	}
+}
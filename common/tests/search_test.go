@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+type SearchTestItem struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Points      int      `json:"points"`
+}
+
+func SetupSearchDB(t *testing.T) database.Database {
+	db, err := database.InitDatabase("memory://", "test-search")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.EnsureIndex("items", database.Index{Key: []string{"$text:name", "$text:description", "$text:tags"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := []SearchTestItem{
+		{ID: "a", Name: "Intro to PyTorch", Description: "A workshop on machine learning with pytorch", Tags: []string{"ml"}, Points: 10},
+		{ID: "b", Name: "Resume Workshop", Description: "Get feedback on your resume", Tags: []string{"career"}, Points: 20},
+		{ID: "c", Name: "Dinner", Description: "Pizza for everyone", Tags: []string{"food"}, Points: 30},
+		{ID: "d", Name: "Machine Learning Talk", Description: "A talk about deep learning", Tags: []string{"ml", "workshop"}, Points: 40},
+	}
+
+	for _, item := range items {
+		err = db.Insert("items", &item)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+/*
+	Returns the ids of the given items in order
+*/
+func getSearchTestItemIDs(items []SearchTestItem) []string {
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func TestMemoryFindAllText(t *testing.T) {
+	db := SetupSearchDB(t)
+	defer CleanupMemoryDB(t, db)
+
+	var items []SearchTestItem
+	results, err := db.FindAllText("items", "pytorch workshop", nil, nil, nil, database.PaginationOptions{}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a matches both terms, and d and b each match one, with d's match making up all of a tag
+	expected_ids := []string{"a", "d", "b"}
+
+	if !reflect.DeepEqual(getSearchTestItemIDs(items), expected_ids) {
+		t.Errorf("Wrong search results. Expected %v, got %v", expected_ids, getSearchTestItemIDs(items))
+	}
+
+	if results.Total != 3 {
+		t.Errorf("Wrong total. Expected 3, got %v", results.Total)
+	}
+
+	items = nil
+	_, err = db.FindAllText("items", "workshop -resume", database.QuerySelector{"points": database.QuerySelector{"$gt": 10}}, nil, nil, database.PaginationOptions{}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_ids = []string{"d"}
+
+	if !reflect.DeepEqual(getSearchTestItemIDs(items), expected_ids) {
+		t.Errorf("Wrong search results. Expected %v, got %v", expected_ids, getSearchTestItemIDs(items))
+	}
+
+	items = nil
+	_, err = db.FindAllText("items", "\"machine learning\"", nil, nil, []database.SortField{{Name: "points", Reversed: true}}, database.PaginationOptions{Limit: 1}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_ids = []string{"d"}
+
+	if !reflect.DeepEqual(getSearchTestItemIDs(items), expected_ids) {
+		t.Errorf("Wrong search results. Expected %v, got %v", expected_ids, getSearchTestItemIDs(items))
+	}
+
+	// Items with equal relevance are ordered by _id rather than by when they were inserted
+	for _, item := range []database.QuerySelector{{"_id": "z", "id": "y", "name": "Hackathon"}, {"_id": "x", "id": "w", "name": "Hackathon"}} {
+		err = db.Insert("items", &item)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items = nil
+	_, err = db.FindAllText("items", "hackathon", nil, nil, nil, database.PaginationOptions{}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_ids = []string{"w", "y"}
+
+	if !reflect.DeepEqual(getSearchTestItemIDs(items), expected_ids) {
+		t.Errorf("Wrong search results. Expected %v, got %v", expected_ids, getSearchTestItemIDs(items))
+	}
+
+	_, err = db.FindAllText("missing", "pytorch", nil, nil, nil, database.PaginationOptions{}, &items)
+
+	if err != database.ErrNoTextIndex {
+		t.Errorf("Expected ErrNoTextIndex searching a collection without a text index, got %v", err)
+	}
+}
+
+func TestFindAllSearched(t *testing.T) {
+	db := SetupSearchDB(t)
+	defer CleanupMemoryDB(t, db)
+
+	parameters := map[string][]string{
+		"q":      {"learning"},
+		"points": {"10"},
+	}
+
+	text, err := database.ParseSearchParameter(parameters)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := database.CreateFilterQuery(parameters, SearchTestItem{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []SearchTestItem
+	_, err = database.FindAllSearched(db, "items", text, query, []string{"id"}, nil, database.PaginationOptions{}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_items := []SearchTestItem{{ID: "a"}}
+
+	if !reflect.DeepEqual(items, expected_items) {
+		t.Errorf("Wrong search results. Expected %v, got %v", expected_items, items)
+	}
+
+	items = nil
+	_, err = database.FindAllSearched(db, "items", "", nil, nil, nil, database.PaginationOptions{}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 4 {
+		t.Errorf("Expected every item without search text, got %v", items)
+	}
+}
+
+func TestSoftDeleteFindAllText(t *testing.T) {
+	db := database.WithSoftDelete(SetupSearchDB(t), []string{"items"})
+	defer CleanupMemoryDB(t, db)
+
+	err := db.RemoveOne("items", database.QuerySelector{"id": "a"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []SearchTestItem
+	_, err = db.FindAllText("items", "pytorch", nil, nil, nil, database.PaginationOptions{}, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 0 {
+		t.Errorf("Expected deleted items to be excluded from search, got %v", items)
+	}
+}
+
+func TestTextIndexStatus(t *testing.T) {
+	db := SetupSearchDB(t)
+	defer CleanupMemoryDB(t, db)
+
+	declarations := database.IndexDeclarations{
+		"items": {
+			{Key: []string{"$text:tags", "$text:name", "$text:description"}},
+		},
+	}
+
+	index_status, err := database.GetIndexStatus(db, declarations)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index_status["items"]) != 1 || !index_status["items"][0].Exists {
+		t.Errorf("Expected the text index to match regardless of field order, got %v", index_status)
+	}
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+/*
+	Tests that removed items are hidden from finds until they are restored
+*/
+func TestSoftDelete(t *testing.T) {
+	db := database.WithSoftDelete(SetupMemoryDB(t), []string{"items"})
+
+	err := db.RemoveOne("items", database.QuerySelector{"id": "a"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var item MemoryTestItem
+	err = db.FindOne("items", database.QuerySelector{"id": "a"}, &item)
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound finding a deleted item, got %v", err)
+	}
+
+	count, err := db.Count("items", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("Wrong count. Expected 2, got %v", count)
+	}
+
+	err = db.RemoveOne("items", database.QuerySelector{"id": "a"})
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound deleting a deleted item, got %v", err)
+	}
+
+	var deleted_items []MemoryTestItem
+	err = database.FindAllDeleted(db, "items", nil, &deleted_items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_deleted_items := []MemoryTestItem{
+		{ID: "a", Points: 10, Tags: []string{"red", "blue"}},
+	}
+
+	if !reflect.DeepEqual(deleted_items, expected_deleted_items) {
+		t.Errorf("Wrong deleted items. Expected %v, got %v", expected_deleted_items, deleted_items)
+	}
+
+	err = database.RestoreOne(db, "items", database.QuerySelector{"id": "a"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.FindOne("items", database.QuerySelector{"id": "a"}, &item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = database.RestoreOne(db, "items", database.QuerySelector{"id": "a"})
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound restoring an item which is not deleted, got %v", err)
+	}
+
+	err = database.RestoreOne(db, "others", database.QuerySelector{"id": "a"})
+
+	if err != database.ErrSoftDeleteDisabled {
+		t.Errorf("Expected ErrSoftDeleteDisabled for a collection which is not soft deleted, got %v", err)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests that inserting an item replaces deleted items it conflicts with on a unique index
+*/
+func TestSoftDeleteInsertConflict(t *testing.T) {
+	db := database.WithSoftDelete(SetupMemoryDB(t), []string{"items"})
+
+	err := db.EnsureIndex("items", database.Index{Key: []string{"id"}, Unique: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.RemoveAll("items", database.QuerySelector{"id": database.QuerySelector{"$in": []string{"a", "b"}}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Insert("items", MemoryTestItem{ID: "a", Points: 50, Tags: []string{}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deleted_items []MemoryTestItem
+	err = database.FindAllDeleted(db, "items", nil, &deleted_items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted_items) != 1 || deleted_items[0].ID != "b" {
+		t.Errorf("Expected only b to remain deleted, got %v", deleted_items)
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests that deleted items are permanently removed once they are purged
+*/
+func TestPurgeDeleted(t *testing.T) {
+	db := database.WithSoftDelete(SetupMemoryDB(t), []string{"items"})
+
+	err := db.RemoveOne("items", database.QuerySelector{"id": "b"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purge_results, err := database.PurgeDeleted(db, time.Now().Add(-time.Hour))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if purge_results.Deleted != 0 {
+		t.Errorf("Expected no items deleted within the retention period to be purged, got %v", purge_results.Deleted)
+	}
+
+	job := database.StartPurgeJob(db, -time.Hour, time.Hour, func(err error) {
+		t.Error(err)
+	})
+
+	// The job purges once when it is started, and Stop waits for it to finish
+	job.Stop()
+
+	var deleted_items []MemoryTestItem
+	err = database.FindAllDeleted(db, "items", nil, &deleted_items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted_items) != 0 {
+		t.Errorf("Expected deleted items to be purged, got %v", deleted_items)
+	}
+
+	_, err = database.PurgeDeleted(db.Database, time.Now())
+
+	if err != database.ErrSoftDeleteDisabled {
+		t.Errorf("Expected ErrSoftDeleteDisabled purging a database without soft deletion, got %v", err)
+	}
+
+	CleanupMemoryDB(t, db)
+}
@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/common/datastore"
+)
+
+func TestParseSortParameters(t *testing.T) {
+	parameters := map[string][]string{
+		"sort":   {"points,-Location.Floor"},
+		"points": {"10"},
+	}
+
+	sort_fields, err := database.ParseSortParameters(parameters, TestStruct4{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_sort_fields := []database.SortField{
+		{Name: "points", Reversed: false},
+		{Name: "location.floor", Reversed: true},
+	}
+
+	if !reflect.DeepEqual(sort_fields, expected_sort_fields) {
+		t.Errorf("Wrong sort fields. Expected %v, got %v", expected_sort_fields, sort_fields)
+	}
+
+	if _, has_sort := parameters["sort"]; has_sort {
+		t.Errorf("Expected the sort parameter to be removed")
+	}
+
+	sort_fields, err = database.ParseSortParameters(parameters, TestStruct4{})
+
+	if err != nil || sort_fields != nil {
+		t.Errorf("Expected no sort fields when sort is not given, got %v, %v", sort_fields, err)
+	}
+
+	parameters = map[string][]string{
+		"sortby": {"-points"},
+	}
+
+	sort_fields, err = database.ParseSortParameters(parameters, TestStruct4{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_sort_fields = []database.SortField{
+		{Name: "points", Reversed: true},
+	}
+
+	if !reflect.DeepEqual(sort_fields, expected_sort_fields) {
+		t.Errorf("Wrong sort fields for sortby. Expected %v, got %v", expected_sort_fields, sort_fields)
+	}
+
+	if _, has_sort := parameters["sortby"]; has_sort {
+		t.Errorf("Expected the sortby parameter to be removed")
+	}
+
+	invalid_parameters := []map[string][]string{
+		{"sort": {"secret"}},
+		{"sort": {"points,"}},
+		{"sort": {"points", "name"}},
+		{"sort": {"points"}, "sortby": {"points"}},
+	}
+
+	for _, parameters := range invalid_parameters {
+		_, err := database.ParseSortParameters(parameters, TestStruct4{})
+
+		if _, ok := err.(database.FilterError); !ok {
+			t.Errorf("Expected a FilterError for %v, got %v", parameters, err)
+		}
+	}
+}
+
+func TestParseSortParametersDataStore(t *testing.T) {
+	var definition datastore.DataStoreDefinition
+	err := json.Unmarshal([]byte(filter_datastore_definition), &definition)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parameters := map[string][]string{
+		"sort": {"-isattending,programmingExperience.go"},
+	}
+
+	sort_fields, err := database.ParseSortParameters(parameters, datastore.NewDataStore(definition))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_sort_fields := []database.SortField{
+		{Name: "isAttending", Reversed: true},
+		{Name: "programmingExperience.go", Reversed: false},
+	}
+
+	if !reflect.DeepEqual(sort_fields, expected_sort_fields) {
+		t.Errorf("Wrong sort fields. Expected %v, got %v", expected_sort_fields, sort_fields)
+	}
+}
+
+func TestMemoryFindSortedByParameters(t *testing.T) {
+	db := SetupMemoryDB(t)
+	defer CleanupMemoryDB(t, db)
+
+	parameters := map[string][]string{
+		"sort": {"-points"},
+	}
+
+	sort_fields, err := database.ParseSortParameters(parameters, MemoryTestItem{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []MemoryTestItem
+	err = db.FindAllSorted("items", nil, sort_fields, &items)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+
+	expected_ids := []string{"b", "c", "a"}
+
+	if !reflect.DeepEqual(ids, expected_ids) {
+		t.Errorf("Wrong order. Expected %v, got %v", expected_ids, ids)
+	}
+}
+
+func TestMemoryFindPaginatedTieBreak(t *testing.T) {
+	db, err := database.InitDatabase("memory://", "test-paginated-tie-break")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.DropDatabase()
+
+	for _, id := range []string{"c", "a", "b"} {
+		err = db.Insert("items", map[string]interface{}{"_id": id, "id": id, "points": 10})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sorts := [][]database.SortField{
+		nil,
+		{{Name: "points"}},
+		{{Name: "points", Reversed: true}},
+	}
+
+	for _, sort_fields := range sorts {
+		ids := []string{}
+
+		for skip := 0; skip < 3; skip++ {
+			var items []MemoryTestItem
+			_, err = db.FindAllPaginated("items", nil, sort_fields, database.PaginationOptions{Skip: skip, Limit: 1}, &items)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, item := range items {
+				ids = append(ids, item.ID)
+			}
+		}
+
+		expected_ids := []string{"a", "b", "c"}
+
+		if !reflect.DeepEqual(ids, expected_ids) {
+			t.Errorf("Wrong pages for sort %v. Expected %v, got %v", sort_fields, expected_ids, ids)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package tests
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/common/datastore"
+)
+
+var versioned_json_definition = `
+{
+	"name": "registration",
+	"type": "object",
+	"validations": "",
+	"version": 2,
+	"migrations": [
+		{
+			"version": 1,
+			"renames": {
+				"name": "firstName"
+			}
+		},
+		{
+			"version": 2,
+			"removals": ["favoriteColor"],
+			"renames": {
+				"school.name": "school.university"
+			},
+			"defaults": {
+				"age": 18,
+				"school.year": "FRESHMAN"
+			}
+		}
+	],
+	"fields": [
+		{
+			"name": "id",
+			"type": "string",
+			"validations": "required",
+			"fields": []
+		},
+		{
+			"name": "firstName",
+			"type": "string",
+			"validations": "required",
+			"fields": []
+		},
+		{
+			"name": "age",
+			"type": "int",
+			"validations": "required",
+			"fields": []
+		},
+		{
+			"name": "school",
+			"type": "object",
+			"validations": "",
+			"fields": [
+				{
+					"name": "university",
+					"type": "string",
+					"validations": "required",
+					"fields": []
+				},
+				{
+					"name": "year",
+					"type": "enum",
+					"validations": "required",
+					"values": ["FRESHMAN", "SOPHOMORE"],
+					"fields": []
+				}
+			]
+		}
+	]
+}
+`
+
+func getVersionedDefinition(t *testing.T) datastore.DataStoreDefinition {
+	var definition datastore.DataStoreDefinition
+	err := json.Unmarshal([]byte(versioned_json_definition), &definition)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return definition
+}
+
+/*
+	Tests that data written with older versions of a definition is upgraded by each later migration in order
+*/
+func TestDataStoreUpgrade(t *testing.T) {
+	definition := getVersionedDefinition(t)
+
+	store := datastore.DataStore{
+		Data: map[string]interface{}{
+			"id":            "testid",
+			"name":          "John",
+			"favoriteColor": "blue",
+			"school": map[string]interface{}{
+				"name": "UIUC",
+			},
+		},
+	}
+
+	err := store.Upgrade(definition)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_data := map[string]interface{}{
+		"id":        "testid",
+		"firstName": "John",
+		"age":       int64(18),
+		"school": map[string]interface{}{
+			"university": "UIUC",
+			"year":       "FRESHMAN",
+		},
+		datastore.DefinitionVersionKey: 2,
+	}
+
+	if !reflect.DeepEqual(store.Data, expected_data) {
+		t.Errorf("Wrong data.\nExpected %v\ngot %v\n", expected_data, store.Data)
+	}
+
+	err = store.Validate()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Data written with version 1 has already been renamed, so only the second migration applies
+	store = datastore.DataStore{
+		Data: map[string]interface{}{
+			"id":                           "testid",
+			"name":                         "Not a first name",
+			"firstName":                    "John",
+			"age":                          int64(20),
+			datastore.DefinitionVersionKey: int64(1),
+		},
+	}
+
+	err = store.Upgrade(definition)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_data = map[string]interface{}{
+		"id":        "testid",
+		"name":      "Not a first name",
+		"firstName": "John",
+		"age":       int64(20),
+		"school": map[string]interface{}{
+			"year": "FRESHMAN",
+		},
+		datastore.DefinitionVersionKey: 2,
+	}
+
+	if !reflect.DeepEqual(store.Data, expected_data) {
+		t.Errorf("Wrong data.\nExpected %v\ngot %v\n", expected_data, store.Data)
+	}
+}
+
+/*
+	Tests that data decoded from a request is stored with the definition's version
+*/
+func TestDataStoreVersionStored(t *testing.T) {
+	definition := getVersionedDefinition(t)
+
+	store := datastore.NewDataStore(definition)
+	err := json.Unmarshal([]byte(`{"id": "testid", "firstName": "John"}`), &store)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if store.GetVersion() != 2 {
+		t.Errorf("Wrong version.\nExpected %v\ngot %v\n", 2, store.GetVersion())
+	}
+
+	if store.NeedsUpgrade(definition) {
+		t.Errorf("Expected data decoded with the current definition not to need an upgrade")
+	}
+}
+
+/*
+	Tests that the definition version is kept in the stored data but not in the encoded response
+*/
+func TestDataStoreVersionNotEncoded(t *testing.T) {
+	definition := getVersionedDefinition(t)
+
+	store := datastore.NewDataStore(definition)
+	err := json.Unmarshal([]byte(`{"id": "testid", "firstName": "John"}`), &store)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded_data, err := json.Marshal(&store)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw_data map[string]interface{}
+	err = json.Unmarshal(encoded_data, &raw_data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, has_version := raw_data[datastore.DefinitionVersionKey]; has_version {
+		t.Errorf("Expected the definition version not to be encoded, got %v", raw_data[datastore.DefinitionVersionKey])
+	}
+
+	if raw_data["firstName"] != "John" {
+		t.Errorf("Wrong first name.\nExpected %v\ngot %v\n", "John", raw_data["firstName"])
+	}
+
+	if store.GetVersion() != 2 {
+		t.Errorf("Wrong version.\nExpected %v\ngot %v\n", 2, store.GetVersion())
+	}
+}
+
+/*
+	Tests that definitions with migrations out of order or after the definition's version are rejected
+*/
+func TestDataStoreUpgradeInvalidMigrations(t *testing.T) {
+	definitions := []datastore.DataStoreDefinition{
+		{
+			Version:    1,
+			Migrations: []datastore.DataStoreMigration{{Version: 2}},
+		},
+		{
+			Version:    2,
+			Migrations: []datastore.DataStoreMigration{{Version: 2}, {Version: 1}},
+		},
+		{
+			Version:    1,
+			Migrations: []datastore.DataStoreMigration{{Version: 0}},
+		},
+	}
+
+	for _, definition := range definitions {
+		store := datastore.DataStore{Data: map[string]interface{}{}}
+
+		err := store.Upgrade(definition)
+
+		if err == nil {
+			t.Errorf("Expected upgrading with the migrations %v to fail", definition.Migrations)
+		}
+	}
+}
+
+/*
+	Tests that the records in a collection which were written with older versions of a definition are upgraded
+*/
+func TestUpgradeCollection(t *testing.T) {
+	db, err := database.InitDatabase("memory://", "test-versioning")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.DropDatabase()
+
+	definition := getVersionedDefinition(t)
+
+	records := []datastore.DataStore{
+		{Data: map[string]interface{}{"id": "old", "name": "John"}},
+		{Data: map[string]interface{}{"id": "current", "firstName": "Jane", "age": int64(20), datastore.DefinitionVersionKey: 2}},
+	}
+
+	for _, record := range records {
+		err = db.Insert("registrations", &record)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	upgraded_count, err := datastore.UpgradeCollection(db, "registrations", definition, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upgraded_count != 1 {
+		t.Errorf("Wrong dry run upgrade count.\nExpected %v\ngot %v\n", 1, upgraded_count)
+	}
+
+	upgraded_count, err = datastore.UpgradeCollection(db, "registrations", definition, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upgraded_count != 1 {
+		t.Errorf("Wrong upgrade count.\nExpected %v\ngot %v\n", 1, upgraded_count)
+	}
+
+	var upgraded_record datastore.DataStore
+	err = db.FindOne("registrations", database.QuerySelector{"id": "old"}, &upgraded_record)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upgraded_record.Data["firstName"] != "John" || upgraded_record.GetVersion() != 2 {
+		t.Errorf("Record was not upgraded.\nGot %v\n", upgraded_record.Data)
+	}
+
+	upgraded_count, err = datastore.UpgradeCollection(db, "registrations", definition, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upgraded_count != 0 {
+		t.Errorf("Expected no records to need an upgrade after upgrading, got %v", upgraded_count)
+	}
+}
+
+/*
+	Tests that upgrade projections include the fields read by the definition's migrations
+*/
+func TestGetUpgradeProjection(t *testing.T) {
+	definition := getVersionedDefinition(t)
+
+	projection := datastore.GetUpgradeProjection(definition, nil)
+
+	if projection != nil {
+		t.Errorf("Expected no projection when every field is included, got %v", projection)
+	}
+
+	projection = datastore.GetUpgradeProjection(definition, []string{"firstName", "school"})
+	sort.Strings(projection)
+
+	expected_projection := []string{datastore.DefinitionVersionKey, "firstName", "name", "school"}
+
+	if !reflect.DeepEqual(projection, expected_projection) {
+		t.Errorf("Wrong upgrade projection. Expected %v, got %v", expected_projection, projection)
+	}
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/HackIllinois/api/common/database"
+)
+
+/*
+	Returns the next event from the stream, failing the test if none arrives in time
+*/
+func nextChangeEvent(t *testing.T, stream *database.ChangeStream) database.ChangeEvent {
+	select {
+	case event, ok := <-stream.Events():
+		if !ok {
+			t.Fatalf("Stream stopped unexpectedly with error %v", stream.Err())
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for change event")
+	}
+
+	return database.ChangeEvent{}
+}
+
+/*
+	Tests that watching a collection yields inserts, updates, and deletes of items matching the filter
+*/
+func TestMemoryWatch(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	previous_interval := database.WatchPollInterval
+	database.WatchPollInterval = 10 * time.Millisecond
+	defer func() {
+		database.WatchPollInterval = previous_interval
+	}()
+
+	stream, err := db.Watch("items", database.QuerySelector{"points": database.QuerySelector{"$gte": 20}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer stream.Close()
+
+	err = db.Insert("items", MemoryTestItem{ID: "d", Points: 40, Tags: []string{}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := nextChangeEvent(t, stream)
+
+	var item MemoryTestItem
+	err = event.Decode(&item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Operation != database.ChangeInsert || item.ID != "d" {
+		t.Errorf("Expected insert of d, got %v of %v", event.Operation, item)
+	}
+
+	err = db.Update("items", database.QuerySelector{"id": "c"}, database.QuerySelector{"$set": database.QuerySelector{"points": 25}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event = nextChangeEvent(t, stream)
+	err = event.Decode(&item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Operation != database.ChangeUpdate || item.ID != "c" || item.Points != 25 {
+		t.Errorf("Expected update of c, got %v of %v", event.Operation, item)
+	}
+
+	// Changes to items which do not match the filter are not reported
+	err = db.Update("items", database.QuerySelector{"id": "a"}, database.QuerySelector{"$set": database.QuerySelector{"points": 15}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.RemoveOne("items", database.QuerySelector{"id": "b"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event = nextChangeEvent(t, stream)
+
+	if event.Operation != database.ChangeDelete || event.DocumentID == nil {
+		t.Errorf("Expected delete of b, got %v of %v", event.Operation, event.DocumentID)
+	}
+
+	err = event.Decode(&item)
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound decoding a deleted item, got %v", err)
+	}
+
+	stream.Close()
+
+	for range stream.Events() {
+	}
+
+	if stream.Err() != nil {
+		t.Errorf("Expected no error after closing the stream, got %v", stream.Err())
+	}
+
+	CleanupMemoryDB(t, db)
+}
+
+/*
+	Tests that a stream stops with the context's error once the database's context is done
+*/
+func TestMemoryWatchContext(t *testing.T) {
+	db := SetupMemoryDB(t)
+
+	previous_interval := database.WatchPollInterval
+	database.WatchPollInterval = 10 * time.Millisecond
+	defer func() {
+		database.WatchPollInterval = previous_interval
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stream, err := db.WithContext(ctx).Watch("items", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-stream.Events():
+		if ok {
+			t.Error("Expected no events from a cancelled stream")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the stream to stop")
+	}
+
+	if stream.Err() != database.ErrCanceled {
+		t.Errorf("Expected ErrCanceled, got %v", stream.Err())
+	}
+
+	_, err = db.WithContext(ctx).Watch("items", nil)
+
+	if err != database.ErrCanceled {
+		t.Errorf("Expected ErrCanceled watching with a cancelled context, got %v", err)
+	}
+
+	CleanupMemoryDB(t, db)
+}
@@ -21,3 +21,13 @@ func RemoveString(slice []string, str string) ([]string, error) {
 	}
 	return nil, errors.New("Value to remove not found")
 }
+
+func ExcludeStrings(slice []string, excluded []string) []string {
+	remaining := []string{}
+	for _, value := range slice {
+		if !ContainsString(excluded, value) {
+			remaining = append(remaining, value)
+		}
+	}
+	return remaining
+}
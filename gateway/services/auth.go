@@ -55,6 +55,12 @@ var AuthRoutes = arbor.RouteCollection{
 		"/auth/roles/add/",
 		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole}), middleware.IdentificationMiddleware).ThenFunc(AddUserRole).ServeHTTP,
 	},
+	arbor.Route{
+		"AddRoleToUsers",
+		"PUT",
+		"/auth/roles/add/bulk/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole}), middleware.IdentificationMiddleware).ThenFunc(AddRoleToUsers).ServeHTTP,
+	},
 	arbor.Route{
 		"RemoveUserRole",
 		"PUT",
@@ -93,6 +99,10 @@ func AddUserRole(w http.ResponseWriter, r *http.Request) {
 	arbor.PUT(w, config.AUTH_SERVICE+r.URL.String(), AuthFormat, "", r)
 }
 
+func AddRoleToUsers(w http.ResponseWriter, r *http.Request) {
+	arbor.PUT(w, config.AUTH_SERVICE+r.URL.String(), AuthFormat, "", r)
+}
+
 func RemoveUserRole(w http.ResponseWriter, r *http.Request) {
 	arbor.PUT(w, config.AUTH_SERVICE+r.URL.String(), AuthFormat, "", r)
 }
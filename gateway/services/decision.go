@@ -31,6 +31,12 @@ var DecisionRoutes = arbor.RouteCollection{
 		"/decision/filter/",
 		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole, models.StaffRole}), middleware.IdentificationMiddleware).ThenFunc(GetFilteredDecisions).ServeHTTP,
 	},
+	arbor.Route{
+		"UpdateDecisions",
+		"POST",
+		"/decision/bulk/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole, models.StaffRole}), middleware.IdentificationMiddleware).ThenFunc(UpdateDecisions).ServeHTTP,
+	},
 	arbor.Route{
 		"FinalizeDecision",
 		"POST",
@@ -57,6 +63,10 @@ func UpdateDecision(w http.ResponseWriter, r *http.Request) {
 	arbor.POST(w, config.DECISION_SERVICE+r.URL.String(), DecisionFormat, "", r)
 }
 
+func UpdateDecisions(w http.ResponseWriter, r *http.Request) {
+	arbor.POST(w, config.DECISION_SERVICE+r.URL.String(), DecisionFormat, "", r)
+}
+
 func GetFilteredDecisions(w http.ResponseWriter, r *http.Request) {
 	arbor.GET(w, config.DECISION_SERVICE+r.URL.String(), DecisionFormat, "", r)
 }
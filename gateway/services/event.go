@@ -55,6 +55,18 @@ var EventRoutes = arbor.RouteCollection{
 		"/event/filter/",
 		alice.New(middleware.IdentificationMiddleware).ThenFunc(GetFilteredEvents).ServeHTTP,
 	},
+	arbor.Route{
+		"GetDeletedEvents",
+		"GET",
+		"/event/deleted/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole}), middleware.IdentificationMiddleware).ThenFunc(GetDeletedEvents).ServeHTTP,
+	},
+	arbor.Route{
+		"RestoreEvent",
+		"POST",
+		"/event/deleted/{id}/restore/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole}), middleware.IdentificationMiddleware).ThenFunc(RestoreEvent).ServeHTTP,
+	},
 	arbor.Route{
 		"GetEvent",
 		"GET",
@@ -117,6 +129,14 @@ func DeleteEvent(w http.ResponseWriter, r *http.Request) {
 	arbor.DELETE(w, config.EVENT_SERVICE+r.URL.String(), EventFormat, "", r)
 }
 
+func GetDeletedEvents(w http.ResponseWriter, r *http.Request) {
+	arbor.GET(w, config.EVENT_SERVICE+r.URL.String(), EventFormat, "", r)
+}
+
+func RestoreEvent(w http.ResponseWriter, r *http.Request) {
+	arbor.POST(w, config.EVENT_SERVICE+r.URL.String(), EventFormat, "", r)
+}
+
 func CreateEvent(w http.ResponseWriter, r *http.Request) {
 	arbor.POST(w, config.EVENT_SERVICE+r.URL.String(), EventFormat, "", r)
 }
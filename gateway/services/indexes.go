@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/HackIllinois/api/common/apirequest"
+	"github.com/HackIllinois/api/gateway/middleware"
+	"github.com/HackIllinois/api/gateway/models"
+	"github.com/arbor-dev/arbor"
+	"github.com/justinas/alice"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var IndexesRoutes = arbor.RouteCollection{
+	arbor.Route{
+		"Index Status",
+		"GET",
+		"/indexes/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole}), middleware.IdentificationMiddleware).ThenFunc(GetIndexStatus).ServeHTTP,
+	},
+}
+
+/*
+	Retrieves the status of the indexes on each collection from every service with a database
+	The services are queried in parallel, and services which do not respond within apirequest.Timeout are listed as failed
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(apirequest.Timeout)*time.Second)
+	defer cancel()
+
+	service_indexes := make(map[string]interface{})
+	failed_services := []string{}
+
+	var mutex sync.Mutex
+	var wait_group sync.WaitGroup
+
+	for service_name, service_location := range ServiceLocations {
+		wait_group.Add(1)
+
+		go func(service_name string, service_location string) {
+			defer wait_group.Done()
+
+			var index_status map[string]interface{}
+			status, err := apirequest.GetWithContext(ctx, fmt.Sprintf("%s/%s/internal/indexes/", service_location, service_name), &index_status)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				failed_services = append(failed_services, service_name)
+				return
+			}
+
+			// Services without a database do not report index status
+			if status == http.StatusNotFound {
+				return
+			}
+
+			if status == http.StatusOK {
+				service_indexes[service_name] = index_status
+			} else {
+				failed_services = append(failed_services, service_name)
+			}
+		}(service_name, service_location)
+	}
+
+	wait_group.Wait()
+
+	sort.Strings(failed_services)
+
+	index_info := map[string]interface{}{
+		"services": service_indexes,
+		"failed":   failed_services,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(index_info)
+}
@@ -37,6 +37,18 @@ var NotificationsRoutes = arbor.RouteCollection{
 		"/notifications/topic/public/",
 		alice.New(middleware.IdentificationMiddleware).ThenFunc(GetAllPublicNotifications).ServeHTTP,
 	},
+	arbor.Route{
+		"GetDeletedTopics",
+		"GET",
+		"/notifications/topic/deleted/",
+		alice.New(middleware.IdentificationMiddleware, middleware.AuthMiddleware([]models.Role{models.AdminRole})).ThenFunc(GetDeletedTopics).ServeHTTP,
+	},
+	arbor.Route{
+		"RestoreTopic",
+		"POST",
+		"/notifications/topic/deleted/{id}/restore/",
+		alice.New(middleware.IdentificationMiddleware, middleware.AuthMiddleware([]models.Role{models.AdminRole})).ThenFunc(RestoreTopic).ServeHTTP,
+	},
 	arbor.Route{
 		"GetNotificationsForTopic",
 		"GET",
@@ -109,6 +121,14 @@ func DeleteTopic(w http.ResponseWriter, r *http.Request) {
 	arbor.DELETE(w, config.NOTIFICATIONS_SERVICE+r.URL.String(), NotificationsFormat, "", r)
 }
 
+func GetDeletedTopics(w http.ResponseWriter, r *http.Request) {
+	arbor.GET(w, config.NOTIFICATIONS_SERVICE+r.URL.String(), NotificationsFormat, "", r)
+}
+
+func RestoreTopic(w http.ResponseWriter, r *http.Request) {
+	arbor.POST(w, config.NOTIFICATIONS_SERVICE+r.URL.String(), NotificationsFormat, "", r)
+}
+
 func SubscribeToTopic(w http.ResponseWriter, r *http.Request) {
 	arbor.POST(w, config.NOTIFICATIONS_SERVICE+r.URL.String(), NotificationsFormat, "", r)
 }
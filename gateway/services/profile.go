@@ -91,6 +91,18 @@ var ProfileRoutes = arbor.RouteCollection{
 		"/profile/tier/threshold/",
 		http.HandlerFunc(GetTierThresholds).ServeHTTP,
 	},
+	arbor.Route{
+		"GetDeletedProfiles",
+		"GET",
+		"/profile/deleted/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole}), middleware.IdentificationMiddleware).ThenFunc(GetDeletedProfiles).ServeHTTP,
+	},
+	arbor.Route{
+		"RestoreProfile",
+		"POST",
+		"/profile/deleted/{id}/restore/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole}), middleware.IdentificationMiddleware).ThenFunc(RestoreProfile).ServeHTTP,
+	},
 	// This needs to be the last route in order to prevent endpoints like "search", "leaderboard" from accidentally being routed as the {id} variable.
 	arbor.Route{
 		"GetUserProfileById",
@@ -128,6 +140,14 @@ func DeleteProfile(w http.ResponseWriter, r *http.Request) {
 	arbor.DELETE(w, config.PROFILE_SERVICE+r.URL.String(), ProfileFormat, "", r)
 }
 
+func GetDeletedProfiles(w http.ResponseWriter, r *http.Request) {
+	arbor.GET(w, config.PROFILE_SERVICE+r.URL.String(), ProfileFormat, "", r)
+}
+
+func RestoreProfile(w http.ResponseWriter, r *http.Request) {
+	arbor.POST(w, config.PROFILE_SERVICE+r.URL.String(), ProfileFormat, "", r)
+}
+
 func GetProfileLeaderboard(w http.ResponseWriter, r *http.Request) {
 	arbor.GET(w, config.PROFILE_SERVICE+r.URL.String(), ProfileFormat, "", r)
 }
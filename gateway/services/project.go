@@ -37,6 +37,18 @@ var ProjectRoutes = arbor.RouteCollection{
 		"/project/filter/",
 		alice.New(middleware.IdentificationMiddleware).ThenFunc(GetFilteredProjects).ServeHTTP,
 	},
+	arbor.Route{
+		"GetDeletedProjects",
+		"GET",
+		"/project/deleted/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole}), middleware.IdentificationMiddleware).ThenFunc(GetDeletedProjects).ServeHTTP,
+	},
+	arbor.Route{
+		"RestoreProject",
+		"POST",
+		"/project/deleted/{id}/restore/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole}), middleware.IdentificationMiddleware).ThenFunc(RestoreProject).ServeHTTP,
+	},
 	arbor.Route{
 		"GetProject",
 		"GET",
@@ -81,6 +93,14 @@ func DeleteProject(w http.ResponseWriter, r *http.Request) {
 	arbor.DELETE(w, config.PROJECT_SERVICE+r.URL.String(), ProjectFormat, "", r)
 }
 
+func GetDeletedProjects(w http.ResponseWriter, r *http.Request) {
+	arbor.GET(w, config.PROJECT_SERVICE+r.URL.String(), ProjectFormat, "", r)
+}
+
+func RestoreProject(w http.ResponseWriter, r *http.Request) {
+	arbor.POST(w, config.PROJECT_SERVICE+r.URL.String(), ProjectFormat, "", r)
+}
+
 func CreateProject(w http.ResponseWriter, r *http.Request) {
 	arbor.POST(w, config.PROJECT_SERVICE+r.URL.String(), ProjectFormat, "", r)
 }
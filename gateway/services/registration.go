@@ -36,6 +36,12 @@ var RegistrationRoutes = arbor.RouteCollection{
 		"/registration/attendee/",
 		alice.New(middleware.AuthMiddleware([]models.Role{models.ApplicantRole}), middleware.IdentificationMiddleware).ThenFunc(UpdateRegistration).ServeHTTP,
 	},
+	arbor.Route{
+		"PatchCurrentUserRegistration",
+		"PATCH",
+		"/registration/attendee/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.ApplicantRole}), middleware.IdentificationMiddleware).ThenFunc(PatchRegistration).ServeHTTP,
+	},
 	arbor.Route{
 		"GetFilteredUserRegistrations",
 		"GET",
@@ -60,12 +66,30 @@ var RegistrationRoutes = arbor.RouteCollection{
 		"/registration/mentor/",
 		alice.New(middleware.AuthMiddleware([]models.Role{models.MentorRole}), middleware.IdentificationMiddleware).ThenFunc(UpdateRegistration).ServeHTTP,
 	},
+	arbor.Route{
+		"PatchCurrentMentorRegistration",
+		"PATCH",
+		"/registration/mentor/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.MentorRole}), middleware.IdentificationMiddleware).ThenFunc(PatchRegistration).ServeHTTP,
+	},
 	arbor.Route{
 		"GetFilteredMentorRegistrations",
 		"GET",
 		"/registration/mentor/list/",
 		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole, models.StaffRole}), middleware.IdentificationMiddleware).ThenFunc(GetRegistration).ServeHTTP,
 	},
+	arbor.Route{
+		"GetUserRegistrationSchema",
+		"GET",
+		"/registration/attendee/schema/",
+		alice.New(middleware.IdentificationMiddleware).ThenFunc(GetRegistration).ServeHTTP,
+	},
+	arbor.Route{
+		"GetMentorRegistrationSchema",
+		"GET",
+		"/registration/mentor/schema/",
+		alice.New(middleware.IdentificationMiddleware).ThenFunc(GetRegistration).ServeHTTP,
+	},
 	arbor.Route{
 		"GetUserRegistration",
 		"GET",
@@ -97,3 +121,7 @@ func CreateRegistration(w http.ResponseWriter, r *http.Request) {
 func UpdateRegistration(w http.ResponseWriter, r *http.Request) {
 	arbor.PUT(w, config.REGISTRATION_SERVICE+r.URL.String(), RegistrationFormat, "", r)
 }
+
+func PatchRegistration(w http.ResponseWriter, r *http.Request) {
+	arbor.PATCH(w, config.REGISTRATION_SERVICE+r.URL.String(), RegistrationFormat, "", r)
+}
@@ -30,6 +30,18 @@ var RsvpRoutes = arbor.RouteCollection{
 		"/rsvp/",
 		alice.New(middleware.AuthMiddleware([]models.Role{models.ApplicantRole}), middleware.IdentificationMiddleware).ThenFunc(UpdateCurrentRsvpInfo).ServeHTTP,
 	},
+	arbor.Route{
+		"PatchCurrentRsvpInfo",
+		"PATCH",
+		"/rsvp/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.ApplicantRole}), middleware.IdentificationMiddleware).ThenFunc(PatchCurrentRsvpInfo).ServeHTTP,
+	},
+	arbor.Route{
+		"GetRsvpSchema",
+		"GET",
+		"/rsvp/schema/",
+		alice.New(middleware.IdentificationMiddleware).ThenFunc(GetRsvpInfo).ServeHTTP,
+	},
 	arbor.Route{
 		"GetRsvpInfo",
 		"GET",
@@ -50,6 +62,10 @@ func UpdateCurrentRsvpInfo(w http.ResponseWriter, r *http.Request) {
 	arbor.PUT(w, config.RSVP_SERVICE+r.URL.String(), RsvpFormat, "", r)
 }
 
+func PatchCurrentRsvpInfo(w http.ResponseWriter, r *http.Request) {
+	arbor.PATCH(w, config.RSVP_SERVICE+r.URL.String(), RsvpFormat, "", r)
+}
+
 func GetRsvpInfo(w http.ResponseWriter, r *http.Request) {
 	arbor.GET(w, config.RSVP_SERVICE+r.URL.String(), RsvpFormat, "", r)
 }
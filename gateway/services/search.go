@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/HackIllinois/api/common/apirequest"
+	"github.com/HackIllinois/api/common/errors"
+	"github.com/HackIllinois/api/gateway/config"
+	"github.com/HackIllinois/api/gateway/middleware"
+	"github.com/HackIllinois/api/gateway/models"
+	"github.com/arbor-dev/arbor"
+	"github.com/justinas/alice"
+)
+
+var SearchRoutes = arbor.RouteCollection{
+	arbor.Route{
+		"Search",
+		"GET",
+		"/search/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole, models.AttendeeRole, models.ApplicantRole, models.StaffRole, models.MentorRole}), middleware.IdentificationMiddleware).ThenFunc(Search).ServeHTTP,
+	},
+}
+
+/*
+	Describes a searchable endpoint of a service, and the key its results are stored under in the responses of both
+*/
+type searchTarget struct {
+	service_name string
+	url          string
+	results_key  string
+}
+
+/*
+	Searches events, projects, and profiles for the text given by the q parameter
+	The search is run by each service in parallel, and the results from each are ordered by relevance
+	Services which fail to respond within apirequest.Timeout are listed as failed rather than failing the whole search
+*/
+func Search(w http.ResponseWriter, r *http.Request) {
+	parameters := r.URL.Query()
+
+	if parameters.Get("q") == "" {
+		errors.WriteError(w, r, errors.BadRequestError("Missing q parameter.", "Must provide text to search for."))
+		return
+	}
+
+	search_parameters := url.Values{}
+	search_parameters.Set("q", parameters.Get("q"))
+
+	if limit := parameters.Get("limit"); limit != "" {
+		search_parameters.Set("limit", limit)
+	}
+
+	targets := []searchTarget{
+		{"event", config.EVENT_SERVICE + "/event/filter/", "events"},
+		{"project", config.PROJECT_SERVICE + "/project/filter/", "projects"},
+		{"profile", config.PROFILE_SERVICE + "/profile/search/", "profiles"},
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(apirequest.Timeout)*time.Second)
+	defer cancel()
+
+	search_results := make(map[string]interface{})
+	failed_services := []string{}
+
+	var mutex sync.Mutex
+	var wait_group sync.WaitGroup
+
+	for _, target := range targets {
+		wait_group.Add(1)
+
+		go func(target searchTarget) {
+			defer wait_group.Done()
+
+			var response map[string]interface{}
+			status, err := apirequest.GetWithContext(ctx, target.url+"?"+search_parameters.Encode(), &response)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			results, has_results := response[target.results_key]
+
+			if err != nil || status != http.StatusOK || !has_results {
+				failed_services = append(failed_services, target.service_name)
+				search_results[target.results_key] = []interface{}{}
+				return
+			}
+
+			search_results[target.results_key] = results
+		}(target)
+	}
+
+	wait_group.Wait()
+
+	search_results["failed"] = failed_services
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(search_results)
+}
@@ -70,6 +70,8 @@ func RegisterAPIs() arbor.RouteCollection {
 	Routes = append(Routes, ProfileRoutes...)
 	Routes = append(Routes, HealthRoutes...)
 	Routes = append(Routes, ReloadRoutes...)
+	Routes = append(Routes, IndexesRoutes...)
+	Routes = append(Routes, SearchRoutes...)
 	return Routes
 }
 
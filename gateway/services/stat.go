@@ -25,6 +25,12 @@ var StatRoutes = arbor.RouteCollection{
 		"/stat/service/{name}/",
 		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole, models.StaffRole}), middleware.IdentificationMiddleware).ThenFunc(GetService).ServeHTTP,
 	},
+	arbor.Route{
+		"GetStatHistory",
+		"GET",
+		"/stat/history/{name}/",
+		alice.New(middleware.AuthMiddleware([]models.Role{models.AdminRole, models.StaffRole}), middleware.IdentificationMiddleware).ThenFunc(GetStatHistory).ServeHTTP,
+	},
 	arbor.Route{
 		"GetStat",
 		"GET",
@@ -51,6 +57,10 @@ func GetStat(w http.ResponseWriter, r *http.Request) {
 	arbor.GET(w, config.STAT_SERVICE+r.URL.String(), StatFormat, "", r)
 }
 
+func GetStatHistory(w http.ResponseWriter, r *http.Request) {
+	arbor.GET(w, config.STAT_SERVICE+r.URL.String(), StatFormat, "", r)
+}
+
 func GetAllStats(w http.ResponseWriter, r *http.Request) {
 	arbor.GET(w, config.STAT_SERVICE+r.URL.String(), StatFormat, "", r)
 }
@@ -13,6 +13,7 @@ require (
 	github.com/onsi/gomega v1.18.1 // indirect
 	github.com/prometheus/client_golang v1.11.0
 	github.com/thoas/stats v0.0.0-20181218120333-e97827ebd7ca
+	go.mongodb.org/mongo-driver v1.11.9
 	gopkg.in/mgo.v2 v2.0.0-20180705113604-9856a29383ce
 )
 
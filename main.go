@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/gateway"
 	"github.com/HackIllinois/api/services/auth"
 	"github.com/HackIllinois/api/services/checkin"
@@ -40,6 +43,17 @@ var SERVICE_ENTRYPOINTS = map[string](func()){
 	"profile":       profile.Entry,
 }
 
+/*
+	The migrations of the services which declare database migrations
+	Other services have no migrations to run
+*/
+var SERVICE_MIGRATIONS = map[string](func(database.MigrationOptions) ([]database.Migration, error)){
+	"registration": registration.Migrate,
+	"rsvp":         rsvp.Migrate,
+	"event":        event.Migrate,
+	"profile":      profile.Migrate,
+}
+
 func StartAll() {
 	gateway_entry, ok := SERVICE_ENTRYPOINTS["gateway"]
 
@@ -57,14 +71,89 @@ func StartAll() {
 	gateway_entry()
 }
 
+/*
+	Runs the database migrations of the given service, or every service with migrations if service is all
+*/
+func MigrateServices(service string, options database.MigrationOptions) {
+	services := []string{service}
+
+	if service == "all" {
+		services = []string{}
+		for service_name := range SERVICE_MIGRATIONS {
+			services = append(services, service_name)
+		}
+		sort.Strings(services)
+	}
+
+	action := "Applied"
+	if options.Rollback {
+		action = "Rolled back"
+	}
+	if options.DryRun {
+		action = "Would have " + strings.ToLower(action)
+	}
+
+	for _, service_name := range services {
+		migrate, ok := SERVICE_MIGRATIONS[service_name]
+
+		if !ok {
+			if _, is_service := SERVICE_ENTRYPOINTS[service_name]; is_service {
+				fmt.Printf("No migrations to run for %s\n", service_name)
+				continue
+			}
+
+			fmt.Fprintf(os.Stderr, "Could not migrate service '%s'\n", service_name)
+			os.Exit(1)
+		}
+
+		migrations, err := migrate(options)
+
+		for _, migration := range migrations {
+			fmt.Printf("%s %s migration %d: %s\n", action, service_name, migration.Version, migration.Description)
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to migrate service '%s': %v\n", service_name, err)
+			os.Exit(1)
+		}
+
+		if len(migrations) == 0 {
+			fmt.Printf("No migrations to run for %s\n", service_name)
+		}
+	}
+}
+
 func main() {
 	rand.Seed(time.Now().UTC().UnixNano())
 
 	var service string
 	flag.StringVar(&service, "service", "", "The service to start")
 
+	var migrate bool
+	flag.BoolVar(&migrate, "migrate", false, "Run the service's pending database migrations instead of starting it")
+
+	var dry_run bool
+	flag.BoolVar(&dry_run, "dry-run", false, "List the migrations which would be run without running them")
+
+	var rollback_version int
+	flag.IntVar(&rollback_version, "rollback", -1, "Roll back the service's migrations after the given version, 0 rolls back every migration")
+
 	flag.Parse()
 
+	if !migrate && (dry_run || rollback_version != -1) {
+		fmt.Fprintf(os.Stderr, "The -dry-run and -rollback flags can only be used with -migrate\n")
+		os.Exit(1)
+	}
+
+	if migrate {
+		MigrateServices(service, database.MigrationOptions{
+			DryRun:        dry_run,
+			Rollback:      rollback_version >= 0,
+			TargetVersion: rollback_version,
+		})
+		os.Exit(0)
+	}
+
 	if service == "all" {
 		StartAll()
 		os.Exit(1)
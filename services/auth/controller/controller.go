@@ -6,6 +6,7 @@ import (
 
 	"github.com/HackIllinois/api/common/errors"
 	"github.com/HackIllinois/api/common/metrics"
+	"github.com/HackIllinois/api/common/utils"
 	"github.com/HackIllinois/api/services/auth/config"
 	"github.com/HackIllinois/api/services/auth/models"
 	"github.com/HackIllinois/api/services/auth/service"
@@ -25,9 +26,11 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/code/{provider}/", Login, "POST", router)
 	metrics.RegisterHandler("/roles/{id}/", GetRoles, "GET", router)
 	metrics.RegisterHandler("/roles/add/", AddRole, "PUT", router)
+	metrics.RegisterHandler("/roles/add/bulk/", AddRoleToUsers, "PUT", router)
 	metrics.RegisterHandler("/roles/remove/", RemoveRole, "PUT", router)
 	metrics.RegisterHandler("/token/refresh/", RefreshToken, "GET", router)
 	metrics.RegisterHandler("/internal/stats/", GetStats, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -96,7 +99,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	roles, err := service.GetUserRoles(user_info.ID, true)
+	roles, err := service.GetUserRoles(r.Context(), user_info.ID, true)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.AuthorizationError(err.Error(), "Could not fetch user's API roles."))
@@ -104,14 +107,14 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if oauth_provider.IsVerifiedUser() {
-		err = service.AddAutomaticRoleGrants(user_info.ID, user_info.Email)
+		err = service.AddAutomaticRoleGrants(r.Context(), user_info.ID, user_info.Email)
 
 		if err != nil {
 			errors.WriteError(w, r, errors.AuthorizationError(err.Error(), "Could not automatically grant roles to user (based on verified email domain)."))
 			return
 		}
 
-		roles, err = service.GetUserRoles(user_info.ID, false)
+		roles, err = service.GetUserRoles(r.Context(), user_info.ID, false)
 
 		if err != nil {
 			errors.WriteError(w, r, errors.AuthorizationError(err.Error(), "Could not determine user roles, after automatic role grants."))
@@ -151,7 +154,7 @@ func GetRoles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	roles, err := service.GetUserRoles(id, false)
+	roles, err := service.GetUserRoles(r.Context(), id, false)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.AuthorizationError(err.Error(), "Could not get user's roles."))
@@ -177,7 +180,7 @@ func GetCurrentUserRoles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	roles, err := service.GetUserRoles(id, false)
+	roles, err := service.GetUserRoles(r.Context(), id, false)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.AuthorizationError(err.Error(), "Could not get user's roles."))
@@ -204,14 +207,14 @@ func AddRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := service.AddUserRole(role_modification.ID, role_modification.Role)
+	err := service.AddUserRole(r.Context(), role_modification.ID, role_modification.Role)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not add user role."))
 		return
 	}
 
-	roles, err := service.GetUserRoles(role_modification.ID, false)
+	roles, err := service.GetUserRoles(r.Context(), role_modification.ID, false)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not get user's roles."))
@@ -226,6 +229,40 @@ func AddRole(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(updated_roles)
 }
 
+/*
+	Adds a role to each of the users with the given ids.
+	Users which do not exist are reported, without preventing the other users from being given the role.
+*/
+func AddRoleToUsers(w http.ResponseWriter, r *http.Request) {
+	var role_modification models.BulkRoleModification
+	err := json.NewDecoder(r.Body).Decode(&role_modification)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.MalformedRequestError(err.Error(), "Could not decode role modification."))
+		return
+	}
+
+	if len(role_modification.IDs) == 0 || role_modification.Role == "" {
+		errors.WriteError(w, r, errors.MalformedRequestError("Must provide ids and role parameters in request.", "Must provide ids and role parameters in request."))
+		return
+	}
+
+	missing_ids, err := service.AddUsersRole(r.Context(), role_modification.IDs, role_modification.Role)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not add user roles."))
+		return
+	}
+
+	results := models.BulkRoleModificationResults{
+		Role:    role_modification.Role,
+		Updated: utils.ExcludeStrings(role_modification.IDs, missing_ids),
+		Missing: missing_ids,
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
+
 /*
 	Removes a role for the user with the given id.
 */
@@ -238,14 +275,14 @@ func RemoveRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := service.RemoveUserRole(role_modification.ID, role_modification.Role)
+	err := service.RemoveUserRole(r.Context(), role_modification.ID, role_modification.Role)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not remove user's user role."))
 		return
 	}
 
-	roles, err := service.GetUserRoles(role_modification.ID, false)
+	roles, err := service.GetUserRoles(r.Context(), role_modification.ID, false)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not fetch user's roles."))
@@ -278,7 +315,7 @@ func RefreshToken(w http.ResponseWriter, r *http.Request) {
 
 	// Get the roles from the given user ID
 
-	roles, err := service.GetUserRoles(id, false)
+	roles, err := service.GetUserRoles(r.Context(), id, false)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not fetch user roles."))
@@ -320,7 +357,7 @@ func GetRolesLists(w http.ResponseWriter, r *http.Request) {
 func GetUserListByRole(w http.ResponseWriter, r *http.Request) {
 	role := mux.Vars(r)["role"]
 
-	userids, err := service.GetUsersByRole(role)
+	userids, err := service.GetUsersByRole(r.Context(), role)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not retrieve list of users with requested role."))
@@ -338,7 +375,7 @@ func GetUserListByRole(w http.ResponseWriter, r *http.Request) {
 	Endpoint to get role stats
 */
 func GetStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := service.GetStats()
+	stats, err := service.GetStats(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not fetch registration service statistics."))
@@ -347,3 +384,17 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(stats)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch auth service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
@@ -0,0 +1,12 @@
+package models
+
+type BulkRoleModification struct {
+	IDs  []string `json:"ids"`
+	Role string   `json:"role"`
+}
+
+type BulkRoleModificationResults struct {
+	Role    string   `json:"role"`
+	Updated []string `json:"updated"`
+	Missing []string `json:"missing"`
+}
@@ -1,17 +1,28 @@
 package service
 
 import (
+	"context"
 	"errors"
-	"github.com/HackIllinois/api/common/utils"
 	"strings"
 
 	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/common/utils"
 	"github.com/HackIllinois/api/services/auth/config"
 	"github.com/HackIllinois/api/services/auth/models"
 )
 
 var db database.Database
 
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"roles": {
+		{Key: []string{"id"}, Unique: true},
+		{Key: []string{"roles"}},
+	},
+}
+
 func Initialize() error {
 	if db != nil {
 		db.Close()
@@ -25,6 +36,12 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -33,22 +50,22 @@ func Initialize() error {
 	If the user has no roles and create_user is true they will be assigned the role User
 	This generally occurs the first time the user logs into the service
 */
-func GetUserRoles(id string, create_user bool) ([]string, error) {
+func GetUserRoles(ctx context.Context, id string, create_user bool) ([]string, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var roles models.UserRoles
-	err := db.FindOne("roles", query, &roles)
+	err := db.WithContext(ctx).FindOne("roles", query, &roles)
 
 	if err != nil {
 		if err == database.ErrNotFound && create_user {
-			db.Insert("roles", &models.UserRoles{
+			db.WithContext(ctx).Insert("roles", &models.UserRoles{
 				ID:    id,
 				Roles: []string{"User"},
 			})
 
-			err := db.FindOne("roles", query, &roles)
+			err := db.WithContext(ctx).FindOne("roles", query, &roles)
 
 			if err != nil {
 				return nil, err
@@ -64,61 +81,103 @@ func GetUserRoles(id string, create_user bool) ([]string, error) {
 /*
 	Adds a role to the user with the specified id
 */
-func AddUserRole(id string, role string) error {
+func AddUserRole(ctx context.Context, id string, role string) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	roles, err := GetUserRoles(id, false)
+	modifier := database.QuerySelector{
+		"$addToSet": database.QuerySelector{
+			"roles": role,
+		},
+	}
+
+	err := db.WithContext(ctx).Update("roles", selector, &modifier)
+
+	return err
+}
+
+/*
+	Adds a role to each of the users with the specified ids in a single batch
+	Returns the ids of the users which do not exist, and so were not given the role
+*/
+func AddUsersRole(ctx context.Context, ids []string, role string) ([]string, error) {
+	query := database.QuerySelector{
+		"id": database.QuerySelector{
+			"$in": ids,
+		},
+	}
+
+	existing_ids := []string{}
+	err := db.WithContext(ctx).Distinct("roles", "id", query, &existing_ids)
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if len(existing_ids) == 0 {
+		return ids, nil
 	}
 
-	if !utils.ContainsString(roles, role) {
-		roles = append(roles, role)
+	modifier := database.QuerySelector{
+		"$addToSet": database.QuerySelector{
+			"roles": role,
+		},
 	}
 
-	err = db.Update("roles", selector, &models.UserRoles{
-		ID:    id,
-		Roles: roles,
-	})
+	operations := make([]database.BulkOperation, len(existing_ids))
+	for i, id := range existing_ids {
+		operations[i] = database.BulkOperation{
+			Type:     database.BulkUpdate,
+			Selector: database.QuerySelector{"id": id},
+			Item:     modifier,
+		}
+	}
 
-	return err
+	_, err = db.WithContext(ctx).BulkWrite("roles", operations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.ExcludeStrings(ids, existing_ids), nil
 }
 
 /*
 	Removes a role from the user with the specified id
 */
-func RemoveUserRole(id string, role string) error {
+func RemoveUserRole(ctx context.Context, id string, role string) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	roles, err := GetUserRoles(id, false)
+	condition := database.QuerySelector{
+		"roles": role,
+	}
 
-	if err != nil {
-		return err
+	modifier := database.QuerySelector{
+		"$pull": database.QuerySelector{
+			"roles": role,
+		},
 	}
 
-	roles, err = utils.RemoveString(roles, role)
+	was_removed, err := db.WithContext(ctx).UpdateIfMatches("roles", selector, condition, &modifier)
 
 	if err != nil {
-		return errors.New("User does not have specified role")
+		return err
 	}
 
-	err = db.Update("roles", selector, &models.UserRoles{
-		ID:    id,
-		Roles: roles,
-	})
+	if !was_removed {
+		return errors.New("User does not have specified role")
+	}
 
-	return err
+	return nil
 }
 
 /*
 	Automatically grant staff and admin roles based on user's verified email
 */
-func AddAutomaticRoleGrants(id string, email string) error {
+func AddAutomaticRoleGrants(ctx context.Context, id string, email string) error {
 	email_components := strings.Split(email, "@")
 
 	if len(email_components) < 2 {
@@ -128,7 +187,7 @@ func AddAutomaticRoleGrants(id string, email string) error {
 	domain := email_components[1]
 
 	if domain == config.STAFF_DOMAIN {
-		err := AddUserRole(id, models.StaffRole)
+		err := AddUserRole(ctx, id, models.StaffRole)
 
 		if err != nil {
 			return err
@@ -136,7 +195,7 @@ func AddAutomaticRoleGrants(id string, email string) error {
 	}
 
 	if email == config.SYSTEM_ADMIN_EMAIL {
-		err := AddUserRole(id, models.AdminRole)
+		err := AddUserRole(ctx, id, models.AdminRole)
 
 		if err != nil {
 			return err
@@ -156,7 +215,7 @@ func GetValidRoles() []models.Role {
 /*
 	Returns a list of user ids with a given role
 */
-func GetUsersByRole(role models.Role) ([]string, error) {
+func GetUsersByRole(ctx context.Context, role models.Role) ([]string, error) {
 	query := database.QuerySelector{
 		"roles": database.QuerySelector{
 			"$elemMatch": database.QuerySelector{
@@ -165,29 +224,30 @@ func GetUsersByRole(role models.Role) ([]string, error) {
 		},
 	}
 
-	var users []models.UserRoles
-	err := db.FindAll("roles", query, &users)
+	userids := []string{}
+	err := db.WithContext(ctx).Distinct("roles", "id", query, &userids)
 
 	if err != nil {
 		return nil, err
 	}
 
-	userids := make([]string, len(users))
-
-	for i, user := range users {
-		userids[i] = user.ID
-	}
-
 	return userids, nil
 }
 
 /*
 	Returns role stats
 */
-func GetStats() (map[string]interface{}, error) {
-	stats, err := db.GetStats("roles", []string{"roles"})
+func GetStats(ctx context.Context) (map[string]interface{}, error) {
+	stats, err := db.WithContext(ctx).GetStats("roles", []string{"roles"})
 	if err != nil {
 		return nil, err
 	}
 	return stats, nil
 }
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/services/auth/config"
@@ -73,7 +74,7 @@ func TestGetRolesService(t *testing.T) {
 	SetupTestDB(t)
 
 	expected_roles := []string{"User"}
-	roles, err := service.GetUserRoles("testid", false)
+	roles, err := service.GetUserRoles(context.Background(), "testid", false)
 
 	if err != nil {
 		t.Fatal(err)
@@ -83,7 +84,7 @@ func TestGetRolesService(t *testing.T) {
 		t.Errorf("Wrong user roles. Expected %v, got %v", expected_roles, roles)
 	}
 
-	roles, err = service.GetUserRoles("testid2", true)
+	roles, err = service.GetUserRoles(context.Background(), "testid2", true)
 
 	if err != nil {
 		t.Fatal(err)
@@ -103,13 +104,13 @@ func TestAddRoleService(t *testing.T) {
 	SetupTestDB(t)
 
 	expected_roles := []string{"User", "Admin"}
-	err := service.AddUserRole("testid", "Admin")
+	err := service.AddUserRole(context.Background(), "testid", "Admin")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	roles, err := service.GetUserRoles("testid", false)
+	roles, err := service.GetUserRoles(context.Background(), "testid", false)
 
 	if err != nil {
 		t.Fatal(err)
@@ -120,13 +121,13 @@ func TestAddRoleService(t *testing.T) {
 	}
 
 	// Test adding duplicate role
-	err = service.AddUserRole("testid", "Admin")
+	err = service.AddUserRole(context.Background(), "testid", "Admin")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	roles, err = service.GetUserRoles("testid", false)
+	roles, err = service.GetUserRoles(context.Background(), "testid", false)
 
 	if err != nil {
 		t.Fatal(err)
@@ -139,6 +140,57 @@ func TestAddRoleService(t *testing.T) {
 	CleanupTestDB(t)
 }
 
+/*
+	Service level test for adding a role to many users in the DB
+	Users which do not exist must be reported, and not be created
+*/
+func TestAddUsersRoleService(t *testing.T) {
+	SetupTestDB(t)
+
+	err := db.Insert("roles", &models.UserRoles{
+		ID:    "testid2",
+		Roles: []string{"User", "Staff"},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing_ids, err := service.AddUsersRole(context.Background(), []string{"testid", "testid2", "unknownid"}, "Staff")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_missing_ids := []string{"unknownid"}
+
+	if !reflect.DeepEqual(missing_ids, expected_missing_ids) {
+		t.Errorf("Wrong missing ids. Expected %v, got %v", expected_missing_ids, missing_ids)
+	}
+
+	for _, id := range []string{"testid", "testid2"} {
+		roles, err := service.GetUserRoles(context.Background(), id, false)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected_roles := []string{"User", "Staff"}
+
+		if !reflect.DeepEqual(roles, expected_roles) {
+			t.Errorf("Wrong user roles for %v. Expected %v, got %v", id, expected_roles, roles)
+		}
+	}
+
+	_, err = service.GetUserRoles(context.Background(), "unknownid", false)
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected the unknown user not to be created, got %v", err)
+	}
+
+	CleanupTestDB(t)
+}
+
 /*
 	Service level test for removing a role from a user in the DB
 */
@@ -146,13 +198,13 @@ func TestRemoveRoleService(t *testing.T) {
 	SetupTestDB(t)
 
 	expected_roles := []string{}
-	err := service.RemoveUserRole("testid", "User")
+	err := service.RemoveUserRole(context.Background(), "testid", "User")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	roles, err := service.GetUserRoles("testid", false)
+	roles, err := service.GetUserRoles(context.Background(), "testid", false)
 
 	if err != nil {
 		t.Fatal(err)
@@ -163,7 +215,7 @@ func TestRemoveRoleService(t *testing.T) {
 	}
 
 	// Ensure removing a user's role fails if they do not have that role
-	err = service.RemoveUserRole("testid", "User")
+	err = service.RemoveUserRole(context.Background(), "testid", "User")
 
 	if err == nil {
 		t.Errorf("Able to remove role \"User\" from a user that does not have the \"User\" role")
@@ -184,7 +236,7 @@ func TestGetUsersByRoleService(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	userids, err := service.GetUsersByRole("Staff")
+	userids, err := service.GetUsersByRole(context.Background(), "Staff")
 
 	if err != nil {
 		t.Fatal(err)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/common/errors"
 	"github.com/HackIllinois/api/common/metrics"
 	"github.com/HackIllinois/api/services/checkin/models"
@@ -23,6 +24,7 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/list/", GetAllCheckedInUsers, "GET", router)
 	metrics.RegisterHandler("/{id}/", GetUserCheckin, "GET", router)
 	metrics.RegisterHandler("/internal/stats/", GetStats, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -31,7 +33,7 @@ func SetupController(route *mux.Route) {
 func GetUserCheckin(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	user_checkin, err := service.GetUserCheckin(id)
+	user_checkin, err := service.GetUserCheckin(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get specified user's check-in details."))
@@ -47,7 +49,7 @@ func GetUserCheckin(w http.ResponseWriter, r *http.Request) {
 func GetCurrentUserCheckin(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	user_checkin, err := service.GetUserCheckin(id)
+	user_checkin, err := service.GetUserCheckin(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get current user's check-in details."))
@@ -100,7 +102,7 @@ func CreateUserCheckin(w http.ResponseWriter, r *http.Request) {
 		user_checkin.RsvpData = rsvp_data
 	}
 
-	err = service.CreateUserCheckin(user_checkin.ID, user_checkin)
+	err = service.CreateUserCheckin(r.Context(), user_checkin.ID, user_checkin)
 
 	if err != nil {
 		if err.Error() == "Checkin already exists" {
@@ -111,7 +113,7 @@ func CreateUserCheckin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updated_checkin, err := service.GetUserCheckin(user_checkin.ID)
+	updated_checkin, err := service.GetUserCheckin(r.Context(), user_checkin.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get recently created check-in information."))
@@ -146,14 +148,14 @@ func UpdateUserCheckin(w http.ResponseWriter, r *http.Request) {
 
 	user_checkin.RsvpData = rsvp_data
 
-	err = service.UpdateUserCheckin(user_checkin.ID, user_checkin)
+	err = service.UpdateUserCheckin(r.Context(), user_checkin.ID, user_checkin)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not update user check-in information."))
 		return
 	}
 
-	updated_checkin, err := service.GetUserCheckin(user_checkin.ID)
+	updated_checkin, err := service.GetUserCheckin(r.Context(), user_checkin.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch updated check-in information."))
@@ -176,9 +178,15 @@ func UpdateUserCheckin(w http.ResponseWriter, r *http.Request) {
 	Endpoint to get all checked in user IDs
 */
 func GetAllCheckedInUsers(w http.ResponseWriter, r *http.Request) {
-	checked_in_users, err := service.GetAllCheckedInUsers()
+	parameters := r.URL.Query()
+
+	checked_in_users, err := service.GetAllCheckedInUsers(r.Context(), parameters)
 
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Invalid sort parameters."))
+			return
+		}
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get all checked-in users."))
 		return
 	}
@@ -190,7 +198,7 @@ func GetAllCheckedInUsers(w http.ResponseWriter, r *http.Request) {
 	Endpoint to get checkin stats
 */
 func GetStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := service.GetStats()
+	stats, err := service.GetStats(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not get check-in service statistics."))
@@ -199,3 +207,17 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(stats)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch checkin service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
 type CheckinList struct {
-	CheckedInUsers []string `json:"checkedInUsers"`
+	CheckedInUsers []string           `json:"checkedInUsers"`
+	Pagination     *database.PageInfo `json:"pagination,omitempty"`
 }
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 
 	"github.com/HackIllinois/api/common/database"
@@ -11,6 +12,15 @@ import (
 
 var db database.Database
 
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"checkins": {
+		{Key: []string{"id"}, Unique: true},
+	},
+}
+
 func Initialize() error {
 	if db != nil {
 		db.Close()
@@ -24,19 +34,25 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 /*
 	Returns the checkin associated with the given user id
 */
-func GetUserCheckin(id string) (*models.UserCheckin, error) {
+func GetUserCheckin(ctx context.Context, id string) (*models.UserCheckin, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var user_checkin models.UserCheckin
-	err := db.FindOne("checkins", query, &user_checkin)
+	err := db.WithContext(ctx).FindOne("checkins", query, &user_checkin)
 
 	if err != nil {
 		return nil, err
@@ -48,8 +64,8 @@ func GetUserCheckin(id string) (*models.UserCheckin, error) {
 /*
 	Create the checkin associated with the given user id
 */
-func CreateUserCheckin(id string, user_checkin models.UserCheckin) error {
-	_, err := GetUserCheckin(id)
+func CreateUserCheckin(ctx context.Context, id string, user_checkin models.UserCheckin) error {
+	_, err := GetUserCheckin(ctx, id)
 
 	if err != database.ErrNotFound {
 		if err != nil {
@@ -58,7 +74,7 @@ func CreateUserCheckin(id string, user_checkin models.UserCheckin) error {
 		return errors.New("Checkin already exists")
 	}
 
-	err = db.Insert("checkins", &user_checkin)
+	err = db.WithContext(ctx).Insert("checkins", &user_checkin)
 
 	return err
 }
@@ -66,12 +82,12 @@ func CreateUserCheckin(id string, user_checkin models.UserCheckin) error {
 /*
 	Update the checkin associated with the given user id
 */
-func UpdateUserCheckin(id string, user_checkin models.UserCheckin) error {
+func UpdateUserCheckin(ctx context.Context, id string, user_checkin models.UserCheckin) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	err := db.Update("checkins", selector, &user_checkin)
+	err := db.WithContext(ctx).Update("checkins", selector, &user_checkin)
 
 	return err
 }
@@ -114,15 +130,27 @@ func CanUserCheckin(id string, user_has_override bool) (bool, error) {
 }
 
 /*
-	Returns a list of all checked in user IDs
+	Returns a list of all checked in user IDs, paginated and sorted by the given parameters
 */
-func GetAllCheckedInUsers() (*models.CheckinList, error) {
+func GetAllCheckedInUsers(ctx context.Context, parameters map[string][]string) (*models.CheckinList, error) {
+	pagination, err := database.ParsePaginationParameters(parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort_fields, err := database.ParseSortParameters(parameters, models.UserCheckin{})
+
+	if err != nil {
+		return nil, err
+	}
+
 	query := database.QuerySelector{
 		"hascheckedin": true,
 	}
 
 	var check_ins []models.UserCheckin
-	err := db.FindAll("checkins", query, &check_ins)
+	pagination_results, err := db.WithContext(ctx).FindAllProjected("checkins", query, []string{"id"}, sort_fields, *pagination, &check_ins)
 
 	if err != nil {
 		return nil, err
@@ -133,12 +161,21 @@ func GetAllCheckedInUsers() (*models.CheckinList, error) {
 		checkin_list.CheckedInUsers = append(checkin_list.CheckedInUsers, check_in.ID)
 	}
 
+	checkin_list.Pagination = database.GetPageInfo(*pagination, pagination_results)
+
 	return &checkin_list, nil
 }
 
 /*
 	Returns all checkin stats
 */
-func GetStats() (map[string]interface{}, error) {
-	return db.GetStats("checkins", []string{"override", "hascheckedin", "haspickedupswag"})
+func GetStats(ctx context.Context) (map[string]interface{}, error) {
+	return db.WithContext(ctx).GetStats("checkins", []string{"override", "hascheckedin", "haspickedupswag"})
+}
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
 }
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/services/checkin/config"
@@ -76,7 +77,7 @@ func CleanupTestDB(t *testing.T) {
 func TestGetUserCheckinService(t *testing.T) {
 	SetupTestDB(t)
 
-	checkin, err := service.GetUserCheckin("testid")
+	checkin, err := service.GetUserCheckin(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -109,13 +110,13 @@ func TestCreateUserCheckinService(t *testing.T) {
 		RsvpData:        map[string]interface{}{},
 	}
 
-	err := service.CreateUserCheckin("testid2", new_checkin)
+	err := service.CreateUserCheckin(context.Background(), "testid2", new_checkin)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	checkin, err := service.GetUserCheckin("testid2")
+	checkin, err := service.GetUserCheckin(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -148,13 +149,13 @@ func TestUpdateUserCheckinService(t *testing.T) {
 		RsvpData:        map[string]interface{}{},
 	}
 
-	err := service.UpdateUserCheckin("testid", checkin)
+	err := service.UpdateUserCheckin(context.Background(), "testid", checkin)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	updated_checkin, err := service.GetUserCheckin("testid")
+	updated_checkin, err := service.GetUserCheckin(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -187,7 +188,7 @@ func TestGetAllCheckedInUsersService(t *testing.T) {
 		RsvpData:        map[string]interface{}{},
 	}
 
-	err := service.CreateUserCheckin("testid2", new_checkin)
+	err := service.CreateUserCheckin(context.Background(), "testid2", new_checkin)
 
 	if err != nil {
 		t.Fatal(err)
@@ -200,13 +201,13 @@ func TestGetAllCheckedInUsersService(t *testing.T) {
 		RsvpData:        map[string]interface{}{},
 	}
 
-	err = service.CreateUserCheckin("testid3", new_checkin)
+	err = service.CreateUserCheckin(context.Background(), "testid3", new_checkin)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	checkin_list, err := service.GetAllCheckedInUsers()
+	checkin_list, err := service.GetAllCheckedInUsers(context.Background(), map[string][]string{})
 
 	if err != nil {
 		t.Fatal(err)
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/common/errors"
 	"github.com/HackIllinois/api/common/metrics"
 	"github.com/HackIllinois/api/common/utils"
@@ -22,10 +23,12 @@ func SetupController(route *mux.Route) {
 
 	metrics.RegisterHandler("/", GetCurrentDecision, "GET", router)
 	metrics.RegisterHandler("/", UpdateDecision, "POST", router)
+	metrics.RegisterHandler("/bulk/", UpdateDecisions, "POST", router)
 	metrics.RegisterHandler("/finalize/", FinalizeDecision, "POST", router)
 	metrics.RegisterHandler("/filter/", GetFilteredDecisions, "GET", router)
 	metrics.RegisterHandler("/{id}/", GetDecision, "GET", router)
 	metrics.RegisterHandler("/internal/stats/", GetStats, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -34,7 +37,7 @@ func SetupController(route *mux.Route) {
 func GetCurrentDecision(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	decision, err := service.GetDecision(id)
+	decision, err := service.GetDecision(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get current user's decision."))
@@ -71,7 +74,7 @@ func UpdateDecision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	has_decision, err := service.HasDecision(decision.ID)
+	has_decision, err := service.HasDecision(r.Context(), decision.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not determine user's decision."))
@@ -79,7 +82,7 @@ func UpdateDecision(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if has_decision {
-		existing_decision_history, err := service.GetDecision(decision.ID)
+		existing_decision_history, err := service.GetDecision(r.Context(), decision.ID)
 
 		if err != nil {
 			errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get current user's existing decision history."))
@@ -98,14 +101,14 @@ func UpdateDecision(w http.ResponseWriter, r *http.Request) {
 	// Finalized is always false, unless explicitly set to true via the appropriate endpoint.
 	decision.Finalized = false
 
-	err = service.UpdateDecision(decision.ID, decision)
+	err = service.UpdateDecision(r.Context(), decision.ID, decision)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not update decision."))
 		return
 	}
 
-	updated_decision, err := service.GetDecision(decision.ID)
+	updated_decision, err := service.GetDecision(r.Context(), decision.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch updated decision."))
@@ -115,6 +118,53 @@ func UpdateDecision(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(updated_decision)
 }
 
+/*
+	Endpoint to update the decisions for many users at once.
+	Decisions which are invalid or already finalized are reported, without preventing the other decisions from being updated.
+*/
+func UpdateDecisions(w http.ResponseWriter, r *http.Request) {
+	var bulk_decisions models.BulkDecisions
+	err := json.NewDecoder(r.Body).Decode(&bulk_decisions)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.MalformedRequestError(err.Error(), "Could not decode decisions."))
+		return
+	}
+
+	reviewer := r.Header.Get("HackIllinois-Identity")
+	timestamp := time.Now().Unix()
+
+	for i := range bulk_decisions.Decisions {
+		bulk_decisions.Decisions[i].Reviewer = reviewer
+		bulk_decisions.Decisions[i].Timestamp = timestamp
+		bulk_decisions.Decisions[i].ExpiresAt = timestamp + utils.HoursToUnixSeconds(config.DECISION_EXPIRATION_HOURS)
+		// Finalized is always false, unless explicitly set to true via the appropriate endpoint.
+		bulk_decisions.Decisions[i].Finalized = false
+	}
+
+	decision_errors, err := service.UpdateDecisions(r.Context(), bulk_decisions.Decisions)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not update decisions."))
+		return
+	}
+
+	results := models.BulkDecisionResults{
+		Updated: []string{},
+		Failed:  map[string]string{},
+	}
+
+	for i, decision_error := range decision_errors {
+		if decision_error != nil {
+			results.Failed[bulk_decisions.Decisions[i].ID] = decision_error.Error()
+		} else {
+			results.Updated = append(results.Updated, bulk_decisions.Decisions[i].ID)
+		}
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
+
 /*
 	Finalizes / unfinalizes the decision associated with the provided ID.
 	Finalized decisions are blocked from further review, unless unfinalized.
@@ -131,7 +181,7 @@ func FinalizeDecision(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Assuming we are working on the specified user's decision
-	existing_decision_history, err := service.GetDecision(id)
+	existing_decision_history, err := service.GetDecision(r.Context(), id)
 
 	// It is an error to finalize a finalized decision, or unfinalize an unfinalized decision.
 	if existing_decision_history.Finalized == decision_finalized.Finalized {
@@ -148,14 +198,14 @@ func FinalizeDecision(w http.ResponseWriter, r *http.Request) {
 	latest_decision.Timestamp = time.Now().Unix()
 	latest_decision.ExpiresAt = latest_decision.Timestamp + utils.HoursToUnixSeconds(config.DECISION_EXPIRATION_HOURS)
 
-	err = service.UpdateDecision(id, latest_decision)
+	err = service.UpdateDecision(r.Context(), id, latest_decision)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Error updating the decision, in an attempt to alter its finalized status."))
 		return
 	}
 
-	updated_decision, err := service.GetDecision(id)
+	updated_decision, err := service.GetDecision(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch updated decision."))
@@ -186,14 +236,25 @@ func FinalizeDecision(w http.ResponseWriter, r *http.Request) {
 */
 func GetFilteredDecisions(w http.ResponseWriter, r *http.Request) {
 	parameters := r.URL.Query()
-	decisions, err := service.GetFilteredDecisions(parameters)
+	decisions, err := service.GetFilteredDecisions(r.Context(), parameters)
 
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Invalid filter or sort parameters."))
+			return
+		}
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not retrieve filtered decisions."))
 		return
 	}
 
-	json.NewEncoder(w).Encode(decisions)
+	response, err := database.ProjectResponse(decisions, r.URL.Query())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not select the requested fields."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 /*
@@ -202,7 +263,7 @@ func GetFilteredDecisions(w http.ResponseWriter, r *http.Request) {
 func GetDecision(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	decision, err := service.GetDecision(id)
+	decision, err := service.GetDecision(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get decision for the specified user."))
@@ -216,7 +277,7 @@ func GetDecision(w http.ResponseWriter, r *http.Request) {
 	Endpoint to get decision stats
 */
 func GetStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := service.GetStats()
+	stats, err := service.GetStats(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not get decision service statistics."))
@@ -225,3 +286,17 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(stats)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch decision service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
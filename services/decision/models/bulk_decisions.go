@@ -0,0 +1,10 @@
+package models
+
+type BulkDecisions struct {
+	Decisions []Decision `json:"decisions"`
+}
+
+type BulkDecisionResults struct {
+	Updated []string          `json:"updated"`
+	Failed  map[string]string `json:"failed"`
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
 type FilteredDecisions struct {
-	Decisions []DecisionHistory `json:"decisions"`
+	Decisions  []DecisionHistory  `json:"decisions"`
+	Pagination *database.PageInfo `json:"pagination,omitempty"`
 }
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 
 	"github.com/HackIllinois/api/common/database"
@@ -11,8 +12,19 @@ import (
 
 var validate *validator.Validate
 
+var ErrDecisionFinalized = errors.New("Cannot modify finalized decisions.")
+
 var db database.Database
 
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"decision": {
+		{Key: []string{"id"}, Unique: true},
+	},
+}
+
 func Initialize() error {
 	if db != nil {
 		db.Close()
@@ -26,6 +38,12 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
 	validate = validator.New()
 
 	return nil
@@ -34,11 +52,11 @@ func Initialize() error {
 /*
 	Returns the decision associated with the given user id
 */
-func GetDecision(id string) (*models.DecisionHistory, error) {
+func GetDecision(ctx context.Context, id string) (*models.DecisionHistory, error) {
 	query := database.QuerySelector{"id": id}
 
 	var decision models.DecisionHistory
-	err := db.FindOne("decision", query, &decision)
+	err := db.WithContext(ctx).FindOne("decision", query, &decision)
 
 	if err != nil {
 		return nil, err
@@ -51,20 +69,14 @@ func GetDecision(id string) (*models.DecisionHistory, error) {
 	Updates the decision associated with the given user id
 	If a decision doesn't exist it will be created
 */
-func UpdateDecision(id string, decision models.Decision) error {
-	err := validate.Struct(decision)
+func UpdateDecision(ctx context.Context, id string, decision models.Decision) error {
+	err := validateDecision(decision)
 
 	if err != nil {
 		return err
 	}
 
-	if decision.Status == "ACCEPTED" && decision.Wave == 0 {
-		return errors.New("Must set a wave for accepted attendee")
-	} else if decision.Status != "ACCEPTED" && decision.Wave != 0 {
-		return errors.New("Cannot set a wave for non-accepted attendee")
-	}
-
-	decision_history, err := GetDecision(id)
+	decision_history, err := GetDecision(ctx, id)
 
 	if err != nil {
 		if err == database.ErrNotFound {
@@ -76,30 +88,140 @@ func UpdateDecision(id string, decision models.Decision) error {
 		}
 	}
 
-	decision_history.Finalized = decision.Finalized
-	decision_history.Status = decision.Status
-	decision_history.Wave = decision.Wave
-	decision_history.History = append(decision_history.History, decision)
-	decision_history.Reviewer = decision.Reviewer
-	decision_history.Timestamp = decision.Timestamp
-	decision_history.ExpiresAt = decision.ExpiresAt
+	applyDecision(decision_history, decision)
 
 	selector := database.QuerySelector{"id": id}
 
-	err = db.Update("decision", selector, &decision_history)
+	err = db.WithContext(ctx).Update("decision", selector, &decision_history)
 
 	if err == database.ErrNotFound {
-		err = db.Insert("decision", &decision_history)
+		err = db.WithContext(ctx).Insert("decision", &decision_history)
 	}
 
 	return err
 }
 
+/*
+	Updates the decisions of many users in a single batch, creating any decisions which don't exist
+	Decisions which are invalid, or which would change a finalized decision, are skipped
+	Returns an error for each of the given decisions in the same order, which is nil if the decision was updated
+*/
+func UpdateDecisions(ctx context.Context, decisions []models.Decision) ([]error, error) {
+	ids := make([]string, len(decisions))
+	for i, decision := range decisions {
+		ids[i] = decision.ID
+	}
+
+	query := database.QuerySelector{
+		"id": database.QuerySelector{
+			"$in": ids,
+		},
+	}
+
+	var existing_decision_histories []models.DecisionHistory
+	err := db.WithContext(ctx).FindAll("decision", query, &existing_decision_histories)
+
+	if err != nil {
+		return nil, err
+	}
+
+	decision_histories := make(map[string]*models.DecisionHistory)
+	for i, decision_history := range existing_decision_histories {
+		decision_histories[decision_history.ID] = &existing_decision_histories[i]
+	}
+
+	decision_errors := make([]error, len(decisions))
+	operations := []database.BulkOperation{}
+	operation_decisions := []int{}
+
+	for i, decision := range decisions {
+		err = validateDecision(decision)
+
+		if err != nil {
+			decision_errors[i] = err
+			continue
+		}
+
+		decision_history, exists := decision_histories[decision.ID]
+
+		if !exists {
+			decision_history = &models.DecisionHistory{
+				ID: decision.ID,
+			}
+			decision_histories[decision.ID] = decision_history
+		}
+
+		if decision_history.Finalized {
+			decision_errors[i] = ErrDecisionFinalized
+			continue
+		}
+
+		applyDecision(decision_history, decision)
+
+		operations = append(operations, database.BulkOperation{
+			Type:     database.BulkUpsert,
+			Selector: database.QuerySelector{"id": decision.ID},
+			Item:     *decision_history,
+		})
+		operation_decisions = append(operation_decisions, i)
+	}
+
+	if len(operations) == 0 {
+		return decision_errors, nil
+	}
+
+	results, err := db.WithContext(ctx).BulkWrite("decision", operations)
+
+	if err != nil && err != database.ErrBulkWrite {
+		return nil, err
+	}
+
+	for i, result := range results.Operations {
+		if result.Err != nil {
+			decision_errors[operation_decisions[i]] = result.Err
+		}
+	}
+
+	return decision_errors, nil
+}
+
+/*
+	Returns an error if the decision is invalid, or its wave does not match its status
+*/
+func validateDecision(decision models.Decision) error {
+	err := validate.Struct(decision)
+
+	if err != nil {
+		return err
+	}
+
+	if decision.Status == "ACCEPTED" && decision.Wave == 0 {
+		return errors.New("Must set a wave for accepted attendee")
+	} else if decision.Status != "ACCEPTED" && decision.Wave != 0 {
+		return errors.New("Cannot set a wave for non-accepted attendee")
+	}
+
+	return nil
+}
+
+/*
+	Makes the decision the current decision in the decision history, and adds it to the history
+*/
+func applyDecision(decision_history *models.DecisionHistory, decision models.Decision) {
+	decision_history.Finalized = decision.Finalized
+	decision_history.Status = decision.Status
+	decision_history.Wave = decision.Wave
+	decision_history.History = append(decision_history.History, decision)
+	decision_history.Reviewer = decision.Reviewer
+	decision_history.Timestamp = decision.Timestamp
+	decision_history.ExpiresAt = decision.ExpiresAt
+}
+
 /*
 	Checks if a decision with the provided id exists.
 */
-func HasDecision(id string) (bool, error) {
-	_, err := GetDecision(id)
+func HasDecision(ctx context.Context, id string) (bool, error) {
+	_, err := GetDecision(ctx, id)
 
 	if err == nil {
 		return true, nil
@@ -113,7 +235,25 @@ func HasDecision(id string) (bool, error) {
 /*
 	Returns decisions based on a filter
 */
-func GetFilteredDecisions(parameters map[string][]string) (*models.FilteredDecisions, error) {
+func GetFilteredDecisions(ctx context.Context, parameters map[string][]string) (*models.FilteredDecisions, error) {
+	pagination, err := database.ParsePaginationParameters(parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	projection, err := database.ParseProjectionParameters(parameters, models.DecisionHistory{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort_fields, err := database.ParseSortParameters(parameters, models.DecisionHistory{})
+
+	if err != nil {
+		return nil, err
+	}
+
 	query, err := database.CreateFilterQuery(parameters, models.DecisionHistory{})
 
 	if err != nil {
@@ -121,17 +261,26 @@ func GetFilteredDecisions(parameters map[string][]string) (*models.FilteredDecis
 	}
 
 	var filtered_decisions models.FilteredDecisions
-	err = db.FindAll("decision", query, &filtered_decisions.Decisions)
+	pagination_results, err := db.WithContext(ctx).FindAllProjected("decision", query, projection, sort_fields, *pagination, &filtered_decisions.Decisions)
 	if err != nil {
 		return nil, err
 	}
 
+	filtered_decisions.Pagination = database.GetPageInfo(*pagination, pagination_results)
+
 	return &filtered_decisions, nil
 }
 
 /*
 	Returns all decision stats
 */
-func GetStats() (map[string]interface{}, error) {
-	return db.GetStats("decision", []string{"status", "finalized", "wave"})
+func GetStats(ctx context.Context) (map[string]interface{}, error) {
+	return db.WithContext(ctx).GetStats("decision", []string{"status", "finalized", "wave"})
+}
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
 }
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"reflect"
@@ -89,7 +90,7 @@ func CleanupTestDB(t *testing.T) {
 func TestGetDecisionService(t *testing.T) {
 	SetupTestDB(t)
 
-	decision, err := service.GetDecision("testid")
+	decision, err := service.GetDecision(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -129,7 +130,7 @@ func TestGetDecisionService(t *testing.T) {
 func TestUpdateDecisionService(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.UpdateDecision("testid", models.Decision{
+	err := service.UpdateDecision(context.Background(), "testid", models.Decision{
 		Finalized: false,
 		ID:        "testid",
 		Status:    "ACCEPTED",
@@ -143,7 +144,7 @@ func TestUpdateDecisionService(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	decision, err := service.GetDecision("testid")
+	decision, err := service.GetDecision(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -186,6 +187,99 @@ func TestUpdateDecisionService(t *testing.T) {
 	CleanupTestDB(t)
 }
 
+/*
+	Service level test for updating many decisions in the db at once
+	Invalid decisions and changes to finalized decisions must be reported without preventing the other updates
+*/
+func TestUpdateDecisionsService(t *testing.T) {
+	SetupTestDB(t)
+
+	err := db.Insert("decision", &models.DecisionHistory{
+		Finalized: true,
+		ID:        "finalizedid",
+		Status:    "REJECTED",
+		Reviewer:  "reviewerid",
+		Timestamp: 1,
+		ExpiresAt: 5,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decisions := []models.Decision{
+		{ID: "testid", Status: "ACCEPTED", Wave: 1, Reviewer: "reviewerid", Timestamp: 2, ExpiresAt: 7},
+		{ID: "newid", Status: "WAITLISTED", Reviewer: "reviewerid", Timestamp: 2, ExpiresAt: 7},
+		{ID: "finalizedid", Status: "ACCEPTED", Wave: 1, Reviewer: "reviewerid", Timestamp: 2, ExpiresAt: 7},
+		{ID: "invalidid", Status: "ACCEPTED", Reviewer: "reviewerid", Timestamp: 2, ExpiresAt: 7},
+	}
+
+	decision_errors, err := service.UpdateDecisions(context.Background(), decisions)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decision_errors[0] != nil || decision_errors[1] != nil {
+		t.Errorf("Expected the valid decisions to be updated, got %v", decision_errors)
+	}
+
+	if decision_errors[2] != service.ErrDecisionFinalized {
+		t.Errorf("Expected ErrDecisionFinalized for the finalized decision, got %v", decision_errors[2])
+	}
+
+	if decision_errors[3] == nil {
+		t.Errorf("Expected an error for the decision without a wave")
+	}
+
+	decision, err := service.GetDecision(context.Background(), "testid")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decision.Status != "ACCEPTED" || decision.Wave != 1 || len(decision.History) != 2 {
+		t.Errorf("Wrong decision info. Expected an accepted decision with two history entries, got %v", decision)
+	}
+
+	decision, err = service.GetDecision(context.Background(), "newid")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_decision := &models.DecisionHistory{
+		ID:        "newid",
+		Status:    "WAITLISTED",
+		Reviewer:  "reviewerid",
+		Timestamp: 2,
+		ExpiresAt: 7,
+		History:   []models.Decision{decisions[1]},
+	}
+
+	if !reflect.DeepEqual(decision, expected_decision) {
+		t.Errorf("Wrong decision info. Expected %v, got %v", expected_decision, decision)
+	}
+
+	decision, err = service.GetDecision(context.Background(), "finalizedid")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decision.Status != "REJECTED" {
+		t.Errorf("Expected the finalized decision not to change, got %v", decision)
+	}
+
+	_, err = service.GetDecision(context.Background(), "invalidid")
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected the invalid decision not to be created, got %v", err)
+	}
+
+	CleanupTestDB(t)
+}
+
 /*
 	Service level test for getting filtered decision info from db
 */
@@ -220,13 +314,13 @@ func TestGetFilteredDecisionsService(t *testing.T) {
 		"id":   {"testid2"},
 		"wave": {"1"},
 	}
-	decisions, err := service.GetFilteredDecisions(parameters)
+	decisions, err := service.GetFilteredDecisions(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_decisions := models.FilteredDecisions{
-		[]models.DecisionHistory{
+		Decisions: []models.DecisionHistory{
 			decision2,
 		},
 	}
@@ -24,6 +24,8 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/favorite/", RemoveEventFavorite, "DELETE", router)
 
 	metrics.RegisterHandler("/filter/", GetFilteredEvents, "GET", router)
+	metrics.RegisterHandler("/deleted/", GetDeletedEvents, "GET", router)
+	metrics.RegisterHandler("/deleted/{id}/restore/", RestoreEvent, "POST", router)
 	metrics.RegisterHandler("/{id}/", GetEvent, "GET", router)
 	metrics.RegisterHandler("/{id}/", DeleteEvent, "DELETE", router)
 	metrics.RegisterHandler("/", CreateEvent, "POST", router)
@@ -39,6 +41,7 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/track/user/{id}/", GetUserTrackingInfo, "GET", router)
 
 	metrics.RegisterHandler("/internal/stats/", GetStats, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -47,7 +50,7 @@ func SetupController(route *mux.Route) {
 func GetEvent(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	event, err := service.GetEvent(id)
+	event, err := service.GetEvent(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch the event details."))
@@ -65,7 +68,7 @@ func GetEvent(w http.ResponseWriter, r *http.Request) {
 func DeleteEvent(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	event, err := service.DeleteEvent(id)
+	event, err := service.DeleteEvent(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not delete either the event, event trackers, or user trackers, or an intermediary subroutine failed."))
@@ -75,11 +78,45 @@ func DeleteEvent(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(event)
 }
 
+/*
+	Endpoint to get the events which have been deleted but not yet purged
+*/
+func GetDeletedEvents(w http.ResponseWriter, r *http.Request) {
+	event_list, err := service.GetDeletedEvents(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get the deleted events."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(event_list)
+}
+
+/*
+	Endpoint to restore the deleted event with the specified id.
+	On successful restoration, it returns the event that was restored.
+*/
+func RestoreEvent(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	event, err := service.RestoreEvent(r.Context(), id)
+
+	if err == service.ErrEventIdConflict {
+		errors.WriteError(w, r, errors.ConflictError(err.Error(), "Could not restore the event, since an event with the same id already exists."))
+		return
+	} else if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not restore the event."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(event)
+}
+
 /*
 	Endpoint to get all events
 */
 func GetAllEvents(w http.ResponseWriter, r *http.Request) {
-	event_list, err := service.GetAllEvents()
+	event_list, err := service.GetAllEvents(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get all events."))
@@ -94,14 +131,25 @@ func GetAllEvents(w http.ResponseWriter, r *http.Request) {
 */
 func GetFilteredEvents(w http.ResponseWriter, r *http.Request) {
 	parameters := r.URL.Query()
-	event, err := service.GetFilteredEvents(parameters)
+	event, err := service.GetFilteredEvents(r.Context(), parameters)
 
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Invalid filter or sort parameters."))
+			return
+		}
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch filtered list of events."))
 		return
 	}
 
-	json.NewEncoder(w).Encode(event)
+	response, err := database.ProjectResponse(event, r.URL.Query())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not select the requested fields."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 /*
@@ -114,14 +162,14 @@ func CreateEvent(w http.ResponseWriter, r *http.Request) {
 	event.ID = utils.GenerateUniqueID()
 	var code = utils.GenerateUniqueCode()
 
-	err := service.CreateEvent(event.ID, code, event)
+	err := service.CreateEvent(r.Context(), event.ID, code, event)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not create new event."))
 		return
 	}
 
-	updated_event, err := service.GetEvent(event.ID)
+	updated_event, err := service.GetEvent(r.Context(), event.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated event."))
@@ -138,14 +186,14 @@ func UpdateEvent(w http.ResponseWriter, r *http.Request) {
 	var event models.Event
 	json.NewDecoder(r.Body).Decode(&event)
 
-	err := service.UpdateEvent(event.ID, event)
+	err := service.UpdateEvent(r.Context(), event.ID, event)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not update the event."))
 		return
 	}
 
-	updated_event, err := service.GetEvent(event.ID)
+	updated_event, err := service.GetEvent(r.Context(), event.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated event details."))
@@ -166,7 +214,7 @@ func GetEventCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	code, err := service.GetEventCode(id)
+	code, err := service.GetEventCode(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Failed to receive event code information from database"))
@@ -192,14 +240,14 @@ func UpdateEventCode(w http.ResponseWriter, r *http.Request) {
 
 	eventCode.ID = id
 
-	err := service.UpdateEventCode(id, eventCode)
+	err := service.UpdateEventCode(r.Context(), id, eventCode)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not update the code and timestamp of the event."))
 		return
 	}
 
-	updated_event, err := service.GetEventCode(id)
+	updated_event, err := service.GetEventCode(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated event code and timestamp details."))
@@ -223,7 +271,7 @@ func Checkin(w http.ResponseWriter, r *http.Request) {
 	var checkin_request models.CheckinRequest
 	json.NewDecoder(r.Body).Decode(&checkin_request)
 
-	valid, event_id, err := service.CanRedeemPoints(checkin_request.Code)
+	valid, event_id, err := service.CanRedeemPoints(r.Context(), checkin_request.Code)
 
 	result := models.CheckinResult{
 		NewPoints:   -1,
@@ -261,7 +309,7 @@ func Checkin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Determine the current event and its point value
-	event, err := service.GetEvent(event_id)
+	event, err := service.GetEvent(r.Context(), event_id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch the event details and point value."))
@@ -289,7 +337,7 @@ func Checkin(w http.ResponseWriter, r *http.Request) {
 func GetEventTrackingInfo(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	tracker, err := service.GetEventTracker(id)
+	tracker, err := service.GetEventTracker(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get event tracker."))
@@ -305,7 +353,7 @@ func GetEventTrackingInfo(w http.ResponseWriter, r *http.Request) {
 func GetUserTrackingInfo(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	tracker, err := service.GetUserTracker(id)
+	tracker, err := service.GetUserTracker(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user tracker."))
@@ -334,7 +382,7 @@ func MarkUserAsAttendingEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = service.MarkUserAsAttendingEvent(tracking_info.EventID, tracking_info.UserID)
+	err = service.MarkUserAsAttendingEvent(r.Context(), tracking_info.EventID, tracking_info.UserID)
 
 	if err != nil {
 		if err.Error() == "User has already been marked as attending" {
@@ -347,14 +395,14 @@ func MarkUserAsAttendingEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	event_tracker, err := service.GetEventTracker(tracking_info.EventID)
+	event_tracker, err := service.GetEventTracker(r.Context(), tracking_info.EventID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get event trackers."))
 		return
 	}
 
-	user_tracker, err := service.GetUserTracker(tracking_info.UserID)
+	user_tracker, err := service.GetUserTracker(r.Context(), tracking_info.UserID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user trackers."))
@@ -375,7 +423,7 @@ func MarkUserAsAttendingEvent(w http.ResponseWriter, r *http.Request) {
 func GetEventFavorites(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	favorites, err := service.GetEventFavorites(id)
+	favorites, err := service.GetEventFavorites(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user's event favorites."))
@@ -394,14 +442,14 @@ func AddEventFavorite(w http.ResponseWriter, r *http.Request) {
 	var event_favorite_modification models.EventFavoriteModification
 	json.NewDecoder(r.Body).Decode(&event_favorite_modification)
 
-	err := service.AddEventFavorite(id, event_favorite_modification.EventID)
+	err := service.AddEventFavorite(r.Context(), id, event_favorite_modification.EventID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not add an event favorite for the current user."))
 		return
 	}
 
-	favorites, err := service.GetEventFavorites(id)
+	favorites, err := service.GetEventFavorites(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated user event favorites."))
@@ -420,14 +468,14 @@ func RemoveEventFavorite(w http.ResponseWriter, r *http.Request) {
 	var event_favorite_modification models.EventFavoriteModification
 	json.NewDecoder(r.Body).Decode(&event_favorite_modification)
 
-	err := service.RemoveEventFavorite(id, event_favorite_modification.EventID)
+	err := service.RemoveEventFavorite(r.Context(), id, event_favorite_modification.EventID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not remove an event favorite for the current user."))
 		return
 	}
 
-	favorites, err := service.GetEventFavorites(id)
+	favorites, err := service.GetEventFavorites(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch updated event favorites for the user (post-removal)."))
@@ -441,7 +489,7 @@ func RemoveEventFavorite(w http.ResponseWriter, r *http.Request) {
 	Endpoint to get event stats
 */
 func GetStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := service.GetStats()
+	stats, err := service.GetStats(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not fetch event service statistics."))
@@ -450,3 +498,17 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(stats)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch event service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
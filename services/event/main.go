@@ -2,6 +2,7 @@ package event
 
 import (
 	"github.com/HackIllinois/api/common/apiserver"
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/services/event/config"
 	"github.com/HackIllinois/api/services/event/controller"
 	"github.com/HackIllinois/api/services/event/service"
@@ -26,6 +27,19 @@ func Initialize() error {
 	return nil
 }
 
+/*
+	Runs the service's database migrations as described by options instead of starting the service
+*/
+func Migrate(options database.MigrationOptions) ([]database.Migration, error) {
+	err := Initialize()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return service.Migrate(options)
+}
+
 func Entry() {
 	err := Initialize()
 
@@ -0,0 +1,6 @@
+package models
+
+type EventFavoriters struct {
+	EventID string   `json:"eventId"`
+	Users   []string `json:"users"`
+}
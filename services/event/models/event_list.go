@@ -1,5 +1,10 @@
 package models
 
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
 type EventList struct {
-	Events []Event `json:"events"`
+	Events     []Event            `json:"events"`
+	Pagination *database.PageInfo `json:"pagination,omitempty"`
 }
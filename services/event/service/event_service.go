@@ -1,11 +1,11 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/HackIllinois/api/common/database"
-	"github.com/HackIllinois/api/common/utils"
 	"github.com/HackIllinois/api/services/event/config"
 	"github.com/HackIllinois/api/services/event/models"
 	"github.com/go-playground/validator/v10"
@@ -15,7 +15,45 @@ var validate *validator.Validate
 
 var db database.Database
 
+var purge_job *database.PurgeJob
+
+var ErrEventIdConflict = errors.New("An event with the given id already exists.")
+
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"events": {
+		{Key: []string{"id"}, Unique: true},
+		{Key: []string{"$text:name", "$text:description", "$text:locations.tags"}},
+	},
+	"eventtrackers": {
+		{Key: []string{"eventid"}, Unique: true},
+	},
+	"usertrackers": {
+		{Key: []string{"userid"}, Unique: true},
+	},
+	"eventcodes": {
+		{Key: []string{"id"}, Unique: true},
+		{Key: []string{"code"}},
+	},
+	"favorites": {
+		{Key: []string{"id"}, Unique: true},
+	},
+	"eventfavoriters": {
+		{Key: []string{"eventid"}, Unique: true},
+	},
+}
+
+/*
+	Collections whose items are soft deleted when soft deletion is enabled
+*/
+var soft_delete_collections = []string{"events", "eventtrackers", "eventfavoriters"}
+
 func Initialize() error {
+	purge_job.Stop()
+	purge_job = nil
+
 	if db != nil {
 		db.Close()
 		db = nil
@@ -28,6 +66,14 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
+	db, purge_job = database.EnableSoftDelete(db, soft_delete_collections)
+
 	validate = validator.New()
 
 	return nil
@@ -36,13 +82,13 @@ func Initialize() error {
 /*
 	Returns the event with the given id
 */
-func GetEvent(id string) (*models.Event, error) {
+func GetEvent(ctx context.Context, id string) (*models.Event, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var event models.Event
-	err := db.FindOne("events", query, &event)
+	err := db.WithContext(ctx).FindOne("events", query, &event)
 
 	if err != nil {
 		return nil, err
@@ -53,14 +99,15 @@ func GetEvent(id string) (*models.Event, error) {
 
 /*
 	Deletes the event with the given id.
-	Removes the event from event trackers and every user's tracker.
+	Removes the event from event trackers, every user's tracker and every user's favorites.
+	The users who favorited the event are recorded, so that restoring the event can add it back to their favorites.
 	Returns the event that was deleted.
 */
-func DeleteEvent(id string) (*models.Event, error) {
+func DeleteEvent(ctx context.Context, id string) (*models.Event, error) {
 
 	// Gets event to be able to return it later
 
-	event, err := GetEvent(id)
+	event, err := GetEvent(ctx, id)
 
 	if err != nil {
 		return nil, err
@@ -72,7 +119,7 @@ func DeleteEvent(id string) (*models.Event, error) {
 
 	// Remove event from events database
 
-	err = db.RemoveOne("events", query)
+	err = db.WithContext(ctx).RemoveOne("events", query)
 
 	if err != nil {
 		return nil, err
@@ -84,7 +131,7 @@ func DeleteEvent(id string) (*models.Event, error) {
 		"eventid": id,
 	}
 
-	err = db.RemoveOne("eventtrackers", event_selector)
+	err = db.WithContext(ctx).RemoveOne("eventtrackers", event_selector)
 
 	if err != nil {
 		return nil, err
@@ -99,18 +146,215 @@ func DeleteEvent(id string) (*models.Event, error) {
 		},
 	}
 
-	_, err = db.UpdateAll("usertrackers", nil, &update_expression)
+	_, err = db.WithContext(ctx).UpdateAll("usertrackers", nil, &update_expression)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = removeEventFromFavorites(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+/*
+	Removes the event with the given id from every user's favorites.
+	The users who favorited the event are recorded as deleted event favoriters, which are purged along with the event.
+*/
+func removeEventFromFavorites(ctx context.Context, id string) error {
+	favorites_selector := database.QuerySelector{
+		"events": id,
+	}
+
+	users := []string{}
+	err := db.WithContext(ctx).Distinct("favorites", "id", favorites_selector, &users)
+
+	if err != nil {
+		return err
+	}
+
+	err = db.WithContext(ctx).Insert("eventfavoriters", &models.EventFavoriters{
+		EventID: id,
+		Users:   users,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	event_selector := database.QuerySelector{
+		"eventid": id,
+	}
+
+	err = db.WithContext(ctx).RemoveOne("eventfavoriters", event_selector)
+
+	if err != nil {
+		return err
+	}
+
+	update_expression := database.QuerySelector{
+		"$pull": database.QuerySelector{
+			"events": id,
+		},
+	}
+
+	_, err = db.WithContext(ctx).UpdateAll("favorites", favorites_selector, &update_expression)
+
+	return err
+}
+
+/*
+	Returns the events which have been deleted but not yet purged, most recently deleted first
+*/
+func GetDeletedEvents(ctx context.Context) (*models.EventList, error) {
+	events := []models.Event{}
+	err := database.FindAllDeleted(db.WithContext(ctx), "events", nil, &events)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event_list := models.EventList{
+		Events: events,
+	}
+
+	return &event_list, nil
+}
+
+/*
+	Restores the deleted event with the given id along with its event tracker.
+	The event is added back to the trackers of the users who attended it, and the favorites of the users who favorited it.
+	Returns ErrEventIdConflict if an event with the given id has been created since it was deleted.
+	Returns the restored event.
+*/
+func RestoreEvent(ctx context.Context, id string) (*models.Event, error) {
+	query := database.QuerySelector{
+		"id": id,
+	}
+
+	deleted_events := []models.Event{}
+	err := database.FindAllDeleted(db.WithContext(ctx), "events", query, &deleted_events)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deleted_events) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	_, err = GetEvent(ctx, id)
+
+	if err == nil {
+		return nil, ErrEventIdConflict
+	} else if err != database.ErrNotFound {
+		return nil, err
+	}
+
+	err = database.RestoreOne(db.WithContext(ctx), "events", query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event_selector := database.QuerySelector{
+		"eventid": id,
+	}
+
+	_, err = database.RestoreAll(db.WithContext(ctx), "eventtrackers", event_selector)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tracker, err := GetEventTracker(ctx, id)
+
+	if err == nil {
+		user_selector := database.QuerySelector{
+			"userid": database.QuerySelector{
+				"$in": tracker.Users,
+			},
+		}
+
+		update_expression := database.QuerySelector{
+			"$addToSet": database.QuerySelector{
+				"events": id,
+			},
+		}
+
+		_, err = db.WithContext(ctx).UpdateAll("usertrackers", user_selector, &update_expression)
+
+		if err != nil {
+			return nil, err
+		}
+	} else if err != database.ErrNotFound {
+		return nil, err
+	}
+
+	err = restoreEventToFavorites(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return GetEvent(ctx, id)
+}
+
+/*
+	Adds the restored event with the given id back to the favorites of the users who favorited it when it was deleted
+*/
+func restoreEventToFavorites(ctx context.Context, id string) error {
+	event_selector := database.QuerySelector{
+		"eventid": id,
+	}
+
+	_, err := database.RestoreAll(db.WithContext(ctx), "eventfavoriters", event_selector)
+
+	if err != nil {
+		return err
+	}
+
+	var event_favoriters models.EventFavoriters
+	err = db.WithContext(ctx).FindOne("eventfavoriters", event_selector, &event_favoriters)
+
+	if err == database.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	user_selector := database.QuerySelector{
+		"id": database.QuerySelector{
+			"$in": event_favoriters.Users,
+		},
+	}
+
+	update_expression := database.QuerySelector{
+		"$addToSet": database.QuerySelector{
+			"events": id,
+		},
+	}
+
+	_, err = db.WithContext(ctx).UpdateAll("favorites", user_selector, &update_expression)
 
-	return event, err
+	if err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).RemoveOne("eventfavoriters", event_selector)
 }
 
 /*
 	Returns all the events
 */
-func GetAllEvents() (*models.EventList, error) {
+func GetAllEvents(ctx context.Context) (*models.EventList, error) {
 	events := []models.Event{}
 	// nil implies there are no filters on the query, therefore everything in the "events" collection is returned.
-	err := db.FindAll("events", nil, &events)
+	err := db.WithContext(ctx).FindAll("events", nil, &events)
 
 	if err != nil {
 		return nil, err
@@ -124,9 +368,33 @@ func GetAllEvents() (*models.EventList, error) {
 }
 
 /*
-	Returns all the events
+	Returns the events matching the given filter parameters
 */
-func GetFilteredEvents(parameters map[string][]string) (*models.EventList, error) {
+func GetFilteredEvents(ctx context.Context, parameters map[string][]string) (*models.EventList, error) {
+	pagination, err := database.ParsePaginationParameters(parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	projection, err := database.ParseProjectionParameters(parameters, models.Event{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort_fields, err := database.ParseSortParameters(parameters, models.Event{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	search_text, err := database.ParseSearchParameter(parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
 	query, err := database.CreateFilterQuery(parameters, models.Event{})
 
 	if err != nil {
@@ -135,26 +403,28 @@ func GetFilteredEvents(parameters map[string][]string) (*models.EventList, error
 
 	events := []models.Event{}
 	filtered_events := models.EventList{Events: events}
-	err = db.FindAll("events", query, &filtered_events.Events)
+	pagination_results, err := database.FindAllSearched(db.WithContext(ctx), "events", search_text, query, projection, sort_fields, *pagination, &filtered_events.Events)
 
 	if err != nil {
 		return nil, err
 	}
 
+	filtered_events.Pagination = database.GetPageInfo(*pagination, pagination_results)
+
 	return &filtered_events, nil
 }
 
 /*
 	Creates an event with the given id
 */
-func CreateEvent(id string, code string, event models.Event) error {
+func CreateEvent(ctx context.Context, id string, code string, event models.Event) error {
 	err := validate.Struct(event)
 
 	if err != nil {
 		return err
 	}
 
-	_, err = GetEvent(id)
+	_, err = GetEvent(ctx, id)
 
 	if err != database.ErrNotFound {
 		if err != nil {
@@ -163,7 +433,7 @@ func CreateEvent(id string, code string, event models.Event) error {
 		return errors.New("Event already exists")
 	}
 
-	err = db.Insert("events", &event)
+	err = db.WithContext(ctx).Insert("events", &event)
 
 	if err != nil {
 		return err
@@ -174,7 +444,7 @@ func CreateEvent(id string, code string, event models.Event) error {
 		Users:   []string{},
 	}
 
-	err = db.Insert("eventtrackers", &event_tracker)
+	err = db.WithContext(ctx).Insert("eventtrackers", &event_tracker)
 
 	if err != nil {
 		return err
@@ -186,7 +456,7 @@ func CreateEvent(id string, code string, event models.Event) error {
 		Expiration: event.EndTime,
 	}
 
-	err = db.Insert("eventcodes", &event_code)
+	err = db.WithContext(ctx).Insert("eventcodes", &event_code)
 
 	return err
 }
@@ -194,7 +464,7 @@ func CreateEvent(id string, code string, event models.Event) error {
 /*
 	Updates the event with the given id
 */
-func UpdateEvent(id string, event models.Event) error {
+func UpdateEvent(ctx context.Context, id string, event models.Event) error {
 	err := validate.Struct(event)
 
 	if err != nil {
@@ -205,7 +475,7 @@ func UpdateEvent(id string, event models.Event) error {
 		"id": id,
 	}
 
-	err = db.Update("events", selector, &event)
+	err = db.WithContext(ctx).Update("events", selector, &event)
 
 	return err
 }
@@ -213,13 +483,13 @@ func UpdateEvent(id string, event models.Event) error {
 /*
 	Returns the event tracker for the specified event
 */
-func GetEventTracker(event_id string) (*models.EventTracker, error) {
+func GetEventTracker(ctx context.Context, event_id string) (*models.EventTracker, error) {
 	query := database.QuerySelector{
 		"eventid": event_id,
 	}
 
 	var tracker models.EventTracker
-	err := db.FindOne("eventtrackers", query, &tracker)
+	err := db.WithContext(ctx).FindOne("eventtrackers", query, &tracker)
 
 	if err != nil {
 		return nil, err
@@ -231,13 +501,13 @@ func GetEventTracker(event_id string) (*models.EventTracker, error) {
 /*
 	Returns the user tracker for the specified user
 */
-func GetUserTracker(user_id string) (*models.UserTracker, error) {
+func GetUserTracker(ctx context.Context, user_id string) (*models.UserTracker, error) {
 	query := database.QuerySelector{
 		"userid": user_id,
 	}
 
 	var tracker models.UserTracker
-	err := db.FindOne("usertrackers", query, &tracker)
+	err := db.WithContext(ctx).FindOne("usertrackers", query, &tracker)
 
 	if err != nil {
 		if err == database.ErrNotFound {
@@ -256,8 +526,8 @@ func GetUserTracker(user_id string) (*models.UserTracker, error) {
 	Returns true is the user has already been marked as attending
 	the specified event, false otherwise
 */
-func IsUserAttendingEvent(event_id string, user_id string) (bool, error) {
-	tracker, err := GetEventTracker(event_id)
+func IsUserAttendingEvent(ctx context.Context, event_id string, user_id string) (bool, error) {
+	tracker, err := GetEventTracker(ctx, event_id)
 
 	if err != nil {
 		return false, err
@@ -276,8 +546,8 @@ func IsUserAttendingEvent(event_id string, user_id string) (bool, error) {
 	Marks the specified user as attending the specified event
 	The user must not already marked as attending for this to return successfully
 */
-func MarkUserAsAttendingEvent(event_id string, user_id string) error {
-	is_attending, err := IsUserAttendingEvent(event_id, user_id)
+func MarkUserAsAttendingEvent(ctx context.Context, event_id string, user_id string) error {
+	is_attending, err := IsUserAttendingEvent(ctx, event_id, user_id)
 
 	if err != nil {
 		return err
@@ -288,7 +558,7 @@ func MarkUserAsAttendingEvent(event_id string, user_id string) error {
 	}
 
 	if config.EVENT_CHECKIN_TIME_RESTRICTED {
-		is_event_active, err := IsEventActive(event_id)
+		is_event_active, err := IsEventActive(ctx, event_id)
 
 		if err != nil {
 			return err
@@ -309,7 +579,7 @@ func MarkUserAsAttendingEvent(event_id string, user_id string) error {
 		},
 	}
 
-	err = db.Update("eventtrackers", event_selector, &event_modifier)
+	err = db.WithContext(ctx).Update("eventtrackers", event_selector, &event_modifier)
 
 	if err != nil {
 		return err
@@ -325,14 +595,14 @@ func MarkUserAsAttendingEvent(event_id string, user_id string) error {
 		},
 	}
 
-	err = db.Update("usertrackers", user_selector, &user_modifier)
+	err = db.WithContext(ctx).Update("usertrackers", user_selector, &user_modifier)
 
 	if err == database.ErrNotFound {
 		user_tracker := models.UserTracker{
 			UserID: user_id,
 			Events: []string{event_id},
 		}
-		err = db.Insert("usertrackers", &user_tracker)
+		err = db.WithContext(ctx).Insert("usertrackers", &user_tracker)
 	}
 
 	return err
@@ -345,8 +615,8 @@ const PreEventCheckinIntervalInSeconds = PreEventCheckinIntervalInMinutes * 60
 	Check if an event is active, i.e., that check-ins are allowed for the event at the current time.
 	Returns true if the current time is between `PreEventCheckinIntervalInMinutes` number of minutes before the event, and the end of event.
 */
-func IsEventActive(event_id string) (bool, error) {
-	event, err := GetEvent(event_id)
+func IsEventActive(ctx context.Context, event_id string) (bool, error) {
+	event, err := GetEvent(ctx, event_id)
 
 	if err != nil {
 		return false, err
@@ -366,17 +636,17 @@ func IsEventActive(event_id string) (bool, error) {
 /*
 	Returns the event favorites for the user with the given id
 */
-func GetEventFavorites(id string) (*models.EventFavorites, error) {
+func GetEventFavorites(ctx context.Context, id string) (*models.EventFavorites, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var event_favorites models.EventFavorites
-	err := db.FindOne("favorites", query, &event_favorites)
+	err := db.WithContext(ctx).FindOne("favorites", query, &event_favorites)
 
 	if err != nil {
 		if err == database.ErrNotFound {
-			err = db.Insert("favorites", &models.EventFavorites{
+			err = db.WithContext(ctx).Insert("favorites", &models.EventFavorites{
 				ID:     id,
 				Events: []string{},
 			})
@@ -385,7 +655,7 @@ func GetEventFavorites(id string) (*models.EventFavorites, error) {
 				return nil, err
 			}
 
-			err = db.FindOne("favorites", query, &event_favorites)
+			err = db.WithContext(ctx).FindOne("favorites", query, &event_favorites)
 
 			if err != nil {
 				return nil, err
@@ -400,29 +670,26 @@ func GetEventFavorites(id string) (*models.EventFavorites, error) {
 
 /*
 	Adds the given event to the favorites for the user with the given id
+	The user's favorites are created if they do not exist yet
 */
-func AddEventFavorite(id string, event string) error {
+func AddEventFavorite(ctx context.Context, id string, event string) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	_, err := GetEvent(event)
+	_, err := GetEvent(ctx, event)
 
 	if err != nil {
 		return errors.New("Could not find event with the given id.")
 	}
 
-	event_favorites, err := GetEventFavorites(id)
-
-	if err != nil {
-		return err
-	}
-
-	if !utils.ContainsString(event_favorites.Events, event) {
-		event_favorites.Events = append(event_favorites.Events, event)
+	modifier := database.QuerySelector{
+		"$addToSet": database.QuerySelector{
+			"events": event,
+		},
 	}
 
-	err = db.Update("favorites", selector, event_favorites)
+	_, err = db.WithContext(ctx).Upsert("favorites", selector, &modifier)
 
 	return err
 }
@@ -430,36 +697,43 @@ func AddEventFavorite(id string, event string) error {
 /*
 	Removes the given event from the favorites for the user with the given id
 */
-func RemoveEventFavorite(id string, event string) error {
+func RemoveEventFavorite(ctx context.Context, id string, event string) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	event_favorites, err := GetEventFavorites(id)
+	condition := database.QuerySelector{
+		"events": event,
+	}
 
-	if err != nil {
-		return err
+	modifier := database.QuerySelector{
+		"$pull": database.QuerySelector{
+			"events": event,
+		},
 	}
 
-	event_favorites.Events, err = utils.RemoveString(event_favorites.Events, event)
+	was_removed, err := db.WithContext(ctx).UpdateIfMatches("favorites", selector, condition, &modifier)
 
-	if err != nil {
-		return errors.New("User's event favorites does not have specified event")
+	// A user without a favorites document has no favorites to remove
+	if err != nil && err != database.ErrNotFound {
+		return err
 	}
 
-	err = db.Update("favorites", selector, event_favorites)
+	if !was_removed {
+		return errors.New("User's event favorites does not have specified event")
+	}
 
-	return err
+	return nil
 }
 
 /*
 	Returns all event stats
 */
-func GetStats() (map[string]interface{}, error) {
+func GetStats(ctx context.Context) (map[string]interface{}, error) {
 	query := database.QuerySelector{}
 
 	var trackers []models.EventTracker
-	err := db.FindAll("eventtrackers", query, &trackers)
+	err := db.WithContext(ctx).FindAll("eventtrackers", query, &trackers)
 
 	if err != nil {
 		return nil, err
@@ -478,13 +752,13 @@ func GetStats() (map[string]interface{}, error) {
 	Check if an event can be redeemed for points, i.e., that the point timeout has not been reached
 	Returns true if the current time is between `PreEventCheckinIntervalInMinutes` number of minutes before the event, and the end of event.
 */
-func CanRedeemPoints(event_code string) (bool, string, error) {
+func CanRedeemPoints(ctx context.Context, event_code string) (bool, string, error) {
 	query := database.QuerySelector{
 		"code": event_code,
 	}
 
 	var eventCode models.EventCode
-	err := db.FindOne("eventcodes", query, &eventCode)
+	err := db.WithContext(ctx).FindOne("eventcodes", query, &eventCode)
 
 	if err != nil {
 		return false, "invalid", err
@@ -499,13 +773,13 @@ func CanRedeemPoints(event_code string) (bool, string, error) {
 /*
 	Returns the eventcode struct for the event with the given id
 */
-func GetEventCode(id string) (*models.EventCode, error) {
+func GetEventCode(ctx context.Context, id string) (*models.EventCode, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var eventCode models.EventCode
-	err := db.FindOne("eventcodes", query, &eventCode)
+	err := db.WithContext(ctx).FindOne("eventcodes", query, &eventCode)
 
 	if err != nil {
 		return nil, err
@@ -517,12 +791,19 @@ func GetEventCode(id string) (*models.EventCode, error) {
 /*
 	Updates the event code and end time with the given id
 */
-func UpdateEventCode(id string, eventCode models.EventCode) error {
+func UpdateEventCode(ctx context.Context, id string, eventCode models.EventCode) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	err := db.Update("eventcodes", selector, &eventCode)
+	err := db.WithContext(ctx).Update("eventcodes", selector, &eventCode)
 
 	return err
 }
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
+}
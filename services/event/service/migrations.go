@@ -0,0 +1,29 @@
+package service
+
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
+/*
+	Migrations which are run on the service's database with the -migrate flag, in order of version
+*/
+var migrations = database.Migrations{
+	{
+		Version:     1,
+		Description: "Backfill points and isAsync on events created before they were added",
+		Up: func(db database.Database) error {
+			return database.BackfillFields(db, "events", database.QuerySelector{"points": 0, "isasync": false})
+		},
+		// Destructive, as this also unsets the fields on events which were saved with their default values after the backfill
+		Down: func(db database.Database) error {
+			return database.UnsetDefaultFields(db, "events", database.QuerySelector{"points": 0, "isasync": false})
+		},
+	},
+}
+
+/*
+	Applies the service's pending migrations or rolls back its applied migrations as described by options
+*/
+func Migrate(options database.MigrationOptions) ([]database.Migration, error) {
+	return database.Migrate(db, migrations, options)
+}
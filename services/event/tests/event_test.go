@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	common_config "github.com/HackIllinois/api/common/config"
 	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/services/event/config"
 	"github.com/HackIllinois/api/services/event/models"
@@ -128,7 +130,7 @@ func TestGetAllEventsService(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	actual_event_list, err := service.GetAllEvents()
+	actual_event_list, err := service.GetAllEvents(context.Background())
 
 	if err != nil {
 		t.Fatal(err)
@@ -183,7 +185,7 @@ func TestGetAllEventsService(t *testing.T) {
 
 	db.RemoveAll("events", nil)
 
-	actual_event_list, err = service.GetAllEvents()
+	actual_event_list, err = service.GetAllEvents(context.Background())
 
 	if err != nil {
 		t.Fatal(err)
@@ -237,7 +239,7 @@ func TestGetFilteredEventsService(t *testing.T) {
 	parameters := map[string][]string{
 		"name": {"testname2"},
 	}
-	actual_event_list, err := service.GetFilteredEvents(parameters)
+	actual_event_list, err := service.GetFilteredEvents(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -275,7 +277,7 @@ func TestGetFilteredEventsService(t *testing.T) {
 	parameters = map[string][]string{
 		"sponsor": {"testsponsor"},
 	}
-	actual_event_list, err = service.GetFilteredEvents(parameters)
+	actual_event_list, err = service.GetFilteredEvents(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -331,7 +333,7 @@ func TestGetFilteredEventsService(t *testing.T) {
 	db.RemoveAll("events", nil)
 
 	// Filter again, with no events remaining
-	actual_event_list, err = service.GetFilteredEvents(parameters)
+	actual_event_list, err = service.GetFilteredEvents(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -349,13 +351,226 @@ func TestGetFilteredEventsService(t *testing.T) {
 
 }
 
+/*
+	Service level test for selecting fields when filtering events
+*/
+func TestGetFilteredEventsProjectionService(t *testing.T) {
+	SetupTestDB(t)
+
+	parameters := map[string][]string{
+		"name":   {"testname"},
+		"fields": {"id,name"},
+	}
+	actual_event_list, err := service.GetFilteredEvents(context.Background(), parameters)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_event_list := models.EventList{
+		Events: []models.Event{
+			{
+				ID:   "testid",
+				Name: "testname",
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(actual_event_list, &expected_event_list) {
+		t.Errorf("Wrong event list. Expected %v, got %v", expected_event_list, actual_event_list)
+	}
+
+	response, err := database.ProjectResponse(actual_event_list, map[string][]string{"fields": {"id,name"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_response := map[string]interface{}{
+		"events": []interface{}{
+			map[string]interface{}{
+				"id":   "testid",
+				"name": "testname",
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(response, expected_response) {
+		t.Errorf("Wrong response. Expected %v, got %v", expected_response, response)
+	}
+
+	parameters = map[string][]string{
+		"fields": {"id,secret"},
+	}
+	_, err = service.GetFilteredEvents(context.Background(), parameters)
+
+	if err == nil {
+		t.Error("Expected an error selecting a field which does not exist")
+	}
+
+	CleanupTestDB(t)
+}
+
+/*
+	Service level test for sorting filtered events
+*/
+func TestGetFilteredEventsSortService(t *testing.T) {
+	SetupTestDB(t)
+
+	event := models.Event{
+		ID:          "testid2",
+		Name:        "testname2",
+		Description: "testdescription2",
+		StartTime:   TestTime - 60000,
+		EndTime:     TestTime,
+		Sponsor:     "testsponsor",
+		EventType:   "WORKSHOP",
+		Locations:   []models.EventLocation{},
+		Points:      20,
+	}
+
+	err := db.Insert("events", &event)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parameters := map[string][]string{
+		"sort":   {"startTime"},
+		"fields": {"id"},
+	}
+	actual_event_list, err := service.GetFilteredEvents(context.Background(), parameters)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_event_list := models.EventList{
+		Events: []models.Event{
+			{ID: "testid2"},
+			{ID: "testid"},
+		},
+	}
+
+	if !reflect.DeepEqual(actual_event_list, &expected_event_list) {
+		t.Errorf("Wrong event list. Expected %v, got %v", expected_event_list, actual_event_list)
+	}
+
+	parameters = map[string][]string{
+		"sort":   {"-name"},
+		"fields": {"id"},
+	}
+	actual_event_list, err = service.GetFilteredEvents(context.Background(), parameters)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual_event_list, &expected_event_list) {
+		t.Errorf("Wrong event list. Expected %v, got %v", expected_event_list, actual_event_list)
+	}
+
+	parameters = map[string][]string{
+		"sort": {"secret"},
+	}
+	_, err = service.GetFilteredEvents(context.Background(), parameters)
+
+	if _, ok := err.(database.FilterError); !ok {
+		t.Errorf("Expected a FilterError sorting on a field which does not exist, got %v", err)
+	}
+
+	CleanupTestDB(t)
+}
+
+/*
+	Service level test for searching events by text
+*/
+func TestGetFilteredEventsSearchService(t *testing.T) {
+	// Dropping the database between tests also drops the text index, so it must be recreated
+	err := service.Initialize()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetupTestDB(t)
+
+	event := models.Event{
+		ID:          "testid2",
+		Name:        "PyTorch Workshop",
+		Description: "An introduction to machine learning",
+		StartTime:   TestTime,
+		EndTime:     TestTime + 60000,
+		Sponsor:     "testsponsor",
+		EventType:   "WORKSHOP",
+		Locations: []models.EventLocation{
+			{
+				Description: "testlocationdescription",
+				Tags:        []string{"SIEBEL0"},
+				Latitude:    123.456,
+				Longitude:   123.456,
+			},
+		},
+		Points: 0,
+	}
+
+	err = db.Insert("events", &event)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parameters := map[string][]string{
+		"q":      {"pytorch workshop"},
+		"fields": {"id"},
+	}
+	actual_event_list, err := service.GetFilteredEvents(context.Background(), parameters)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_event_list := models.EventList{
+		Events: []models.Event{
+			{ID: "testid2"},
+		},
+	}
+
+	if !reflect.DeepEqual(actual_event_list, &expected_event_list) {
+		t.Errorf("Wrong event list. Expected %v, got %v", expected_event_list, actual_event_list)
+	}
+
+	parameters = map[string][]string{
+		"q":      {"siebel0 testname"},
+		"fields": {"id"},
+	}
+	actual_event_list, err = service.GetFilteredEvents(context.Background(), parameters)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_event_list = models.EventList{
+		Events: []models.Event{
+			{ID: "testid"},
+			{ID: "testid2"},
+		},
+	}
+
+	if !reflect.DeepEqual(actual_event_list, &expected_event_list) {
+		t.Errorf("Wrong event list. Expected %v, got %v", expected_event_list, actual_event_list)
+	}
+
+	CleanupTestDB(t)
+}
+
 /*
 	Service level test for getting event from db
 */
 func TestGetEventService(t *testing.T) {
 	SetupTestDB(t)
 
-	event, err := service.GetEvent("testid")
+	event, err := service.GetEvent(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -411,13 +626,13 @@ func TestCreateEventService(t *testing.T) {
 		},
 	}
 
-	err := service.CreateEvent("testid2", "testcode2", new_event)
+	err := service.CreateEvent(context.Background(), "testid2", "testcode2", new_event)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	event, err := service.GetEvent("testid2")
+	event, err := service.GetEvent(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -467,13 +682,13 @@ func TestCreateEventService(t *testing.T) {
 		IsAsync: true,
 	}
 
-	err = service.CreateEvent("testid2", "testcode2", new_event_async)
+	err = service.CreateEvent(context.Background(), "testid2", "testcode2", new_event_async)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	event_async, err := service.GetEvent("testid2")
+	event_async, err := service.GetEvent(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -514,19 +729,19 @@ func TestDeleteEventService(t *testing.T) {
 
 	// Mark 3 users as attending the event
 
-	err := service.MarkUserAsAttendingEvent(event_id, "user0")
+	err := service.MarkUserAsAttendingEvent(context.Background(), event_id, "user0")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = service.MarkUserAsAttendingEvent(event_id, "user1")
+	err = service.MarkUserAsAttendingEvent(context.Background(), event_id, "user1")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = service.MarkUserAsAttendingEvent(event_id, "user2")
+	err = service.MarkUserAsAttendingEvent(context.Background(), event_id, "user2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -534,21 +749,21 @@ func TestDeleteEventService(t *testing.T) {
 
 	// Try to delete the event
 
-	_, err = service.DeleteEvent(event_id)
+	_, err = service.DeleteEvent(context.Background(), event_id)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Try to find the event in the events db
-	event, err := service.GetEvent(event_id)
+	event, err := service.GetEvent(context.Background(), event_id)
 
 	if err == nil {
 		t.Errorf("Found event %v in events database.", event)
 	}
 
 	// Try to find the event in the eventtrackers db
-	event_tracker, err := service.GetEventTracker(event_id)
+	event_tracker, err := service.GetEventTracker(context.Background(), event_id)
 
 	if err == nil {
 		t.Errorf("Found event in the eventtracker %v.", event_tracker)
@@ -569,6 +784,189 @@ func TestDeleteEventService(t *testing.T) {
 	CleanupTestDB(t)
 }
 
+/*
+	Service level test for soft deleting an event and restoring it
+*/
+func TestDeleteAndRestoreEventService(t *testing.T) {
+	common_config.SOFT_DELETE = true
+	defer func() {
+		common_config.SOFT_DELETE = false
+		service.Initialize()
+	}()
+
+	err := service.Initialize()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetupTestDB(t)
+
+	event_id := "testid"
+
+	err = service.MarkUserAsAttendingEvent(context.Background(), event_id, "user0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.AddEventFavorite(context.Background(), "user1", event_id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted_event, err := service.DeleteEvent(context.Background(), event_id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = service.GetEvent(context.Background(), event_id)
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a deleted event, got %v", err)
+	}
+
+	event_favorites, err := service.GetEventFavorites(context.Background(), "user1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_event_favorites := models.EventFavorites{
+		ID:     "user1",
+		Events: []string{},
+	}
+
+	if !reflect.DeepEqual(event_favorites, &expected_event_favorites) {
+		t.Errorf("Wrong event favorites. Expected %v, got %v", expected_event_favorites, event_favorites)
+	}
+
+	deleted_events, err := service.GetDeletedEvents(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_deleted_events := models.EventList{
+		Events: []models.Event{*deleted_event},
+	}
+
+	if !reflect.DeepEqual(deleted_events, &expected_deleted_events) {
+		t.Errorf("Wrong deleted events. Expected %v, got %v", expected_deleted_events, deleted_events)
+	}
+
+	restored_event, err := service.RestoreEvent(context.Background(), event_id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(restored_event, deleted_event) {
+		t.Errorf("Wrong restored event. Expected %v, got %v", deleted_event, restored_event)
+	}
+
+	user_tracker, err := service.GetUserTracker(context.Background(), "user0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_user_tracker := models.UserTracker{
+		UserID: "user0",
+		Events: []string{event_id},
+	}
+
+	if !reflect.DeepEqual(user_tracker, &expected_user_tracker) {
+		t.Errorf("Wrong user tracker. Expected %v, got %v", expected_user_tracker, user_tracker)
+	}
+
+	event_favorites, err = service.GetEventFavorites(context.Background(), "user1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_event_favorites = models.EventFavorites{
+		ID:     "user1",
+		Events: []string{event_id},
+	}
+
+	if !reflect.DeepEqual(event_favorites, &expected_event_favorites) {
+		t.Errorf("Wrong event favorites. Expected %v, got %v", expected_event_favorites, event_favorites)
+	}
+
+	_, err = service.RestoreEvent(context.Background(), event_id)
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound restoring an event which is not deleted, got %v", err)
+	}
+
+	CleanupTestDB(t)
+}
+
+/*
+	Service level test for restoring a deleted event when an event with the same id has since been created
+*/
+func TestRestoreEventIdConflictService(t *testing.T) {
+	common_config.SOFT_DELETE = true
+	defer func() {
+		common_config.SOFT_DELETE = false
+		service.Initialize()
+	}()
+
+	err := service.Initialize()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the indexes, as if the unique index on event ids could not be created due to duplicate ids
+
+	err = db.DropDatabase()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetupTestDB(t)
+
+	event_id := "testid"
+
+	_, err = service.DeleteEvent(context.Background(), event_id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Insert without soft deletion, so that the deleted event is kept alongside the new event
+
+	err = db.Insert("events", &models.Event{ID: event_id, Name: "newname"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = service.RestoreEvent(context.Background(), event_id)
+
+	if err != service.ErrEventIdConflict {
+		t.Errorf("Expected ErrEventIdConflict restoring an event whose id is taken, got %v", err)
+	}
+
+	event, err := service.GetEvent(context.Background(), event_id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Name != "newname" {
+		t.Errorf("Wrong event after failed restore. Expected name %v, got %v", "newname", event.Name)
+	}
+
+	CleanupTestDB(t)
+}
+
 /*
 	Service level test for updating an event in the db
 */
@@ -594,13 +992,13 @@ func TestUpdateEventService(t *testing.T) {
 		Points: 100,
 	}
 
-	err := service.UpdateEvent("testid", event)
+	err := service.UpdateEvent(context.Background(), "testid", event)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	updated_event, err := service.GetEvent("testid")
+	updated_event, err := service.GetEvent(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -638,13 +1036,13 @@ func TestUpdateEventService(t *testing.T) {
 func TestMarkUserAsAttendingEventService(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.MarkUserAsAttendingEvent("testid", "testuser")
+	err := service.MarkUserAsAttendingEvent(context.Background(), "testid", "testuser")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	event_tracker, err := service.GetEventTracker("testid")
+	event_tracker, err := service.GetEventTracker(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -659,7 +1057,7 @@ func TestMarkUserAsAttendingEventService(t *testing.T) {
 		t.Errorf("Wrong tracker info. Expected %v, got %v", expected_event_tracker, event_tracker)
 	}
 
-	user_tracker, err := service.GetUserTracker("testuser")
+	user_tracker, err := service.GetUserTracker(context.Background(), "testuser")
 
 	if err != nil {
 		t.Fatal(err)
@@ -684,19 +1082,19 @@ func TestMarkUserAsAttendingEventService(t *testing.T) {
 func TestMarkUserAsAttendingEventErrorService(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.MarkUserAsAttendingEvent("testid", "testuser")
+	err := service.MarkUserAsAttendingEvent(context.Background(), "testid", "testuser")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = service.MarkUserAsAttendingEvent("testid", "testuser")
+	err = service.MarkUserAsAttendingEvent(context.Background(), "testid", "testuser")
 
 	if err == nil {
 		t.Fatal("User was marked as attending event twice")
 	}
 
-	event_tracker, err := service.GetEventTracker("testid")
+	event_tracker, err := service.GetEventTracker(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -711,7 +1109,7 @@ func TestMarkUserAsAttendingEventErrorService(t *testing.T) {
 		t.Errorf("Wrong tracker info. Expected %v, got %v", expected_event_tracker, event_tracker)
 	}
 
-	user_tracker, err := service.GetUserTracker("testuser")
+	user_tracker, err := service.GetUserTracker(context.Background(), "testuser")
 
 	if err != nil {
 		t.Fatal(err)
@@ -755,9 +1153,9 @@ func TestIsEventActive(t *testing.T) {
 		},
 	}
 
-	service.CreateEvent(new_event.ID, "testcode3", new_event)
+	service.CreateEvent(context.Background(), new_event.ID, "testcode3", new_event)
 
-	is_active, err := service.IsEventActive("testid3")
+	is_active, err := service.IsEventActive(context.Background(), "testid3")
 
 	if err != nil {
 		t.Fatal(err)
@@ -774,9 +1172,9 @@ func TestIsEventActive(t *testing.T) {
 	new_event.StartTime = TestTime
 	new_event.EndTime = TestTime + ONE_MINUTE_IN_SECONDS*20
 
-	service.CreateEvent(new_event.ID, "testcode4", new_event)
+	service.CreateEvent(context.Background(), new_event.ID, "testcode4", new_event)
 
-	is_active, err = service.IsEventActive("testid4")
+	is_active, err = service.IsEventActive(context.Background(), "testid4")
 
 	if err != nil {
 		t.Fatal(err)
@@ -796,7 +1194,7 @@ func TestIsEventActive(t *testing.T) {
 func TestGetEventFavorites(t *testing.T) {
 	SetupTestDB(t)
 
-	event_favorites, err := service.GetEventFavorites("testid")
+	event_favorites, err := service.GetEventFavorites(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -820,13 +1218,13 @@ func TestGetEventFavorites(t *testing.T) {
 func TestAddEventFavorite(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.AddEventFavorite("testid", "testid")
+	err := service.AddEventFavorite(context.Background(), "testid", "testid")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	event_favorites, err := service.GetEventFavorites("testid")
+	event_favorites, err := service.GetEventFavorites(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -850,19 +1248,19 @@ func TestAddEventFavorite(t *testing.T) {
 func TestRemoveEventFavorite(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.AddEventFavorite("testid", "testid")
+	err := service.AddEventFavorite(context.Background(), "testid", "testid")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = service.RemoveEventFavorite("testid", "testid")
+	err = service.RemoveEventFavorite(context.Background(), "testid", "testid")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	event_favorites, err := service.GetEventFavorites("testid")
+	event_favorites, err := service.GetEventFavorites(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -877,5 +1275,11 @@ func TestRemoveEventFavorite(t *testing.T) {
 		t.Errorf("Wrong tracker info. Expected %v, got %v", &expected_event_favorites, event_favorites)
 	}
 
+	err = service.RemoveEventFavorite(context.Background(), "otherid", "testid")
+
+	if err == nil {
+		t.Errorf("Expected an error removing a favorite event for a user without favorites")
+	}
+
 	CleanupTestDB(t)
 }
@@ -24,6 +24,7 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/list/add/", AddToMailList, "POST", router)
 	metrics.RegisterHandler("/list/remove/", RemoveFromMailList, "POST", router)
 	metrics.RegisterHandler("/list/{id}/", GetMailList, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -52,7 +53,7 @@ func SendMailList(w http.ResponseWriter, r *http.Request) {
 	var mail_order_list models.MailOrderList
 	json.NewDecoder(r.Body).Decode(&mail_order_list)
 
-	mail_status, err := service.SendMailByList(mail_order_list)
+	mail_status, err := service.SendMailByList(r.Context(), mail_order_list)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not send email by list."))
@@ -69,14 +70,14 @@ func CreateMailList(w http.ResponseWriter, r *http.Request) {
 	var mail_list models.MailList
 	json.NewDecoder(r.Body).Decode(&mail_list)
 
-	err := service.CreateMailList(mail_list)
+	err := service.CreateMailList(r.Context(), mail_list)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not create the specified mail list."))
 		return
 	}
 
-	created_list, err := service.GetMailList(mail_list.ID)
+	created_list, err := service.GetMailList(r.Context(), mail_list.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get mail list."))
@@ -93,14 +94,14 @@ func AddToMailList(w http.ResponseWriter, r *http.Request) {
 	var mail_list models.MailList
 	json.NewDecoder(r.Body).Decode(&mail_list)
 
-	err := service.AddToMailList(mail_list)
+	err := service.AddToMailList(r.Context(), mail_list)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not add user to mail list."))
 		return
 	}
 
-	modified_list, err := service.GetMailList(mail_list.ID)
+	modified_list, err := service.GetMailList(r.Context(), mail_list.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get modified mail list."))
@@ -117,14 +118,14 @@ func RemoveFromMailList(w http.ResponseWriter, r *http.Request) {
 	var mail_list models.MailList
 	json.NewDecoder(r.Body).Decode(&mail_list)
 
-	err := service.RemoveFromMailList(mail_list)
+	err := service.RemoveFromMailList(r.Context(), mail_list)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not remove user from mailing list."))
 		return
 	}
 
-	modified_list, err := service.GetMailList(mail_list.ID)
+	modified_list, err := service.GetMailList(r.Context(), mail_list.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get modified mail list."))
@@ -140,7 +141,7 @@ func RemoveFromMailList(w http.ResponseWriter, r *http.Request) {
 func GetMailList(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	mail_list, err := service.GetMailList(id)
+	mail_list, err := service.GetMailList(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get mail list."))
@@ -154,7 +155,9 @@ func GetMailList(w http.ResponseWriter, r *http.Request) {
 	Endpoint to get all mailing lists
 */
 func GetAllMailLists(w http.ResponseWriter, r *http.Request) {
-	mail_lists, err := service.GetAllMailLists()
+	parameters := r.URL.Query()
+
+	mail_lists, err := service.GetAllMailLists(r.Context(), parameters)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get all mail lists."))
@@ -163,3 +166,17 @@ func GetAllMailLists(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(mail_lists)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch mail service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
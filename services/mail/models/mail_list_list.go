@@ -1,5 +1,10 @@
 package models
 
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
 type MailListList struct {
-	MailLists []MailList `json:"mailLists"`
+	MailLists  []MailList         `json:"mailLists"`
+	Pagination *database.PageInfo `json:"pagination,omitempty"`
 }
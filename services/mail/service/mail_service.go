@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +15,15 @@ import (
 
 var db database.Database
 
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"lists": {
+		{Key: []string{"id"}, Unique: true},
+	},
+}
+
 func Initialize() error {
 	if db != nil {
 		db.Close()
@@ -27,6 +37,12 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -34,8 +50,8 @@ func Initialize() error {
 	Send mail to the users in the given mailing list, using the provided template
 	Substitution will be generated based on user info
 */
-func SendMailByList(mail_order_list models.MailOrderList) (*models.MailStatus, error) {
-	mail_list, err := GetMailList(mail_order_list.ListID)
+func SendMailByList(ctx context.Context, mail_order_list models.MailOrderList) (*models.MailStatus, error) {
+	mail_list, err := GetMailList(ctx, mail_order_list.ListID)
 
 	if err != nil {
 		return nil, err
@@ -155,15 +171,15 @@ func SendMailDev(mail_info models.MailInfo) (*models.MailStatus, error) {
 	Create a mailing list with the given id and initial set of user, if provided.
 	Returns an error if a list with given ID already exists.
 */
-func CreateMailList(mail_list models.MailList) error {
+func CreateMailList(ctx context.Context, mail_list models.MailList) error {
 	if mail_list.UserIDs == nil {
 		mail_list.UserIDs = []string{}
 	}
 
-	_, err := GetMailList(mail_list.ID)
+	_, err := GetMailList(ctx, mail_list.ID)
 
 	if err == database.ErrNotFound {
-		return db.Insert("lists", &mail_list)
+		return db.WithContext(ctx).Insert("lists", &mail_list)
 	} else if err != nil {
 		return err
 	} else {
@@ -174,7 +190,7 @@ func CreateMailList(mail_list models.MailList) error {
 /*
 	Adds the given users to the specified mailing list
 */
-func AddToMailList(mail_list models.MailList) error {
+func AddToMailList(ctx context.Context, mail_list models.MailList) error {
 	selector := database.QuerySelector{
 		"id": mail_list.ID,
 	}
@@ -187,13 +203,13 @@ func AddToMailList(mail_list models.MailList) error {
 		},
 	}
 
-	return db.Update("lists", selector, &modifier)
+	return db.WithContext(ctx).Update("lists", selector, &modifier)
 }
 
 /*
 	Removes the given users from the specified mailing list
 */
-func RemoveFromMailList(mail_list models.MailList) error {
+func RemoveFromMailList(ctx context.Context, mail_list models.MailList) error {
 	selector := database.QuerySelector{
 		"id": mail_list.ID,
 	}
@@ -206,19 +222,19 @@ func RemoveFromMailList(mail_list models.MailList) error {
 		},
 	}
 
-	return db.Update("lists", selector, &modifier)
+	return db.WithContext(ctx).Update("lists", selector, &modifier)
 }
 
 /*
 	Gets the mail list with the given id
 */
-func GetMailList(id string) (*models.MailList, error) {
+func GetMailList(ctx context.Context, id string) (*models.MailList, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var mail_list models.MailList
-	err := db.FindOne("lists", query, &mail_list)
+	err := db.WithContext(ctx).FindOne("lists", query, &mail_list)
 
 	if err != nil {
 		return nil, err
@@ -228,21 +244,35 @@ func GetMailList(id string) (*models.MailList, error) {
 }
 
 /*
-	Gets all created mailing lists
+	Gets all created mailing lists, paginated by the given parameters
 */
-func GetAllMailLists() (*models.MailListList, error) {
+func GetAllMailLists(ctx context.Context, parameters map[string][]string) (*models.MailListList, error) {
+	pagination, err := database.ParsePaginationParameters(parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
 	var mail_lists []models.MailList
 
 	// nil in this case means that we return everything in the lists collection
-	err := db.FindAll("lists", nil, &mail_lists)
+	pagination_results, err := db.WithContext(ctx).FindAllPaginated("lists", nil, nil, *pagination, &mail_lists)
 
 	if err != nil {
 		return nil, err
 	}
 
 	mail_list_list := models.MailListList{
-		MailLists: mail_lists,
+		MailLists:  mail_lists,
+		Pagination: database.GetPageInfo(*pagination, pagination_results),
 	}
 
 	return &mail_list_list, nil
 }
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/services/mail/config"
@@ -72,7 +73,7 @@ func CleanupTestDB(t *testing.T) {
 func TestGetMailListService(t *testing.T) {
 	SetupTestDB(t)
 
-	mail_list, err := service.GetMailList("testlist")
+	mail_list, err := service.GetMailList(context.Background(), "testlist")
 
 	if err != nil {
 		t.Fatal(err)
@@ -101,13 +102,13 @@ func TestCreateMailListService(t *testing.T) {
 		UserIDs: []string{"userid1", "userid2"},
 	}
 
-	err := service.CreateMailList(mail_list)
+	err := service.CreateMailList(context.Background(), mail_list)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	retreived_list, err := service.GetMailList("testlist2")
+	retreived_list, err := service.GetMailList(context.Background(), "testlist2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -131,13 +132,13 @@ func TestAddToMailList(t *testing.T) {
 		UserIDs: []string{"userid3", "userid4"},
 	}
 
-	err := service.AddToMailList(mail_list)
+	err := service.AddToMailList(context.Background(), mail_list)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	retreived_list, err := service.GetMailList("testlist")
+	retreived_list, err := service.GetMailList(context.Background(), "testlist")
 
 	if err != nil {
 		t.Fatal(err)
@@ -166,13 +167,13 @@ func TestRemoveFromMailList(t *testing.T) {
 		UserIDs: []string{"userid2"},
 	}
 
-	err := service.RemoveFromMailList(mail_list)
+	err := service.RemoveFromMailList(context.Background(), mail_list)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	retreived_list, err := service.GetMailList("testlist")
+	retreived_list, err := service.GetMailList(context.Background(), "testlist")
 
 	if err != nil {
 		t.Fatal(err)
@@ -201,13 +202,13 @@ func TestGetAllMailLists(t *testing.T) {
 		UserIDs: []string{"userid1", "userid2"},
 	}
 
-	err := service.CreateMailList(mail_list)
+	err := service.CreateMailList(context.Background(), mail_list)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	mail_lists, err := service.GetAllMailLists()
+	mail_lists, err := service.GetAllMailLists(context.Background(), map[string][]string{})
 
 	if err != nil {
 		t.Fatal(err)
@@ -23,6 +23,8 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/topic/", CreateTopic, "POST", router)
 	metrics.RegisterHandler("/topic/all/", GetAllNotifications, "GET", router)
 	metrics.RegisterHandler("/topic/public/", GetAllPublicNotifications, "GET", router)
+	metrics.RegisterHandler("/topic/deleted/", GetDeletedTopics, "GET", router)
+	metrics.RegisterHandler("/topic/deleted/{id}/restore/", RestoreTopic, "POST", router)
 	metrics.RegisterHandler("/topic/{id}/", GetNotificationsForTopic, "GET", router)
 	metrics.RegisterHandler("/topic/{id}/", PublishNotificationToTopic, "POST", router)
 	metrics.RegisterHandler("/topic/{id}/", DeleteTopic, "DELETE", router)
@@ -30,13 +32,14 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/topic/{id}/unsubscribe/", UnsubscribeToTopic, "POST", router)
 	metrics.RegisterHandler("/device/", RegisterDeviceToUser, "POST", router)
 	metrics.RegisterHandler("/order/{id}/", GetNotificationOrder, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
 	Returns all topics that notifications can be published to
 */
 func GetAllTopics(w http.ResponseWriter, r *http.Request) {
-	topics, err := service.GetAllTopicIDs()
+	topics, err := service.GetAllTopicIDs(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not retrieve topics."))
@@ -66,14 +69,14 @@ func CreateTopic(w http.ResponseWriter, r *http.Request) {
 	var topic models.Topic
 	json.NewDecoder(r.Body).Decode(&topic)
 
-	err := service.CreateTopic(topic.ID)
+	err := service.CreateTopic(r.Context(), topic.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not create a new topic."))
 		return
 	}
 
-	created_topic, err := service.GetTopic(topic.ID)
+	created_topic, err := service.GetTopic(r.Context(), topic.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not retrieve topic."))
@@ -89,14 +92,14 @@ func CreateTopic(w http.ResponseWriter, r *http.Request) {
 func GetAllNotifications(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	topics, err := service.GetSubscriptions(id)
+	topics, err := service.GetSubscriptions(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not retrieve user subscriptions."))
 		return
 	}
 
-	notifications, err := service.GetAllNotifications(topics)
+	notifications, err := service.GetAllNotifications(r.Context(), topics)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not retrieve notifications."))
@@ -114,7 +117,7 @@ func GetAllNotifications(w http.ResponseWriter, r *http.Request) {
 	Returns all public notifications
 */
 func GetAllPublicNotifications(w http.ResponseWriter, r *http.Request) {
-	notifications, err := service.GetAllPublicNotifications()
+	notifications, err := service.GetAllPublicNotifications(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not retrieve notifications."))
@@ -134,7 +137,7 @@ func GetAllPublicNotifications(w http.ResponseWriter, r *http.Request) {
 func GetNotificationsForTopic(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	notifications, err := service.GetAllNotificationsForTopic(id)
+	notifications, err := service.GetAllNotificationsForTopic(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not retrieve notifications."))
@@ -161,7 +164,7 @@ func PublishNotificationToTopic(w http.ResponseWriter, r *http.Request) {
 	notification.ID = utils.GenerateUniqueID()
 	notification.Time = time.Now().Unix()
 
-	order, err := service.PublishNotificationToTopic(notification)
+	order, err := service.PublishNotificationToTopic(r.Context(), notification)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not publish notification."))
@@ -177,7 +180,7 @@ func PublishNotificationToTopic(w http.ResponseWriter, r *http.Request) {
 func DeleteTopic(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	err := service.DeleteTopic(id)
+	err := service.DeleteTopic(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not publish notification."))
@@ -187,6 +190,40 @@ func DeleteTopic(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{})
 }
 
+/*
+	Returns the ids of the topics which have been deleted but not yet purged
+*/
+func GetDeletedTopics(w http.ResponseWriter, r *http.Request) {
+	topics, err := service.GetDeletedTopicIDs(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not retrieve deleted topics."))
+		return
+	}
+
+	topic_list := models.TopicList{
+		Topics: topics,
+	}
+
+	json.NewEncoder(w).Encode(topic_list)
+}
+
+/*
+	Restores the specified deleted topic
+*/
+func RestoreTopic(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := service.RestoreTopic(r.Context(), id)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not restore topic."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{})
+}
+
 /*
 	Subscribes a user to the specied topic and returns their updated subscriptions
 */
@@ -194,14 +231,14 @@ func SubscribeToTopic(w http.ResponseWriter, r *http.Request) {
 	topicId := mux.Vars(r)["id"]
 	userId := r.Header.Get("HackIllinois-Identity")
 
-	err := service.SubscribeToTopic(userId, topicId)
+	err := service.SubscribeToTopic(r.Context(), userId, topicId)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Failed to subscribe user to topic."))
 		return
 	}
 
-	subscriptions, err := service.GetSubscriptions(userId)
+	subscriptions, err := service.GetSubscriptions(r.Context(), userId)
 
 	topic_list := models.TopicList{
 		Topics: subscriptions,
@@ -217,14 +254,14 @@ func UnsubscribeToTopic(w http.ResponseWriter, r *http.Request) {
 	topicId := mux.Vars(r)["id"]
 	userId := r.Header.Get("HackIllinois-Identity")
 
-	err := service.UnsubscribeToTopic(userId, topicId)
+	err := service.UnsubscribeToTopic(r.Context(), userId, topicId)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Failed to unsubscribe user to topic."))
 		return
 	}
 
-	subscriptions, err := service.GetSubscriptions(userId)
+	subscriptions, err := service.GetSubscriptions(r.Context(), userId)
 
 	topic_list := models.TopicList{
 		Topics: subscriptions,
@@ -242,14 +279,14 @@ func RegisterDeviceToUser(w http.ResponseWriter, r *http.Request) {
 	var device_registration models.DeviceRegistration
 	json.NewDecoder(r.Body).Decode(&device_registration)
 
-	err := service.RegisterDeviceToUser(device_registration.Token, device_registration.Platform, id)
+	err := service.RegisterDeviceToUser(r.Context(), device_registration.Token, device_registration.Platform, id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Failed to register device to user."))
 		return
 	}
 
-	devices, err := service.GetUserDevices(id)
+	devices, err := service.GetUserDevices(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Failed to retrieve user's devices."))
@@ -269,7 +306,7 @@ func RegisterDeviceToUser(w http.ResponseWriter, r *http.Request) {
 func GetNotificationOrder(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	order, err := service.GetNotificationOrder(id)
+	order, err := service.GetNotificationOrder(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not retrieve notification order."))
@@ -278,3 +315,17 @@ func GetNotificationOrder(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(order)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch notifications service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
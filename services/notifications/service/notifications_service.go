@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"strings"
@@ -21,12 +22,40 @@ var sess *session.Session
 var client *sns.SNS
 var db database.Database
 
+var purge_job *database.PurgeJob
+
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"topics": {
+		{Key: []string{"id"}, Unique: true},
+	},
+	"notifications": {
+		{Key: []string{"topic", "-time"}},
+	},
+	"users": {
+		{Key: []string{"id"}, Unique: true},
+	},
+	"orders": {
+		{Key: []string{"id"}, Unique: true},
+	},
+}
+
+/*
+	Collections whose items are soft deleted when soft deletion is enabled
+*/
+var soft_delete_collections = []string{"topics"}
+
 func Initialize() error {
 	sess = session.Must(session.NewSession(&aws.Config{
 		Region: aws.String(config.SNS_REGION),
 	}))
 	client = sns.New(sess)
 
+	purge_job.Stop()
+	purge_job = nil
+
 	if db != nil {
 		db.Close()
 		db = nil
@@ -39,15 +68,23 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
+	db, purge_job = database.EnableSoftDelete(db, soft_delete_collections)
+
 	return nil
 }
 
 /*
 	Returns a list of all topic ids
 */
-func GetAllTopicIDs() ([]string, error) {
+func GetAllTopicIDs(ctx context.Context) ([]string, error) {
 	var topics []models.Topic
-	err := db.FindAll("topics", nil, &topics)
+	err := db.WithContext(ctx).FindAll("topics", nil, &topics)
 
 	if err != nil {
 		return nil, err
@@ -65,13 +102,13 @@ func GetAllTopicIDs() ([]string, error) {
 /*
 	Returns the topic with the specified id
 */
-func GetTopic(id string) (*models.Topic, error) {
+func GetTopic(ctx context.Context, id string) (*models.Topic, error) {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
 	var topic models.Topic
-	err := db.FindOne("topics", selector, &topic)
+	err := db.WithContext(ctx).FindOne("topics", selector, &topic)
 
 	if err != nil {
 		return nil, err
@@ -83,8 +120,8 @@ func GetTopic(id string) (*models.Topic, error) {
 /*
 	Creates a topic
 */
-func CreateTopic(id string) error {
-	_, err := GetTopic(id)
+func CreateTopic(ctx context.Context, id string) error {
+	_, err := GetTopic(ctx, id)
 
 	if err != database.ErrNotFound {
 		if err != nil {
@@ -99,7 +136,7 @@ func CreateTopic(id string) error {
 		UserIDs: []string{},
 	}
 
-	err = db.Insert("topics", &topic)
+	err = db.WithContext(ctx).Insert("topics", &topic)
 
 	if err != nil {
 		return err
@@ -111,12 +148,12 @@ func CreateTopic(id string) error {
 /*
 	Deletes a topic
 */
-func DeleteTopic(id string) error {
+func DeleteTopic(ctx context.Context, id string) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	err := db.RemoveOne("topics", selector)
+	err := db.WithContext(ctx).RemoveOne("topics", selector)
 
 	if err != nil {
 		return err
@@ -125,16 +162,47 @@ func DeleteTopic(id string) error {
 	return nil
 }
 
+/*
+	Returns a list of the ids of the topics which have been deleted but not yet purged, most recently deleted first
+*/
+func GetDeletedTopicIDs(ctx context.Context) ([]string, error) {
+	var topics []models.Topic
+	err := database.FindAllDeleted(db.WithContext(ctx), "topics", nil, &topics)
+
+	if err != nil {
+		return nil, err
+	}
+
+	topicIds := make([]string, len(topics))
+
+	for i, topic := range topics {
+		topicIds[i] = topic.ID
+	}
+
+	return topicIds, nil
+}
+
+/*
+	Restores a deleted topic along with its subscriptions
+*/
+func RestoreTopic(ctx context.Context, id string) error {
+	selector := database.QuerySelector{
+		"id": id,
+	}
+
+	return database.RestoreOne(db.WithContext(ctx), "topics", selector)
+}
+
 /*
 	Returns all notification for the specified topic
 */
-func GetAllNotificationsForTopic(topic string) ([]models.Notification, error) {
+func GetAllNotificationsForTopic(ctx context.Context, topic string) ([]models.Notification, error) {
 	selector := database.QuerySelector{
 		"topic": topic,
 	}
 
 	var notifications []models.Notification
-	err := db.FindAll("notifications", selector, &notifications)
+	err := db.WithContext(ctx).FindAll("notifications", selector, &notifications)
 
 	if err != nil {
 		return nil, err
@@ -146,11 +214,11 @@ func GetAllNotificationsForTopic(topic string) ([]models.Notification, error) {
 /*
 	Returns all notifications for the specified topics
 */
-func GetAllNotifications(topics []string) ([]models.Notification, error) {
+func GetAllNotifications(ctx context.Context, topics []string) ([]models.Notification, error) {
 	notifications := make([]models.Notification, 0)
 
 	for _, topic := range topics {
-		topic_notifications, err := GetAllNotificationsForTopic(topic)
+		topic_notifications, err := GetAllNotificationsForTopic(ctx, topic)
 
 		if err != nil {
 			return nil, err
@@ -165,14 +233,14 @@ func GetAllNotifications(topics []string) ([]models.Notification, error) {
 /*
 	Returns all public notifications
 */
-func GetAllPublicNotifications() ([]models.Notification, error) {
-	return GetAllNotifications([]string{"User", "Attendee"})
+func GetAllPublicNotifications(ctx context.Context) ([]models.Notification, error) {
+	return GetAllNotifications(ctx, []string{"User", "Attendee"})
 }
 
 /*
 	Returns the list of topics the user is subscribed to
 */
-func GetSubscriptions(id string) ([]string, error) {
+func GetSubscriptions(ctx context.Context, id string) ([]string, error) {
 	selector := database.QuerySelector{
 		"userids": database.QuerySelector{
 			"$elemMatch": database.QuerySelector{
@@ -182,7 +250,7 @@ func GetSubscriptions(id string) ([]string, error) {
 	}
 
 	var topics []models.Topic
-	err := db.FindAll("topics", selector, &topics)
+	err := db.WithContext(ctx).FindAll("topics", selector, &topics)
 
 	if err != nil {
 		return nil, err
@@ -208,7 +276,7 @@ func GetSubscriptions(id string) ([]string, error) {
 /*
 	Subscribes the user to the specified topic
 */
-func SubscribeToTopic(userId string, topicId string) error {
+func SubscribeToTopic(ctx context.Context, userId string, topicId string) error {
 	selector := database.QuerySelector{
 		"id": topicId,
 	}
@@ -219,7 +287,7 @@ func SubscribeToTopic(userId string, topicId string) error {
 		},
 	}
 
-	err := db.Update("topics", selector, &modifier)
+	err := db.WithContext(ctx).Update("topics", selector, &modifier)
 
 	if err != nil {
 		return err
@@ -231,7 +299,7 @@ func SubscribeToTopic(userId string, topicId string) error {
 /*
 	Unsubscribes the user to the specified topic
 */
-func UnsubscribeToTopic(userId string, topicId string) error {
+func UnsubscribeToTopic(ctx context.Context, userId string, topicId string) error {
 	selector := database.QuerySelector{
 		"id": topicId,
 	}
@@ -242,7 +310,7 @@ func UnsubscribeToTopic(userId string, topicId string) error {
 		},
 	}
 
-	err := db.Update("topics", selector, &modifier)
+	err := db.WithContext(ctx).Update("topics", selector, &modifier)
 
 	if err != nil {
 		return err
@@ -254,17 +322,17 @@ func UnsubscribeToTopic(userId string, topicId string) error {
 /*
 	Gets the list of devices registered to a user
 */
-func GetUserDevices(id string) ([]string, error) {
+func GetUserDevices(ctx context.Context, id string) ([]string, error) {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
 	var user models.User
-	err := db.FindOne("users", selector, &user)
+	err := db.WithContext(ctx).FindOne("users", selector, &user)
 
 	if err != nil {
 		if err == database.ErrNotFound {
-			err = db.Insert("users", &models.User{
+			err = db.WithContext(ctx).Insert("users", &models.User{
 				ID:      id,
 				Devices: []string{},
 			})
@@ -273,7 +341,7 @@ func GetUserDevices(id string) ([]string, error) {
 				return nil, err
 			}
 
-			err = db.FindOne("users", selector, &user)
+			err = db.WithContext(ctx).FindOne("users", selector, &user)
 
 			if err != nil {
 				return nil, err
@@ -288,7 +356,7 @@ func GetUserDevices(id string) ([]string, error) {
 /*
 	Sets the list of devices registered to a user
 */
-func SetUserDevices(id string, devices []string) error {
+func SetUserDevices(ctx context.Context, id string, devices []string) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
@@ -298,7 +366,7 @@ func SetUserDevices(id string, devices []string) error {
 		Devices: devices,
 	}
 
-	err := db.Update("users", selector, &user)
+	err := db.WithContext(ctx).Update("users", selector, &user)
 
 	if err != nil {
 		return err
@@ -310,7 +378,7 @@ func SetUserDevices(id string, devices []string) error {
 /*
 	Registers the device token with SNS and stores the arn with the associated user
 */
-func RegisterDeviceToUser(token string, platform string, id string) error {
+func RegisterDeviceToUser(ctx context.Context, token string, platform string, id string) error {
 	var platform_arn string
 
 	switch strings.ToLower(platform) {
@@ -340,7 +408,7 @@ func RegisterDeviceToUser(token string, platform string, id string) error {
 		device_arn = *response.EndpointArn
 	}
 
-	devices, err := GetUserDevices(id)
+	devices, err := GetUserDevices(ctx, id)
 
 	if err != nil {
 		return err
@@ -350,7 +418,7 @@ func RegisterDeviceToUser(token string, platform string, id string) error {
 		devices = append(devices, device_arn)
 	}
 
-	err = SetUserDevices(id, devices)
+	err = SetUserDevices(ctx, id, devices)
 
 	if err != nil {
 		return err
@@ -362,8 +430,8 @@ func RegisterDeviceToUser(token string, platform string, id string) error {
 /*
 	Returns a list of userids to receive a notification to the specified topic
 */
-func GetNotificationRecipients(topicId string) ([]string, error) {
-	topic, err := GetTopic(topicId)
+func GetNotificationRecipients(ctx context.Context, topicId string) ([]string, error) {
+	topic, err := GetTopic(ctx, topicId)
 
 	if err != nil {
 		if err == database.ErrNotFound {
@@ -384,11 +452,11 @@ func GetNotificationRecipients(topicId string) ([]string, error) {
 /*
 	Returns a list of arns to receive a notification
 */
-func GetNotificationRecipientArns(userIds []string) ([]string, error) {
+func GetNotificationRecipientArns(ctx context.Context, userIds []string) ([]string, error) {
 	device_arns := make([]string, 0)
 
 	for _, userId := range userIds {
-		devices, err := GetUserDevices(userId)
+		devices, err := GetUserDevices(ctx, userId)
 
 		if err != nil {
 			return nil, err
@@ -403,13 +471,13 @@ func GetNotificationRecipientArns(userIds []string) ([]string, error) {
 /*
 	Returns the notification order with the specified id
 */
-func GetNotificationOrder(id string) (*models.NotificationOrder, error) {
+func GetNotificationOrder(ctx context.Context, id string) (*models.NotificationOrder, error) {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
 	var order models.NotificationOrder
-	err := db.FindOne("orders", selector, &order)
+	err := db.WithContext(ctx).FindOne("orders", selector, &order)
 
 	if err != nil {
 		return nil, err
@@ -421,20 +489,20 @@ func GetNotificationOrder(id string) (*models.NotificationOrder, error) {
 /*
 	Publishes a notification to the specified topic
 */
-func PublishNotificationToTopic(notification models.Notification) (*models.NotificationOrder, error) {
-	err := db.Insert("notifications", &notification)
+func PublishNotificationToTopic(ctx context.Context, notification models.Notification) (*models.NotificationOrder, error) {
+	err := db.WithContext(ctx).Insert("notifications", &notification)
 
 	if err != nil {
 		return nil, err
 	}
 
-	recipients, err := GetNotificationRecipients(notification.Topic)
+	recipients, err := GetNotificationRecipients(ctx, notification.Topic)
 
 	if err != nil {
 		return nil, err
 	}
 
-	device_arns, err := GetNotificationRecipientArns(recipients)
+	device_arns, err := GetNotificationRecipientArns(ctx, recipients)
 
 	if err != nil {
 		return nil, err
@@ -447,7 +515,8 @@ func PublishNotificationToTopic(notification models.Notification) (*models.Notif
 	}
 
 	if config.IS_PRODUCTION {
-		go PublishNotification(notification.ID, notification_payload, device_arns)
+		// Publishing continues after the response is sent, so it cannot use the request's context
+		go PublishNotification(context.Background(), notification.ID, notification_payload, device_arns)
 	}
 
 	order := models.NotificationOrder{
@@ -458,7 +527,7 @@ func PublishNotificationToTopic(notification models.Notification) (*models.Notif
 		Time:       notification.Time,
 	}
 
-	err = db.Insert("orders", &order)
+	err = db.WithContext(ctx).Insert("orders", &order)
 
 	if err != nil {
 		return nil, err
@@ -470,7 +539,7 @@ func PublishNotificationToTopic(notification models.Notification) (*models.Notif
 /*
 	Publishes the notification payload to all specified arns
 */
-func PublishNotification(id string, payload string, arns []string) error {
+func PublishNotification(ctx context.Context, id string, payload string, arns []string) error {
 	success_count := 0
 	failure_count := 0
 
@@ -499,7 +568,7 @@ func PublishNotification(id string, payload string, arns []string) error {
 
 	close(responses)
 
-	order, err := GetNotificationOrder(id)
+	order, err := GetNotificationOrder(ctx, id)
 
 	if err != nil {
 		return err
@@ -512,7 +581,7 @@ func PublishNotification(id string, payload string, arns []string) error {
 		"id": id,
 	}
 
-	err = db.Update("orders", selector, &order)
+	err = db.WithContext(ctx).Update("orders", selector, &order)
 
 	if err != nil {
 		return err
@@ -585,3 +654,10 @@ func GenerateNotificationJson(notification models.Notification) (string, error)
 
 	return string(notification_json), nil
 }
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
+}
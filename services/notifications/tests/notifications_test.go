@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"reflect"
@@ -100,7 +101,7 @@ func CleanupTestDB(t *testing.T) {
 func TestGetAllTopicIDs(t *testing.T) {
 	SetupTestDB(t)
 
-	topics, err := service.GetAllTopicIDs()
+	topics, err := service.GetAllTopicIDs(context.Background())
 
 	if err != nil {
 		t.Fatal(err)
@@ -121,7 +122,7 @@ func TestGetAllTopicIDs(t *testing.T) {
 func TestGetTopic(t *testing.T) {
 	SetupTestDB(t)
 
-	topic, err := service.GetTopic("User")
+	topic, err := service.GetTopic(context.Background(), "User")
 
 	if err != nil {
 		t.Fatal(err)
@@ -145,13 +146,13 @@ func TestGetTopic(t *testing.T) {
 func TestCreateTopic(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.CreateTopic("User2")
+	err := service.CreateTopic(context.Background(), "User2")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	topic, err := service.GetTopic("User2")
+	topic, err := service.GetTopic(context.Background(), "User2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -175,13 +176,13 @@ func TestCreateTopic(t *testing.T) {
 func TestDeleteTopic(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.DeleteTopic("User")
+	err := service.DeleteTopic(context.Background(), "User")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	_, err = service.GetTopic("User")
+	_, err = service.GetTopic(context.Background(), "User")
 
 	if err != database.ErrNotFound {
 		t.Fatal(err)
@@ -196,7 +197,7 @@ func TestDeleteTopic(t *testing.T) {
 func TestGetAllNotificationsForTopic(t *testing.T) {
 	SetupTestDB(t)
 
-	notifications, err := service.GetAllNotificationsForTopic("User")
+	notifications, err := service.GetAllNotificationsForTopic(context.Background(), "User")
 
 	if err != nil {
 		t.Fatal(err)
@@ -225,7 +226,7 @@ func TestGetAllNotificationsForTopic(t *testing.T) {
 func TestGetAllNotifications(t *testing.T) {
 	SetupTestDB(t)
 
-	notifications, err := service.GetAllNotifications([]string{"User"})
+	notifications, err := service.GetAllNotifications(context.Background(), []string{"User"})
 
 	if err != nil {
 		t.Fatal(err)
@@ -254,7 +255,7 @@ func TestGetAllNotifications(t *testing.T) {
 func TestGetAllPublicNotifications(t *testing.T) {
 	SetupTestDB(t)
 
-	notifications, err := service.GetAllPublicNotifications()
+	notifications, err := service.GetAllPublicNotifications(context.Background())
 
 	if err != nil {
 		t.Fatal(err)
@@ -283,7 +284,7 @@ func TestGetAllPublicNotifications(t *testing.T) {
 func TestSubscribeToTopic(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.SubscribeToTopic("test_user2", "User")
+	err := service.SubscribeToTopic(context.Background(), "test_user2", "User")
 
 	if err != nil {
 		t.Fatal(err)
@@ -298,7 +299,7 @@ func TestSubscribeToTopic(t *testing.T) {
 func TestUnsubscribeToTopic(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.UnsubscribeToTopic("test_user", "User")
+	err := service.UnsubscribeToTopic(context.Background(), "test_user", "User")
 
 	if err != nil {
 		t.Fatal(err)
@@ -313,7 +314,7 @@ func TestUnsubscribeToTopic(t *testing.T) {
 func TestGetUserDevices(t *testing.T) {
 	SetupTestDB(t)
 
-	devices, err := service.GetUserDevices("test_user")
+	devices, err := service.GetUserDevices(context.Background(), "test_user")
 
 	if err != nil {
 		t.Fatal(err)
@@ -334,13 +335,13 @@ func TestGetUserDevices(t *testing.T) {
 func TestSetUserDevices(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.SetUserDevices("test_user", []string{"test_arn", "test_arn2"})
+	err := service.SetUserDevices(context.Background(), "test_user", []string{"test_arn", "test_arn2"})
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	devices, err := service.GetUserDevices("test_user")
+	devices, err := service.GetUserDevices(context.Background(), "test_user")
 
 	if err != nil {
 		t.Fatal(err)
@@ -361,13 +362,13 @@ func TestSetUserDevices(t *testing.T) {
 func TestRegisterDeviceToUser(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.RegisterDeviceToUser("test_token", "android", "test_user")
+	err := service.RegisterDeviceToUser(context.Background(), "test_token", "android", "test_user")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	devices, err := service.GetUserDevices("test_user")
+	devices, err := service.GetUserDevices(context.Background(), "test_user")
 
 	if err != nil {
 		t.Fatal(err)
@@ -380,13 +381,13 @@ func TestRegisterDeviceToUser(t *testing.T) {
 	}
 
 	// Test deduplication
-	err = service.RegisterDeviceToUser("test_token", "android", "test_user")
+	err = service.RegisterDeviceToUser(context.Background(), "test_token", "android", "test_user")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	devices, err = service.GetUserDevices("test_user")
+	devices, err = service.GetUserDevices(context.Background(), "test_user")
 
 	if err != nil {
 		t.Fatal(err)
@@ -407,7 +408,7 @@ func TestRegisterDeviceToUser(t *testing.T) {
 func TestGetNotificationRecipients(t *testing.T) {
 	SetupTestDB(t)
 
-	userids, err := service.GetNotificationRecipients("User")
+	userids, err := service.GetNotificationRecipients(context.Background(), "User")
 
 	if err != nil {
 		t.Fatal(err)
@@ -428,7 +429,7 @@ func TestGetNotificationRecipients(t *testing.T) {
 func TestGetNotificationRecipientArns(t *testing.T) {
 	SetupTestDB(t)
 
-	arns, err := service.GetNotificationRecipientArns([]string{"test_user"})
+	arns, err := service.GetNotificationRecipientArns(context.Background(), []string{"test_user"})
 
 	if err != nil {
 		t.Fatal(err)
@@ -458,7 +459,7 @@ func TestPublishNotificationToTopic(t *testing.T) {
 	}
 
 	// Send notification to one user w/ one device
-	order, err := service.PublishNotificationToTopic(notification)
+	order, err := service.PublishNotificationToTopic(context.Background(), notification)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -494,7 +495,7 @@ func TestPublishNotificationToTopic(t *testing.T) {
 	}
 
 	// Send notification to two users w/ three total devices
-	order, err = service.PublishNotificationToTopic(notification)
+	order, err = service.PublishNotificationToTopic(context.Background(), notification)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/common/errors"
 	"github.com/HackIllinois/api/common/metrics"
 	"github.com/HackIllinois/api/common/utils"
@@ -35,9 +36,12 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/favorite/", AddProfileFavorite, "POST", router)
 	metrics.RegisterHandler("/favorite/", RemoveProfileFavorite, "DELETE", router)
 
+	metrics.RegisterHandler("/deleted/", GetDeletedProfiles, "GET", router)
+	metrics.RegisterHandler("/deleted/{id}/restore/", RestoreProfile, "POST", router)
 	metrics.RegisterHandler("/{id}/", GetProfileById, "GET", router)
 
 	metrics.RegisterHandler("/tier/threshold/", GetTierThresholds, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -46,14 +50,14 @@ func SetupController(route *mux.Route) {
 func GetProfile(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	profile_id, err := service.GetProfileIdFromUserId(id)
+	profile_id, err := service.GetProfileIdFromUserId(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get profile id associated with the user"))
 		return
 	}
 
-	user_profile, err := service.GetProfile(profile_id)
+	user_profile, err := service.GetProfile(r.Context(), profile_id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get current user's profile."))
@@ -69,7 +73,7 @@ func GetProfile(w http.ResponseWriter, r *http.Request) {
 func GetProfileById(w http.ResponseWriter, r *http.Request) {
 	profile_id := mux.Vars(r)["id"]
 
-	user_profile, err := service.GetProfile(profile_id)
+	user_profile, err := service.GetProfile(r.Context(), profile_id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get profile for profile id "+profile_id))
@@ -90,7 +94,7 @@ func CreateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	profile_id, err := service.GetProfileIdFromUserId(id)
+	profile_id, err := service.GetProfileIdFromUserId(r.Context(), id)
 
 	if err == nil {
 		errors.WriteError(w, r, errors.DatabaseError("", "User already has a profile with profile id "+profile_id))
@@ -102,14 +106,14 @@ func CreateProfile(w http.ResponseWriter, r *http.Request) {
 	var profile models.Profile
 	json.NewDecoder(r.Body).Decode(&profile)
 
-	err = service.CreateProfile(id, profile_id, profile)
+	err = service.CreateProfile(r.Context(), id, profile_id, profile)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not create new profile."))
 		return
 	}
 
-	created_profile, err := service.GetProfile(profile_id)
+	created_profile, err := service.GetProfile(r.Context(), profile_id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get created profile."))
@@ -130,7 +134,7 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	profile_id, err := service.GetProfileIdFromUserId(id)
+	profile_id, err := service.GetProfileIdFromUserId(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get profile id associated with the user"))
@@ -140,7 +144,7 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	var profile models.Profile
 	json.NewDecoder(r.Body).Decode(&profile)
 
-	old_profile, err := service.GetProfile(profile_id)
+	old_profile, err := service.GetProfile(r.Context(), profile_id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get profile associated with this profile id."))
@@ -151,14 +155,14 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		profile.Points = old_profile.Points
 	}
 
-	err = service.UpdateProfile(profile_id, profile)
+	err = service.UpdateProfile(r.Context(), profile_id, profile)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not update the profile."))
 		return
 	}
 
-	updated_profile, err := service.GetProfile(profile_id)
+	updated_profile, err := service.GetProfile(r.Context(), profile_id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated profile details."))
@@ -197,13 +201,44 @@ func DeleteProfile(w http.ResponseWriter, r *http.Request) {
 	errors.WriteError(w, r, errors.InternalError("Endpoint temporarily disabled.", "Endpoint temporarily disabled."))
 }
 
+/*
+	GetDeletedProfiles is the endpoint to get the profiles which have been deleted but not yet purged
+*/
+func GetDeletedProfiles(w http.ResponseWriter, r *http.Request) {
+	profile_list, err := service.GetDeletedProfiles(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get the deleted profiles."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(profile_list)
+}
+
+/*
+	RestoreProfile is the endpoint to restore the deleted profile with the specified id, along with its attendance and favorites.
+	On successful restoration, it returns the profile that was restored.
+*/
+func RestoreProfile(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	profile, err := service.RestoreProfile(r.Context(), id)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not restore the profile."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(profile)
+}
+
 /*
 	GetProfileLeaderboard is the endpoint used to return a list of profiles, sorted by the amount of points they have (descending).
 */
 func GetProfileLeaderboard(w http.ResponseWriter, r *http.Request) {
 	parameters := r.URL.Query()
 
-	user_profile_list, err := service.GetProfileLeaderboard(parameters)
+	user_profile_list, err := service.GetProfileLeaderboard(r.Context(), parameters)
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get the profile leaderboard."))
 		return
@@ -218,13 +253,24 @@ func GetProfileLeaderboard(w http.ResponseWriter, r *http.Request) {
 func GetFilteredProfiles(w http.ResponseWriter, r *http.Request) {
 	parameters := r.URL.Query()
 
-	filtered_profile_list, err := service.GetFilteredProfiles(parameters)
+	filtered_profile_list, err := service.GetFilteredProfiles(r.Context(), parameters)
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Invalid filter or sort parameters."))
+			return
+		}
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get the filtered profiles."))
 		return
 	}
 
-	json.NewEncoder(w).Encode(filtered_profile_list)
+	response, err := database.ProjectResponse(filtered_profile_list, r.URL.Query())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not select the requested fields."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 /*
@@ -233,13 +279,24 @@ func GetFilteredProfiles(w http.ResponseWriter, r *http.Request) {
 func GetValidFilteredProfiles(w http.ResponseWriter, r *http.Request) {
 	parameters := r.URL.Query()
 
-	filtered_profile_list, err := service.GetValidFilteredProfiles(parameters)
+	filtered_profile_list, err := service.GetValidFilteredProfiles(r.Context(), parameters)
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Invalid filter or sort parameters."))
+			return
+		}
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get the valid filtered profiles."))
 		return
 	}
 
-	json.NewEncoder(w).Encode(filtered_profile_list)
+	response, err := database.ProjectResponse(filtered_profile_list, r.URL.Query())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not select the requested fields."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 /*
@@ -252,14 +309,14 @@ func RedeemEvent(w http.ResponseWriter, r *http.Request) {
 
 	id := request.ID
 
-	profile_id, err := service.GetProfileIdFromUserId(id)
+	profile_id, err := service.GetProfileIdFromUserId(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get profile id associated with the user"))
 		return
 	}
 
-	redemption_status, err := service.RedeemEvent(profile_id, request.EventID)
+	redemption_status, err := service.RedeemEvent(r.Context(), profile_id, request.EventID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not check if event was redeemed for id "+request.ID+" and event id "+request.EventID+". "+redemption_status.Status))
@@ -278,33 +335,17 @@ func AwardPoints(w http.ResponseWriter, r *http.Request) {
 
 	id := request.ID
 
-	profile_id, err := service.GetProfileIdFromUserId(id)
+	profile_id, err := service.GetProfileIdFromUserId(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get profile id associated with the user"))
 		return
 	}
 
-	user_profile, err := service.GetProfile(profile_id)
-
-	if err != nil {
-		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get profile for id "+request.ID+" when trying to award points."))
-		return
-	}
-
-	user_profile.Points += request.Points
-
-	err = service.UpdateProfile(profile_id, *user_profile)
-
-	if err != nil {
-		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not update the profile when trying to award points."))
-		return
-	}
-
-	updated_profile, err := service.GetProfile(profile_id)
+	updated_profile, err := service.AwardPoints(r.Context(), profile_id, request.Points)
 
 	if err != nil {
-		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated profile details after awarding points."))
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not award points to the profile for id "+request.ID+"."))
 		return
 	}
 
@@ -322,14 +363,14 @@ func GetProfileFavorites(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	profile_id, err := service.GetProfileIdFromUserId(id)
+	profile_id, err := service.GetProfileIdFromUserId(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get profile id associated with the user"))
 		return
 	}
 
-	favorites, err := service.GetProfileFavorites(profile_id)
+	favorites, err := service.GetProfileFavorites(r.Context(), profile_id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user's profile favorites."))
@@ -350,7 +391,7 @@ func AddProfileFavorite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	profile_id, err := service.GetProfileIdFromUserId(id)
+	profile_id, err := service.GetProfileIdFromUserId(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get profile id associated with the user"))
@@ -360,14 +401,14 @@ func AddProfileFavorite(w http.ResponseWriter, r *http.Request) {
 	var profile_favorite_modification models.ProfileFavoriteModification
 	json.NewDecoder(r.Body).Decode(&profile_favorite_modification)
 
-	err = service.AddProfileFavorite(profile_id, profile_favorite_modification.ID)
+	err = service.AddProfileFavorite(r.Context(), profile_id, profile_favorite_modification.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not add a profile favorite for the current user."))
 		return
 	}
 
-	favorites, err := service.GetProfileFavorites(profile_id)
+	favorites, err := service.GetProfileFavorites(r.Context(), profile_id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated user profile favorites."))
@@ -388,7 +429,7 @@ func RemoveProfileFavorite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	profile_id, err := service.GetProfileIdFromUserId(id)
+	profile_id, err := service.GetProfileIdFromUserId(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get profile id associated with the user"))
@@ -398,14 +439,14 @@ func RemoveProfileFavorite(w http.ResponseWriter, r *http.Request) {
 	var profile_favorite_modification models.ProfileFavoriteModification
 	json.NewDecoder(r.Body).Decode(&profile_favorite_modification)
 
-	err = service.RemoveProfileFavorite(profile_id, profile_favorite_modification.ID)
+	err = service.RemoveProfileFavorite(r.Context(), profile_id, profile_favorite_modification.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not remove a profile favorite for the current user."))
 		return
 	}
 
-	favorites, err := service.GetProfileFavorites(profile_id)
+	favorites, err := service.GetProfileFavorites(r.Context(), profile_id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated user profile favorites."))
@@ -421,3 +462,17 @@ func RemoveProfileFavorite(w http.ResponseWriter, r *http.Request) {
 func GetTierThresholds(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(config.TIER_THRESHOLDS)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch profile service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
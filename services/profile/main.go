@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"github.com/HackIllinois/api/common/apiserver"
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/services/profile/config"
 	"github.com/HackIllinois/api/services/profile/controller"
 	"github.com/HackIllinois/api/services/profile/service"
@@ -27,6 +28,19 @@ func Initialize() error {
 	return nil
 }
 
+/*
+	Runs the service's database migrations as described by options instead of starting the service
+*/
+func Migrate(options database.MigrationOptions) ([]database.Migration, error) {
+	err := Initialize()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return service.Migrate(options)
+}
+
 func Entry() {
 	err := Initialize()
 
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
 type LeaderboardEntryList struct {
 	LeaderboardEntries []LeaderboardEntry `json:"profiles"`
+	Pagination         *database.PageInfo `json:"pagination,omitempty"`
 }
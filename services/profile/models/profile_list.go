@@ -1,5 +1,10 @@
 package models
 
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
 type ProfileList struct {
-	Profiles []Profile `json:"profiles"`
+	Profiles   []Profile          `json:"profiles"`
+	Pagination *database.PageInfo `json:"pagination,omitempty"`
 }
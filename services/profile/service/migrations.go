@@ -0,0 +1,29 @@
+package service
+
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
+/*
+	Migrations which are run on the service's database with the -migrate flag, in order of version
+*/
+var migrations = database.Migrations{
+	{
+		Version:     1,
+		Description: "Backfill discord and avatar url on profiles created before they were added",
+		Up: func(db database.Database) error {
+			return database.BackfillFields(db, "profiles", database.QuerySelector{"discord": "", "avatarurl": ""})
+		},
+		// Destructive, as this also unsets the fields on profiles which were saved with their default values after the backfill
+		Down: func(db database.Database) error {
+			return database.UnsetDefaultFields(db, "profiles", database.QuerySelector{"discord": "", "avatarurl": ""})
+		},
+	},
+}
+
+/*
+	Applies the service's pending migrations or rolls back its applied migrations as described by options
+*/
+func Migrate(options database.MigrationOptions) ([]database.Migration, error) {
+	return database.Migrate(db, migrations, options)
+}
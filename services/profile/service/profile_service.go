@@ -1,11 +1,10 @@
 package service
 
 import (
+	"context"
 	"errors"
-	"strconv"
 
 	"github.com/HackIllinois/api/common/database"
-	"github.com/HackIllinois/api/common/utils"
 	"github.com/HackIllinois/api/services/profile/config"
 	"github.com/HackIllinois/api/services/profile/models"
 	"github.com/go-playground/validator/v10"
@@ -15,7 +14,38 @@ var validate *validator.Validate
 
 var db database.Database
 
+var purge_job *database.PurgeJob
+
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"profiles": {
+		{Key: []string{"id"}, Unique: true},
+		{Key: []string{"-points"}},
+		{Key: []string{"$text:firstname", "$text:lastname", "$text:discord"}},
+	},
+	"profileids": {
+		{Key: []string{"userid"}, Unique: true},
+		{Key: []string{"profileid"}, Unique: true},
+	},
+	"profileattendance": {
+		{Key: []string{"id"}, Unique: true},
+	},
+	"profilefavorites": {
+		{Key: []string{"id"}, Unique: true},
+	},
+}
+
+/*
+	Collections whose items are soft deleted when soft deletion is enabled
+*/
+var soft_delete_collections = []string{"profileids", "profiles", "profileattendance", "profilefavorites"}
+
 func Initialize() error {
+	purge_job.Stop()
+	purge_job = nil
+
 	if db != nil {
 		db.Close()
 		db = nil
@@ -28,6 +58,14 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
+	db, purge_job = database.EnableSoftDelete(db, soft_delete_collections)
+
 	validate = validator.New()
 
 	return nil
@@ -36,13 +74,13 @@ func Initialize() error {
 /*
 	Returns the profile id associated with the given user id
 */
-func GetProfileIdFromUserId(id string) (string, error) {
+func GetProfileIdFromUserId(ctx context.Context, id string) (string, error) {
 	query := database.QuerySelector{
 		"userid": id,
 	}
 
 	var id_map models.IdMap
-	err := db.FindOne("profileids", query, &id_map)
+	err := db.WithContext(ctx).FindOne("profileids", query, &id_map)
 
 	// Returns error if no mapping was found
 	if err != nil {
@@ -55,13 +93,13 @@ func GetProfileIdFromUserId(id string) (string, error) {
 /*
 	Returns the profile with the given id
 */
-func GetProfile(profile_id string) (*models.Profile, error) {
+func GetProfile(ctx context.Context, profile_id string) (*models.Profile, error) {
 	query := database.QuerySelector{
 		"id": profile_id,
 	}
 
 	var profile models.Profile
-	err := db.FindOne("profiles", query, &profile)
+	err := db.WithContext(ctx).FindOne("profiles", query, &profile)
 
 	if err != nil {
 		return nil, err
@@ -75,9 +113,9 @@ func GetProfile(profile_id string) (*models.Profile, error) {
 	Removes the profile from profile trackers and every user's tracker.
 	Returns the profile that was deleted.
 */
-func DeleteProfile(profile_id string) (*models.Profile, error) {
+func DeleteProfile(ctx context.Context, profile_id string) (*models.Profile, error) {
 	// Gets profile to be able to return it later
-	profile, err := GetProfile(profile_id)
+	profile, err := GetProfile(ctx, profile_id)
 
 	if err != nil {
 		return nil, err
@@ -88,7 +126,7 @@ func DeleteProfile(profile_id string) (*models.Profile, error) {
 		"profileid": profile_id,
 	}
 
-	err = db.RemoveOne("profileids", query)
+	err = db.WithContext(ctx).RemoveOne("profileids", query)
 
 	if err != nil {
 		return nil, err
@@ -99,19 +137,19 @@ func DeleteProfile(profile_id string) (*models.Profile, error) {
 		"id": profile_id,
 	}
 
-	err = db.RemoveOne("profiles", query)
+	err = db.WithContext(ctx).RemoveOne("profiles", query)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.RemoveOne("profileattendance", query)
+	err = db.WithContext(ctx).RemoveOne("profileattendance", query)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.RemoveOne("profilefavorites", query)
+	err = db.WithContext(ctx).RemoveOne("profilefavorites", query)
 
 	if err != nil {
 		return nil, err
@@ -120,10 +158,69 @@ func DeleteProfile(profile_id string) (*models.Profile, error) {
 	return profile, err
 }
 
+/*
+	Returns the profiles which have been deleted but not yet purged, most recently deleted first
+*/
+func GetDeletedProfiles(ctx context.Context) (*models.ProfileList, error) {
+	profiles := []models.Profile{}
+	err := database.FindAllDeleted(db.WithContext(ctx), "profiles", nil, &profiles)
+
+	if err != nil {
+		return nil, err
+	}
+
+	profile_list := models.ProfileList{
+		Profiles: profiles,
+	}
+
+	return &profile_list, nil
+}
+
+/*
+	Restores the deleted profile with the given id.
+	Restores the user id to profile id mapping, attendance, and favorites deleted with the profile.
+	Returns the restored profile.
+*/
+func RestoreProfile(ctx context.Context, profile_id string) (*models.Profile, error) {
+	query := database.QuerySelector{
+		"id": profile_id,
+	}
+
+	err := database.RestoreOne(db.WithContext(ctx), "profiles", query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = database.RestoreAll(db.WithContext(ctx), "profileattendance", query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = database.RestoreAll(db.WithContext(ctx), "profilefavorites", query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id_query := database.QuerySelector{
+		"profileid": profile_id,
+	}
+
+	_, err = database.RestoreAll(db.WithContext(ctx), "profileids", id_query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return GetProfile(ctx, profile_id)
+}
+
 /*
 	Creates a profile with the given id
 */
-func CreateProfile(id string, profile_id string, profile models.Profile) error {
+func CreateProfile(ctx context.Context, id string, profile_id string, profile models.Profile) error {
 	profile.ID = profile_id
 	err := validate.Struct(profile)
 
@@ -131,7 +228,7 @@ func CreateProfile(id string, profile_id string, profile models.Profile) error {
 		return err
 	}
 
-	_, err = GetProfile(profile_id)
+	_, err = GetProfile(ctx, profile_id)
 
 	if err != database.ErrNotFound {
 		if err != nil {
@@ -147,13 +244,13 @@ func CreateProfile(id string, profile_id string, profile models.Profile) error {
 	id_map.UserID = id
 	id_map.ProfileID = profile_id
 
-	err = db.Insert("profileids", &id_map)
+	err = db.WithContext(ctx).Insert("profileids", &id_map)
 
 	if err != nil {
 		return err
 	}
 
-	err = db.Insert("profiles", &profile)
+	err = db.WithContext(ctx).Insert("profiles", &profile)
 
 	if err != nil {
 		return err
@@ -164,7 +261,7 @@ func CreateProfile(id string, profile_id string, profile models.Profile) error {
 		Events: []string{},
 	}
 
-	err = db.Insert("profileattendance", &attendance_tracker)
+	err = db.WithContext(ctx).Insert("profileattendance", &attendance_tracker)
 
 	if err != nil {
 		return err
@@ -175,7 +272,7 @@ func CreateProfile(id string, profile_id string, profile models.Profile) error {
 		Profiles: []string{},
 	}
 
-	err = db.Insert("profilefavorites", &profile_favorites)
+	err = db.WithContext(ctx).Insert("profilefavorites", &profile_favorites)
 
 	if err != nil {
 		return err
@@ -187,7 +284,7 @@ func CreateProfile(id string, profile_id string, profile models.Profile) error {
 /*
 	Updates the profile with the given id
 */
-func UpdateProfile(profile_id string, profile models.Profile) error {
+func UpdateProfile(ctx context.Context, profile_id string, profile models.Profile) error {
 	profile.ID = profile_id
 	err := validate.Struct(profile)
 
@@ -199,7 +296,7 @@ func UpdateProfile(profile_id string, profile models.Profile) error {
 		"id": profile_id,
 	}
 
-	err = db.Update("profiles", selector, &profile)
+	err = db.WithContext(ctx).Update("profiles", selector, &profile)
 
 	return err
 }
@@ -208,17 +305,11 @@ func UpdateProfile(profile_id string, profile models.Profile) error {
 	Returns a list of "limit" profiles sorted decesending by points.
 	If "limit" is not provided, this will return a list of all profiles.
 */
-func GetProfileLeaderboard(parameters map[string][]string) (*models.LeaderboardEntryList, error) {
-	limit_param, ok := parameters["limit"]
-
-	if !ok {
-		limit_param = []string{"0"}
-	}
-
-	limit, err := strconv.Atoi(limit_param[0])
+func GetProfileLeaderboard(ctx context.Context, parameters map[string][]string) (*models.LeaderboardEntryList, error) {
+	pagination, err := database.ParsePaginationParameters(parameters)
 
 	if err != nil {
-		return nil, errors.New("Could not convert 'limit' to int.")
+		return nil, err
 	}
 
 	leaderboard_entries := []models.LeaderboardEntry{}
@@ -228,19 +319,15 @@ func GetProfileLeaderboard(parameters map[string][]string) (*models.LeaderboardE
 		Reversed: true,
 	}
 
-	err = db.FindAllSorted("profiles", nil, []database.SortField{sort_field}, &leaderboard_entries)
+	pagination_results, err := db.WithContext(ctx).FindAllPaginated("profiles", nil, []database.SortField{sort_field}, *pagination, &leaderboard_entries)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if limit > 0 {
-		limit = utils.Min(limit, len(leaderboard_entries))
-		leaderboard_entries = leaderboard_entries[:limit]
-	}
-
 	leaderboard_entry_list := models.LeaderboardEntryList{
 		LeaderboardEntries: leaderboard_entries,
+		Pagination:         database.GetPageInfo(*pagination, pagination_results),
 	}
 
 	return &leaderboard_entry_list, nil
@@ -249,21 +336,31 @@ func GetProfileLeaderboard(parameters map[string][]string) (*models.LeaderboardE
 /*
 	Returns a list of profiles filtered upon teamStatus and interests. Will be limited to only include the first "limit" results.
 */
-func GetFilteredProfiles(parameters map[string][]string) (*models.ProfileList, error) {
-	limit_param, ok := parameters["limit"]
+func GetFilteredProfiles(ctx context.Context, parameters map[string][]string) (*models.ProfileList, error) {
+	// Remove pagination parameters from parameters before querying db
+	pagination, err := database.ParsePaginationParameters(parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	projection, err := database.ParseProjectionParameters(parameters, models.Profile{})
 
-	if !ok {
-		limit_param = []string{"0"}
+	if err != nil {
+		return nil, err
 	}
 
-	limit, err := strconv.Atoi(limit_param[0])
+	sort_fields, err := database.ParseSortParameters(parameters, models.Profile{})
 
 	if err != nil {
-		return nil, errors.New("Could not convert 'limit' to int.")
+		return nil, err
 	}
 
-	// Remove "limit" from parameters before querying db
-	delete(parameters, "limit")
+	search_text, err := database.ParseSearchParameter(parameters)
+
+	if err != nil {
+		return nil, err
+	}
 
 	query, err := database.CreateFilterQuery(parameters, models.Profile{})
 
@@ -272,7 +369,7 @@ func GetFilteredProfiles(parameters map[string][]string) (*models.ProfileList, e
 	}
 
 	profiles := []models.Profile{}
-	err = db.FindAll("profiles", query, &profiles)
+	pagination_results, err := database.FindAllSearched(db.WithContext(ctx), "profiles", search_text, query, projection, sort_fields, *pagination, &profiles)
 
 	if err != nil {
 		return nil, err
@@ -280,13 +377,9 @@ func GetFilteredProfiles(parameters map[string][]string) (*models.ProfileList, e
 
 	// TODO: add some kind of recommendation sort/metric here
 
-	if limit > 0 {
-		limit = utils.Min(limit, len(profiles))
-		profiles = profiles[:limit]
-	}
-
 	profile_list := models.ProfileList{
-		Profiles: profiles,
+		Profiles:   profiles,
+		Pagination: database.GetPageInfo(*pagination, pagination_results),
 	}
 
 	return &profile_list, nil
@@ -296,10 +389,13 @@ func GetFilteredProfiles(parameters map[string][]string) (*models.ProfileList, e
 	Returns a list of profiles filtered upon teamStatus and interests. Will be limited to only include the first "limit" results.
 	Will also remove profiles with a TeamStatus set to "NOT_LOOKING"
 */
-func GetValidFilteredProfiles(parameters map[string][]string) (*models.ProfileList, error) {
-	filtered_profile_list, err := GetFilteredProfiles(parameters)
+func GetValidFilteredProfiles(ctx context.Context, parameters map[string][]string) (*models.ProfileList, error) {
+	filtered_profile_list, err := GetFilteredProfiles(ctx, parameters)
 
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			return nil, err
+		}
 		return nil, errors.New("Could not get filtered profiles")
 	}
 
@@ -308,8 +404,10 @@ func GetValidFilteredProfiles(parameters map[string][]string) (*models.ProfileLi
 
 /*
   Redeems the event with `event_id` for the user with profile id `id`
+  The event is only added to the user's attendance tracker if it has not already been redeemed,
+  so concurrent redemptions of the same event cannot both succeed
 */
-func RedeemEvent(profile_id string, event_id string) (*models.RedeemEventResponse, error) {
+func RedeemEvent(ctx context.Context, profile_id string, event_id string) (*models.RedeemEventResponse, error) {
 	var redemption_status models.RedeemEventResponse
 	redemption_status.Status = "Success"
 
@@ -317,49 +415,87 @@ func RedeemEvent(profile_id string, event_id string) (*models.RedeemEventRespons
 		"id": profile_id,
 	}
 
-	var attended_events models.AttendanceTracker
-	err := db.FindOne("profileattendance", selector, &attended_events)
+	condition := database.QuerySelector{
+		"events": database.QuerySelector{
+			"$ne": event_id,
+		},
+	}
+
+	modifier := database.QuerySelector{
+		"$addToSet": database.QuerySelector{
+			"events": event_id,
+		},
+	}
+
+	was_redeemed, err := db.WithContext(ctx).UpdateIfMatches("profileattendance", selector, condition, &modifier)
 
-	if err != nil {
-		if err == database.ErrNotFound {
-			attended_events = models.AttendanceTracker{
-				ID:     profile_id,
-				Events: []string{},
-			}
-			err = db.Insert("profileattendance", &attended_events)
+	if err == database.ErrNotFound {
+		// Create an empty tracker if it does not exist, then try to redeem the event again
+		tracker_modifier := database.QuerySelector{
+			"$addToSet": database.QuerySelector{
+				"events": database.QuerySelector{
+					"$each": []string{},
+				},
+			},
+		}
+
+		_, err = db.WithContext(ctx).Upsert("profileattendance", selector, &tracker_modifier)
 
-			if err != nil {
-				redemption_status.Status = "Could not add tracker to db"
-				return &redemption_status, err
-			}
-		} else {
-			redemption_status.Status = "Could not access db"
+		if err != nil {
+			redemption_status.Status = "Could not add tracker to db"
 			return &redemption_status, err
 		}
+
+		was_redeemed, err = db.WithContext(ctx).UpdateIfMatches("profileattendance", selector, condition, &modifier)
 	}
 
-	if utils.ContainsString(attended_events.Events, event_id) {
+	if err != nil {
+		redemption_status.Status = "Could not access db"
+		return &redemption_status, err
+	}
+
+	if !was_redeemed {
 		redemption_status.Status = "Event already redeemed"
-		return &redemption_status, nil
-	} else {
-		attended_events.Events = append(attended_events.Events, event_id)
 	}
 
-	err = db.Update("profileattendance", selector, attended_events)
+	return &redemption_status, nil
+}
 
-	return &redemption_status, err
+/*
+	Atomically adds the given number of points to the profile with the given id
+	Returns the updated profile
+*/
+func AwardPoints(ctx context.Context, profile_id string, points int) (*models.Profile, error) {
+	selector := database.QuerySelector{
+		"id": profile_id,
+	}
+
+	modifier := database.QuerySelector{
+		"$inc": database.QuerySelector{
+			"points": points,
+		},
+	}
+
+	var profile models.Profile
+	err := db.WithContext(ctx).FindAndModify("profiles", selector, &modifier, false, &profile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
 }
 
 /*
 	Returns the profile favorites for the user with the given id
 */
-func GetProfileFavorites(profile_id string) (*models.ProfileFavorites, error) {
+func GetProfileFavorites(ctx context.Context, profile_id string) (*models.ProfileFavorites, error) {
 	query := database.QuerySelector{
 		"id": profile_id,
 	}
 
 	var profile_favorites models.ProfileFavorites
-	err := db.FindOne("profilefavorites", query, &profile_favorites)
+	err := db.WithContext(ctx).FindOne("profilefavorites", query, &profile_favorites)
 
 	if err != nil {
 		return nil, err
@@ -371,7 +507,7 @@ func GetProfileFavorites(profile_id string) (*models.ProfileFavorites, error) {
 /*
 	Adds the given profile to the favorites for the user with the given id
 */
-func AddProfileFavorite(profile_id string, profile string) error {
+func AddProfileFavorite(ctx context.Context, profile_id string, profile string) error {
 	if profile_id == profile {
 		return errors.New("User's profile matches the specified profile.")
 	}
@@ -380,23 +516,19 @@ func AddProfileFavorite(profile_id string, profile string) error {
 		"id": profile_id,
 	}
 
-	_, err := GetProfile(profile)
+	_, err := GetProfile(ctx, profile)
 
 	if err != nil {
 		return errors.New("Could not find profile with the given id.")
 	}
 
-	profile_favorites, err := GetProfileFavorites(profile_id)
-
-	if err != nil {
-		return err
-	}
-
-	if !utils.ContainsString(profile_favorites.Profiles, profile) {
-		profile_favorites.Profiles = append(profile_favorites.Profiles, profile)
+	modifier := database.QuerySelector{
+		"$addToSet": database.QuerySelector{
+			"profiles": profile,
+		},
 	}
 
-	err = db.Update("profilefavorites", selector, profile_favorites)
+	err = db.WithContext(ctx).Update("profilefavorites", selector, &modifier)
 
 	return err
 }
@@ -404,24 +536,37 @@ func AddProfileFavorite(profile_id string, profile string) error {
 /*
 	Removes the given profile from the favorites for the user with the given id
 */
-func RemoveProfileFavorite(profile_id string, profile string) error {
+func RemoveProfileFavorite(ctx context.Context, profile_id string, profile string) error {
 	selector := database.QuerySelector{
 		"id": profile_id,
 	}
 
-	profile_favorites, err := GetProfileFavorites(profile_id)
+	condition := database.QuerySelector{
+		"profiles": profile,
+	}
 
-	if err != nil {
-		return err
+	modifier := database.QuerySelector{
+		"$pull": database.QuerySelector{
+			"profiles": profile,
+		},
 	}
 
-	profile_favorites.Profiles, err = utils.RemoveString(profile_favorites.Profiles, profile)
+	was_removed, err := db.WithContext(ctx).UpdateIfMatches("profilefavorites", selector, condition, &modifier)
 
 	if err != nil {
+		return err
+	}
+
+	if !was_removed {
 		return errors.New("User's profile favorites does not have specified profile")
 	}
 
-	err = db.Update("profilefavorites", selector, profile_favorites)
+	return nil
+}
 
-	return err
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
 }
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -137,7 +138,7 @@ func TestGetAllProfilesService(t *testing.T) {
 
 	parameters := map[string][]string{}
 
-	actual_profile_list, err := service.GetFilteredProfiles(parameters)
+	actual_profile_list, err := service.GetFilteredProfiles(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -172,7 +173,7 @@ func TestGetAllProfilesService(t *testing.T) {
 
 	db.RemoveAll("profiles", nil)
 
-	actual_profile_list, err = service.GetFilteredProfiles(parameters)
+	actual_profile_list, err = service.GetFilteredProfiles(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -196,7 +197,7 @@ func TestGetAllProfilesService(t *testing.T) {
 func TestGetProfileService(t *testing.T) {
 	SetupTestDB(t)
 
-	profile, err := service.GetProfile("testid")
+	profile, err := service.GetProfile(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -235,13 +236,13 @@ func TestCreateProfileService(t *testing.T) {
 		AvatarUrl: "https://yt3.ggpht.com/ytc/AAUvwniHNhQyp4hWj3nrADnils-6N3jNREP8rWKGDTp0Lg=s900-c-k-c0x00ffffff-no-rj",
 	}
 
-	err := service.CreateProfile("testuserid2", "testid2", new_profile)
+	err := service.CreateProfile(context.Background(), "testuserid2", "testid2", new_profile)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	profile, err := service.GetProfile("testid2")
+	profile, err := service.GetProfile(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -262,7 +263,7 @@ func TestCreateProfileService(t *testing.T) {
 	}
 
 	// Test that id mapping was inserted correctly
-	profile_id1, err := service.GetProfileIdFromUserId("testuserid2")
+	profile_id1, err := service.GetProfileIdFromUserId(context.Background(), "testuserid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -285,14 +286,14 @@ func TestDeleteProfileService(t *testing.T) {
 
 	// Try to delete the profile
 
-	_, err := service.DeleteProfile(profile_id)
+	_, err := service.DeleteProfile(context.Background(), profile_id)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Try to find the profile in the profiles db
-	profile, err := service.GetProfile(profile_id)
+	profile, err := service.GetProfile(context.Background(), profile_id)
 
 	if err == nil {
 		t.Errorf("Found profile %v in profiles database.", profile)
@@ -317,13 +318,13 @@ func TestUpdateProfileService(t *testing.T) {
 		AvatarUrl: "https://yt3.ggpht.com/ytc/AAUvwniHNhQyp4hWj3nrADnils-6N3jNREP8rWKGDTp0Lg=s900-c-k-c0x00ffffff-no-rj",
 	}
 
-	err := service.UpdateProfile("testid", profile)
+	err := service.UpdateProfile(context.Background(), "testid", profile)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	updated_profile, err := service.GetProfile("testid")
+	updated_profile, err := service.GetProfile(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -377,7 +378,7 @@ func TestGetFilteredProfiles(t *testing.T) {
 		"limit":    {"0"},
 	}
 
-	filtered_profile_list, err := service.GetFilteredProfiles(parameters)
+	filtered_profile_list, err := service.GetFilteredProfiles(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -416,7 +417,7 @@ func TestGetFilteredProfiles(t *testing.T) {
 		"limit":    {"1"},
 	}
 
-	filtered_profile_list, err = service.GetFilteredProfiles(parameters)
+	filtered_profile_list, err = service.GetFilteredProfiles(context.Background(), parameters)
 
 	expected_filtered_profile_list = models.ProfileList{
 		Profiles: []models.Profile{
@@ -430,6 +431,12 @@ func TestGetFilteredProfiles(t *testing.T) {
 				AvatarUrl: "https://yt3.ggpht.com/ytc/AAUvwniHNhQyp4hWj3nrADnils-6N3jNREP8rWKGDTp0Lg=s900-c-k-c0x00ffffff-no-rj",
 			},
 		},
+		Pagination: &database.PageInfo{
+			Total:      2,
+			Limit:      1,
+			Page:       1,
+			NextCursor: database.EncodeCursor(database.PaginationOptions{Limit: 1, Skip: 1}),
+		},
 	}
 
 	if !reflect.DeepEqual(filtered_profile_list, &expected_filtered_profile_list) {
@@ -460,7 +467,7 @@ func TestGetProfileLeaderboard(t *testing.T) {
 
 	parameters := map[string][]string{}
 
-	leaderboard, err := service.GetProfileLeaderboard(parameters)
+	leaderboard, err := service.GetProfileLeaderboard(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -506,7 +513,7 @@ func TestGetProfileLeaderboard(t *testing.T) {
 		"limit": {"0"},
 	}
 
-	leaderboard, err = service.GetProfileLeaderboard(parameters)
+	leaderboard, err = service.GetProfileLeaderboard(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -540,7 +547,7 @@ func TestGetProfileLeaderboard(t *testing.T) {
 		"limit": {"2"}, // Get the top two
 	}
 
-	leaderboard, err = service.GetProfileLeaderboard(parameters)
+	leaderboard, err = service.GetProfileLeaderboard(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -559,6 +566,12 @@ func TestGetProfileLeaderboard(t *testing.T) {
 				Discord: "testdiscordusername2",
 			},
 		},
+		Pagination: &database.PageInfo{
+			Total:      3,
+			Limit:      2,
+			Page:       1,
+			NextCursor: database.EncodeCursor(database.PaginationOptions{Limit: 2, Skip: 2}),
+		},
 	}
 	if !reflect.DeepEqual(leaderboard, &expected_leaderboard) {
 		t.Errorf("Wrong profile info. Expected %v, got %v", expected_leaderboard, leaderboard)
@@ -598,7 +611,7 @@ func TestGetValidFilteredProfiles(t *testing.T) {
 		"limit":     {"0"},
 	}
 
-	filtered_profile_list, err := service.GetValidFilteredProfiles(parameters)
+	filtered_profile_list, err := service.GetValidFilteredProfiles(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -639,7 +652,7 @@ func TestGetValidFilteredProfiles(t *testing.T) {
 		"limit": {"0"},
 	}
 
-	filtered_profile_list, err = service.GetValidFilteredProfiles(parameters)
+	filtered_profile_list, err = service.GetValidFilteredProfiles(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -711,7 +724,7 @@ func TestProfileFavorites(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	profile_favorites, err := service.GetProfileFavorites("testid")
+	profile_favorites, err := service.GetProfileFavorites(context.Background(), "testid")
 
 	expected_profile_favorites := models.ProfileFavorites{
 		ID:       "testid",
@@ -723,12 +736,12 @@ func TestProfileFavorites(t *testing.T) {
 	}
 
 	// Add a profile to the favorites
-	err = service.AddProfileFavorite("testid", "testid2")
+	err = service.AddProfileFavorite(context.Background(), "testid", "testid2")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	profile_favorites, err = service.GetProfileFavorites("testid")
+	profile_favorites, err = service.GetProfileFavorites(context.Background(), "testid")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -743,21 +756,21 @@ func TestProfileFavorites(t *testing.T) {
 	}
 
 	// Favorite another (nonexistent) profile and make sure it fails.
-	err = service.AddProfileFavorite("testid", "testid3")
+	err = service.AddProfileFavorite(context.Background(), "testid", "testid3")
 	expected_err := errors.New("Could not find profile with the given id.")
 	if !reflect.DeepEqual(err, expected_err) {
 		t.Errorf("The service did not return the correct error. Expected %v, got %v", expected_err, err)
 	}
 
 	// Remove the (nonexistent) profile from the favorites and make sure it fails.
-	err = service.RemoveProfileFavorite("testid", "testid3")
+	err = service.RemoveProfileFavorite(context.Background(), "testid", "testid3")
 	expected_err = errors.New("User's profile favorites does not have specified profile")
 	if !reflect.DeepEqual(err, expected_err) {
 		t.Errorf("The service did not return the correct error. Expected %v, got %v", expected_err, err)
 	}
 
 	// Add yourself to the favorites and make sure it fails
-	err = service.AddProfileFavorite("testid", "testid")
+	err = service.AddProfileFavorite(context.Background(), "testid", "testid")
 	expected_err = errors.New("User's profile matches the specified profile.")
 	if !reflect.DeepEqual(err, expected_err) {
 		t.Errorf("The service did not return the correct error. Expected %v, got %v", expected_err, err)
@@ -778,12 +791,12 @@ func TestProfileFavorites(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = service.AddProfileFavorite("testid", "testid3")
+	err = service.AddProfileFavorite(context.Background(), "testid", "testid3")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	profile_favorites, err = service.GetProfileFavorites("testid")
+	profile_favorites, err = service.GetProfileFavorites(context.Background(), "testid")
 	expected_profile_favorites = models.ProfileFavorites{
 		ID:       "testid",
 		Profiles: []string{"testid2", "testid3"},
@@ -794,12 +807,12 @@ func TestProfileFavorites(t *testing.T) {
 	}
 
 	// Remove a favorite
-	err = service.RemoveProfileFavorite("testid", "testid2")
+	err = service.RemoveProfileFavorite(context.Background(), "testid", "testid2")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	profile_favorites, err = service.GetProfileFavorites("testid")
+	profile_favorites, err = service.GetProfileFavorites(context.Background(), "testid")
 	expected_profile_favorites = models.ProfileFavorites{
 		ID:       "testid",
 		Profiles: []string{"testid3"},
@@ -811,3 +824,191 @@ func TestProfileFavorites(t *testing.T) {
 
 	CleanupTestDB(t)
 }
+
+/*
+	Service level test for atomically awarding points to a profile
+*/
+func TestAwardPointsService(t *testing.T) {
+	SetupTestDB(t)
+
+	updated_profile, err := service.AwardPoints(context.Background(), "testid", 10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated_profile, err = service.AwardPoints(context.Background(), "testid", 5)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_profile := models.Profile{
+		ID:        "testid",
+		FirstName: "testfirstname",
+		LastName:  "testlastname",
+		Points:    15,
+		Timezone:  "America/Chicago",
+		Discord:   "testdiscordusername",
+		AvatarUrl: "https://imgs.smoothradio.com/images/191589?crop=16_9&width=660&relax=1&signature=Rz93ikqcAz7BcX6SKiEC94zJnqo=",
+	}
+
+	if !reflect.DeepEqual(updated_profile, &expected_profile) {
+		t.Errorf("Wrong profile info. Expected %v, got %v", expected_profile, updated_profile)
+	}
+
+	_, err = service.AwardPoints(context.Background(), "nonexistentid", 5)
+
+	if err != database.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	CleanupTestDB(t)
+}
+
+/*
+	Service level test for redeeming an event, including a user without an attendance tracker
+*/
+func TestRedeemEventService(t *testing.T) {
+	SetupTestDB(t)
+
+	redemption_status, err := service.RedeemEvent(context.Background(), "testid", "testevent")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if redemption_status.Status != "Success" {
+		t.Errorf("Wrong redemption status. Expected %v, got %v", "Success", redemption_status.Status)
+	}
+
+	redemption_status, err = service.RedeemEvent(context.Background(), "testid", "testevent")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if redemption_status.Status != "Event already redeemed" {
+		t.Errorf("Wrong redemption status. Expected %v, got %v", "Event already redeemed", redemption_status.Status)
+	}
+
+	redemption_status, err = service.RedeemEvent(context.Background(), "testid2", "testevent")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if redemption_status.Status != "Success" {
+		t.Errorf("Wrong redemption status. Expected %v, got %v", "Success", redemption_status.Status)
+	}
+
+	var attendance_tracker models.AttendanceTracker
+	err = db.FindOne("profileattendance", database.QuerySelector{"id": "testid2"}, &attendance_tracker)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_attendance_tracker := models.AttendanceTracker{
+		ID:     "testid2",
+		Events: []string{"testevent"},
+	}
+
+	if !reflect.DeepEqual(attendance_tracker, expected_attendance_tracker) {
+		t.Errorf("Wrong attendance tracker. Expected %v, got %v", expected_attendance_tracker, attendance_tracker)
+	}
+
+	CleanupTestDB(t)
+}
+
+/*
+	Service level test for backfilling fields on profiles created before they were added
+*/
+func TestMigrateService(t *testing.T) {
+	err := db.Insert("profiles", database.QuerySelector{
+		"id":        "oldprofile",
+		"firstname": "oldfirstname",
+		"lastname":  "oldlastname",
+		"points":    5,
+		"timezone":  "America/Chicago",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := service.Migrate(database.MigrationOptions{DryRun: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 1 || migrations[0].Version != 1 {
+		t.Fatalf("Expected migration 1 to be pending, got %v", migrations)
+	}
+
+	var profile database.QuerySelector
+	err = db.FindOne("profiles", database.QuerySelector{"id": "oldprofile"}, &profile)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, exists := profile["discord"]; exists {
+		t.Errorf("Dry run modified the profile. Got %v", profile)
+	}
+
+	migrations, err = service.Migrate(database.MigrationOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 1 {
+		t.Fatalf("Expected migration 1 to be applied, got %v", migrations)
+	}
+
+	profile = nil
+	err = db.FindOne("profiles", database.QuerySelector{"id": "oldprofile"}, &profile)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if profile["discord"] != "" || profile["avatarurl"] != "" {
+		t.Errorf("Expected discord and avatar url to be backfilled. Got %v", profile)
+	}
+
+	migrations, err = service.Migrate(database.MigrationOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 0 {
+		t.Errorf("Expected no pending migrations, got %v", migrations)
+	}
+
+	migrations, err = service.Migrate(database.MigrationOptions{Rollback: true, TargetVersion: 0})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 1 {
+		t.Fatalf("Expected migration 1 to be rolled back, got %v", migrations)
+	}
+
+	profile = nil
+	err = db.FindOne("profiles", database.QuerySelector{"id": "oldprofile"}, &profile)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, exists := profile["discord"]; exists {
+		t.Errorf("Expected discord to be removed by the rollback. Got %v", profile)
+	}
+
+	CleanupTestDB(t)
+}
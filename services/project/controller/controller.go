@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/common/errors"
 	"github.com/HackIllinois/api/common/metrics"
 	"github.com/HackIllinois/api/common/utils"
@@ -23,11 +24,14 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/favorite/", RemoveProjectFavorite, "DELETE", router)
 
 	metrics.RegisterHandler("/filter/", GetFilteredProjects, "GET", router)
+	metrics.RegisterHandler("/deleted/", GetDeletedProjects, "GET", router)
+	metrics.RegisterHandler("/deleted/{id}/restore/", RestoreProject, "POST", router)
 	metrics.RegisterHandler("/{id}/", GetProject, "GET", router)
 	metrics.RegisterHandler("/{id}/", DeleteProject, "DELETE", router)
 	metrics.RegisterHandler("/", CreateProject, "POST", router)
 	metrics.RegisterHandler("/", UpdateProject, "PUT", router)
 	metrics.RegisterHandler("/", GetAllProjects, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -36,7 +40,7 @@ func SetupController(route *mux.Route) {
 func GetProjectFavorites(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	favorites, err := service.GetProjectFavorites(id)
+	favorites, err := service.GetProjectFavorites(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user's project favourites."))
@@ -55,14 +59,14 @@ func AddProjectFavorite(w http.ResponseWriter, r *http.Request) {
 	var project_favorite_modification models.ProjectFavoriteModification
 	json.NewDecoder(r.Body).Decode(&project_favorite_modification)
 
-	err := service.AddProjectFavorite(id, project_favorite_modification.ProjectID)
+	err := service.AddProjectFavorite(r.Context(), id, project_favorite_modification.ProjectID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not add a project favorite for the current user."))
 		return
 	}
 
-	favorites, err := service.GetProjectFavorites(id)
+	favorites, err := service.GetProjectFavorites(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated user project favorites."))
@@ -81,14 +85,14 @@ func RemoveProjectFavorite(w http.ResponseWriter, r *http.Request) {
 	var project_favorite_modification models.ProjectFavoriteModification
 	json.NewDecoder(r.Body).Decode(&project_favorite_modification)
 
-	err := service.RemoveProjectFavorite(id, project_favorite_modification.ProjectID)
+	err := service.RemoveProjectFavorite(r.Context(), id, project_favorite_modification.ProjectID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not remove a project favorite for the current user."))
 		return
 	}
 
-	favorites, err := service.GetProjectFavorites(id)
+	favorites, err := service.GetProjectFavorites(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch updated project favourites for the user (post-removal)."))
@@ -104,7 +108,7 @@ func RemoveProjectFavorite(w http.ResponseWriter, r *http.Request) {
 func GetProject(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	project, err := service.GetProject(id)
+	project, err := service.GetProject(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch the project details."))
@@ -122,7 +126,7 @@ func GetProject(w http.ResponseWriter, r *http.Request) {
 func DeleteProject(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	project, err := service.DeleteProject(id)
+	project, err := service.DeleteProject(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not delete either the project, project trackers, or user trackers, or an intermediary subroutine failed."))
@@ -132,8 +136,39 @@ func DeleteProject(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(project)
 }
 
+/*
+	Endpoint to get the projects which have been deleted but not yet purged
+*/
+func GetDeletedProjects(w http.ResponseWriter, r *http.Request) {
+	project_list, err := service.GetDeletedProjects(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get the deleted projects."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(project_list)
+}
+
+/*
+	Endpoint to restore the deleted project with the specified id.
+	On successful restoration, it returns the project that was restored.
+*/
+func RestoreProject(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	project, err := service.RestoreProject(r.Context(), id)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not restore the project."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(project)
+}
+
 func GetAllProjects(w http.ResponseWriter, r *http.Request) {
-	project_list, err := service.GetAllProjects()
+	project_list, err := service.GetAllProjects(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get all projects."))
@@ -148,14 +183,25 @@ func GetAllProjects(w http.ResponseWriter, r *http.Request) {
 */
 func GetFilteredProjects(w http.ResponseWriter, r *http.Request) {
 	parameters := r.URL.Query()
-	project, err := service.GetFilteredProjects(parameters)
+	project, err := service.GetFilteredProjects(r.Context(), parameters)
 
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Invalid filter or sort parameters."))
+			return
+		}
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch filtered list of projects."))
 		return
 	}
 
-	json.NewEncoder(w).Encode(project)
+	response, err := database.ProjectResponse(project, r.URL.Query())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not select the requested fields."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 func CreateProject(w http.ResponseWriter, r *http.Request) {
@@ -164,14 +210,14 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 
 	project.ID = utils.GenerateUniqueID()
 
-	err := service.CreateProject(project.ID, project)
+	err := service.CreateProject(r.Context(), project.ID, project)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not create new project."))
 		return
 	}
 
-	updated_project, err := service.GetProject(project.ID)
+	updated_project, err := service.GetProject(r.Context(), project.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated project."))
@@ -185,14 +231,14 @@ func UpdateProject(w http.ResponseWriter, r *http.Request) {
 	var project models.Project
 	json.NewDecoder(r.Body).Decode(&project)
 
-	err := service.UpdateProject(project.ID, project)
+	err := service.UpdateProject(r.Context(), project.ID, project)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not update the project."))
 		return
 	}
 
-	updated_project, err := service.GetProject(project.ID)
+	updated_project, err := service.GetProject(r.Context(), project.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated project details."))
@@ -201,3 +247,17 @@ func UpdateProject(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(updated_project)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch project service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
 type ProjectList struct {
-	Projects []Project `json:"projects"`
+	Projects   []Project          `json:"projects"`
+	Pagination *database.PageInfo `json:"pagination,omitempty"`
 }
@@ -1,10 +1,10 @@
 package service
 
 import (
+	"context"
 	"errors"
 
 	"github.com/HackIllinois/api/common/database"
-	"github.com/HackIllinois/api/common/utils"
 	"github.com/HackIllinois/api/services/project/config"
 	"github.com/HackIllinois/api/services/project/models"
 	"github.com/go-playground/validator/v10"
@@ -14,7 +14,30 @@ var validate *validator.Validate
 
 var db database.Database
 
+var purge_job *database.PurgeJob
+
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"projects": {
+		{Key: []string{"id"}, Unique: true},
+		{Key: []string{"$text:name", "$text:description", "$text:tags"}},
+	},
+	"favorites": {
+		{Key: []string{"id"}, Unique: true},
+	},
+}
+
+/*
+	Collections whose items are soft deleted when soft deletion is enabled
+*/
+var soft_delete_collections = []string{"projects"}
+
 func Initialize() error {
+	purge_job.Stop()
+	purge_job = nil
+
 	if db != nil {
 		db.Close()
 		db = nil
@@ -27,6 +50,14 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
+	db, purge_job = database.EnableSoftDelete(db, soft_delete_collections)
+
 	validate = validator.New()
 
 	return nil
@@ -35,13 +66,13 @@ func Initialize() error {
 /*
 	Returns the project with the given id
 */
-func GetProject(id string) (*models.Project, error) {
+func GetProject(ctx context.Context, id string) (*models.Project, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var project models.Project
-	err := db.FindOne("projects", query, &project)
+	err := db.WithContext(ctx).FindOne("projects", query, &project)
 
 	if err != nil {
 		return nil, err
@@ -55,11 +86,11 @@ func GetProject(id string) (*models.Project, error) {
 	Removes the project from project trackers and every user's tracker.
 	Returns the project that was deleted.
 */
-func DeleteProject(id string) (*models.Project, error) {
+func DeleteProject(ctx context.Context, id string) (*models.Project, error) {
 
 	// Gets project to be able to return it later
 
-	project, err := GetProject(id)
+	project, err := GetProject(ctx, id)
 
 	if err != nil {
 		return nil, err
@@ -71,7 +102,7 @@ func DeleteProject(id string) (*models.Project, error) {
 
 	// Remove project from projects database
 
-	err = db.RemoveOne("projects", query)
+	err = db.WithContext(ctx).RemoveOne("projects", query)
 
 	if err != nil {
 		return nil, err
@@ -80,13 +111,49 @@ func DeleteProject(id string) (*models.Project, error) {
 	return project, err
 }
 
+/*
+	Returns the projects which have been deleted but not yet purged, most recently deleted first
+*/
+func GetDeletedProjects(ctx context.Context) (*models.ProjectList, error) {
+	projects := []models.Project{}
+	err := database.FindAllDeleted(db.WithContext(ctx), "projects", nil, &projects)
+
+	if err != nil {
+		return nil, err
+	}
+
+	project_list := models.ProjectList{
+		Projects: projects,
+	}
+
+	return &project_list, nil
+}
+
+/*
+	Restores the deleted project with the given id.
+	Returns the restored project.
+*/
+func RestoreProject(ctx context.Context, id string) (*models.Project, error) {
+	query := database.QuerySelector{
+		"id": id,
+	}
+
+	err := database.RestoreOne(db.WithContext(ctx), "projects", query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return GetProject(ctx, id)
+}
+
 /*
 	Returns all the projects
 */
-func GetAllProjects() (*models.ProjectList, error) {
+func GetAllProjects(ctx context.Context) (*models.ProjectList, error) {
 	projects := []models.Project{}
 	// nil implies there are no filters on the query, therefore everything in the "projects" collection is returned.
-	err := db.FindAll("projects", nil, &projects)
+	err := db.WithContext(ctx).FindAll("projects", nil, &projects)
 
 	if err != nil {
 		return nil, err
@@ -100,9 +167,33 @@ func GetAllProjects() (*models.ProjectList, error) {
 }
 
 /*
-	Returns all the projects
+	Returns the projects matching the given filter parameters
 */
-func GetFilteredProjects(parameters map[string][]string) (*models.ProjectList, error) {
+func GetFilteredProjects(ctx context.Context, parameters map[string][]string) (*models.ProjectList, error) {
+	pagination, err := database.ParsePaginationParameters(parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	projection, err := database.ParseProjectionParameters(parameters, models.Project{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort_fields, err := database.ParseSortParameters(parameters, models.Project{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	search_text, err := database.ParseSearchParameter(parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
 	query, err := database.CreateFilterQuery(parameters, models.Project{})
 
 	if err != nil {
@@ -111,26 +202,28 @@ func GetFilteredProjects(parameters map[string][]string) (*models.ProjectList, e
 
 	projects := []models.Project{}
 	filtered_projects := models.ProjectList{Projects: projects}
-	err = db.FindAll("projects", query, &filtered_projects.Projects)
+	pagination_results, err := database.FindAllSearched(db.WithContext(ctx), "projects", search_text, query, projection, sort_fields, *pagination, &filtered_projects.Projects)
 
 	if err != nil {
 		return nil, err
 	}
 
+	filtered_projects.Pagination = database.GetPageInfo(*pagination, pagination_results)
+
 	return &filtered_projects, nil
 }
 
 /*
 	Creates a project with the given id
 */
-func CreateProject(id string, project models.Project) error {
+func CreateProject(ctx context.Context, id string, project models.Project) error {
 	err := validate.Struct(project)
 
 	if err != nil {
 		return err
 	}
 
-	_, err = GetProject(id)
+	_, err = GetProject(ctx, id)
 
 	if err != database.ErrNotFound {
 		if err != nil {
@@ -139,7 +232,7 @@ func CreateProject(id string, project models.Project) error {
 		return errors.New("Project already exists")
 	}
 
-	err = db.Insert("projects", &project)
+	err = db.WithContext(ctx).Insert("projects", &project)
 
 	return err
 }
@@ -147,7 +240,7 @@ func CreateProject(id string, project models.Project) error {
 /*
 	Updates the project with the given id
 */
-func UpdateProject(id string, project models.Project) error {
+func UpdateProject(ctx context.Context, id string, project models.Project) error {
 	err := validate.Struct(project)
 
 	if err != nil {
@@ -158,7 +251,7 @@ func UpdateProject(id string, project models.Project) error {
 		"id": id,
 	}
 
-	err = db.Update("projects", selector, &project)
+	err = db.WithContext(ctx).Update("projects", selector, &project)
 
 	return err
 }
@@ -166,17 +259,17 @@ func UpdateProject(id string, project models.Project) error {
 /*
 	Returns the project favorites for the user with the given id
 */
-func GetProjectFavorites(id string) (*models.ProjectFavorites, error) {
+func GetProjectFavorites(ctx context.Context, id string) (*models.ProjectFavorites, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var project_favorites models.ProjectFavorites
-	err := db.FindOne("favorites", query, &project_favorites)
+	err := db.WithContext(ctx).FindOne("favorites", query, &project_favorites)
 
 	if err != nil {
 		if err == database.ErrNotFound {
-			err = db.Insert("favorites", &models.ProjectFavorites{
+			err = db.WithContext(ctx).Insert("favorites", &models.ProjectFavorites{
 				ID:       id,
 				Projects: []string{},
 			})
@@ -185,7 +278,7 @@ func GetProjectFavorites(id string) (*models.ProjectFavorites, error) {
 				return nil, err
 			}
 
-			err = db.FindOne("favorites", query, &project_favorites)
+			err = db.WithContext(ctx).FindOne("favorites", query, &project_favorites)
 
 			if err != nil {
 				return nil, err
@@ -200,29 +293,26 @@ func GetProjectFavorites(id string) (*models.ProjectFavorites, error) {
 
 /*
 	Adds the given project to the favorites for the user with the given id
+	The user's favorites are created if they do not exist yet
 */
-func AddProjectFavorite(id string, project string) error {
+func AddProjectFavorite(ctx context.Context, id string, project string) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	_, err := GetProject(project)
+	_, err := GetProject(ctx, project)
 
 	if err != nil {
 		return errors.New("Could not find project with the given id.")
 	}
 
-	project_favorites, err := GetProjectFavorites(id)
-
-	if err != nil {
-		return err
-	}
-
-	if !utils.ContainsString(project_favorites.Projects, project) {
-		project_favorites.Projects = append(project_favorites.Projects, project)
+	modifier := database.QuerySelector{
+		"$addToSet": database.QuerySelector{
+			"projects": project,
+		},
 	}
 
-	err = db.Update("favorites", selector, project_favorites)
+	_, err = db.WithContext(ctx).Upsert("favorites", selector, &modifier)
 
 	return err
 }
@@ -230,24 +320,38 @@ func AddProjectFavorite(id string, project string) error {
 /*
 	Removes the given project from the favorites of the user with the given id
 */
-func RemoveProjectFavorite(id string, project string) error {
+func RemoveProjectFavorite(ctx context.Context, id string, project string) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	project_favorites, err := GetProjectFavorites(id)
+	condition := database.QuerySelector{
+		"projects": project,
+	}
 
-	if err != nil {
-		return err
+	modifier := database.QuerySelector{
+		"$pull": database.QuerySelector{
+			"projects": project,
+		},
 	}
 
-	project_favorites.Projects, err = utils.RemoveString(project_favorites.Projects, project)
+	was_removed, err := db.WithContext(ctx).UpdateIfMatches("favorites", selector, condition, &modifier)
 
-	if err != nil {
+	// A user without a favorites document has no favorites to remove
+	if err != nil && err != database.ErrNotFound {
+		return err
+	}
+
+	if !was_removed {
 		return errors.New("User's project favorites does not have specified project")
 	}
 
-	err = db.Update("favorites", selector, project_favorites)
+	return nil
+}
 
-	return err
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
 }
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"reflect"
@@ -99,7 +100,7 @@ func TestGetAllProjectsService(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	actual_project_list, err := service.GetAllProjects()
+	actual_project_list, err := service.GetAllProjects(context.Background())
 
 	if err != nil {
 		t.Fatal(err)
@@ -134,7 +135,7 @@ func TestGetAllProjectsService(t *testing.T) {
 
 	db.RemoveAll("projects", nil)
 
-	actual_project_list, err = service.GetAllProjects()
+	actual_project_list, err = service.GetAllProjects(context.Background())
 
 	if err != nil {
 		t.Fatal(err)
@@ -178,7 +179,7 @@ func TestGetFilteredProjectsService(t *testing.T) {
 	parameters := map[string][]string{
 		"name": {"testname2"},
 	}
-	actual_project_list, err := service.GetFilteredProjects(parameters)
+	actual_project_list, err := service.GetFilteredProjects(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -206,7 +207,7 @@ func TestGetFilteredProjectsService(t *testing.T) {
 	parameters = map[string][]string{
 		"number": {"2"},
 	}
-	actual_project_list, err = service.GetFilteredProjects(parameters)
+	actual_project_list, err = service.GetFilteredProjects(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -232,7 +233,7 @@ func TestGetFilteredProjectsService(t *testing.T) {
 
 	// Filter to multiple (all) projects
 	parameters = map[string][]string{}
-	actual_project_list, err = service.GetFilteredProjects(parameters)
+	actual_project_list, err = service.GetFilteredProjects(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -268,7 +269,7 @@ func TestGetFilteredProjectsService(t *testing.T) {
 	db.RemoveAll("projects", nil)
 
 	// Filter again, with no projects remaining
-	actual_project_list, err = service.GetFilteredProjects(parameters)
+	actual_project_list, err = service.GetFilteredProjects(context.Background(), parameters)
 
 	if err != nil {
 		t.Fatal(err)
@@ -292,7 +293,7 @@ func TestGetFilteredProjectsService(t *testing.T) {
 func TestGetProjectService(t *testing.T) {
 	SetupTestDB(t)
 
-	project, err := service.GetProject("testid")
+	project, err := service.GetProject(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -331,13 +332,13 @@ func TestCreateProjectService(t *testing.T) {
 		Room:        "testroom2",
 	}
 
-	err := service.CreateProject("testid2", new_project)
+	err := service.CreateProject(context.Background(), "testid2", new_project)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	project, err := service.GetProject("testid2")
+	project, err := service.GetProject(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -370,14 +371,14 @@ func TestDeleteProjectService(t *testing.T) {
 
 	// Try to delete the project
 
-	_, err := service.DeleteProject(project_id)
+	_, err := service.DeleteProject(context.Background(), project_id)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Try to find the project in the projects db
-	project, err := service.GetProject(project_id)
+	project, err := service.GetProject(context.Background(), project_id)
 
 	if err == nil {
 		t.Errorf("Found project %v in projects database.", project)
@@ -402,13 +403,13 @@ func TestUpdateProjectService(t *testing.T) {
 		Room:        "testroom2",
 	}
 
-	err := service.UpdateProject("testid", project)
+	err := service.UpdateProject(context.Background(), "testid", project)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	updated_project, err := service.GetProject("testid")
+	updated_project, err := service.GetProject(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -437,7 +438,7 @@ func TestUpdateProjectService(t *testing.T) {
 func TestGetProjectFavorites(t *testing.T) {
 	SetupTestDB(t)
 
-	project_favorites, err := service.GetProjectFavorites("testid")
+	project_favorites, err := service.GetProjectFavorites(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -461,13 +462,13 @@ func TestGetProjectFavorites(t *testing.T) {
 func TestAddProjectFavorite(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.AddProjectFavorite("testid", "testid")
+	err := service.AddProjectFavorite(context.Background(), "testid", "testid")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	project_favorites, err := service.GetProjectFavorites("testid")
+	project_favorites, err := service.GetProjectFavorites(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -491,19 +492,19 @@ func TestAddProjectFavorite(t *testing.T) {
 func TestRemoveProjectFavorite(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.AddProjectFavorite("testid", "testid")
+	err := service.AddProjectFavorite(context.Background(), "testid", "testid")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = service.RemoveProjectFavorite("testid", "testid")
+	err = service.RemoveProjectFavorite(context.Background(), "testid", "testid")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	project_favorites, err := service.GetProjectFavorites("testid")
+	project_favorites, err := service.GetProjectFavorites(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -518,5 +519,11 @@ func TestRemoveProjectFavorite(t *testing.T) {
 		t.Errorf("Wrong tracker info. Expected %v, got %v", &expected_project_favorites, project_favorites)
 	}
 
+	err = service.RemoveProjectFavorite(context.Background(), "otherid", "testid")
+
+	if err == nil {
+		t.Errorf("Expected an error removing a favorite project for a user without favorites")
+	}
+
 	CleanupTestDB(t)
 }
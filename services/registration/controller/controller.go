@@ -2,12 +2,15 @@ package controller
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"time"
 
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/common/datastore"
 	"github.com/HackIllinois/api/common/errors"
 	"github.com/HackIllinois/api/common/metrics"
+	"github.com/HackIllinois/api/common/utils"
 	"github.com/HackIllinois/api/services/registration/config"
 	"github.com/HackIllinois/api/services/registration/models"
 	"github.com/HackIllinois/api/services/registration/service"
@@ -15,6 +18,11 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+/*
+	The registration fields which are set by the api, and so are not part of the request format
+*/
+var serverSetFields = []string{"id", "github", "createdAt", "updatedAt"}
+
 func SetupController(route *mux.Route) {
 	router := route.Subrouter()
 
@@ -25,20 +33,25 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/attendee/", GetCurrentUserRegistration, "GET", router)
 	metrics.RegisterHandler("/attendee/", CreateCurrentUserRegistration, "POST", router)
 	metrics.RegisterHandler("/attendee/", UpdateCurrentUserRegistration, "PUT", router)
+	metrics.RegisterHandler("/attendee/", PatchCurrentUserRegistration, "PATCH", router)
 
 	metrics.RegisterHandler("/attendee/list/", GetFilteredUserRegistrations, "GET", router)
+	metrics.RegisterHandler("/attendee/schema/", GetUserRegistrationSchema, "GET", router)
 
 	metrics.RegisterHandler("/mentor/", GetFilteredUserRegistrations, "GET", router)
 	metrics.RegisterHandler("/mentor/", CreateCurrentMentorRegistration, "POST", router)
 	metrics.RegisterHandler("/mentor/", UpdateCurrentMentorRegistration, "PUT", router)
+	metrics.RegisterHandler("/mentor/", PatchCurrentMentorRegistration, "PATCH", router)
 
 	metrics.RegisterHandler("/mentor/list/", GetFilteredMentorRegistrations, "GET", router)
+	metrics.RegisterHandler("/mentor/schema/", GetMentorRegistrationSchema, "GET", router)
 
 	metrics.RegisterHandler("/{id}/", GetAllRegistrations, "GET", router)
 	metrics.RegisterHandler("/attendee/{id}/", GetUserRegistration, "GET", router)
 	metrics.RegisterHandler("/mentor/{id}/", GetMentorRegistration, "GET", router)
 
 	metrics.RegisterHandler("/internal/stats/", GetStats, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -48,9 +61,9 @@ func SetupController(route *mux.Route) {
 func GetAllCurrentRegistrations(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	user_registration, _ := service.GetUserRegistration(id)
+	user_registration, _ := service.GetUserRegistration(r.Context(), id)
 
-	mentor_registration, _ := service.GetMentorRegistration(id)
+	mentor_registration, _ := service.GetMentorRegistration(r.Context(), id)
 
 	var all_registration = models.AllRegistration{
 		Attendee: user_registration,
@@ -67,9 +80,9 @@ func GetAllCurrentRegistrations(w http.ResponseWriter, r *http.Request) {
 func GetAllRegistrations(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	user_registration, _ := service.GetUserRegistration(id)
+	user_registration, _ := service.GetUserRegistration(r.Context(), id)
 
-	mentor_registration, _ := service.GetMentorRegistration(id)
+	mentor_registration, _ := service.GetMentorRegistration(r.Context(), id)
 
 	var all_registration = models.AllRegistration{
 		Attendee: user_registration,
@@ -85,7 +98,7 @@ func GetAllRegistrations(w http.ResponseWriter, r *http.Request) {
 func GetCurrentUserRegistration(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	user_registration, err := service.GetUserRegistration(id)
+	user_registration, err := service.GetUserRegistration(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get current user's registration."))
@@ -129,7 +142,7 @@ func CreateCurrentUserRegistration(w http.ResponseWriter, r *http.Request) {
 	user_registration.Data["createdAt"] = time.Now().Unix()
 	user_registration.Data["updatedAt"] = time.Now().Unix()
 
-	err = service.CreateUserRegistration(id, user_registration)
+	err = service.CreateUserRegistration(r.Context(), id, user_registration)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not create user registration."))
@@ -150,7 +163,7 @@ func CreateCurrentUserRegistration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updated_registration, err := service.GetUserRegistration(id)
+	updated_registration, err := service.GetUserRegistration(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user registration."))
@@ -217,35 +230,92 @@ func UpdateCurrentUserRegistration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user_registration.Data["id"] = id
+	original_registration, stored_version, err := service.GetUserRegistrationWithVersion(r.Context(), id)
 
-	user_info, err := service.GetUserInfo(id)
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user's original registration."))
+		return
+	}
+
+	saveCurrentUserRegistration(w, r, id, original_registration, stored_version, user_registration, nil)
+}
+
+/*
+	Endpoint to partially update the registration for the current user.
+	The body is a JSON Merge Patch, which is applied to the stored registration, and only the patched fields are written.
+	On successful update, sends the user a confirmation mail.
+*/
+func PatchCurrentUserRegistration(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get("HackIllinois-Identity")
+
+	if id == "" {
+		errors.WriteError(w, r, errors.MalformedRequestError("Must provide id in request.", "Must provide id in request."))
+		return
+	}
+
+	patch, err := ioutil.ReadAll(r.Body)
 
 	if err != nil {
-		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not get user info."))
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not read user registration patch."))
 		return
 	}
 
-	original_registration, err := service.GetUserRegistration(id)
+	original_registration, stored_version, err := service.GetUserRegistrationWithVersion(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user's original registration."))
 		return
 	}
 
+	user_registration, changed_fields, err := original_registration.ApplyMergePatch(patch)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.MalformedRequestError(err.Error(), "Could not apply user registration patch. Possible failure in JSON validation, or invalid registration format."))
+		return
+	}
+
+	changed_fields = append(utils.ExcludeStrings(changed_fields, serverSetFields), "github", "updatedAt")
+
+	saveCurrentUserRegistration(w, r, id, original_registration, stored_version, user_registration, changed_fields)
+}
+
+/*
+	Sets the fields of the registration which are set by the api, and saves it as the registration for the current user.
+	Only the changed fields are written if they are given, and otherwise the whole registration is written.
+	The changed fields are only written if the stored registration still has the version it was read with.
+	Then sends the user a confirmation mail, and responds with the updated registration.
+*/
+func saveCurrentUserRegistration(w http.ResponseWriter, r *http.Request, id string, original_registration *models.UserRegistration, stored_version int, user_registration models.UserRegistration, changed_fields []string) {
+	user_info, err := service.GetUserInfo(id)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not get user info."))
+		return
+	}
+
+	user_registration.Data["id"] = id
 	user_registration.Data["github"] = user_info.Username
 
 	user_registration.Data["createdAt"] = original_registration.Data["createdAt"]
 	user_registration.Data["updatedAt"] = time.Now().Unix()
 
-	err = service.UpdateUserRegistration(id, user_registration)
+	if changed_fields == nil {
+		err = service.UpdateUserRegistration(r.Context(), id, user_registration)
+	} else {
+		err = service.PatchUserRegistration(r.Context(), id, user_registration, stored_version, changed_fields)
+	}
+
+	if err == datastore.ErrStoredVersionChanged {
+		errors.WriteError(w, r, errors.ConflictError(err.Error(), "The user's registration was changed while it was being patched."))
+		return
+	}
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not update user's registration."))
 		return
 	}
 
-	updated_registration, err := service.GetUserRegistration(id)
+	updated_registration, err := service.GetUserRegistration(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch user's updated registration."))
@@ -268,14 +338,25 @@ func UpdateCurrentUserRegistration(w http.ResponseWriter, r *http.Request) {
 */
 func GetFilteredUserRegistrations(w http.ResponseWriter, r *http.Request) {
 	parameters := r.URL.Query()
-	user_registrations, err := service.GetFilteredUserRegistrations(parameters)
+	user_registrations, err := service.GetFilteredUserRegistrations(r.Context(), parameters)
 
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Invalid filter or sort parameters."))
+			return
+		}
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get filtered user registrations."))
 		return
 	}
 
-	json.NewEncoder(w).Encode(user_registrations)
+	response, err := database.ProjectResponse(user_registrations, r.URL.Query())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not select the requested fields."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 /*
@@ -284,7 +365,7 @@ func GetFilteredUserRegistrations(w http.ResponseWriter, r *http.Request) {
 func GetCurrentMentorRegistration(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	mentor_registration, err := service.GetMentorRegistration(id)
+	mentor_registration, err := service.GetMentorRegistration(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get mentor registration."))
@@ -327,7 +408,7 @@ func CreateCurrentMentorRegistration(w http.ResponseWriter, r *http.Request) {
 	mentor_registration.Data["createdAt"] = time.Now().Unix()
 	mentor_registration.Data["updatedAt"] = time.Now().Unix()
 
-	err = service.CreateMentorRegistration(id, mentor_registration)
+	err = service.CreateMentorRegistration(r.Context(), id, mentor_registration)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not create mentor registration."))
@@ -341,7 +422,7 @@ func CreateCurrentMentorRegistration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updated_registration, err := service.GetMentorRegistration(id)
+	updated_registration, err := service.GetMentorRegistration(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated mentor registration."))
@@ -370,35 +451,91 @@ func UpdateCurrentMentorRegistration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mentor_registration.Data["id"] = id
+	original_registration, stored_version, err := service.GetMentorRegistrationWithVersion(r.Context(), id)
 
-	user_info, err := service.GetUserInfo(id)
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get mentor registration."))
+		return
+	}
+
+	saveCurrentMentorRegistration(w, r, id, original_registration, stored_version, mentor_registration, nil)
+}
+
+/*
+	Endpoint to partially update the registration for the current mentor.
+	The body is a JSON Merge Patch, which is applied to the stored registration, and only the patched fields are written.
+*/
+func PatchCurrentMentorRegistration(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get("HackIllinois-Identity")
+
+	if id == "" {
+		errors.WriteError(w, r, errors.MalformedRequestError("Must provide id in request.", "Must provide id in request."))
+		return
+	}
+
+	patch, err := ioutil.ReadAll(r.Body)
 
 	if err != nil {
-		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get mentor's user info."))
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not read mentor registration patch."))
 		return
 	}
 
-	original_registration, err := service.GetMentorRegistration(id)
+	original_registration, stored_version, err := service.GetMentorRegistrationWithVersion(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get mentor registration."))
 		return
 	}
 
+	mentor_registration, changed_fields, err := original_registration.ApplyMergePatch(patch)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.MalformedRequestError(err.Error(), "Could not apply mentor registration patch. Possible failure in JSON validation, or invalid registration format."))
+		return
+	}
+
+	changed_fields = append(utils.ExcludeStrings(changed_fields, serverSetFields), "github", "updatedAt")
+
+	saveCurrentMentorRegistration(w, r, id, original_registration, stored_version, mentor_registration, changed_fields)
+}
+
+/*
+	Sets the fields of the registration which are set by the api, and saves it as the registration for the current mentor.
+	Only the changed fields are written if they are given, and otherwise the whole registration is written.
+	The changed fields are only written if the stored registration still has the version it was read with.
+	Then responds with the updated registration.
+*/
+func saveCurrentMentorRegistration(w http.ResponseWriter, r *http.Request, id string, original_registration *models.MentorRegistration, stored_version int, mentor_registration models.MentorRegistration, changed_fields []string) {
+	user_info, err := service.GetUserInfo(id)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get mentor's user info."))
+		return
+	}
+
+	mentor_registration.Data["id"] = id
 	mentor_registration.Data["github"] = user_info.Username
 
 	mentor_registration.Data["createdAt"] = original_registration.Data["createdAt"]
 	mentor_registration.Data["updatedAt"] = time.Now().Unix()
 
-	err = service.UpdateMentorRegistration(id, mentor_registration)
+	if changed_fields == nil {
+		err = service.UpdateMentorRegistration(r.Context(), id, mentor_registration)
+	} else {
+		err = service.PatchMentorRegistration(r.Context(), id, mentor_registration, stored_version, changed_fields)
+	}
+
+	if err == datastore.ErrStoredVersionChanged {
+		errors.WriteError(w, r, errors.ConflictError(err.Error(), "The mentor registration was changed while it was being patched."))
+		return
+	}
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not update mentor registration."))
 		return
 	}
 
-	updated_registration, err := service.GetMentorRegistration(id)
+	updated_registration, err := service.GetMentorRegistration(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated mentor registration."))
@@ -413,14 +550,25 @@ func UpdateCurrentMentorRegistration(w http.ResponseWriter, r *http.Request) {
 */
 func GetFilteredMentorRegistrations(w http.ResponseWriter, r *http.Request) {
 	parameters := r.URL.Query()
-	mentor_registrations, err := service.GetFilteredMentorRegistrations(parameters)
+	mentor_registrations, err := service.GetFilteredMentorRegistrations(r.Context(), parameters)
 
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Invalid filter or sort parameters."))
+			return
+		}
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get filtered mentor registrations."))
 		return
 	}
 
-	json.NewEncoder(w).Encode(mentor_registrations)
+	response, err := database.ProjectResponse(mentor_registrations, r.URL.Query())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not select the requested fields."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 /*
@@ -429,7 +577,7 @@ func GetFilteredMentorRegistrations(w http.ResponseWriter, r *http.Request) {
 func GetUserRegistration(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	user_registration, err := service.GetUserRegistration(id)
+	user_registration, err := service.GetUserRegistration(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user registration."))
@@ -445,7 +593,7 @@ func GetUserRegistration(w http.ResponseWriter, r *http.Request) {
 func GetMentorRegistration(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	mentor_registration, err := service.GetMentorRegistration(id)
+	mentor_registration, err := service.GetMentorRegistration(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get mentor registration."))
@@ -455,11 +603,40 @@ func GetMentorRegistration(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(mentor_registration)
 }
 
+/*
+	Endpoint to get the JSON Schema of the attendee registration form
+	Fields which are set by the api rather than the client are omitted
+*/
+func GetUserRegistrationSchema(w http.ResponseWriter, r *http.Request) {
+	schema, err := config.REGISTRATION_DEFINITION.WithoutFields(serverSetFields...).ToJSONSchema()
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not generate attendee registration schema."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(schema)
+}
+
+/*
+	Endpoint to get the JSON Schema of the mentor registration form
+*/
+func GetMentorRegistrationSchema(w http.ResponseWriter, r *http.Request) {
+	schema, err := config.MENTOR_REGISTRATION_DEFINITION.WithoutFields(serverSetFields...).ToJSONSchema()
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not generate mentor registration schema."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(schema)
+}
+
 /*
 	Endpoint to get registration stats
 */
 func GetStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := service.GetStats()
+	stats, err := service.GetStats(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not fetch registration service statistics."))
@@ -468,3 +645,17 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(stats)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch registration service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
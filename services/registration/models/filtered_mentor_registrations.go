@@ -2,10 +2,13 @@ package models
 
 import (
 	"encoding/json"
+
+	"github.com/HackIllinois/api/common/database"
 )
 
 type FilteredMentorRegistrations struct {
 	Registrations []MentorRegistration `json:"registrations"`
+	Pagination    *database.PageInfo   `json:"pagination,omitempty"`
 }
 
 func (original FilteredMentorRegistrations) MarshalJSON() ([]byte, error) {
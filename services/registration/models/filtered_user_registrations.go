@@ -2,10 +2,13 @@ package models
 
 import (
 	"encoding/json"
+
+	"github.com/HackIllinois/api/common/database"
 )
 
 type FilteredUserRegistrations struct {
 	Registrations []UserRegistration `json:"registrations"`
+	Pagination    *database.PageInfo `json:"pagination,omitempty"`
 }
 
 func (original FilteredUserRegistrations) MarshalJSON() ([]byte, error) {
@@ -1,11 +1,11 @@
 package service
 
 import (
+	"context"
 	"errors"
-	"strconv"
-	"strings"
 
 	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/common/datastore"
 	"github.com/HackIllinois/api/services/registration/config"
 	"github.com/HackIllinois/api/services/registration/models"
 	"github.com/go-playground/validator/v10"
@@ -15,6 +15,18 @@ var validate *validator.Validate
 
 var db database.Database
 
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"attendees": {
+		{Key: []string{"id"}, Unique: true},
+	},
+	"mentors": {
+		{Key: []string{"id"}, Unique: true},
+	},
+}
+
 func Initialize() error {
 	if db != nil {
 		db.Close()
@@ -28,38 +40,62 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
 	validate = validator.New()
 
 	return nil
 }
 
 /*
-	Returns the registration associated with the given user id
+	Returns the registration associated with the given user id, upgraded to the current registration definition
+*/
+func GetUserRegistration(ctx context.Context, id string) (*models.UserRegistration, error) {
+	user_registration, _, err := GetUserRegistrationWithVersion(ctx, id)
+
+	return user_registration, err
+}
+
+/*
+	Returns the registration associated with the given user id, upgraded to the current registration definition
+	Also returns the version of the definition the stored registration was written with, which is needed to patch it
 */
-func GetUserRegistration(id string) (*models.UserRegistration, error) {
+func GetUserRegistrationWithVersion(ctx context.Context, id string) (*models.UserRegistration, int, error) {
 	query := database.QuerySelector{"id": id}
 
 	var user_registration models.UserRegistration
-	err := db.FindOne("attendees", query, &user_registration)
+	err := db.WithContext(ctx).FindOne("attendees", query, &user_registration)
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	stored_version := user_registration.GetVersion()
+
+	err = user_registration.Upgrade(config.REGISTRATION_DEFINITION)
+
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return &user_registration, nil
+	return &user_registration, stored_version, nil
 }
 
 /*
 	Creates the registration associated with the given user id
 */
-func CreateUserRegistration(id string, user_registration models.UserRegistration) error {
+func CreateUserRegistration(ctx context.Context, id string, user_registration models.UserRegistration) error {
 	err := user_registration.Validate()
 
 	if err != nil {
 		return err
 	}
 
-	_, err = GetUserRegistration(id)
+	_, err = GetUserRegistration(ctx, id)
 
 	if err != database.ErrNotFound {
 		if err != nil {
@@ -68,7 +104,7 @@ func CreateUserRegistration(id string, user_registration models.UserRegistration
 		return errors.New("Registration already exists.")
 	}
 
-	err = db.Insert("attendees", &user_registration)
+	err = db.WithContext(ctx).Insert("attendees", &user_registration)
 
 	return err
 }
@@ -76,7 +112,7 @@ func CreateUserRegistration(id string, user_registration models.UserRegistration
 /*
 	Updates the registration associated with the given user id
 */
-func UpdateUserRegistration(id string, user_registration models.UserRegistration) error {
+func UpdateUserRegistration(ctx context.Context, id string, user_registration models.UserRegistration) error {
 	err := user_registration.Validate()
 
 	if err != nil {
@@ -85,106 +121,125 @@ func UpdateUserRegistration(id string, user_registration models.UserRegistration
 
 	selector := database.QuerySelector{"id": id}
 
-	err = db.Update("attendees", selector, &user_registration)
+	err = db.WithContext(ctx).Update("attendees", selector, &user_registration)
 
 	return err
 }
 
 /*
-	Returns db search query based on given parameters
+	Updates only the given fields of the registration associated with the given user id
+	The registration is validated as a whole, so it should be the result of applying a patch to the stored registration
+	If the stored registration was written with an older version of the definition, the whole registration is saved instead
+	The stored registration must still be written with the given version of the definition, which it was read with,
+	and otherwise ErrStoredVersionChanged is returned so that a concurrent upgrade is not overwritten
 */
-func getFilterQuery(parameters map[string][]string) (map[string]interface{}, error) {
-	query := make(map[string]interface{})
-	for key, values := range parameters {
-		if len(values) == 1 {
-			value_list := strings.Split(values[0], ",")
-
-			correctly_typed_value_list := make([]interface{}, len(value_list))
-			for i, value := range value_list {
-				correctly_typed_value, err := AssignValueType(key, value)
-				if err == nil {
-					correctly_typed_value_list[i] = correctly_typed_value
-				} else {
-					return nil, err
-				}
-			}
-			query[key] = database.QuerySelector{"$in": correctly_typed_value_list}
-		} else {
-			return nil, errors.New("Multiple usage of key " + key)
-		}
+func PatchUserRegistration(ctx context.Context, id string, user_registration models.UserRegistration, stored_version int, changed_fields []string) error {
+	err := user_registration.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	selector := database.QuerySelector{"id": id}
+
+	matched, err := db.WithContext(ctx).UpdateIfMatches("attendees", selector, datastore.GetPatchCondition(stored_version), user_registration.GetPatchUpdate(stored_version, changed_fields))
+
+	if err != nil {
+		return err
 	}
 
-	return query, nil
+	if !matched {
+		return datastore.ErrStoredVersionChanged
+	}
+
+	return nil
 }
 
 /*
-	Returns the user registrations associated with the given parameters
+	Returns the user registrations associated with the given parameters, upgraded to the current registration definition
 */
-func GetFilteredUserRegistrations(parameters map[string][]string) (*models.FilteredUserRegistrations, error) {
-	query, err := getFilterQuery(parameters)
+func GetFilteredUserRegistrations(ctx context.Context, parameters map[string][]string) (*models.FilteredUserRegistrations, error) {
+	pagination, err := database.ParsePaginationParameters(parameters)
 	if err != nil {
 		return nil, err
 	}
 
-	var filtered_registrations models.FilteredUserRegistrations
-	err = db.FindAll("attendees", query, &filtered_registrations.Registrations)
+	projection, err := database.ParseProjectionParameters(parameters, datastore.NewDataStore(config.REGISTRATION_DEFINITION))
 	if err != nil {
 		return nil, err
 	}
 
-	return &filtered_registrations, nil
-}
+	sort_fields, err := database.ParseSortParameters(parameters, datastore.NewDataStore(config.REGISTRATION_DEFINITION))
+	if err != nil {
+		return nil, err
+	}
 
-func AssignValueType(key, value string) (interface{}, error) {
-	int_keys := []string{"age", "graduationYear"}
-	if Contains(int_keys, key) {
-		return strconv.Atoi(value)
+	query, err := database.CreateFilterQuery(parameters, datastore.NewDataStore(config.REGISTRATION_DEFINITION))
+	if err != nil {
+		return nil, err
 	}
 
-	bool_keys := []string{"isNovice", "isPrivate"}
-	if Contains(bool_keys, key) {
-		return strconv.ParseBool(value)
+	var filtered_registrations models.FilteredUserRegistrations
+	pagination_results, err := db.WithContext(ctx).FindAllProjected("attendees", query, datastore.GetUpgradeProjection(config.REGISTRATION_DEFINITION, projection), sort_fields, *pagination, &filtered_registrations.Registrations)
+	if err != nil {
+		return nil, err
 	}
 
-	return value, nil
+	err = datastore.UpgradeAll(filtered_registrations.Registrations, config.REGISTRATION_DEFINITION)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered_registrations.Pagination = database.GetPageInfo(*pagination, pagination_results)
+
+	return &filtered_registrations, nil
 }
 
-func Contains(slice []string, str string) bool {
-	for _, value := range slice {
-		if value == str {
-			return true
-		}
-	}
-	return false
+/*
+	Returns the registration associated with the given mentor id, upgraded to the current mentor registration definition
+*/
+func GetMentorRegistration(ctx context.Context, id string) (*models.MentorRegistration, error) {
+	mentor_registration, _, err := GetMentorRegistrationWithVersion(ctx, id)
+
+	return mentor_registration, err
 }
 
 /*
-	Returns the registration associated with the given mentor id
+	Returns the registration associated with the given mentor id, upgraded to the current mentor registration definition
+	Also returns the version of the definition the stored registration was written with, which is needed to patch it
 */
-func GetMentorRegistration(id string) (*models.MentorRegistration, error) {
+func GetMentorRegistrationWithVersion(ctx context.Context, id string) (*models.MentorRegistration, int, error) {
 	query := database.QuerySelector{"id": id}
 
 	var mentor_registration models.MentorRegistration
-	err := db.FindOne("mentors", query, &mentor_registration)
+	err := db.WithContext(ctx).FindOne("mentors", query, &mentor_registration)
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return &mentor_registration, nil
+	stored_version := mentor_registration.GetVersion()
+
+	err = mentor_registration.Upgrade(config.MENTOR_REGISTRATION_DEFINITION)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &mentor_registration, stored_version, nil
 }
 
 /*
 	Creates the registration associated with the given mentor id
 */
-func CreateMentorRegistration(id string, mentor_registration models.MentorRegistration) error {
+func CreateMentorRegistration(ctx context.Context, id string, mentor_registration models.MentorRegistration) error {
 	err := mentor_registration.Validate()
 
 	if err != nil {
 		return err
 	}
 
-	_, err = GetMentorRegistration(id)
+	_, err = GetMentorRegistration(ctx, id)
 
 	if err != database.ErrNotFound {
 		if err != nil {
@@ -193,7 +248,7 @@ func CreateMentorRegistration(id string, mentor_registration models.MentorRegist
 		return errors.New("Registration already exists")
 	}
 
-	err = db.Insert("mentors", &mentor_registration)
+	err = db.WithContext(ctx).Insert("mentors", &mentor_registration)
 
 	return err
 }
@@ -201,7 +256,7 @@ func CreateMentorRegistration(id string, mentor_registration models.MentorRegist
 /*
 	Updates the registration associated with the given mentor id
 */
-func UpdateMentorRegistration(id string, mentor_registration models.MentorRegistration) error {
+func UpdateMentorRegistration(ctx context.Context, id string, mentor_registration models.MentorRegistration) error {
 	err := mentor_registration.Validate()
 
 	if err != nil {
@@ -210,40 +265,91 @@ func UpdateMentorRegistration(id string, mentor_registration models.MentorRegist
 
 	selector := database.QuerySelector{"id": id}
 
-	err = db.Update("mentors", selector, &mentor_registration)
+	err = db.WithContext(ctx).Update("mentors", selector, &mentor_registration)
 
 	return err
 }
 
 /*
-	Returns the mentor registrations associated with the given parameters
+	Updates only the given fields of the registration associated with the given mentor id
+	The registration is validated as a whole, so it should be the result of applying a patch to the stored registration
+	If the stored registration was written with an older version of the definition, the whole registration is saved instead
+	The stored registration must still be written with the given version of the definition, which it was read with,
+	and otherwise ErrStoredVersionChanged is returned so that a concurrent upgrade is not overwritten
+*/
+func PatchMentorRegistration(ctx context.Context, id string, mentor_registration models.MentorRegistration, stored_version int, changed_fields []string) error {
+	err := mentor_registration.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	selector := database.QuerySelector{"id": id}
+
+	matched, err := db.WithContext(ctx).UpdateIfMatches("mentors", selector, datastore.GetPatchCondition(stored_version), mentor_registration.GetPatchUpdate(stored_version, changed_fields))
+
+	if err != nil {
+		return err
+	}
+
+	if !matched {
+		return datastore.ErrStoredVersionChanged
+	}
+
+	return nil
+}
+
+/*
+	Returns the mentor registrations associated with the given parameters, upgraded to the current mentor registration definition
 */
-func GetFilteredMentorRegistrations(parameters map[string][]string) (*models.FilteredMentorRegistrations, error) {
-	query, err := getFilterQuery(parameters)
+func GetFilteredMentorRegistrations(ctx context.Context, parameters map[string][]string) (*models.FilteredMentorRegistrations, error) {
+	pagination, err := database.ParsePaginationParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	projection, err := database.ParseProjectionParameters(parameters, datastore.NewDataStore(config.MENTOR_REGISTRATION_DEFINITION))
+	if err != nil {
+		return nil, err
+	}
+
+	sort_fields, err := database.ParseSortParameters(parameters, datastore.NewDataStore(config.MENTOR_REGISTRATION_DEFINITION))
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := database.CreateFilterQuery(parameters, datastore.NewDataStore(config.MENTOR_REGISTRATION_DEFINITION))
 	if err != nil {
 		return nil, err
 	}
 
 	var filtered_registrations models.FilteredMentorRegistrations
-	err = db.FindAll("mentors", query, &filtered_registrations.Registrations)
+	pagination_results, err := db.WithContext(ctx).FindAllProjected("mentors", query, datastore.GetUpgradeProjection(config.MENTOR_REGISTRATION_DEFINITION, projection), sort_fields, *pagination, &filtered_registrations.Registrations)
 	if err != nil {
 		return nil, err
 	}
 
+	err = datastore.UpgradeAll(filtered_registrations.Registrations, config.MENTOR_REGISTRATION_DEFINITION)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered_registrations.Pagination = database.GetPageInfo(*pagination, pagination_results)
+
 	return &filtered_registrations, nil
 }
 
 /*
 	Returns all registration stats
 */
-func GetStats() (map[string]interface{}, error) {
-	attendee_stats, err := db.GetStats("attendees", config.REGISTRATION_STAT_FIELDS)
+func GetStats(ctx context.Context) (map[string]interface{}, error) {
+	attendee_stats, err := database.GetFieldStats(db.WithContext(ctx), "attendees", config.REGISTRATION_STAT_FIELDS)
 
 	if err != nil {
 		return nil, err
 	}
 
-	mentor_stats, err := db.GetStats("mentors", []string{})
+	mentor_stats, err := db.WithContext(ctx).GetStats("mentors", []string{})
 
 	if err != nil {
 		return nil, err
@@ -255,3 +361,10 @@ func GetStats() (map[string]interface{}, error) {
 
 	return stats, nil
 }
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/HackIllinois/api/common/database"
@@ -79,7 +80,7 @@ func CleanupTestDB(t *testing.T) {
 func TestGetUserRegistrationService(t *testing.T) {
 	SetupTestDB(t)
 
-	user_registration, err := service.GetUserRegistration("testid")
+	user_registration, err := service.GetUserRegistration(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -104,13 +105,13 @@ func TestCreateUserRegistrationService(t *testing.T) {
 	new_registration.Data["id"] = "testid2"
 	new_registration.Data["firstName"] = "first2"
 	new_registration.Data["lastName"] = "last2"
-	err := service.CreateUserRegistration("testid2", new_registration)
+	err := service.CreateUserRegistration(context.Background(), "testid2", new_registration)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	user_registration, err := service.GetUserRegistration("testid2")
+	user_registration, err := service.GetUserRegistration(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -138,13 +139,13 @@ func TestUpdateUserRegistrationService(t *testing.T) {
 	updated_registration.Data["id"] = "testid"
 	updated_registration.Data["firstName"] = "first2"
 	updated_registration.Data["lastName"] = "last2"
-	err := service.UpdateUserRegistration("testid", updated_registration)
+	err := service.UpdateUserRegistration(context.Background(), "testid", updated_registration)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	user_registration, err := service.GetUserRegistration("testid")
+	user_registration, err := service.GetUserRegistration(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -162,13 +163,274 @@ func TestUpdateUserRegistrationService(t *testing.T) {
 	CleanupTestDB(t)
 }
 
+/*
+	Service level test for patching user registration in the db
+	Fields which are not patched must not be overwritten, even if they were changed after the registration was read
+*/
+func TestPatchUserRegistrationService(t *testing.T) {
+	SetupTestDB(t)
+
+	original_registration, stored_version, err := service.GetUserRegistrationWithVersion(context.Background(), "testid")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patched_registration, changed_fields, err := original_registration.ApplyMergePatch([]byte(`{"firstName": "first2"}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(changed_fields, []string{"firstName"}) {
+		t.Errorf("Wrong changed fields.\nExpected %v\ngot %v\n", []string{"firstName"}, changed_fields)
+	}
+
+	err = db.Update("attendees", database.QuerySelector{"id": "testid"}, database.QuerySelector{"$set": database.QuerySelector{"lastName": "last2"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.PatchUserRegistration(context.Background(), "testid", patched_registration, stored_version, changed_fields)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user_registration, err := service.GetUserRegistration(context.Background(), "testid")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user_registration.Data["firstName"] != "first2" {
+		t.Errorf("Wrong first name.\nExpected %v\ngot %v\n", "first2", user_registration.Data["firstName"])
+	}
+
+	if user_registration.Data["lastName"] != "last2" {
+		t.Errorf("Wrong last name.\nExpected %v\ngot %v\n", "last2", user_registration.Data["lastName"])
+	}
+
+	CleanupTestDB(t)
+}
+
+/*
+	Service level test for patching a user registration which was written with an older version of the definition
+	The upgrade of the stored registration must be saved along with the patch
+*/
+func TestPatchOutdatedUserRegistrationService(t *testing.T) {
+	defer func(definition datastore.DataStoreDefinition) {
+		config.REGISTRATION_DEFINITION = definition
+	}(config.REGISTRATION_DEFINITION)
+
+	base_user_registration := getBaseUserRegistration()
+	stored_registration := make(map[string]interface{})
+
+	for key, value := range base_user_registration.Data {
+		stored_registration[key] = value
+	}
+
+	stored_registration["surname"] = stored_registration["lastName"]
+	delete(stored_registration, "lastName")
+
+	err := db.Insert("attendees", &stored_registration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.REGISTRATION_DEFINITION.Version = 1
+	config.REGISTRATION_DEFINITION.Migrations = []datastore.DataStoreMigration{
+		{Version: 1, Renames: map[string]string{"surname": "lastName"}},
+	}
+
+	original_registration, stored_version, err := service.GetUserRegistrationWithVersion(context.Background(), "testid")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patched_registration, changed_fields, err := original_registration.ApplyMergePatch([]byte(`{"firstName": "first2"}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.PatchUserRegistration(context.Background(), "testid", patched_registration, stored_version, changed_fields)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var user_registration map[string]interface{}
+	err = db.FindOne("attendees", database.QuerySelector{"id": "testid"}, &user_registration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user_registration["firstName"] != "first2" {
+		t.Errorf("Wrong first name.\nExpected %v\ngot %v\n", "first2", user_registration["firstName"])
+	}
+
+	if user_registration["lastName"] != base_user_registration.Data["lastName"] {
+		t.Errorf("Wrong last name.\nExpected %v\ngot %v\n", base_user_registration.Data["lastName"], user_registration["lastName"])
+	}
+
+	if _, has_surname := user_registration["surname"]; has_surname {
+		t.Errorf("Expected the renamed surname to be removed, got %v", user_registration["surname"])
+	}
+
+	if fmt.Sprint(user_registration[datastore.DefinitionVersionKey]) != "1" {
+		t.Errorf("Wrong definition version.\nExpected %v\ngot %v\n", 1, user_registration[datastore.DefinitionVersionKey])
+	}
+
+	count, err := datastore.UpgradeCollection(db, "attendees", config.REGISTRATION_DEFINITION, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("Expected no registrations to need an upgrade, got %v", count)
+	}
+
+	CleanupTestDB(t)
+}
+
+/*
+	Service level test for patching a user registration which was upgraded after it was read
+	The patch must not replace the upgraded registration with the one upgraded from the outdated read
+*/
+func TestPatchConcurrentlyUpgradedUserRegistrationService(t *testing.T) {
+	defer func(definition datastore.DataStoreDefinition) {
+		config.REGISTRATION_DEFINITION = definition
+	}(config.REGISTRATION_DEFINITION)
+
+	SetupTestDB(t)
+
+	config.REGISTRATION_DEFINITION.Version = 1
+
+	original_registration, stored_version, err := service.GetUserRegistrationWithVersion(context.Background(), "testid")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored_version != 0 {
+		t.Errorf("Wrong stored version.\nExpected %v\ngot %v\n", 0, stored_version)
+	}
+
+	patched_registration, changed_fields, err := original_registration.ApplyMergePatch([]byte(`{"firstName": "first2"}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update("attendees", database.QuerySelector{"id": "testid"}, database.QuerySelector{
+		"$set": database.QuerySelector{
+			"lastName":                     "last2",
+			datastore.DefinitionVersionKey: 1,
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.PatchUserRegistration(context.Background(), "testid", patched_registration, stored_version, changed_fields)
+
+	if err != datastore.ErrStoredVersionChanged {
+		t.Errorf("Expected ErrStoredVersionChanged, got %v", err)
+	}
+
+	user_registration, err := service.GetUserRegistration(context.Background(), "testid")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user_registration.Data["firstName"] != original_registration.Data["firstName"] {
+		t.Errorf("Wrong first name.\nExpected %v\ngot %v\n", original_registration.Data["firstName"], user_registration.Data["firstName"])
+	}
+
+	if user_registration.Data["lastName"] != "last2" {
+		t.Errorf("Wrong last name.\nExpected %v\ngot %v\n", "last2", user_registration.Data["lastName"])
+	}
+
+	CleanupTestDB(t)
+}
+
+/*
+	Service level test for filtering user registrations written with an older version of the registration definition
+*/
+func TestGetFilteredOutdatedUserRegistrationsService(t *testing.T) {
+	defer func(definition datastore.DataStoreDefinition) {
+		config.REGISTRATION_DEFINITION = definition
+	}(config.REGISTRATION_DEFINITION)
+
+	base_user_registration := getBaseUserRegistration()
+	stored_registration := make(map[string]interface{})
+
+	for key, value := range base_user_registration.Data {
+		stored_registration[key] = value
+	}
+
+	stored_registration["surname"] = stored_registration["lastName"]
+	delete(stored_registration, "lastName")
+
+	err := db.Insert("attendees", &stored_registration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.REGISTRATION_DEFINITION.Version = 1
+	config.REGISTRATION_DEFINITION.Migrations = []datastore.DataStoreMigration{
+		{Version: 1, Renames: map[string]string{"surname": "lastName"}},
+	}
+
+	for _, fields := range []string{"", "id,lastName"} {
+		parameters := map[string][]string{
+			"id": {"testid"},
+		}
+
+		if fields != "" {
+			parameters["fields"] = []string{fields}
+		}
+
+		user_registrations, err := service.GetFilteredUserRegistrations(context.Background(), parameters)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(user_registrations.Registrations) != 1 {
+			t.Fatalf("Wrong number of registrations.\nExpected %v\ngot %v\n", 1, len(user_registrations.Registrations))
+		}
+
+		user_registration := user_registrations.Registrations[0]
+
+		if user_registration.Data["lastName"] != base_user_registration.Data["lastName"] {
+			t.Errorf("Wrong last name with fields %q.\nExpected %v\ngot %v\n", fields, base_user_registration.Data["lastName"], user_registration.Data["lastName"])
+		}
+
+		if _, has_surname := user_registration.Data["surname"]; has_surname {
+			t.Errorf("Expected the renamed surname to be removed with fields %q, got %v", fields, user_registration.Data["surname"])
+		}
+	}
+
+	CleanupTestDB(t)
+}
+
 /*
 	Service level test for getting mentor registration from db
 */
 func TestGetMentorRegistrationService(t *testing.T) {
 	SetupTestDB(t)
 
-	mentor_registration, err := service.GetMentorRegistration("testid")
+	mentor_registration, err := service.GetMentorRegistration(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -193,13 +455,13 @@ func TestCreateMentorRegistrationService(t *testing.T) {
 	new_registration.Data["id"] = "testid2"
 	new_registration.Data["firstName"] = "first2"
 	new_registration.Data["lastName"] = "last2"
-	err := service.CreateMentorRegistration("testid2", new_registration)
+	err := service.CreateMentorRegistration(context.Background(), "testid2", new_registration)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	mentor_registration, err := service.GetMentorRegistration("testid2")
+	mentor_registration, err := service.GetMentorRegistration(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -227,13 +489,13 @@ func TestUpdateMentorRegistrationService(t *testing.T) {
 	updated_registration.Data["id"] = "testid"
 	updated_registration.Data["firstName"] = "first2"
 	updated_registration.Data["lastName"] = "last2"
-	err := service.UpdateMentorRegistration("testid", updated_registration)
+	err := service.UpdateMentorRegistration(context.Background(), "testid", updated_registration)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	mentor_registration, err := service.GetMentorRegistration("testid")
+	mentor_registration, err := service.GetMentorRegistration(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -262,7 +524,7 @@ func TestGetFilteredUserRegistrationsService(t *testing.T) {
 	registration_2 := getBaseUserRegistration()
 	registration_2.Data["id"] = "testid2"
 
-	err := service.CreateUserRegistration(registration_2.Data["id"].(string), registration_2)
+	err := service.CreateUserRegistration(context.Background(), registration_2.Data["id"].(string), registration_2)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -271,13 +533,13 @@ func TestGetFilteredUserRegistrationsService(t *testing.T) {
 	parameters := map[string][]string{
 		"id": {"testid"},
 	}
-	user_registrations, err := service.GetFilteredUserRegistrations(parameters)
+	user_registrations, err := service.GetFilteredUserRegistrations(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_registrations := models.FilteredUserRegistrations{
-		[]models.UserRegistration{
+		Registrations: []models.UserRegistration{
 			registration_1,
 		},
 	}
@@ -294,13 +556,13 @@ func TestGetFilteredUserRegistrationsService(t *testing.T) {
 	parameters = map[string][]string{
 		"id": {"testid,testid2"},
 	}
-	user_registrations, err = service.GetFilteredUserRegistrations(parameters)
+	user_registrations, err = service.GetFilteredUserRegistrations(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_registrations = models.FilteredUserRegistrations{
-		[]models.UserRegistration{
+		Registrations: []models.UserRegistration{
 			registration_1,
 			registration_2,
 		},
@@ -319,13 +581,13 @@ func TestGetFilteredUserRegistrationsService(t *testing.T) {
 		"firstName": {"first"},
 		"age":       {"20"},
 	}
-	user_registrations, err = service.GetFilteredUserRegistrations(parameters)
+	user_registrations, err = service.GetFilteredUserRegistrations(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_registrations = models.FilteredUserRegistrations{
-		[]models.UserRegistration{
+		Registrations: []models.UserRegistration{
 			registration_1,
 			registration_2,
 		},
@@ -353,7 +615,7 @@ func TestGetFilteredMentorRegistrationsService(t *testing.T) {
 	registration_2 := getBaseMentorRegistration()
 	registration_2.Data["id"] = "testid2"
 
-	err := service.CreateMentorRegistration(registration_2.Data["id"].(string), registration_2)
+	err := service.CreateMentorRegistration(context.Background(), registration_2.Data["id"].(string), registration_2)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -362,13 +624,13 @@ func TestGetFilteredMentorRegistrationsService(t *testing.T) {
 	parameters := map[string][]string{
 		"id": {"testid"},
 	}
-	mentor_registrations, err := service.GetFilteredMentorRegistrations(parameters)
+	mentor_registrations, err := service.GetFilteredMentorRegistrations(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_registrations := models.FilteredMentorRegistrations{
-		[]models.MentorRegistration{
+		Registrations: []models.MentorRegistration{
 			registration_1,
 		},
 	}
@@ -385,13 +647,13 @@ func TestGetFilteredMentorRegistrationsService(t *testing.T) {
 	parameters = map[string][]string{
 		"id": {"testid,testid2"},
 	}
-	mentor_registrations, err = service.GetFilteredMentorRegistrations(parameters)
+	mentor_registrations, err = service.GetFilteredMentorRegistrations(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_registrations = models.FilteredMentorRegistrations{
-		[]models.MentorRegistration{
+		Registrations: []models.MentorRegistration{
 			registration_1,
 			registration_2,
 		},
@@ -454,3 +716,35 @@ var mentor_registration_data string = `
 	"updatedAt": 15
 }
 `
+
+/*
+	Tests that the configured registration definitions can be converted to JSON Schema
+*/
+func TestRegistrationDefinitionSchemas(t *testing.T) {
+	definitions := []datastore.DataStoreDefinition{
+		config.REGISTRATION_DEFINITION,
+		config.MENTOR_REGISTRATION_DEFINITION,
+	}
+
+	for _, definition := range definitions {
+		schema, err := definition.WithoutFields("id").ToJSONSchema()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		properties, ok := schema["properties"].(map[string]interface{})
+
+		if !ok {
+			t.Fatalf("Schema of %s has no properties", definition.Name)
+		}
+
+		if _, has_id := properties["id"]; has_id {
+			t.Errorf("Schema of %s includes the excluded id field", definition.Name)
+		}
+
+		if len(properties) != len(definition.Fields)-1 {
+			t.Errorf("Wrong number of properties in schema of %s.\nExpected %v\ngot %v\n", definition.Name, len(definition.Fields)-1, len(properties))
+		}
+	}
+}
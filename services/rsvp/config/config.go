@@ -2,6 +2,7 @@ package config
 
 import (
 	"github.com/HackIllinois/api/common/configloader"
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/common/datastore"
 	"os"
 )
@@ -18,7 +19,7 @@ var MAIL_SERVICE string
 
 var RSVP_DEFINITION datastore.DataStoreDefinition
 
-var RSVP_STAT_FIELDS []string
+var RSVP_STAT_FIELDS []database.StatField
 
 func Initialize() error {
 	cfg_loader, err := configloader.Load(os.Getenv("HI_CONFIG"))
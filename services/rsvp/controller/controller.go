@@ -2,29 +2,41 @@ package controller
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/common/datastore"
 	"github.com/HackIllinois/api/common/errors"
 	"github.com/HackIllinois/api/common/metrics"
+	"github.com/HackIllinois/api/common/utils"
 	"github.com/HackIllinois/api/services/rsvp/config"
+	"github.com/HackIllinois/api/services/rsvp/models"
 	"github.com/HackIllinois/api/services/rsvp/service"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+/*
+	The rsvp fields which are set by the api, and so are not part of the request format
+*/
+var serverSetFields = []string{"id", "registrationData"}
+
 func SetupController(route *mux.Route) {
 	router := route.Subrouter()
 
 	router.Handle("/internal/metrics/", promhttp.Handler()).Methods("GET")
 
 	metrics.RegisterHandler("/filter/", GetFilteredRsvps, "GET", router)
+	metrics.RegisterHandler("/schema/", GetRsvpSchema, "GET", router)
 	metrics.RegisterHandler("/{id}/", GetUserRsvp, "GET", router)
 	metrics.RegisterHandler("/", GetCurrentUserRsvp, "GET", router)
 	metrics.RegisterHandler("/", CreateCurrentUserRsvp, "POST", router)
 	metrics.RegisterHandler("/", UpdateCurrentUserRsvp, "PUT", router)
+	metrics.RegisterHandler("/", PatchCurrentUserRsvp, "PATCH", router)
 
 	metrics.RegisterHandler("/internal/stats/", GetStats, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -33,7 +45,7 @@ func SetupController(route *mux.Route) {
 func GetUserRsvp(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	rsvp, err := service.GetUserRsvp(id)
+	rsvp, err := service.GetUserRsvp(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Cannot get user's RSVP status."))
@@ -49,7 +61,7 @@ func GetUserRsvp(w http.ResponseWriter, r *http.Request) {
 func GetCurrentUserRsvp(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	rsvp, err := service.GetUserRsvp(id)
+	rsvp, err := service.GetUserRsvp(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Cannot get user's RSVP status."))
@@ -107,7 +119,7 @@ func CreateCurrentUserRsvp(w http.ResponseWriter, r *http.Request) {
 
 	rsvp.Data["registrationData"] = registration_data
 
-	err = service.CreateUserRsvp(id, rsvp)
+	err = service.CreateUserRsvp(r.Context(), id, rsvp)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not create an RSVP for the user."))
@@ -130,7 +142,7 @@ func CreateCurrentUserRsvp(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	updated_rsvp, err := service.GetUserRsvp(id)
+	updated_rsvp, err := service.GetUserRsvp(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user's RSVP."))
@@ -160,38 +172,103 @@ func UpdateCurrentUserRsvp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	isAccepted, isActive, err := service.IsApplicantAcceptedAndActive(id)
+	if !canModifyRsvp(w, r, id) {
+		return
+	}
+
+	original_rsvp, stored_version, err := service.GetUserRsvpWithVersion(r.Context(), id)
 
 	if err != nil {
-		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not determine if applicant was accepted and/or decision expiration status."))
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user's RSVP status."))
 		return
 	}
 
-	if !isAccepted {
-		errors.WriteError(w, r, errors.AttributeMismatchError("Applicant must be accepted to modify RSVP.", "Applicant must be accepted to modify RSVP."))
+	rsvp := datastore.NewDataStore(config.RSVP_DEFINITION)
+	err = json.NewDecoder(r.Body).Decode(&rsvp)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not decode user rsvp information. Failure in JSON validation or incorrect rsvp definition."))
 		return
 	}
 
-	if !isActive {
-		errors.WriteError(w, r, errors.AttributeMismatchError("Cannot modify RSVP, applicant decision has expired.", "Cannot modify RSVP, applicant decision has expired."))
+	saveCurrentUserRsvp(w, r, id, original_rsvp, stored_version, rsvp, nil)
+}
+
+/*
+	Endpoint to partially update the rsvp for the current user.
+	The body is a JSON Merge Patch, which is applied to the stored rsvp, and only the patched fields are written.
+	On successful update, sends the user a confirmation mail.
+*/
+func PatchCurrentUserRsvp(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get("HackIllinois-Identity")
+
+	if id == "" {
+		errors.WriteError(w, r, errors.MalformedRequestError("Must provide id in request.", "Must provide id in the request."))
+		return
+	}
+
+	if !canModifyRsvp(w, r, id) {
+		return
+	}
+
+	patch, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not read user rsvp patch."))
 		return
 	}
 
-	original_rsvp, err := service.GetUserRsvp(id)
+	original_rsvp, stored_version, err := service.GetUserRsvpWithVersion(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get user's RSVP status."))
 		return
 	}
 
-	rsvp := datastore.NewDataStore(config.RSVP_DEFINITION)
-	err = json.NewDecoder(r.Body).Decode(&rsvp)
+	rsvp, changed_fields, err := original_rsvp.ApplyMergePatch(patch)
 
 	if err != nil {
-		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not decode user rsvp information. Failure in JSON validation or incorrect rsvp definition."))
+		errors.WriteError(w, r, errors.MalformedRequestError(err.Error(), "Could not apply user rsvp patch. Failure in JSON validation or incorrect rsvp definition."))
 		return
 	}
 
+	changed_fields = append(utils.ExcludeStrings(changed_fields, serverSetFields), "registrationData")
+
+	saveCurrentUserRsvp(w, r, id, original_rsvp, stored_version, rsvp, changed_fields)
+}
+
+/*
+	Returns whether the given applicant was accepted and their decision has not expired, so that they can modify their rsvp
+	If they cannot, the reason is written as an error response
+*/
+func canModifyRsvp(w http.ResponseWriter, r *http.Request, id string) bool {
+	isAccepted, isActive, err := service.IsApplicantAcceptedAndActive(id)
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not determine if applicant was accepted and/or decision expiration status."))
+		return false
+	}
+
+	if !isAccepted {
+		errors.WriteError(w, r, errors.AttributeMismatchError("Applicant must be accepted to modify RSVP.", "Applicant must be accepted to modify RSVP."))
+		return false
+	}
+
+	if !isActive {
+		errors.WriteError(w, r, errors.AttributeMismatchError("Cannot modify RSVP, applicant decision has expired.", "Cannot modify RSVP, applicant decision has expired."))
+		return false
+	}
+
+	return true
+}
+
+/*
+	Sets the fields of the rsvp which are set by the api, and saves it as the rsvp for the current user.
+	Only the changed fields are written if they are given, and otherwise the whole rsvp is written.
+	The changed fields are only written if the stored rsvp still has the version it was read with.
+	Then updates the user's Attendee role, sends the user a confirmation mail, and responds with the updated rsvp.
+*/
+func saveCurrentUserRsvp(w http.ResponseWriter, r *http.Request, id string, original_rsvp *models.UserRsvp, stored_version int, rsvp models.UserRsvp, changed_fields []string) {
 	rsvp.Data["id"] = id
 
 	registration_data, err := service.GetRegistrationData(id)
@@ -203,7 +280,16 @@ func UpdateCurrentUserRsvp(w http.ResponseWriter, r *http.Request) {
 
 	rsvp.Data["registrationData"] = registration_data
 
-	err = service.UpdateUserRsvp(id, rsvp)
+	if changed_fields == nil {
+		err = service.UpdateUserRsvp(r.Context(), id, rsvp)
+	} else {
+		err = service.PatchUserRsvp(r.Context(), id, rsvp, stored_version, changed_fields)
+	}
+
+	if err == datastore.ErrStoredVersionChanged {
+		errors.WriteError(w, r, errors.ConflictError(err.Error(), "The user's RSVP was changed while it was being patched."))
+		return
+	}
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not update user RSVP."))
@@ -213,14 +299,14 @@ func UpdateCurrentUserRsvp(w http.ResponseWriter, r *http.Request) {
 	wasAttending, ok := original_rsvp.Data["isAttending"].(bool)
 
 	if !ok {
-		errors.WriteError(w, r, errors.InternalError(err.Error(), "Failure in parsing user rsvp"))
+		errors.WriteError(w, r, errors.InternalError("Failure in parsing user rsvp", "Failure in parsing user rsvp"))
 		return
 	}
 
 	isAttending, ok := rsvp.Data["isAttending"].(bool)
 
 	if !ok {
-		errors.WriteError(w, r, errors.InternalError(err.Error(), "Failure in parsing user rsvp"))
+		errors.WriteError(w, r, errors.InternalError("Failure in parsing user rsvp", "Failure in parsing user rsvp"))
 		return
 	}
 
@@ -240,7 +326,7 @@ func UpdateCurrentUserRsvp(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	updated_rsvp, err := service.GetUserRsvp(id)
+	updated_rsvp, err := service.GetUserRsvp(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not get updated RSVP for user."))
@@ -263,21 +349,47 @@ func UpdateCurrentUserRsvp(w http.ResponseWriter, r *http.Request) {
 */
 func GetFilteredRsvps(w http.ResponseWriter, r *http.Request) {
 	parameters := r.URL.Query()
-	rsvps, err := service.GetFilteredRsvps(parameters)
+	rsvps, err := service.GetFilteredRsvps(r.Context(), parameters)
 
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Invalid filter or sort parameters."))
+			return
+		}
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch filtered list of rsvps."))
 		return
 	}
 
-	json.NewEncoder(w).Encode(rsvps)
+	response, err := database.ProjectResponse(rsvps, r.URL.Query())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not select the requested fields."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+/*
+	Endpoint to get the JSON Schema of the rsvp form
+	Fields which are set by the api rather than the client are omitted
+*/
+func GetRsvpSchema(w http.ResponseWriter, r *http.Request) {
+	schema, err := config.RSVP_DEFINITION.WithoutFields(serverSetFields...).ToJSONSchema()
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not generate RSVP schema."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(schema)
 }
 
 /*
 	Endpoint to get rsvp stats
 */
 func GetStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := service.GetStats()
+	stats, err := service.GetStats(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not get RSVP service statistics."))
@@ -286,3 +398,17 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(stats)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch rsvp service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
 type FilteredRsvps struct {
-	Rsvps []UserRsvp `json:"rsvps"`
+	Rsvps      []UserRsvp         `json:"rsvps"`
+	Pagination *database.PageInfo `json:"pagination,omitempty"`
 }
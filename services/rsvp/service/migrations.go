@@ -0,0 +1,56 @@
+package service
+
+import (
+	"log"
+
+	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/common/datastore"
+	"github.com/HackIllinois/api/services/rsvp/config"
+)
+
+/*
+	Migrations which are run on the service's database with the -migrate flag, in order of version
+*/
+var migrations = database.Migrations{}
+
+/*
+	Applies the service's pending migrations or rolls back its applied migrations as described by options
+	When applying migrations, stored rsvps written with older versions of their definitions are then upgraded
+*/
+func Migrate(options database.MigrationOptions) ([]database.Migration, error) {
+	run_migrations, err := database.Migrate(db, migrations, options)
+
+	if err != nil || options.Rollback {
+		return run_migrations, err
+	}
+
+	return run_migrations, upgradeDefinitions(options.DryRun)
+}
+
+/*
+	Upgrades the stored rsvps written with older versions of their definitions
+*/
+func upgradeDefinitions(dry_run bool) error {
+	definitions := map[string]datastore.DataStoreDefinition{
+		"rsvps": config.RSVP_DEFINITION,
+	}
+
+	action := "Upgraded"
+	if dry_run {
+		action = "Would have upgraded"
+	}
+
+	for collection_name, definition := range definitions {
+		upgraded_count, err := datastore.UpgradeCollection(db, collection_name, definition, dry_run)
+
+		if err != nil {
+			return err
+		}
+
+		if upgraded_count > 0 {
+			log.Printf("%s %d items in %s to definition version %d", action, upgraded_count, collection_name, definition.Version)
+		}
+	}
+
+	return nil
+}
@@ -1,15 +1,26 @@
 package service
 
 import (
+	"context"
 	"errors"
 
 	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/common/datastore"
 	"github.com/HackIllinois/api/services/rsvp/config"
 	"github.com/HackIllinois/api/services/rsvp/models"
 )
 
 var db database.Database
 
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"rsvps": {
+		{Key: []string{"id"}, Unique: true},
+	},
+}
+
 func Initialize() error {
 	if db != nil {
 		db.Close()
@@ -23,46 +34,62 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 /*
-	Returns the rsvp associated with the given user id
+	Returns the rsvp associated with the given user id, upgraded to the current rsvp definition
+*/
+func GetUserRsvp(ctx context.Context, id string) (*models.UserRsvp, error) {
+	rsvp, _, err := GetUserRsvpWithVersion(ctx, id)
+
+	return rsvp, err
+}
+
+/*
+	Returns the rsvp associated with the given user id, upgraded to the current rsvp definition
+	Also returns the version of the definition the stored rsvp was written with, which is needed to patch it
 */
-func GetUserRsvp(id string) (*models.UserRsvp, error) {
+func GetUserRsvpWithVersion(ctx context.Context, id string) (*models.UserRsvp, int, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var rsvp models.UserRsvp
-	err := db.FindOne("rsvps", query, &rsvp)
+	err := db.WithContext(ctx).FindOne("rsvps", query, &rsvp)
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	stored_version := rsvp.GetVersion()
+
+	err = rsvp.Upgrade(config.RSVP_DEFINITION)
+
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return &rsvp, nil
+	return &rsvp, stored_version, nil
 }
 
 /*
 	Creates the rsvp associated with the given user id
 */
-func CreateUserRsvp(id string, rsvp models.UserRsvp) error {
-	isAttending, ok := rsvp.Data["isAttending"].(bool)
+func CreateUserRsvp(ctx context.Context, id string, rsvp models.UserRsvp) error {
+	err := rsvp.Validate()
 
-	if !ok {
-		return errors.New("Type mismatch in rsvp data")
-	}
-
-	if isAttending {
-		err := rsvp.Validate()
-
-		if err != nil {
-			return err
-		}
+	if err != nil {
+		return err
 	}
 
-	_, err := GetUserRsvp(id)
+	_, err = GetUserRsvp(ctx, id)
 
 	if err != database.ErrNotFound {
 		if err != nil {
@@ -71,7 +98,7 @@ func CreateUserRsvp(id string, rsvp models.UserRsvp) error {
 		return errors.New("RSVP already exists.")
 	}
 
-	err = db.Insert("rsvps", &rsvp)
+	err = db.WithContext(ctx).Insert("rsvps", &rsvp)
 
 	return err
 }
@@ -79,53 +106,109 @@ func CreateUserRsvp(id string, rsvp models.UserRsvp) error {
 /*
 	Updates the rsvp associated with the given user id
 */
-func UpdateUserRsvp(id string, rsvp models.UserRsvp) error {
-	isAttending, ok := rsvp.Data["isAttending"].(bool)
+func UpdateUserRsvp(ctx context.Context, id string, rsvp models.UserRsvp) error {
+	err := rsvp.Validate()
 
-	if !ok {
-		return errors.New("Type mismatch in rsvp data")
+	if err != nil {
+		return err
 	}
 
-	if isAttending {
-		err := rsvp.Validate()
+	selector := database.QuerySelector{
+		"id": id,
+	}
 
-		if err != nil {
-			return err
-		}
+	err = db.WithContext(ctx).Update("rsvps", selector, &rsvp)
+
+	return err
+}
+
+/*
+	Updates only the given fields of the rsvp associated with the given user id
+	The rsvp is validated as a whole, so it should be the result of applying a patch to the stored rsvp
+	If the stored rsvp was written with an older version of the definition, the whole rsvp is saved instead
+	The stored rsvp must still be written with the given version of the definition, which it was read with,
+	and otherwise ErrStoredVersionChanged is returned so that a concurrent upgrade is not overwritten
+*/
+func PatchUserRsvp(ctx context.Context, id string, rsvp models.UserRsvp, stored_version int, changed_fields []string) error {
+	err := rsvp.Validate()
+
+	if err != nil {
+		return err
 	}
 
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	err := db.Update("rsvps", selector, &rsvp)
+	matched, err := db.WithContext(ctx).UpdateIfMatches("rsvps", selector, datastore.GetPatchCondition(stored_version), rsvp.GetPatchUpdate(stored_version, changed_fields))
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	if !matched {
+		return datastore.ErrStoredVersionChanged
+	}
+
+	return nil
 }
 
 /*
-	Returns the rsvps associated with the given parameters
+	Returns the rsvps associated with the given parameters, upgraded to the current rsvp definition
 */
-func GetFilteredRsvps(parameters map[string][]string) (*models.FilteredRsvps, error) {
-	query, err := database.CreateFilterQuery(parameters, models.UserRsvp{})
+func GetFilteredRsvps(ctx context.Context, parameters map[string][]string) (*models.FilteredRsvps, error) {
+	pagination, err := database.ParsePaginationParameters(parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	projection, err := database.ParseProjectionParameters(parameters, datastore.NewDataStore(config.RSVP_DEFINITION))
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort_fields, err := database.ParseSortParameters(parameters, datastore.NewDataStore(config.RSVP_DEFINITION))
+
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := database.CreateFilterQuery(parameters, datastore.NewDataStore(config.RSVP_DEFINITION))
 
 	if err != nil {
 		return nil, err
 	}
 
 	var filtered_rsvps models.FilteredRsvps
-	err = db.FindAll("rsvps", query, &filtered_rsvps.Rsvps)
+	pagination_results, err := db.WithContext(ctx).FindAllProjected("rsvps", query, datastore.GetUpgradeProjection(config.RSVP_DEFINITION, projection), sort_fields, *pagination, &filtered_rsvps.Rsvps)
 
 	if err != nil {
 		return nil, err
 	}
 
+	err = datastore.UpgradeAll(filtered_rsvps.Rsvps, config.RSVP_DEFINITION)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filtered_rsvps.Pagination = database.GetPageInfo(*pagination, pagination_results)
+
 	return &filtered_rsvps, nil
 }
 
 /*
 	Returns all rsvp stats
 */
-func GetStats() (map[string]interface{}, error) {
-	return db.GetStats("rsvps", config.RSVP_STAT_FIELDS)
+func GetStats(ctx context.Context) (map[string]interface{}, error) {
+	return database.GetFieldStats(db.WithContext(ctx), "rsvps", config.RSVP_STAT_FIELDS)
+}
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
 }
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/HackIllinois/api/common/database"
@@ -72,7 +73,7 @@ func CleanupTestDB(t *testing.T) {
 func TestGetUserRsvpService(t *testing.T) {
 	SetupTestDB(t)
 
-	rsvp, err := service.GetUserRsvp("testid")
+	rsvp, err := service.GetUserRsvp(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -97,13 +98,13 @@ func TestCreateUserRsvpService(t *testing.T) {
 	new_rsvp.Data["id"] = "testid2"
 	new_rsvp.Data["isAttending"] = false
 
-	err := service.CreateUserRsvp("testid2", new_rsvp)
+	err := service.CreateUserRsvp(context.Background(), "testid2", new_rsvp)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	rsvp, err := service.GetUserRsvp("testid2")
+	rsvp, err := service.GetUserRsvp(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -129,13 +130,13 @@ func TestUpdateUserRsvpService(t *testing.T) {
 	rsvp := getBaseUserRsvp()
 	rsvp.Data["isAttending"] = false
 
-	err := service.UpdateUserRsvp("testid", rsvp)
+	err := service.UpdateUserRsvp(context.Background(), "testid", rsvp)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	updated_rsvp, err := service.GetUserRsvp("testid")
+	updated_rsvp, err := service.GetUserRsvp(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -151,6 +152,68 @@ func TestUpdateUserRsvpService(t *testing.T) {
 	CleanupTestDB(t)
 }
 
+/*
+	Service level test for filtering user rsvps on datastore and nested registration fields
+*/
+func TestGetFilteredRsvpsService(t *testing.T) {
+	SetupTestDB(t)
+
+	new_rsvp := getBaseUserRsvp()
+	new_rsvp.Data["id"] = "testid2"
+	new_rsvp.Data["isAttending"] = false
+	new_rsvp.Data["registrationData"] = map[string]interface{}{
+		"attendee": map[string]interface{}{
+			"school": "UIUC",
+		},
+	}
+
+	err := db.Insert("rsvps", &new_rsvp)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parameters := map[string][]string{
+		"isAttending": {"false"},
+	}
+
+	filtered_rsvps, err := service.GetFilteredRsvps(context.Background(), parameters)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(filtered_rsvps.Rsvps) != 1 || filtered_rsvps.Rsvps[0].Data["id"] != "testid2" {
+		t.Errorf("Wrong rsvps. Expected only testid2, got %v", filtered_rsvps.Rsvps)
+	}
+
+	parameters = map[string][]string{
+		"registrationData.attendee.school": {"UIUC"},
+	}
+
+	filtered_rsvps, err = service.GetFilteredRsvps(context.Background(), parameters)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(filtered_rsvps.Rsvps) != 1 || filtered_rsvps.Rsvps[0].Data["id"] != "testid2" {
+		t.Errorf("Wrong rsvps. Expected only testid2, got %v", filtered_rsvps.Rsvps)
+	}
+
+	parameters = map[string][]string{
+		"unknownField": {"value"},
+	}
+
+	_, err = service.GetFilteredRsvps(context.Background(), parameters)
+
+	if err == nil {
+		t.Errorf("Expected filtering on an unknown field to fail")
+	}
+
+	CleanupTestDB(t)
+}
+
 /*
 	Returns a basic user registration
 */
@@ -166,3 +229,18 @@ var user_rsvp_data string = `
 	"isAttending": true
 }
 `
+
+/*
+	Tests that the configured rsvp definition can be converted to JSON Schema
+*/
+func TestRsvpDefinitionSchema(t *testing.T) {
+	schema, err := config.RSVP_DEFINITION.ToJSONSchema()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schema["title"] != config.RSVP_DEFINITION.Name {
+		t.Errorf("Wrong schema title.\nExpected %v\ngot %v\n", config.RSVP_DEFINITION.Name, schema["title"])
+	}
+}
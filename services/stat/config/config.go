@@ -3,6 +3,7 @@ package config
 import (
 	"github.com/HackIllinois/api/common/configloader"
 	"os"
+	"time"
 )
 
 var STAT_DB_HOST string
@@ -12,6 +13,36 @@ var STAT_PORT string
 
 var STAT_ENDPOINTS map[string]string
 
+/*
+	The dotted paths of the categorical stats of each service, such as "status" for decision, whose counts of
+	each value are exported as metrics
+	Only the counts and numeric summaries of other stats are exported, since their values are not bounded
+*/
+var STAT_METRIC_FIELDS map[string][]string
+
+/*
+	How often a snapshot of the stats of every service in STAT_ENDPOINTS is recorded, such as "15m"
+	Snapshots are not recorded if the interval is 0
+*/
+var STAT_SNAPSHOT_INTERVAL time.Duration
+
+const DEFAULT_STAT_SNAPSHOT_INTERVAL = 15 * time.Minute
+
+/*
+	How long the stats retrieved from a service are reused before they are retrieved again, such as "30s"
+	Stats are retrieved on every request if the ttl is 0
+*/
+var STAT_CACHE_TTL time.Duration
+
+const DEFAULT_STAT_CACHE_TTL = 30 * time.Second
+
+/*
+	The deadline for retrieving the stats of every service, such as "5s"
+*/
+var STAT_FETCH_TIMEOUT time.Duration
+
+const DEFAULT_STAT_FETCH_TIMEOUT = 5 * time.Second
+
 func Initialize() error {
 
 	cfg_loader, err := configloader.Load(os.Getenv("HI_CONFIG"))
@@ -44,5 +75,54 @@ func Initialize() error {
 		return err
 	}
 
+	STAT_METRIC_FIELDS = map[string][]string{}
+	err = cfg_loader.ParseInto("STAT_METRIC_FIELDS", &STAT_METRIC_FIELDS)
+
+	if err != nil && err != configloader.ErrNotSet {
+		return err
+	}
+
+	snapshot_interval, err := cfg_loader.Get("STAT_SNAPSHOT_INTERVAL")
+
+	if err == configloader.ErrNotSet {
+		STAT_SNAPSHOT_INTERVAL = DEFAULT_STAT_SNAPSHOT_INTERVAL
+	} else if err != nil {
+		return err
+	} else {
+		STAT_SNAPSHOT_INTERVAL, err = time.ParseDuration(snapshot_interval)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	cache_ttl, err := cfg_loader.Get("STAT_CACHE_TTL")
+
+	if err == configloader.ErrNotSet {
+		STAT_CACHE_TTL = DEFAULT_STAT_CACHE_TTL
+	} else if err != nil {
+		return err
+	} else {
+		STAT_CACHE_TTL, err = time.ParseDuration(cache_ttl)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	fetch_timeout, err := cfg_loader.Get("STAT_FETCH_TIMEOUT")
+
+	if err == configloader.ErrNotSet {
+		STAT_FETCH_TIMEOUT = DEFAULT_STAT_FETCH_TIMEOUT
+	} else if err != nil {
+		return err
+	} else {
+		STAT_FETCH_TIMEOUT, err = time.ParseDuration(fetch_timeout)
+
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
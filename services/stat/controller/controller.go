@@ -3,9 +3,12 @@ package controller
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/HackIllinois/api/common/errors"
 	"github.com/HackIllinois/api/common/metrics"
+	"github.com/HackIllinois/api/services/stat/models"
 	"github.com/HackIllinois/api/services/stat/service"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -16,6 +19,9 @@ func SetupController(route *mux.Route) {
 
 	router.Handle("/internal/metrics/", promhttp.Handler()).Methods("GET")
 
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
+
+	metrics.RegisterHandler("/history/{name}/", GetStatHistory, "GET", router)
 	metrics.RegisterHandler("/{name}/", GetStat, "GET", router)
 	metrics.RegisterHandler("/", GetAllStat, "GET", router)
 }
@@ -26,21 +32,26 @@ func SetupController(route *mux.Route) {
 func GetStat(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 
-	stat, err := service.GetAggregatedStats(name)
+	service_stat, err := service.GetAggregatedStats(r.Context(), name)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Failed to get statistics for service "+name+"."))
 		return
 	}
 
-	json.NewEncoder(w).Encode(stat)
+	if service_stat.Status == models.StatStatusError {
+		errors.WriteError(w, r, errors.InternalError(service_stat.Error, "Failed to get statistics for service "+name+"."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(service_stat)
 }
 
 /*
 	Endpoint to retrieve stats for all services
 */
 func GetAllStat(w http.ResponseWriter, r *http.Request) {
-	all_stat, err := service.GetAllAggregatedStats()
+	all_stat, err := service.GetAllAggregatedStats(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Failed to aggregate statistics."))
@@ -49,3 +60,77 @@ func GetAllStat(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(all_stat)
 }
+
+/*
+	Endpoint to retrieve the history of the stats for a specified service
+	from and to are unix timestamps in seconds, and default to the day before to, which defaults to now
+	interval is a duration such as "1h", and defaults to an hour
+*/
+func GetStatHistory(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	parameters := r.URL.Query()
+
+	var err error
+
+	to := time.Now().Unix()
+
+	if to_param := parameters.Get("to"); to_param != "" {
+		to, err = strconv.ParseInt(to_param, 10, 64)
+
+		if err != nil {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Could not convert 'to' to a unix timestamp."))
+			return
+		}
+	}
+
+	from := to - int64((24 * time.Hour).Seconds())
+
+	if from_param := parameters.Get("from"); from_param != "" {
+		from, err = strconv.ParseInt(from_param, 10, 64)
+
+		if err != nil {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Could not convert 'from' to a unix timestamp."))
+			return
+		}
+	}
+
+	interval := time.Hour
+
+	if interval_param := parameters.Get("interval"); interval_param != "" {
+		interval, err = time.ParseDuration(interval_param)
+
+		if err != nil {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Could not convert 'interval' to a duration."))
+			return
+		}
+	}
+
+	history, err := service.GetStatHistory(r.Context(), name, from, to, interval)
+
+	if err == service.ErrUnknownStatService {
+		errors.WriteError(w, r, errors.NotFoundError(err.Error(), "Could not find statistics for service "+name+"."))
+		return
+	} else if err == service.ErrInvalidHistoryRange || err == service.ErrTooManyHistoryPoints {
+		errors.WriteError(w, r, errors.BadRequestError(err.Error(), err.Error()))
+		return
+	} else if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Failed to get statistics history for service "+name+"."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(history)
+}
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch stat service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
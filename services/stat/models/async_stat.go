@@ -2,6 +2,5 @@ package models
 
 type AsyncStat struct {
 	Service string
-	Stat    *Stat
-	Error   error
+	Stat    ServiceStat
 }
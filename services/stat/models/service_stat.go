@@ -0,0 +1,15 @@
+package models
+
+const (
+	StatStatusOk    = "OK"
+	StatStatusStale = "STALE"
+	StatStatusError = "ERROR"
+)
+
+type ServiceStat struct {
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+	FetchedAt int64  `json:"fetchedAt"`
+	Cached    bool   `json:"cached"`
+	Stat      Stat   `json:"stat"`
+}
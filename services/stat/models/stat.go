@@ -2,4 +2,4 @@ package models
 
 type Stat map[string]interface{}
 
-type AggregatedStat map[string]Stat
+type AggregatedStat map[string]ServiceStat
@@ -0,0 +1,15 @@
+package models
+
+type StatHistory struct {
+	Service  string             `json:"service"`
+	From     int64              `json:"from"`
+	To       int64              `json:"to"`
+	Interval int64              `json:"interval"`
+	Points   []StatHistoryPoint `json:"points"`
+}
+
+type StatHistoryPoint struct {
+	Time         int64 `json:"time"`
+	SnapshotTime int64 `json:"snapshotTime"`
+	Stat         Stat  `json:"stat"`
+}
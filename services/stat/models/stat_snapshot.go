@@ -0,0 +1,7 @@
+package models
+
+type StatSnapshot struct {
+	Service string `json:"service"`
+	Time    int64  `json:"time"`
+	Stat    Stat   `json:"stat"`
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/services/stat/config"
+	"github.com/HackIllinois/api/services/stat/models"
+)
+
+/*
+	The largest number of points which can be returned in a stat history
+*/
+const MaxStatHistoryPoints = 1000
+
+var ErrInvalidHistoryRange = errors.New("The history must end after it starts, and have a positive interval.")
+var ErrTooManyHistoryPoints = errors.New("The history range contains too many intervals.")
+
+/*
+	Periodically records a snapshot of the stats of every service until it is stopped
+*/
+type SnapshotJob struct {
+	stop      chan struct{}
+	done      chan struct{}
+	stop_once sync.Once
+}
+
+/*
+	Starts a job recording the stats of every service, running once immediately and then after every interval
+	Each run is cancelled if it takes longer than the interval, or if the job is stopped
+*/
+func StartSnapshotJob(interval time.Duration) *SnapshotJob {
+	job := SnapshotJob{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	job_ctx, cancel_job := context.WithCancel(context.Background())
+
+	go func() {
+		select {
+		case <-job.stop:
+			cancel_job()
+		case <-job_ctx.Done():
+		}
+	}()
+
+	go func() {
+		defer close(job.done)
+		defer cancel_job()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			ctx, cancel := context.WithTimeout(job_ctx, interval)
+			err := RecordSnapshots(ctx)
+			cancel()
+
+			if err != nil {
+				log.Printf("Failed to record stat snapshots: %v", err)
+			}
+
+			select {
+			case <-job.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return &job
+}
+
+/*
+	Stops the job, cancelling any snapshot in progress and waiting for it to return
+	Stopping a nil job does nothing
+*/
+func (job *SnapshotJob) Stop() {
+	if job == nil {
+		return
+	}
+
+	job.stop_once.Do(func() {
+		close(job.stop)
+	})
+
+	<-job.done
+}
+
+/*
+	Records a snapshot of the current stats of every service, at the time each service's stats were retrieved
+	Services whose stats could not be retrieved are skipped, and snapshots which could not be saved are logged and skipped
+*/
+func RecordSnapshots(ctx context.Context) error {
+	all_stats, err := GetAllAggregatedStats(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for service, service_stat := range *all_stats {
+		if service_stat.Status != models.StatStatusOk {
+			continue
+		}
+
+		err = SaveSnapshot(ctx, models.StatSnapshot{
+			Service: service,
+			Time:    service_stat.FetchedAt,
+			Stat:    service_stat.Stat,
+		})
+
+		if err != nil {
+			log.Printf("Failed to save the stat snapshot of service %s: %v", service, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+	Saves the given snapshot of a service's stats
+*/
+func SaveSnapshot(ctx context.Context, snapshot models.StatSnapshot) error {
+	return db.WithContext(ctx).Insert("snapshots", &snapshot)
+}
+
+/*
+	Returns the history of the given service's stats between from and to, in unix seconds
+	The history has a point at the start of each interval, holding the most recent snapshot taken before the end
+	of the interval, or no stats if no snapshot was taken before then
+	Returns ErrUnknownStatService if the service does not have a stat endpoint
+*/
+func GetStatHistory(ctx context.Context, service string, from int64, to int64, interval time.Duration) (*models.StatHistory, error) {
+	if _, exists := config.STAT_ENDPOINTS[service]; !exists {
+		return nil, ErrUnknownStatService
+	}
+
+	interval_seconds := int64(interval / time.Second)
+
+	if to <= from || interval_seconds <= 0 {
+		return nil, ErrInvalidHistoryRange
+	}
+
+	if (to-from+interval_seconds-1)/interval_seconds > MaxStatHistoryPoints {
+		return nil, ErrTooManyHistoryPoints
+	}
+
+	time_sort := []database.SortField{{Name: "time"}}
+	reversed_time_sort := []database.SortField{{Name: "time", Reversed: true}}
+
+	// The most recent snapshot before the history starts is the value of each point until a later snapshot
+	query := database.QuerySelector{
+		"service": service,
+		"time": database.QuerySelector{
+			"$lt": from,
+		},
+	}
+
+	var previous_snapshots []models.StatSnapshot
+	_, err := db.WithContext(ctx).FindAllPaginated("snapshots", query, reversed_time_sort, database.PaginationOptions{Limit: 1}, &previous_snapshots)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query = database.QuerySelector{
+		"service": service,
+		"time": database.QuerySelector{
+			"$gte": from,
+			"$lt":  to,
+		},
+	}
+
+	var snapshots []models.StatSnapshot
+	err = db.WithContext(ctx).FindAllSorted("snapshots", query, time_sort, &snapshots)
+
+	if err != nil {
+		return nil, err
+	}
+
+	history := models.StatHistory{
+		Service:  service,
+		From:     from,
+		To:       to,
+		Interval: interval_seconds,
+		Points:   []models.StatHistoryPoint{},
+	}
+
+	var latest_snapshot *models.StatSnapshot
+
+	if len(previous_snapshots) > 0 {
+		latest_snapshot = &previous_snapshots[0]
+	}
+
+	next_snapshot := 0
+	for point_time := from; point_time < to; point_time += interval_seconds {
+		point_end := point_time + interval_seconds
+
+		for next_snapshot < len(snapshots) && snapshots[next_snapshot].Time < point_end {
+			latest_snapshot = &snapshots[next_snapshot]
+			next_snapshot += 1
+		}
+
+		point := models.StatHistoryPoint{
+			Time: point_time,
+		}
+
+		if latest_snapshot != nil {
+			point.SnapshotTime = latest_snapshot.Time
+			point.Stat = latest_snapshot.Stat
+		}
+
+		history.Points = append(history.Points, point)
+	}
+
+	return &history, nil
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/HackIllinois/api/common/utils"
+	"github.com/HackIllinois/api/services/stat/config"
+	"github.com/HackIllinois/api/services/stat/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/*
+	The keys of the counts and numeric summaries in a service's stats, which are always exported
+*/
+var exported_stat_keys = []string{"count", "min", "max", "mean", "median"}
+
+/*
+	Exports the stats of every service as prometheus gauges
+	Each count and numeric summary in a service's stats is a stat_value sample, labelled with the dotted path of the
+	object containing it as the stat, and its key within that object
+	The counts of each value of a categorical stat are only exported if the stat is in STAT_METRIC_FIELDS, since
+	every distinct value is a new series
+	Arrays, such as the histograms of numeric stats, and other non-numeric values are not exported
+	Whether each service's stats could be retrieved, and when they were last retrieved, are also exported, so that
+	stale or broken services can be told apart from services whose stats are not changing
+*/
+type StatCollector struct {
+	value_desc      *prometheus.Desc
+	up_desc         *prometheus.Desc
+	fetched_at_desc *prometheus.Desc
+}
+
+func NewStatCollector() *StatCollector {
+	return &StatCollector{
+		value_desc: prometheus.NewDesc(
+			"stat_value",
+			"The value of a statistic reported by a service.",
+			[]string{"service", "stat", "key"},
+			nil,
+		),
+		up_desc: prometheus.NewDesc(
+			"stat_up",
+			"Whether the statistics of a service were retrieved on the latest attempt.",
+			[]string{"service"},
+			nil,
+		),
+		fetched_at_desc: prometheus.NewDesc(
+			"stat_fetched_at_seconds",
+			"The unix timestamp at which the statistics of a service were last retrieved.",
+			[]string{"service"},
+			nil,
+		),
+	}
+}
+
+func (collector *StatCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.value_desc
+	ch <- collector.up_desc
+	ch <- collector.fetched_at_desc
+}
+
+/*
+	Retrieves the stats of every service, which are cached for STAT_CACHE_TTL, within STAT_FETCH_TIMEOUT and exports them
+*/
+func (collector *StatCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.STAT_FETCH_TIMEOUT)
+	defer cancel()
+
+	all_stats, err := GetAllAggregatedStats(ctx)
+
+	if err != nil {
+		return
+	}
+
+	for service, service_stat := range *all_stats {
+		is_up := 0.0
+
+		if service_stat.Status == models.StatStatusOk {
+			is_up = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(collector.up_desc, prometheus.GaugeValue, is_up, service)
+
+		if service_stat.FetchedAt == 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(collector.fetched_at_desc, prometheus.GaugeValue, float64(service_stat.FetchedAt), service)
+
+		// Keys containing '.' may flatten to the same labels as another path, and only the first is exported
+		// since prometheus rejects duplicate samples
+		seen_labels := make(map[[2]string]bool)
+		collector.collectStatValues(ch, service, []string{}, map[string]interface{}(service_stat.Stat), seen_labels)
+	}
+}
+
+/*
+	Exports every count, numeric summary and value count of the service's metric fields nested within the value at
+	the given path of a service's stats
+	Keys are visited in order, so that the samples exported for duplicate labels do not change between scrapes
+*/
+func (collector *StatCollector) collectStatValues(ch chan<- prometheus.Metric, service string, path []string, value interface{}, seen_labels map[[2]string]bool) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+
+		for key := range value {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			collector.collectStatValues(ch, service, append(path[:len(path):len(path)], key), value[key], seen_labels)
+		}
+	case float64, int, int64:
+		if len(path) == 0 {
+			return
+		}
+
+		labels := [2]string{strings.Join(path[:len(path)-1], "."), path[len(path)-1]}
+
+		if !utils.ContainsString(exported_stat_keys, labels[1]) && !utils.ContainsString(config.STAT_METRIC_FIELDS[service], labels[0]) {
+			return
+		}
+
+		if seen_labels[labels] {
+			return
+		}
+
+		seen_labels[labels] = true
+
+		ch <- prometheus.MustNewConstMetric(collector.value_desc, prometheus.GaugeValue, toStatNumber(value), service, labels[0], labels[1])
+	}
+}
+
+/*
+	Returns the given number of a service's stats as a float
+*/
+func toStatNumber(value interface{}) float64 {
+	switch number := value.(type) {
+	case int:
+		return float64(number)
+	case int64:
+		return float64(number)
+	case float64:
+		return number
+	default:
+		return 0
+	}
+}
@@ -1,80 +1,211 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/HackIllinois/api/common/apirequest"
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/services/stat/config"
 	"github.com/HackIllinois/api/services/stat/models"
+	"github.com/prometheus/client_golang/prometheus"
 	"net/http"
+	"sync"
+	"time"
 )
 
+var db database.Database
+
+var snapshot_job *SnapshotJob
+
+var stat_collector = NewStatCollector()
+
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"snapshots": {
+		{Key: []string{"service", "time"}},
+	},
+}
+
 func Initialize() error {
+	snapshot_job.Stop()
+	snapshot_job = nil
+
+	stat_cache_mutex.Lock()
+	stat_cache = make(map[string]statCacheEntry)
+	stat_cache_mutex.Unlock()
+
+	if db != nil {
+		db.Close()
+		db = nil
+	}
+
+	var err error
+	db, err = database.InitDatabase(config.STAT_DB_HOST, config.STAT_DB_NAME)
+
+	if err != nil {
+		return err
+	}
+
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
+	// The collector is already registered when the service is reloaded
+	err = prometheus.Register(stat_collector)
+
+	if _, is_registered := err.(prometheus.AlreadyRegisteredError); err != nil && !is_registered {
+		return err
+	}
+
+	if config.STAT_SNAPSHOT_INTERVAL > 0 {
+		snapshot_job = StartSnapshotJob(config.STAT_SNAPSHOT_INTERVAL)
+	}
+
 	return nil
 }
 
+var ErrUnknownStatService = errors.New("Could not find endpoint for requested statistics.")
+
+/*
+	The most recently retrieved stats of a service
+*/
+type statCacheEntry struct {
+	stat       models.Stat
+	fetched_at time.Time
+}
+
+var stat_cache = make(map[string]statCacheEntry)
+var stat_cache_mutex sync.Mutex
+
 /*
-	Retrieve stats from the specified service
+	Retrieve stats from the specified service, reusing stats which were retrieved within STAT_CACHE_TTL
+	The stats must be retrieved within STAT_FETCH_TIMEOUT, and if they cannot be retrieved,
+	the most recently retrieved stats are returned as stale along with the error
 */
-func GetAggregatedStats(service string) (*models.Stat, error) {
+func GetAggregatedStats(ctx context.Context, service string) (*models.ServiceStat, error) {
 	endpoint, exists := config.STAT_ENDPOINTS[service]
 
 	if !exists {
-		return nil, errors.New("Could not find endpoint for requested statistics.")
+		return nil, ErrUnknownStatService
 	}
 
+	stat_cache_mutex.Lock()
+	cached, is_cached := stat_cache[service]
+	stat_cache_mutex.Unlock()
+
+	if is_cached && time.Since(cached.fetched_at) < config.STAT_CACHE_TTL {
+		return &models.ServiceStat{
+			Status:    models.StatStatusOk,
+			FetchedAt: cached.fetched_at.Unix(),
+			Cached:    true,
+			Stat:      cached.stat,
+		}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.STAT_FETCH_TIMEOUT)
+	defer cancel()
+
+	stat, err := fetchStats(ctx, endpoint)
+
+	if err != nil {
+		service_stat := models.ServiceStat{
+			Status: models.StatStatusError,
+			Error:  err.Error(),
+		}
+
+		if is_cached {
+			service_stat.Status = models.StatStatusStale
+			service_stat.FetchedAt = cached.fetched_at.Unix()
+			service_stat.Cached = true
+			service_stat.Stat = cached.stat
+		}
+
+		return &service_stat, nil
+	}
+
+	fetched_at := time.Now()
+
+	stat_cache_mutex.Lock()
+	stat_cache[service] = statCacheEntry{
+		stat:       stat,
+		fetched_at: fetched_at,
+	}
+	stat_cache_mutex.Unlock()
+
+	return &models.ServiceStat{
+		Status:    models.StatStatusOk,
+		FetchedAt: fetched_at.Unix(),
+		Stat:      stat,
+	}, nil
+}
+
+/*
+	Retrieve stats from the given endpoint within the context
+*/
+func fetchStats(ctx context.Context, endpoint string) (models.Stat, error) {
 	var stat models.Stat
-	status, err := apirequest.Get(endpoint, &stat)
+	status, err := apirequest.GetWithContext(ctx, endpoint, &stat)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if status != http.StatusOK {
-		return nil, errors.New("Could not retrieve stats from service.")
+		return nil, fmt.Errorf("Could not retrieve stats from service, received status %d.", status)
 	}
 
-	return &stat, nil
+	return stat, nil
 }
 
 /*
 	Attempts to retrieve stats from the specified service and outputs this
 	information to the given channel
 */
-func GetAggregatedStatsAsync(service string, stat_chan chan models.AsyncStat) {
-	stat, err := GetAggregatedStats(service)
+func GetAggregatedStatsAsync(ctx context.Context, service string, stat_chan chan models.AsyncStat) {
+	service_stat, err := GetAggregatedStats(ctx, service)
+
+	if err != nil {
+		service_stat = &models.ServiceStat{
+			Status: models.StatStatusError,
+			Error:  err.Error(),
+		}
+	}
+
 	stat_chan <- models.AsyncStat{
 		Service: service,
-		Stat:    stat,
-		Error:   err,
+		Stat:    *service_stat,
 	}
 }
 
 /*
-	Retreives stats from all services
-	Returns a map of service name to stats
+	Retreives stats from all services concurrently, within STAT_FETCH_TIMEOUT
+	Returns a map of service name to stats, along with the status of each service's stats
 */
-func GetAllAggregatedStats() (*models.AggregatedStat, error) {
+func GetAllAggregatedStats(ctx context.Context) (*models.AggregatedStat, error) {
 	stats := models.AggregatedStat{}
 
 	stat_chan := make(chan models.AsyncStat)
 
 	for service := range config.STAT_ENDPOINTS {
-		go GetAggregatedStatsAsync(service, stat_chan)
+		go GetAggregatedStatsAsync(ctx, service, stat_chan)
 	}
 
 	for i := 0; i < len(config.STAT_ENDPOINTS); i++ {
 		async_stat := <-stat_chan
-
-		service := async_stat.Service
-		stat := async_stat.Stat
-		err := async_stat.Error
-
-		if err == nil {
-			stats[service] = *stat
-		} else {
-			stats[service] = nil
-		}
+		stats[async_stat.Service] = async_stat.Stat
 	}
 
 	return &stats, nil
 }
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
+}
@@ -1,11 +1,374 @@
 package tests
 
 import (
+	"context"
+	"fmt"
+	"github.com/HackIllinois/api/common/database"
+	"github.com/HackIllinois/api/services/stat/config"
+	"github.com/HackIllinois/api/services/stat/models"
+	"github.com/HackIllinois/api/services/stat/service"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
+var db database.Database
+
+func TestMain(m *testing.M) {
+	err := config.Initialize()
+
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = service.Initialize()
+
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	db, err = database.InitDatabase(config.STAT_DB_HOST, config.STAT_DB_NAME)
+
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	return_code := m.Run()
+
+	os.Exit(return_code)
+}
+
 /*
 	Placeholder test for CI build
 */
 func TestPlaceholder(t *testing.T) {
 }
+
+/*
+	Initialize db with snapshots of the registration stats
+*/
+func SetupTestDB(t *testing.T) {
+	snapshots := []models.StatSnapshot{
+		{Service: "registration", Time: 900, Stat: models.Stat{"count": 1}},
+		{Service: "registration", Time: 1100, Stat: models.Stat{"count": 2}},
+		{Service: "registration", Time: 1150, Stat: models.Stat{"count": 3}},
+		{Service: "registration", Time: 1400, Stat: models.Stat{"count": 5}},
+		{Service: "checkin", Time: 1100, Stat: models.Stat{"count": 10}},
+	}
+
+	for _, snapshot := range snapshots {
+		err := service.SaveSnapshot(context.Background(), snapshot)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+/*
+	Drop test db
+*/
+func CleanupTestDB(t *testing.T) {
+	err := db.DropDatabase()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+	Service level test for getting the history of a service's stats
+	Each point holds the latest snapshot taken before the end of its interval
+*/
+func TestGetStatHistoryService(t *testing.T) {
+	SetupTestDB(t)
+
+	history, err := service.GetStatHistory(context.Background(), "registration", 1000, 1400, 100*time.Second)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_history := models.StatHistory{
+		Service:  "registration",
+		From:     1000,
+		To:       1400,
+		Interval: 100,
+		Points: []models.StatHistoryPoint{
+			{Time: 1000, SnapshotTime: 900, Stat: models.Stat{"count": 1}},
+			{Time: 1100, SnapshotTime: 1150, Stat: models.Stat{"count": 3}},
+			{Time: 1200, SnapshotTime: 1150, Stat: models.Stat{"count": 3}},
+			{Time: 1300, SnapshotTime: 1150, Stat: models.Stat{"count": 3}},
+		},
+	}
+
+	if !reflect.DeepEqual(history, &expected_history) {
+		t.Errorf("Wrong history.\nExpected %v\ngot %v\n", &expected_history, history)
+	}
+
+	history, err = service.GetStatHistory(context.Background(), "registration", 0, 1000, 500*time.Second)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected_history = models.StatHistory{
+		Service:  "registration",
+		From:     0,
+		To:       1000,
+		Interval: 500,
+		Points: []models.StatHistoryPoint{
+			{Time: 0},
+			{Time: 500, SnapshotTime: 900, Stat: models.Stat{"count": 1}},
+		},
+	}
+
+	if !reflect.DeepEqual(history, &expected_history) {
+		t.Errorf("Wrong history.\nExpected %v\ngot %v\n", &expected_history, history)
+	}
+
+	CleanupTestDB(t)
+}
+
+/*
+	Service level test for rejecting invalid history ranges
+*/
+func TestGetStatHistoryInvalidRangeService(t *testing.T) {
+	_, err := service.GetStatHistory(context.Background(), "registration", 1000, 1000, time.Hour)
+
+	if err != service.ErrInvalidHistoryRange {
+		t.Errorf("Expected ErrInvalidHistoryRange, got %v", err)
+	}
+
+	_, err = service.GetStatHistory(context.Background(), "registration", 0, 1000, 0)
+
+	if err != service.ErrInvalidHistoryRange {
+		t.Errorf("Expected ErrInvalidHistoryRange, got %v", err)
+	}
+
+	_, err = service.GetStatHistory(context.Background(), "registration", 0, 1000000, time.Second)
+
+	if err != service.ErrTooManyHistoryPoints {
+		t.Errorf("Expected ErrTooManyHistoryPoints, got %v", err)
+	}
+}
+
+/*
+	Service level test for rejecting the history of a service without a stat endpoint
+*/
+func TestGetStatHistoryUnknownService(t *testing.T) {
+	_, err := service.GetStatHistory(context.Background(), "unknown", 1000, 1400, 100*time.Second)
+
+	if err != service.ErrUnknownStatService {
+		t.Errorf("Expected ErrUnknownStatService, got %v", err)
+	}
+}
+
+/*
+	Service level test for retrieving stats from a service, reusing cached stats within the ttl, and returning
+	the cached stats as stale when they cannot be retrieved
+*/
+func TestGetAggregatedStatsCacheService(t *testing.T) {
+	request_count := 0
+	is_failing := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request_count += 1
+
+		if is_failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte(`{"count": 4}`))
+	}))
+	defer server.Close()
+
+	defer resetStatConfig(config.STAT_ENDPOINTS, config.STAT_CACHE_TTL, config.STAT_FETCH_TIMEOUT)
+	config.STAT_ENDPOINTS = map[string]string{"cached": server.URL}
+	config.STAT_CACHE_TTL = time.Hour
+
+	service_stat, err := service.GetAggregatedStats(context.Background(), "cached")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service_stat.Status != models.StatStatusOk || service_stat.Cached || !reflect.DeepEqual(service_stat.Stat, models.Stat{"count": float64(4)}) {
+		t.Errorf("Wrong stats.\nExpected fresh stats with count 4\ngot %v\n", service_stat)
+	}
+
+	service_stat, err = service.GetAggregatedStats(context.Background(), "cached")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !service_stat.Cached || request_count != 1 {
+		t.Errorf("Expected the stats to be cached, got %v after %v requests", service_stat, request_count)
+	}
+
+	config.STAT_CACHE_TTL = 0
+	is_failing = true
+
+	service_stat, err = service.GetAggregatedStats(context.Background(), "cached")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service_stat.Status != models.StatStatusStale || service_stat.Error == "" || !reflect.DeepEqual(service_stat.Stat, models.Stat{"count": float64(4)}) {
+		t.Errorf("Wrong stats.\nExpected stale stats with count 4 and an error\ngot %v\n", service_stat)
+	}
+
+	_, err = service.GetAggregatedStats(context.Background(), "unknown")
+
+	if err != service.ErrUnknownStatService {
+		t.Errorf("Expected ErrUnknownStatService, got %v", err)
+	}
+}
+
+/*
+	Service level test for retrieving the stats of every service within the fetch timeout
+	Services which do not respond in time are reported with an error
+*/
+func TestGetAllAggregatedStatsTimeoutService(t *testing.T) {
+	slow_server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow_server.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"count": 2}`))
+	}))
+	defer server.Close()
+
+	defer resetStatConfig(config.STAT_ENDPOINTS, config.STAT_CACHE_TTL, config.STAT_FETCH_TIMEOUT)
+	config.STAT_ENDPOINTS = map[string]string{"slow": slow_server.URL, "fast": server.URL}
+	config.STAT_CACHE_TTL = 0
+	config.STAT_FETCH_TIMEOUT = 100 * time.Millisecond
+
+	start := time.Now()
+	all_stats, err := service.GetAllAggregatedStats(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Expected the stats to be retrieved within the fetch timeout, took %v", time.Since(start))
+	}
+
+	slow_stat := (*all_stats)["slow"]
+
+	if slow_stat.Status != models.StatStatusError || slow_stat.Error == "" || slow_stat.Stat != nil {
+		t.Errorf("Wrong stats for slow service.\nExpected an error\ngot %v\n", slow_stat)
+	}
+
+	fast_stat := (*all_stats)["fast"]
+
+	if fast_stat.Status != models.StatStatusOk || fast_stat.FetchedAt == 0 || !reflect.DeepEqual(fast_stat.Stat, models.Stat{"count": float64(2)}) {
+		t.Errorf("Wrong stats for fast service.\nExpected fresh stats with count 2\ngot %v\n", fast_stat)
+	}
+}
+
+/*
+	Tests that stopping the snapshot job cancels a snapshot which is waiting on a slow service
+*/
+func TestStopSnapshotJobService(t *testing.T) {
+	slow_server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow_server.Close()
+
+	defer resetStatConfig(config.STAT_ENDPOINTS, config.STAT_CACHE_TTL, config.STAT_FETCH_TIMEOUT)
+	config.STAT_ENDPOINTS = map[string]string{"slow": slow_server.URL}
+	config.STAT_CACHE_TTL = 0
+	config.STAT_FETCH_TIMEOUT = 10 * time.Second
+
+	job := service.StartSnapshotJob(time.Hour)
+
+	// Give the job time to start waiting on the slow service
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	job.Stop()
+
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Expected stopping the job to cancel the snapshot in progress, took %v", time.Since(start))
+	}
+}
+
+/*
+	Tests that the counts and numeric summaries in the stats of each service, and the value counts of its metric
+	fields, are exported as labelled gauges, along with whether the stats of each service could be retrieved
+*/
+func TestStatCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"count": 3,
+			"isAttending": {"true": 2, "false": 1},
+			"school": {"UIUC": 2, "MIT": 1, "Purdue": 0},
+			"age": {"count": 3, "mean": 20.5, "histogram": [{"min": 15, "max": 20, "count": 1}]},
+			"hasName": true
+		}`))
+	}))
+	defer server.Close()
+
+	failing_server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing_server.Close()
+
+	defer resetStatConfig(config.STAT_ENDPOINTS, config.STAT_CACHE_TTL, config.STAT_FETCH_TIMEOUT)
+	config.STAT_ENDPOINTS = map[string]string{"rsvp": server.URL, "checkin": failing_server.URL}
+	config.STAT_CACHE_TTL = 0
+
+	defer func(metric_fields map[string][]string) {
+		config.STAT_METRIC_FIELDS = metric_fields
+	}(config.STAT_METRIC_FIELDS)
+	config.STAT_METRIC_FIELDS = map[string][]string{"rsvp": {"isAttending"}}
+
+	expected_metrics := `
+		# HELP stat_up Whether the statistics of a service were retrieved on the latest attempt.
+		# TYPE stat_up gauge
+		stat_up{service="checkin"} 0
+		stat_up{service="rsvp"} 1
+		# HELP stat_value The value of a statistic reported by a service.
+		# TYPE stat_value gauge
+		stat_value{key="count",service="rsvp",stat=""} 3
+		stat_value{key="count",service="rsvp",stat="age"} 3
+		stat_value{key="false",service="rsvp",stat="isAttending"} 1
+		stat_value{key="mean",service="rsvp",stat="age"} 20.5
+		stat_value{key="true",service="rsvp",stat="isAttending"} 2
+	`
+
+	err := testutil.CollectAndCompare(service.NewStatCollector(), strings.NewReader(expected_metrics), "stat_up", "stat_value")
+
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+/*
+	Restores the stat endpoints and fetch configuration changed by a test
+*/
+func resetStatConfig(endpoints map[string]string, cache_ttl time.Duration, fetch_timeout time.Duration) {
+	config.STAT_ENDPOINTS = endpoints
+	config.STAT_CACHE_TTL = cache_ttl
+	config.STAT_FETCH_TIMEOUT = fetch_timeout
+}
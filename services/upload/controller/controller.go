@@ -29,6 +29,7 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/blobstore/", UpdateBlob, "PUT", router)
 	metrics.RegisterHandler("/blobstore/{id}/", GetBlob, "GET", router)
 	metrics.RegisterHandler("/blobstore/{id}/", DeleteBlob, "DELETE", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -133,7 +134,7 @@ func GetUpdateUserPhoto(w http.ResponseWriter, r *http.Request) {
 func GetBlob(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	blob, err := service.GetBlob(id)
+	blob, err := service.GetBlob(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Unable to retrieve blob."))
@@ -155,14 +156,14 @@ func CreateBlob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := service.CreateBlob(blob)
+	err := service.CreateBlob(r.Context(), blob)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Unable to create blob."))
 		return
 	}
 
-	stored_blob, err := service.GetBlob(blob.ID)
+	stored_blob, err := service.GetBlob(r.Context(), blob.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Unable to retrieve blob."))
@@ -184,14 +185,14 @@ func UpdateBlob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := service.UpdateBlob(blob)
+	err := service.UpdateBlob(r.Context(), blob)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Unable to update blob."))
 		return
 	}
 
-	stored_blob, err := service.GetBlob(blob.ID)
+	stored_blob, err := service.GetBlob(r.Context(), blob.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Unable to retrieve blob."))
@@ -207,7 +208,7 @@ func UpdateBlob(w http.ResponseWriter, r *http.Request) {
 func DeleteBlob(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	blob, err := service.DeleteBlob(id)
+	blob, err := service.DeleteBlob(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Unable to delete blob."))
@@ -216,3 +217,17 @@ func DeleteBlob(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(blob)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch upload service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/services/upload/config"
@@ -13,6 +14,15 @@ import (
 
 var db database.Database
 
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"blobstore": {
+		{Key: []string{"id"}, Unique: true},
+	},
+}
+
 var sess *session.Session
 var client *s3.S3
 
@@ -34,6 +44,12 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -160,13 +176,13 @@ func GetUpdateUserPhotoLink(id string) (*models.UserPhoto, error) {
 /*
 	Returns the blob with the given id
 */
-func GetBlob(id string) (*models.Blob, error) {
+func GetBlob(ctx context.Context, id string) (*models.Blob, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var blob models.Blob
-	err := db.FindOne("blobstore", query, &blob)
+	err := db.WithContext(ctx).FindOne("blobstore", query, &blob)
 
 	if err != nil {
 		return nil, err
@@ -178,8 +194,8 @@ func GetBlob(id string) (*models.Blob, error) {
 /*
 	Creates and stores a blob
 */
-func CreateBlob(blob models.Blob) error {
-	_, err := GetBlob(blob.ID)
+func CreateBlob(ctx context.Context, blob models.Blob) error {
+	_, err := GetBlob(ctx, blob.ID)
 
 	if err != database.ErrNotFound {
 		if err != nil {
@@ -188,7 +204,7 @@ func CreateBlob(blob models.Blob) error {
 		return errors.New("Blob already exists.")
 	}
 
-	err = db.Insert("blobstore", &blob)
+	err = db.WithContext(ctx).Insert("blobstore", &blob)
 
 	return err
 }
@@ -196,12 +212,12 @@ func CreateBlob(blob models.Blob) error {
 /*
 	Updates the blob with the given id
 */
-func UpdateBlob(blob models.Blob) error {
+func UpdateBlob(ctx context.Context, blob models.Blob) error {
 	selector := database.QuerySelector{
 		"id": blob.ID,
 	}
 
-	err := db.Update("blobstore", selector, &blob)
+	err := db.WithContext(ctx).Update("blobstore", selector, &blob)
 
 	return err
 }
@@ -210,8 +226,8 @@ func UpdateBlob(blob models.Blob) error {
 Deletes the blob with the given id
 Returns the blob that was deleted
 */
-func DeleteBlob(id string) (*models.Blob, error) {
-	blob, err := GetBlob(id)
+func DeleteBlob(ctx context.Context, id string) (*models.Blob, error) {
+	blob, err := GetBlob(ctx, id)
 
 	if err != nil {
 		return nil, err
@@ -221,7 +237,14 @@ func DeleteBlob(id string) (*models.Blob, error) {
 		"id": id,
 	}
 
-	err = db.RemoveOne("blobstore", selector)
+	err = db.WithContext(ctx).RemoveOne("blobstore", selector)
 
 	return blob, err
 }
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
+}
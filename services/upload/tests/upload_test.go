@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/services/upload/config"
@@ -72,7 +73,7 @@ func CleanupTestDB(t *testing.T) {
 func TestGetBlobService(t *testing.T) {
 	SetupTestDB(t)
 
-	blob, err := service.GetBlob("testid")
+	blob, err := service.GetBlob(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -101,13 +102,13 @@ func TestCreateBlobService(t *testing.T) {
 		Data: "testdata2",
 	}
 
-	err := service.CreateBlob(new_blob)
+	err := service.CreateBlob(context.Background(), new_blob)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	retrieved_blob, err := service.GetBlob("testid2")
+	retrieved_blob, err := service.GetBlob(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -136,13 +137,13 @@ func TestUpdateBlobService(t *testing.T) {
 		Data: "testdata2",
 	}
 
-	err := service.UpdateBlob(updated_blob)
+	err := service.UpdateBlob(context.Background(), updated_blob)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	retrieved_blob, err := service.GetBlob("testid")
+	retrieved_blob, err := service.GetBlob(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -168,7 +169,7 @@ func TestDeleteBlobService(t *testing.T) {
 		Data: "testdata2",
 	}
 
-	err := service.CreateBlob(new_blob)
+	err := service.CreateBlob(context.Background(), new_blob)
 
 	if err != nil {
 		t.Fatal(err)
@@ -179,7 +180,7 @@ func TestDeleteBlobService(t *testing.T) {
 		Data: "testdata2",
 	}
 
-	returned_blob, err := service.DeleteBlob("testid2")
+	returned_blob, err := service.DeleteBlob(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -189,7 +190,7 @@ func TestDeleteBlobService(t *testing.T) {
 		t.Errorf("Wrong blob. Expected %v, got %v", expected_blob, returned_blob)
 	}
 
-	retrieved_blob, err := service.GetBlob("testid2")
+	retrieved_blob, err := service.GetBlob(context.Background(), "testid2")
 
 	if retrieved_blob != nil || err.Error() != "Error: NOT_FOUND" {
 		t.Errorf("Blob deletion failed")
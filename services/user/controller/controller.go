@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/HackIllinois/api/common/database"
 	"github.com/HackIllinois/api/common/errors"
 	"github.com/HackIllinois/api/common/metrics"
 	"github.com/HackIllinois/api/services/user/models"
@@ -27,6 +28,7 @@ func SetupController(route *mux.Route) {
 	metrics.RegisterHandler("/{id}/", GetUserInfo, "GET", router)
 
 	metrics.RegisterHandler("/internal/stats/", GetStats, "GET", router)
+	metrics.RegisterHandler("/internal/indexes/", GetIndexStatus, "GET", router)
 }
 
 /*
@@ -35,7 +37,7 @@ func SetupController(route *mux.Route) {
 func GetCurrentUserInfo(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	user_info, err := service.GetUserInfo(id)
+	user_info, err := service.GetUserInfo(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch user info by ID."))
@@ -57,14 +59,14 @@ func SetUserInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := service.SetUserInfo(user_info.ID, user_info)
+	err := service.SetUserInfo(r.Context(), user_info.ID, user_info)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not upsert user info."))
 		return
 	}
 
-	updated_info, err := service.GetUserInfo(user_info.ID)
+	updated_info, err := service.GetUserInfo(r.Context(), user_info.ID)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch user info by ID."))
@@ -79,14 +81,25 @@ func SetUserInfo(w http.ResponseWriter, r *http.Request) {
 */
 func GetFilteredUserInfo(w http.ResponseWriter, r *http.Request) {
 	parameters := r.URL.Query()
-	user_info, err := service.GetFilteredUserInfo(parameters)
+	user_info, err := service.GetFilteredUserInfo(r.Context(), parameters)
 
 	if err != nil {
+		if _, ok := err.(database.FilterError); ok {
+			errors.WriteError(w, r, errors.BadRequestError(err.Error(), "Invalid filter or sort parameters."))
+			return
+		}
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch filtered list of users."))
 		return
 	}
 
-	json.NewEncoder(w).Encode(user_info)
+	response, err := database.ProjectResponse(user_info, r.URL.Query())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not select the requested fields."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 /*
@@ -95,7 +108,7 @@ func GetFilteredUserInfo(w http.ResponseWriter, r *http.Request) {
 func GetUserInfo(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	user_info, err := service.GetUserInfo(id)
+	user_info, err := service.GetUserInfo(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch user information by user id."))
@@ -111,7 +124,7 @@ func GetUserInfo(w http.ResponseWriter, r *http.Request) {
 func GetCurrentQrCodeInfo(w http.ResponseWriter, r *http.Request) {
 	id := r.Header.Get("HackIllinois-Identity")
 
-	uri, err := service.GetQrInfo(id)
+	uri, err := service.GetQrInfo(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not parse QR code URI."))
@@ -132,7 +145,7 @@ func GetCurrentQrCodeInfo(w http.ResponseWriter, r *http.Request) {
 func GetQrCodeInfo(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	uri, err := service.GetQrInfo(id)
+	uri, err := service.GetQrInfo(r.Context(), id)
 
 	if err != nil {
 		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not parse QR code URI."))
@@ -151,7 +164,7 @@ func GetQrCodeInfo(w http.ResponseWriter, r *http.Request) {
 	Endpoint to get user stats
 */
 func GetStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := service.GetStats()
+	stats, err := service.GetStats(r.Context())
 
 	if err != nil {
 		errors.WriteError(w, r, errors.InternalError(err.Error(), "Could not retrieve user service statistics."))
@@ -160,3 +173,17 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(stats)
 }
+
+/*
+	Endpoint to get the status of the indexes on the service's collections
+*/
+func GetIndexStatus(w http.ResponseWriter, r *http.Request) {
+	index_status, err := service.GetIndexStatus(r.Context())
+
+	if err != nil {
+		errors.WriteError(w, r, errors.DatabaseError(err.Error(), "Could not fetch user service index status."))
+		return
+	}
+
+	json.NewEncoder(w).Encode(index_status)
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"github.com/HackIllinois/api/common/database"
+)
+
 type FilteredUsers struct {
-	Users []UserInfo `json:"users"`
+	Users      []UserInfo         `json:"users"`
+	Pagination *database.PageInfo `json:"pagination,omitempty"`
 }
@@ -1,19 +1,26 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"net/url"
-	"strconv"
-	"strings"
 
 	"github.com/HackIllinois/api/common/database"
-	"github.com/HackIllinois/api/common/utils"
 	"github.com/HackIllinois/api/services/user/config"
 	"github.com/HackIllinois/api/services/user/models"
 )
 
 var db database.Database
 
+/*
+	Indexes which are created on the service's collections when the service is initialized
+*/
+var indexes = database.IndexDeclarations{
+	"info": {
+		{Key: []string{"id"}, Unique: true},
+	},
+}
+
 func Initialize() error {
 	if db != nil {
 		db.Close()
@@ -27,19 +34,25 @@ func Initialize() error {
 		return err
 	}
 
+	err = database.EnsureIndexes(db, indexes)
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 /*
 	Returns the info associated with the given user id
 */
-func GetUserInfo(id string) (*models.UserInfo, error) {
+func GetUserInfo(ctx context.Context, id string) (*models.UserInfo, error) {
 	query := database.QuerySelector{
 		"id": id,
 	}
 
 	var user_info models.UserInfo
-	err := db.FindOne("info", query, &user_info)
+	err := db.WithContext(ctx).FindOne("info", query, &user_info)
 
 	if err != nil {
 		return nil, err
@@ -52,15 +65,15 @@ func GetUserInfo(id string) (*models.UserInfo, error) {
 	Set the info associated with the given user id
 	The record will be created if it does not already exist
 */
-func SetUserInfo(id string, user_info models.UserInfo) error {
+func SetUserInfo(ctx context.Context, id string, user_info models.UserInfo) error {
 	selector := database.QuerySelector{
 		"id": id,
 	}
 
-	err := db.Update("info", selector, &user_info)
+	err := db.WithContext(ctx).Update("info", selector, &user_info)
 
 	if err == database.ErrNotFound {
-		err = db.Insert("info", &user_info)
+		err = db.WithContext(ctx).Insert("info", &user_info)
 	}
 
 	return err
@@ -69,69 +82,61 @@ func SetUserInfo(id string, user_info models.UserInfo) error {
 /*
 	Returns the users associated with the given parameters
 */
-func GetFilteredUserInfo(parameters map[string][]string) (*models.FilteredUsers, error) {
-	// Grab pagination and sorting parameters and delete to prevent the CreateFilterQuery from using them
-	page := parameters["p"]
-	page_limit := parameters["limit"]
-	sort_parameters := parameters["sortby"]
-	delete(parameters, "p")
-	delete(parameters, "limit")
-	delete(parameters, "sortby")
+func GetFilteredUserInfo(ctx context.Context, parameters map[string][]string) (*models.FilteredUsers, error) {
+	// "p" is accepted as an alias of "page" for older clients
+	if page, ok := parameters["p"]; ok {
+		if _, has_page := parameters["page"]; has_page {
+			return nil, errors.New("Cannot provide both 'page' and 'p'.")
+		}
 
-	query, err := database.CreateFilterQuery(parameters, models.UserInfo{})
+		parameters["page"] = page
+		delete(parameters, "p")
+	}
+
+	// Grab pagination and sorting parameters and delete to prevent the CreateFilterQuery from using them
+	pagination, err := database.ParsePaginationParameters(parameters)
 
 	if err != nil {
 		return nil, err
 	}
 
-	var filtered_users models.FilteredUsers
-
-	if len(sort_parameters) == 1 {
-		// Split by comma to get a slice of sorting parameters
-		// i.e FirstName, LastName --> ["FirstName", "LastName"]
-		sort_parameters = strings.Split(sort_parameters[0], ",")
-
-		var sort_fields []database.SortField
+	projection, err := database.ParseProjectionParameters(parameters, models.UserInfo{})
 
-		for _, field := range sort_parameters {
-			// Push to lowercase because MongoDB columns are all lowercase
-			field = strings.ToLower(field)
-			field = strings.TrimSpace(field)
+	if err != nil {
+		return nil, err
+	}
 
-			sort_fields = append(sort_fields,
-				database.SortField{
-					Name:     field,
-					Reversed: false,
-				})
-		}
+	sort_fields, err := database.ParseSortParameters(parameters, models.UserInfo{})
 
-		// Fetch and Sort
-		err = db.FindAllSorted("info", query, sort_fields, &filtered_users.Users)
-	} else {
-		err = db.FindAll("info", query, &filtered_users.Users)
+	if err != nil {
+		return nil, err
 	}
 
+	query, err := database.CreateFilterQuery(parameters, models.UserInfo{})
+
 	if err != nil {
 		return nil, err
 	}
 
-	if len(page) == 1 && len(page_limit) == 1 {
-		page, _ := strconv.Atoi(page[0])
-		page_limit, _ := strconv.Atoi(page_limit[0])
+	var filtered_users models.FilteredUsers
 
-		// Subtract one because page numbers will be 1-indexed
-		// The max() function will ensure we don't paginate past the length of the Users list
-		filtered_users.Users = filtered_users.Users[(page-1)*page_limit : utils.Min(page*page_limit, len(filtered_users.Users))]
+	// Fetch, sort, and paginate
+	pagination_results, err := db.WithContext(ctx).FindAllProjected("info", query, projection, sort_fields, *pagination, &filtered_users.Users)
+
+	if err != nil {
+		return nil, err
 	}
 
+	filtered_users.Pagination = database.GetPageInfo(*pagination, pagination_results)
+
 	return &filtered_users, nil
 }
 
 /*
 	Generates a QR string for a user with the provided ID, as a URI
 */
-func GetQrInfo(id string) (string, error) {
-	_, err := GetUserInfo(id)
+func GetQrInfo(ctx context.Context, id string) (string, error) {
+	_, err := GetUserInfo(ctx, id)
 
 	if err != nil {
 		return "", errors.New("User does not exist.")
@@ -158,6 +163,13 @@ func GetQrInfo(id string) (string, error) {
 /*
 	Returns all user stats
 */
-func GetStats() (map[string]interface{}, error) {
-	return db.GetStats("info", []string{})
+func GetStats(ctx context.Context) (map[string]interface{}, error) {
+	return db.WithContext(ctx).GetStats("info", []string{})
+}
+
+/*
+	Returns the status of the indexes on the service's collections
+*/
+func GetIndexStatus(ctx context.Context) (map[string][]database.IndexStatus, error) {
+	return database.GetIndexStatus(db.WithContext(ctx), indexes)
 }
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"os"
@@ -168,7 +169,7 @@ func CleanupTestDB(t *testing.T) {
 func TestGetUserInfoService(t *testing.T) {
 	SetupTestDB(t)
 
-	user_info, err := service.GetUserInfo("testid")
+	user_info, err := service.GetUserInfo(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)
@@ -193,7 +194,7 @@ func TestGetUserInfoService(t *testing.T) {
 func TestSetUserInfoService(t *testing.T) {
 	SetupTestDB(t)
 
-	err := service.SetUserInfo("testid2", models.UserInfo{
+	err := service.SetUserInfo(context.Background(), "testid2", models.UserInfo{
 		ID:       "testid2",
 		Username: "testusername2",
 		Email:    "testemail2@domain.com",
@@ -203,7 +204,7 @@ func TestSetUserInfoService(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	user_info, err := service.GetUserInfo("testid2")
+	user_info, err := service.GetUserInfo(context.Background(), "testid2")
 
 	if err != nil {
 		t.Fatal(err)
@@ -228,12 +229,12 @@ func TestSetUserInfoService(t *testing.T) {
 func TestGetFilteredUserInfoService(t *testing.T) {
 	SetupTestDB(t)
 
-	user_info_1, err := service.GetUserInfo("testid")
+	user_info_1, err := service.GetUserInfo(context.Background(), "testid")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	user_info_2, err := service.GetUserInfo("testid2")
+	user_info_2, err := service.GetUserInfo(context.Background(), "testid2")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -241,13 +242,13 @@ func TestGetFilteredUserInfoService(t *testing.T) {
 	parameters := map[string][]string{
 		"username": {"testusername"},
 	}
-	filtered_info, err := service.GetFilteredUserInfo(parameters)
+	filtered_info, err := service.GetFilteredUserInfo(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_info := &models.FilteredUsers{
-		[]models.UserInfo{
+		Users: []models.UserInfo{
 			*user_info_1,
 			*user_info_2,
 		},
@@ -266,12 +267,12 @@ func TestGetFilteredUserInfoService(t *testing.T) {
 func TestGetFilteredUserInfoWithSortingService(t *testing.T) {
 	SetupFilterTestDB(t)
 
-	user_info_1, err := service.GetUserInfo("testid1")
+	user_info_1, err := service.GetUserInfo(context.Background(), "testid1")
 	if err != nil {
 		t.Fatal(err)
 	}
-	user_info_2, err := service.GetUserInfo("testid2")
-	user_info_3, err := service.GetUserInfo("testid3")
+	user_info_2, err := service.GetUserInfo(context.Background(), "testid2")
+	user_info_3, err := service.GetUserInfo(context.Background(), "testid3")
 
 	// Sort by first name and expect: Alex, Bobby, Charlie
 
@@ -280,13 +281,13 @@ func TestGetFilteredUserInfoWithSortingService(t *testing.T) {
 		"sortby":   {"FiRsTNAmE"},
 	}
 
-	filtered_info, err := service.GetFilteredUserInfo(parameters)
+	filtered_info, err := service.GetFilteredUserInfo(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_info := &models.FilteredUsers{
-		[]models.UserInfo{
+		Users: []models.UserInfo{
 			*user_info_1, // Alex
 			*user_info_3, // Bobby
 			*user_info_2, // Charlie
@@ -304,13 +305,13 @@ func TestGetFilteredUserInfoWithSortingService(t *testing.T) {
 		"sortby":   {"-FiRsTNAmE"},
 	}
 
-	filtered_info, err = service.GetFilteredUserInfo(parameters)
+	filtered_info, err = service.GetFilteredUserInfo(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_info = &models.FilteredUsers{
-		[]models.UserInfo{
+		Users: []models.UserInfo{
 			*user_info_2, // Charlie
 			*user_info_3, // Bobby
 			*user_info_1, // Alex
@@ -323,20 +324,20 @@ func TestGetFilteredUserInfoWithSortingService(t *testing.T) {
 
 	// Sort by two parameters and expect: Bobby Adamson, Bobby Zulu
 
-	user_info_1, err = service.GetUserInfo("testid5")
-	user_info_2, err = service.GetUserInfo("testid4")
+	user_info_1, err = service.GetUserInfo(context.Background(), "testid5")
+	user_info_2, err = service.GetUserInfo(context.Background(), "testid4")
 	parameters = map[string][]string{
 		"username": {"test-two-parameter-filter"},
 		"sortby":   {"firstName,lastName"},
 	}
 
-	filtered_info, err = service.GetFilteredUserInfo(parameters)
+	filtered_info, err = service.GetFilteredUserInfo(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_info = &models.FilteredUsers{
-		[]models.UserInfo{
+		Users: []models.UserInfo{
 			*user_info_2, // Bobby Adamson
 			*user_info_1, // Bobby Zulu
 		},
@@ -355,27 +356,27 @@ func TestGetFilteredUserInfoWithSortingService(t *testing.T) {
 func TestGetFilteredUserInfoServicePagination(t *testing.T) {
 	SetupPaginationDB(t)
 
-	user_info_1, err := service.GetUserInfo("testid")
+	user_info_1, err := service.GetUserInfo(context.Background(), "testid")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	user_info_2, err := service.GetUserInfo("testid2")
+	user_info_2, err := service.GetUserInfo(context.Background(), "testid2")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	user_info_3, err := service.GetUserInfo("testid3")
+	user_info_3, err := service.GetUserInfo(context.Background(), "testid3")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	user_info_4, err := service.GetUserInfo("testid4")
+	user_info_4, err := service.GetUserInfo(context.Background(), "testid4")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	user_info_5, err := service.GetUserInfo("testid5")
+	user_info_5, err := service.GetUserInfo(context.Background(), "testid5")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -386,15 +387,21 @@ func TestGetFilteredUserInfoServicePagination(t *testing.T) {
 		"p":        {"2"},
 		"limit":    {"1"},
 	}
-	filtered_info, err := service.GetFilteredUserInfo(parameters)
+	filtered_info, err := service.GetFilteredUserInfo(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_info := &models.FilteredUsers{
-		[]models.UserInfo{
+		Users: []models.UserInfo{
 			*user_info_2,
 		},
+		Pagination: &database.PageInfo{
+			Total:      5,
+			Limit:      1,
+			Page:       2,
+			NextCursor: database.EncodeCursor(database.PaginationOptions{Limit: 1, Skip: 2}),
+		},
 	}
 
 	if !reflect.DeepEqual(filtered_info, expected_info) {
@@ -409,16 +416,22 @@ func TestGetFilteredUserInfoServicePagination(t *testing.T) {
 		"p":        {"1"},
 		"limit":    {"2"},
 	}
-	filtered_info, err = service.GetFilteredUserInfo(parameters)
+	filtered_info, err = service.GetFilteredUserInfo(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_info = &models.FilteredUsers{
-		[]models.UserInfo{
+		Users: []models.UserInfo{
 			*user_info_1,
 			*user_info_2,
 		},
+		Pagination: &database.PageInfo{
+			Total:      5,
+			Limit:      2,
+			Page:       1,
+			NextCursor: database.EncodeCursor(database.PaginationOptions{Limit: 2, Skip: 2}),
+		},
 	}
 
 	if !reflect.DeepEqual(filtered_info, expected_info) {
@@ -431,16 +444,22 @@ func TestGetFilteredUserInfoServicePagination(t *testing.T) {
 		"p":        {"2"},
 		"limit":    {"2"},
 	}
-	filtered_info, err = service.GetFilteredUserInfo(parameters)
+	filtered_info, err = service.GetFilteredUserInfo(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_info = &models.FilteredUsers{
-		[]models.UserInfo{
+		Users: []models.UserInfo{
 			*user_info_3,
 			*user_info_4,
 		},
+		Pagination: &database.PageInfo{
+			Total:      5,
+			Limit:      2,
+			Page:       2,
+			NextCursor: database.EncodeCursor(database.PaginationOptions{Limit: 2, Skip: 4}),
+		},
 	}
 
 	if !reflect.DeepEqual(filtered_info, expected_info) {
@@ -453,21 +472,37 @@ func TestGetFilteredUserInfoServicePagination(t *testing.T) {
 		"p":        {"3"},
 		"limit":    {"2"},
 	}
-	filtered_info, err = service.GetFilteredUserInfo(parameters)
+	filtered_info, err = service.GetFilteredUserInfo(context.Background(), parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected_info = &models.FilteredUsers{
-		[]models.UserInfo{
+		Users: []models.UserInfo{
 			*user_info_5,
 		},
+		Pagination: &database.PageInfo{
+			Total:      5,
+			Limit:      2,
+			Page:       3,
+			NextCursor: "",
+		},
 	}
 
 	if !reflect.DeepEqual(filtered_info, expected_info) {
 		t.Errorf("Wrong user info. Expected %v, got %v", expected_info, filtered_info)
 	}
 
+	// "p" is an alias of "page", so it also requires "limit"
+	parameters = map[string][]string{
+		"username": {"testusername"},
+		"p":        {"2"},
+	}
+	_, err = service.GetFilteredUserInfo(context.Background(), parameters)
+	if err == nil {
+		t.Errorf("Expected an error when providing p without limit")
+	}
+
 	CleanupTestDB(t)
 }
 
@@ -477,7 +512,7 @@ func TestGetFilteredUserInfoServicePagination(t *testing.T) {
 func TestGetQrInfo(t *testing.T) {
 	SetupTestDB(t)
 
-	actual_uri, err := service.GetQrInfo("testid")
+	actual_uri, err := service.GetQrInfo(context.Background(), "testid")
 
 	if err != nil {
 		t.Fatal(err)